@@ -0,0 +1,291 @@
+// Package accessibility checks books against the parts of WCAG that can be
+// checked without a person, and describes them with the schema.org
+// accessibility properties retailers and libraries read from EPUBs.
+package accessibility
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The rules a book is checked against
+const (
+	RuleImageAlt      = "image-alt"
+	RuleHeadingOrder  = "heading-order"
+	RuleLanguage      = "document-language"
+	RuleTableHeaders  = "table-headers"
+	RuleColorContrast = "color-contrast"
+)
+
+// minContrast is the WCAG AA contrast ratio for body text
+const minContrast = 4.5
+
+// Book is everything a check looks at
+type Book struct {
+	Book     models.Book
+	Chapters []models.Chapter
+	Images   []models.ChapterImages
+	Pages    []models.Page
+}
+
+// Issue is a problem found in a book. Chapter, Page and Image locate it
+// when it has a place.
+type Issue struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Chapter int    `json:"chapter,omitempty"`
+	Page    int    `json:"page,omitempty"`
+	Image   string `json:"image,omitempty"`
+}
+
+// Report is the result of checking a book, with the metadata its exports
+// are described by
+type Report struct {
+	Passed   bool     `json:"passed"`
+	Issues   []Issue  `json:"issues"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Metadata holds the schema.org accessibility properties of a publication
+type Metadata struct {
+	AccessModes []string `json:"accessMode"`
+	// AccessModeSufficient lists sets of access modes, each joined by
+	// commas, that are enough to read the whole book
+	AccessModeSufficient []string `json:"accessModeSufficient"`
+	Features             []string `json:"accessibilityFeature"`
+	Hazards              []string `json:"accessibilityHazard"`
+	Summary              string   `json:"accessibilitySummary"`
+}
+
+// Check reports the accessibility problems of a book. Only images the
+// book shows are checked: chapter headers, images in chapter text and
+// page images.
+func Check(b Book) Report {
+	c := checker{book: b, images: map[string]models.ChapterImages{}}
+	for _, image := range b.Images {
+		c.images[image.ID.Hex()] = image
+	}
+
+	if strings.TrimSpace(b.Book.Language) == "" {
+		c.add(Issue{Rule: RuleLanguage, Message: "the book has no language, so screen readers can't pick a voice"})
+	}
+	c.theme()
+	if b.Book.Layout == models.LayoutFixed {
+		c.pages()
+	} else {
+		for _, chapter := range b.Chapters {
+			c.chapter(chapter)
+		}
+	}
+
+	report := Report{Passed: len(c.issues) == 0, Issues: c.issues, Metadata: c.metadata()}
+	if report.Issues == nil {
+		report.Issues = []Issue{}
+	}
+	return report
+}
+
+type checker struct {
+	book   Book
+	images map[string]models.ChapterImages
+	issues []Issue
+
+	// what the metadata is made from
+	text, visual, longDescriptions bool
+	undescribed                    int
+}
+
+func (c *checker) add(issue Issue) {
+	c.issues = append(c.issues, issue)
+}
+
+// image checks an image the book shows, alt being the alt text given
+// where it's shown
+func (c *checker) image(image models.ChapterImages, alt string, issue Issue) {
+	c.visual = true
+	if image.LongDescription != "" {
+		c.longDescriptions = true
+	}
+	if strings.TrimSpace(alt) != "" || image.Described() {
+		return
+	}
+	c.undescribed++
+	issue.Rule = RuleImageAlt
+	if !image.ID.IsZero() {
+		issue.Image = image.ID.Hex()
+	}
+	c.add(issue)
+}
+
+// theme checks the contrast of the book's colors
+func (c *checker) theme() {
+	theme := c.book.Book.Theme
+	if theme == nil {
+		return
+	}
+	if ratio, ok := contrast(theme.Text, theme.Background); ok && ratio < minContrast {
+		c.add(Issue{Rule: RuleColorContrast, Message: fmt.Sprintf("text %s on background %s has a contrast of %.2f:1, below %.1f:1", theme.Text, theme.Background, ratio, minContrast)})
+	}
+	if theme.Link == "" {
+		return
+	}
+	if ratio, ok := contrast(theme.Link, theme.Background); ok && ratio < minContrast {
+		c.add(Issue{Rule: RuleColorContrast, Message: fmt.Sprintf("links %s on background %s have a contrast of %.2f:1, below %.1f:1", theme.Link, theme.Background, ratio, minContrast)})
+	}
+}
+
+// pages checks the images of a fixed-layout book's pages
+func (c *checker) pages() {
+	for _, page := range c.book.Pages {
+		if len(page.Regions) > 0 {
+			c.text = true
+		}
+		image, ok := c.images[page.ImageID.Hex()]
+		if !ok {
+			continue
+		}
+		c.image(image, "", Issue{Page: page.PageNum, Message: fmt.Sprintf("the image of page %d has no alt text and isn't marked decorative", page.PageNum)})
+	}
+}
+
+// chapter checks a chapter's header image and text
+func (c *checker) chapter(chapter models.Chapter) {
+	c.text = true
+	for _, image := range c.book.Images {
+		if image.ChapterNum == chapter.ChapterNum && image.Type != models.ImageTypeInline && image.Type != models.ImageTypePage {
+			c.image(image, "", Issue{Chapter: chapter.ChapterNum, Message: fmt.Sprintf("the header image of chapter %d has no alt text and isn't marked decorative", chapter.ChapterNum)})
+			break
+		}
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(chapter.Text), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return
+	}
+	// the chapter title is the h1 above the text
+	level := 1
+	for _, n := range nodes {
+		c.walk(chapter, n, &level)
+	}
+}
+
+func (c *checker) walk(chapter models.Chapter, n *html.Node, level *int) {
+	if n.Type == html.ElementNode {
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			next := int(n.Data[1] - '0')
+			if next > *level+1 {
+				c.add(Issue{Rule: RuleHeadingOrder, Chapter: chapter.ChapterNum, Message: fmt.Sprintf("chapter %d skips from h%d to h%d at %q", chapter.ChapterNum, *level, next, heading(n))})
+			}
+			*level = next
+		case atom.Table:
+			if !contains(n, atom.Th) {
+				c.add(Issue{Rule: RuleTableHeaders, Chapter: chapter.ChapterNum, Message: fmt.Sprintf("a table in chapter %d has no header cells", chapter.ChapterNum)})
+			}
+		case atom.Img:
+			c.inline(chapter, n)
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(chapter, child, level)
+	}
+}
+
+// inline checks an image in a chapter's text. Images stored with the book
+// may be described there rather than in the text.
+func (c *checker) inline(chapter models.Chapter, n *html.Node) {
+	src, alt := attr(n, "src"), attr(n, "alt")
+	image, ok := c.images[strings.TrimPrefix(src, "/images/")]
+	issue := Issue{Chapter: chapter.ChapterNum, Message: fmt.Sprintf("an image in chapter %d has no alt text and isn't marked decorative", chapter.ChapterNum)}
+	if !ok {
+		issue.Message = fmt.Sprintf("the image %s in chapter %d has no alt text", src, chapter.ChapterNum)
+	}
+	c.image(image, alt, issue)
+}
+
+// metadata describes the book as checked
+func (c *checker) metadata() Metadata {
+	m := Metadata{Hazards: []string{"none"}}
+	if c.text {
+		m.AccessModes = append(m.AccessModes, "textual")
+	}
+	if c.visual {
+		m.AccessModes = append(m.AccessModes, "visual")
+		m.AccessModeSufficient = append(m.AccessModeSufficient, strings.Join(m.AccessModes, ","))
+	}
+	// text alternatives make every image readable as text
+	if (c.text || c.visual) && c.undescribed == 0 {
+		m.AccessModeSufficient = append(m.AccessModeSufficient, "textual")
+	}
+
+	m.Features = []string{"tableOfContents", "readingOrder"}
+	if c.book.Book.Layout == models.LayoutFixed {
+		m.Features = append(m.Features, "pageNavigation")
+	} else {
+		m.Features = append(m.Features, "structuralNavigation", "displayTransformability")
+	}
+	if c.visual && c.undescribed == 0 {
+		m.Features = append(m.Features, "alternativeText")
+	}
+	if c.longDescriptions {
+		m.Features = append(m.Features, "longDescription")
+	}
+
+	switch {
+	case len(c.issues) == 0 && c.visual:
+		m.Summary = "Every image has a text alternative or is marked decorative. No accessibility problems were found by automated checks."
+	case len(c.issues) == 0:
+		m.Summary = "No accessibility problems were found by automated checks."
+	case c.undescribed > 0:
+		m.Summary = fmt.Sprintf("%s without a text alternative. Automated checks found %s in all.", plural(c.undescribed, "image"), plural(len(c.issues), "accessibility problem"))
+	default:
+		m.Summary = fmt.Sprintf("Automated checks found %s.", plural(len(c.issues), "accessibility problem"))
+	}
+	return m
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// contains reports whether an element of the given kind is under n
+func contains(n *html.Node, a atom.Atom) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == a || contains(child, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// heading is the text of a heading, for naming it in an issue
+func heading(n *html.Node) string {
+	var sb strings.Builder
+	var text func(*html.Node)
+	text = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			text(child)
+		}
+	}
+	text(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
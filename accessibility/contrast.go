@@ -0,0 +1,52 @@
+package accessibility
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// contrast is the WCAG contrast ratio of two hex colors, from 1 to 21.
+// Alpha is ignored. ok is false when a color can't be read.
+func contrast(foreground, background string) (ratio float64, ok bool) {
+	fg, ok := luminance(foreground)
+	if !ok {
+		return 0, false
+	}
+	bg, ok := luminance(background)
+	if !ok {
+		return 0, false
+	}
+	if fg < bg {
+		fg, bg = bg, fg
+	}
+	return (fg + 0.05) / (bg + 0.05), true
+}
+
+// luminance is the relative luminance of a color written #rgb, #rgba,
+// #rrggbb or #rrggbbaa
+func luminance(hex string) (float64, bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	switch len(hex) {
+	case 3, 4:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6, 8:
+		hex = hex[:6]
+	default:
+		return 0, false
+	}
+	var channels [3]float64
+	for i := range channels {
+		v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return 0, false
+		}
+		c := float64(v) / 255
+		if c <= 0.03928 {
+			channels[i] = c / 12.92
+		} else {
+			channels[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2], true
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Books lists the books the caller may read
+func (c *Client) Books(options *ListOptions) *Iterator[models.Book] {
+	return newIterator[models.Book](c, "/v2/books", options)
+}
+
+func (c *Client) Book(ctx context.Context, id primitive.ObjectID) (*models.Book, error) {
+	var book models.Book
+	if err := c.do(ctx, request{method: http.MethodGet, path: bookPath(id)}, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+// bookInput is what clients may set on a book
+type bookInput struct {
+	Title      string        `json:"title"`
+	Subtitle   string        `json:"subtitle"`
+	Author     string        `json:"author"`
+	Visibility string        `json:"visibility,omitempty"`
+	Language   string        `json:"language,omitempty"`
+	Theme      *models.Theme `json:"theme,omitempty"`
+
+	Description     string                   `json:"description,omitempty"`
+	Keywords        []string                 `json:"keywords,omitempty"`
+	Subjects        []models.Subject         `json:"subjects,omitempty"`
+	Publisher       string                   `json:"publisher,omitempty"`
+	Imprint         string                   `json:"imprint,omitempty"`
+	PublicationDate string                   `json:"publicationDate,omitempty"`
+	Series          *models.SeriesMembership `json:"series,omitempty"`
+	Contributors    []models.Contributor     `json:"contributors,omitempty"`
+	Identifiers     []models.Identifier      `json:"identifiers,omitempty"`
+}
+
+// CreateBook adds a book owned by the caller. Its cover and layout are
+// left out.
+func (c *Client) CreateBook(ctx context.Context, book models.Book) (*models.Book, error) {
+	var created models.Book
+	if err := c.doJSON(ctx, http.MethodPost, "/v2/books", newBookInput(book), &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateBook replaces the details and metadata of book.ID. Its cover and
+// layout are kept.
+func (c *Client) UpdateBook(ctx context.Context, book models.Book) (*models.Book, error) {
+	var updated models.Book
+	if err := c.doJSON(ctx, http.MethodPut, bookPath(book.ID), newBookInput(book), &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteBook moves a book and everything in it to the trash
+func (c *Client) DeleteBook(ctx context.Context, id primitive.ObjectID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: bookPath(id)}, nil)
+}
+
+// BookCover returns the cover image, resized when width or height is set.
+// The caller must close it.
+func (c *Client) BookCover(ctx context.Context, id primitive.ObjectID, width, height int) (io.ReadCloser, error) {
+	return c.file(ctx, bookPath(id)+"/cover", width, height)
+}
+
+func (c *Client) file(ctx context.Context, path string, width, height int) (io.ReadCloser, error) {
+	query := url.Values{}
+	if width > 0 {
+		query.Set("w", strconv.Itoa(width))
+	}
+	if height > 0 {
+		query.Set("h", strconv.Itoa(height))
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func newBookInput(book models.Book) bookInput {
+	return bookInput{
+		Title:      book.Title,
+		Subtitle:   book.Subtitle,
+		Author:     book.Author,
+		Visibility: book.Visibility,
+		Language:   book.Language,
+		Theme:      book.Theme,
+
+		Description:     book.Description,
+		Keywords:        book.Keywords,
+		Subjects:        book.Subjects,
+		Publisher:       book.Publisher,
+		Imprint:         book.Imprint,
+		PublicationDate: book.PublicationDate,
+		Series:          book.Series,
+		Contributors:    book.Contributors,
+		Identifiers:     book.Identifiers,
+	}
+}
+
+func bookPath(id primitive.ObjectID) string {
+	return "/v2/books/" + id.Hex()
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// chapterInput is what clients may set on a chapter
+type chapterInput struct {
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	ChapterNum int    `json:"chapterNum"`
+	VersionID  string `json:"versionID,omitempty"`
+}
+
+// Chapters lists a book's chapters
+func (c *Client) Chapters(bookID primitive.ObjectID, options *ListOptions) *Iterator[models.Chapter] {
+	return newIterator[models.Chapter](c, bookPath(bookID)+"/chapters", options)
+}
+
+func (c *Client) Chapter(ctx context.Context, bookID, id primitive.ObjectID) (*models.Chapter, error) {
+	var chapter models.Chapter
+	if err := c.do(ctx, request{method: http.MethodGet, path: chapterPath(bookID, id)}, &chapter); err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+// CreateChapter adds a chapter to chapter.BookID. A taken number moves it
+// to the next free one, so check the number of the returned chapter.
+func (c *Client) CreateChapter(ctx context.Context, chapter models.Chapter) (*models.Chapter, error) {
+	var created models.Chapter
+	if err := c.doJSON(ctx, http.MethodPost, bookPath(chapter.BookID)+"/chapters", newChapterInput(chapter), &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateChapter replaces the title, text and number of chapter.ID
+func (c *Client) UpdateChapter(ctx context.Context, chapter models.Chapter) (*models.Chapter, error) {
+	var updated models.Chapter
+	if err := c.doJSON(ctx, http.MethodPut, chapterPath(chapter.BookID, chapter.ID), newChapterInput(chapter), &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteChapter moves a chapter to the trash
+func (c *Client) DeleteChapter(ctx context.Context, bookID, id primitive.ObjectID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: chapterPath(bookID, id)}, nil)
+}
+
+func newChapterInput(chapter models.Chapter) chapterInput {
+	return chapterInput{Title: chapter.Title, Text: chapter.Text, ChapterNum: chapter.ChapterNum, VersionID: hex(chapter.VersionID)}
+}
+
+func chapterPath(bookID, id primitive.ObjectID) string {
+	return bookPath(bookID) + "/chapters/" + id.Hex()
+}
+
+// hex leaves unset IDs out of request bodies
+func hex(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
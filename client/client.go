@@ -0,0 +1,351 @@
+// Package client is a typed Go client for the OnWord v2 API. It signs in
+// and refreshes tokens, retries idempotent requests that fail for reasons
+// worth retrying, walks paginated listings and turns error bodies into
+// *Error values.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// DefaultRetries is how many times an idempotent request is retried
+	DefaultRetries = 3
+	// DefaultBackoff is the wait before the first retry; it doubles after each
+	DefaultBackoff = 200 * time.Millisecond
+	// refreshBefore is how long before expiry a token is replaced, or half
+	// its lifetime for shorter-lived tokens
+	refreshBefore = time.Minute
+	maxBackoff    = 10 * time.Second
+)
+
+// Client talks to one OnWord server. It is safe for concurrent use.
+type Client struct {
+	base    *url.URL
+	http    *http.Client
+	retries int
+	backoff time.Duration
+
+	// mu guards the session and serializes refreshes
+	mu       sync.Mutex
+	token    string
+	renewAt  time.Time
+	userID   string
+	email    string
+	password string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries sets how many times idempotent requests are retried
+func WithRetries(n int) Option {
+	return func(c *Client) { c.retries = n }
+}
+
+// WithBackoff sets the wait before the first retry
+func WithBackoff(d time.Duration) Option {
+	return func(c *Client) { c.backoff = d }
+}
+
+// WithToken uses a token issued elsewhere. Without credentials from
+// SignIn it can't be refreshed.
+func WithToken(token string) Option {
+	return func(c *Client) { c.setToken(token, "") }
+}
+
+// New returns a client for the server at baseURL, e.g. https://onword.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, errors.New("client: base URL needs a scheme and host")
+	}
+	c := &Client{base: base, http: http.DefaultClient, retries: DefaultRetries, backoff: DefaultBackoff}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// SignIn exchanges an email and password for a token. The credentials are
+// kept so the token can be replaced when it expires.
+func (c *Client) SignIn(ctx context.Context, email, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.email, c.password = email, password
+	return c.signIn(ctx)
+}
+
+// SignOut forgets the token and credentials
+func (c *Client) SignOut() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.userID, c.email, c.password = "", "", "", ""
+	c.renewAt = time.Time{}
+}
+
+// UserID is the signed-in user's ID, empty when signed out
+func (c *Client) UserID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userID
+}
+
+// signIn requests a token with the stored credentials; c.mu must be held
+func (c *Client) signIn(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"email": c.email, "password": c.password})
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/v2/sessions", body: body, contentType: "application/json", anonymous: true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var session struct {
+		Token  string `json:"token"`
+		UserID string `json:"userId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return err
+	}
+	c.setToken(session.Token, session.UserID)
+	return nil
+}
+
+func (c *Client) setToken(token, userID string) {
+	c.token, c.userID, c.renewAt = token, userID, time.Time{}
+	var claims struct {
+		UserID string `json:"userId"`
+		jwt.StandardClaims
+	}
+	// the server checks the signature; the client only wants the expiry
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, &claims); err == nil {
+		if claims.ExpiresAt > 0 {
+			expires := time.Unix(claims.ExpiresAt, 0)
+			early := time.Until(expires) / 2
+			if early > refreshBefore {
+				early = refreshBefore
+			}
+			c.renewAt = expires.Add(-early)
+		}
+		if userID == "" {
+			c.userID = claims.UserID
+		}
+	}
+}
+
+// currentToken returns the token to send, replacing it first when it is
+// about to expire and the credentials are known
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.email != "" && (c.token == "" || !c.renewAt.IsZero() && time.Now().After(c.renewAt)) {
+		if err := c.signIn(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.token, nil
+}
+
+// refresh replaces a token the server rejected, unless another request
+// already has. It reports whether there is a new token to try.
+func (c *Client) refresh(ctx context.Context, rejected string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != rejected {
+		return true, nil
+	}
+	if c.email == "" {
+		return false, nil
+	}
+	if err := c.signIn(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	// anonymous requests don't carry the token, which is how sign-in avoids
+	// refreshing itself
+	anonymous bool
+}
+
+// do sends the request and decodes a JSON response into out, if given
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// doJSON sends in as a JSON body
+func (c *Client) doJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, request{method: method, path: path, body: body, contentType: "application/json"}, out)
+}
+
+// send returns the response to a request, which the caller must close.
+// A rejected token is replaced once; idempotent requests are retried on
+// network errors and 429, 502, 503 and 504. Error statuses become *Error.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		token := ""
+		if !req.anonymous {
+			var err error
+			if token, err = c.currentToken(ctx); err != nil {
+				return nil, err
+			}
+		}
+		httpReq, err := c.newRequest(ctx, req, token)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.http.Do(httpReq)
+		retry := attempt < c.retries && idempotent(req.method)
+		if err != nil {
+			if ctx.Err() != nil || !retry {
+				return nil, err
+			}
+			if err := c.wait(ctx, c.delay(attempt, nil)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && token != "" && !refreshed {
+			drain(resp)
+			refreshed = true
+			ok, err := c.refresh(ctx, token)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				// the request was rejected before it was handled, so even a
+				// POST is safe to send again
+				attempt--
+				continue
+			}
+			return nil, &Error{Status: http.StatusUnauthorized, Message: "the token was rejected"}
+		}
+		if retry && retryable(resp.StatusCode) {
+			delay := c.delay(attempt, resp)
+			drain(resp)
+			if err := c.wait(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			defer resp.Body.Close()
+			return nil, decodeError(resp)
+		}
+		return resp, nil
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, req request, token string) (*http.Request, error) {
+	target := *c.base
+	target.Path += req.path
+	target.RawQuery = req.query.Encode()
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json, application/problem+json")
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return httpReq, nil
+}
+
+// delay is how long to wait before retry attempt+1, honouring Retry-After
+func (c *Client) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	delay := c.backoff << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	// jitter keeps clients that failed together from retrying together
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (c *Client) wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+}
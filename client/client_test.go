@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+	"github.com/programmingbunny/epub-backend/service/servicetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// server runs the API on an in-memory store. Requests whose path starts
+// with a prefix in failures are answered with the status given there until
+// the count runs out.
+type server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures map[string]*failure
+	requests map[string]int
+}
+
+type failure struct {
+	status int
+	times  int
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	handler := servicetest.Handler(t)
+	s := &server{failures: map[string]*failure{}, requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		var failed *failure
+		for prefix, f := range s.failures {
+			if strings.HasPrefix(r.Method+" "+r.URL.Path, prefix) && f.times > 0 {
+				f.times--
+				failed = f
+			}
+		}
+		s.mu.Unlock()
+		if failed != nil {
+			rw.Header().Set("Retry-After", "0")
+			http.Error(rw, http.StatusText(failed.status), failed.status)
+			return
+		}
+		handler.ServeHTTP(rw, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// fail answers the next times requests matching "METHOD /path" prefix with status
+func (s *server) fail(prefix string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[prefix] = &failure{status: status, times: times}
+}
+
+// count is how many requests reached "METHOD /path"
+func (s *server) count(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[route]
+}
+
+// signedIn returns a client signed in as a new user
+func signedIn(t *testing.T, s *server, email string) *Client {
+	t.Helper()
+	ctx := context.Background()
+	c, err := New(s.URL, WithHTTPClient(s.Client()), WithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, models.User{Email: email, FirstName: "Ann", LastName: "Lee", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SignIn(ctx, email, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func createBook(t *testing.T, c *Client, title string) *models.Book {
+	t.Helper()
+	book, err := c.CreateBook(context.Background(), models.Book{Title: title, Subtitle: "A tale", Author: "Ann Lee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestUpdatesBooks(t *testing.T) {
+	s := newServer(t)
+	c := signedIn(t, s, "ann@example.com")
+	ctx := context.Background()
+	book := createBook(t, c, "The Cats")
+
+	book.Title, book.Description, book.Keywords = "The Cats Return", "They come back.", []string{"cats"}
+	if _, err := c.UpdateBook(ctx, *book); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Book(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "The Cats Return" || got.Description != "They come back." || len(got.Keywords) != 1 {
+		t.Errorf("got %+v", got)
+	}
+
+	other := signedIn(t, s, "bob@example.com")
+	if _, err := other.UpdateBook(ctx, *book); !errors.Is(err, ErrForbidden) {
+		t.Errorf("updating someone else's book: got %v, want ErrForbidden", err)
+	}
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	s := newServer(t)
+	c := signedIn(t, s, "ann@example.com")
+	book := createBook(t, c, "The Cats")
+	route := "GET /v2/books/" + book.ID.Hex()
+
+	s.fail(route, http.StatusServiceUnavailable, 2)
+	got, err := c.Book(context.Background(), book.ID)
+	if err != nil {
+		t.Fatalf("got %v, want the book after two retries", err)
+	}
+	if got.Title != "The Cats" {
+		t.Errorf("got title %q", got.Title)
+	}
+	if n := s.count(route); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+
+	s.fail(route, http.StatusBadGateway, DefaultRetries+1)
+	_, err = c.Book(context.Background(), book.ID)
+	var failed *Error
+	if !errors.As(err, &failed) || failed.Status != http.StatusBadGateway {
+		t.Errorf("got %v, want the 502 once retries run out", err)
+	}
+}
+
+func TestDoesNotRetryPosts(t *testing.T) {
+	s := newServer(t)
+	c := signedIn(t, s, "ann@example.com")
+
+	s.fail("POST /v2/books", http.StatusServiceUnavailable, 1)
+	_, err := c.CreateBook(context.Background(), models.Book{Title: "The Cats", Subtitle: "A tale", Author: "Ann Lee"})
+	var failed *Error
+	if !errors.As(err, &failed) || failed.Status != http.StatusServiceUnavailable {
+		t.Errorf("got %v, want the 503", err)
+	}
+	if n := s.count("POST /v2/books"); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestReplacesRejectedTokens(t *testing.T) {
+	s := newServer(t)
+	c := signedIn(t, s, "ann@example.com")
+	book := createBook(t, c, "The Cats")
+
+	c.mu.Lock()
+	c.token = "stale"
+	c.mu.Unlock()
+	if _, err := c.Book(context.Background(), book.ID); err != nil {
+		t.Fatalf("got %v, want the book after signing in again", err)
+	}
+	if n := s.count("POST /v2/sessions"); n != 2 {
+		t.Errorf("signed in %d times, want 2", n)
+	}
+}
+
+func TestWalksPages(t *testing.T) {
+	s := newServer(t)
+	c := signedIn(t, s, "ann@example.com")
+	for _, title := range []string{"A", "B", "C", "D", "E"} {
+		createBook(t, c, title)
+	}
+
+	books, err := c.Books(&ListOptions{Sort: "title", Limit: 2}).All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, book := range books {
+		titles = append(titles, book.Title)
+	}
+	if got := strings.Join(titles, ""); got != "ABCDE" {
+		t.Errorf("got titles %q, want ABCDE", got)
+	}
+	if n := s.count("GET /v2/books"); n != 3 {
+		t.Errorf("fetched %d pages, want 3", n)
+	}
+
+	books, err = c.Books(&ListOptions{Filters: map[string][]string{"title": {"Z"}}}).All(context.Background())
+	if err != nil || len(books) != 0 {
+		t.Errorf("got %d books and %v, want none", len(books), err)
+	}
+}
+
+func TestDecodesProblems(t *testing.T) {
+	s := newServer(t)
+	owner := signedIn(t, s, "ann@example.com")
+	other := signedIn(t, s, "bob@example.com")
+	ctx := context.Background()
+
+	_, err := owner.CreateBook(ctx, models.Book{Title: "No author"})
+	var failed *Error
+	if !errors.As(err, &failed) || !errors.Is(err, ErrInvalid) {
+		t.Fatalf("got %v, want a validation problem", err)
+	}
+	if failed.Code != responses.CodeValidationFailed || len(failed.Fields) == 0 {
+		t.Errorf("got code %q and fields %v", failed.Code, failed.Fields)
+	}
+
+	_, err = owner.Book(ctx, primitive.NewObjectID())
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want not found", err)
+	}
+
+	book := createBook(t, owner, "The Cats")
+	if err := other.DeleteBook(ctx, book.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got %v, want forbidden", err)
+	}
+}
+
+func TestDecodesV1Errors(t *testing.T) {
+	s := newServer(t)
+	c := signedIn(t, s, "ann@example.com")
+
+	err := c.do(context.Background(), request{method: http.MethodDelete, path: "/deleteChapter/" + primitive.NewObjectID().Hex()}, nil)
+	var failed *Error
+	if !errors.As(err, &failed) || failed.Status != http.StatusNotFound {
+		t.Fatalf("got %v, want a 404", err)
+	}
+	if failed.Code != "" || failed.Message != "chapter not found" {
+		t.Errorf("got code %q and message %q", failed.Code, failed.Message)
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// problemContentType is the media type of problem details (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// Errors to match with errors.Is against an *Error
+var (
+	ErrInvalid      = errors.New("client: invalid request")
+	ErrUnauthorized = errors.New("client: unauthorized")
+	ErrForbidden    = errors.New("client: forbidden")
+	ErrNotFound     = errors.New("client: not found")
+	ErrConflict     = errors.New("client: conflict")
+)
+
+// Error is an error response from the server
+type Error struct {
+	Status int
+	// Code is the machine-readable problem code, e.g. "validation_failed".
+	// Responses in the v1 envelope don't carry one.
+	Code    string
+	Message string
+	// Fields lists the failed rules of a validation error
+	Fields []FieldError
+}
+
+// FieldError is one failed validation rule
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "title"
+	Field string `json:"field"`
+	// Rule is the validator tag that failed, e.g. "required"
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("onword: %d %s: %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("onword: %d: %s", e.Status, e.Message)
+}
+
+// Is matches the Err values by status
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrInvalid:
+		return e.Status == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.Status == http.StatusForbidden
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrConflict:
+		return e.Status == http.StatusConflict
+	}
+	return false
+}
+
+// decodeError reads problem details or, from v1 routes, the
+// {status, message, data} envelope
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	failure := &Error{Status: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == problemContentType {
+		var problem struct {
+			Title  string       `json:"title"`
+			Detail string       `json:"detail"`
+			Code   string       `json:"code"`
+			Errors []FieldError `json:"errors"`
+		}
+		if json.Unmarshal(body, &problem) == nil {
+			failure.Code = problem.Code
+			failure.Message = problem.Detail
+			if failure.Message == "" {
+				failure.Message = problem.Title
+			}
+			failure.Fields = problem.Errors
+			return failure
+		}
+	}
+
+	var envelope struct {
+		Status  int                    `json:"status"`
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Status != 0 {
+		if detail, ok := envelope.Data["data"].(string); ok && detail != "" {
+			failure.Message = detail
+		} else if envelope.Message != "" {
+			failure.Message = envelope.Message
+		}
+		return failure
+	}
+	if text := strings.TrimSpace(string(body)); text != "" {
+		failure.Message = text
+	}
+	return failure
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Images lists a book's chapter header images
+func (c *Client) Images(bookID primitive.ObjectID, options *ListOptions) *Iterator[models.ChapterImages] {
+	return newIterator[models.ChapterImages](c, bookPath(bookID)+"/images", options)
+}
+
+func (c *Client) Image(ctx context.Context, bookID, id primitive.ObjectID) (*models.ChapterImages, error) {
+	var image models.ChapterImages
+	if err := c.do(ctx, request{method: http.MethodGet, path: imagePath(bookID, id)}, &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// UploadImage adds a header image for image.ChapterNum in image.BookID,
+// reading the file from r
+func (c *Client) UploadImage(ctx context.Context, image models.ChapterImages, name string, r io.Reader) (*models.ChapterImages, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("chapterNum", strconv.Itoa(image.ChapterNum))
+	if image.Type != "" {
+		form.WriteField("type", image.Type)
+	}
+	part, err := form.CreateFormFile("image", name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var created models.ChapterImages
+	req := request{method: http.MethodPost, path: bookPath(image.BookID) + "/images", body: body.Bytes(), contentType: form.FormDataContentType()}
+	if err := c.do(ctx, req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ImageFile returns the image, resized when width or height is set. The
+// caller must close it.
+func (c *Client) ImageFile(ctx context.Context, bookID, id primitive.ObjectID, width, height int) (io.ReadCloser, error) {
+	return c.file(ctx, imagePath(bookID, id)+"/file", width, height)
+}
+
+// DeleteImage moves an image to the trash
+func (c *Client) DeleteImage(ctx context.Context, bookID, id primitive.ObjectID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: imagePath(bookID, id)}, nil)
+}
+
+func imagePath(bookID, id primitive.ObjectID) string {
+	return bookPath(bookID) + "/images/" + id.Hex()
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// noteInput is what clients may set on a note
+type noteInput struct {
+	Title     string `json:"title"`
+	Text      string `json:"text"`
+	Type      string `json:"type"`
+	VersionID string `json:"versionID,omitempty"`
+}
+
+// Notes lists a book's notes
+func (c *Client) Notes(bookID primitive.ObjectID, options *ListOptions) *Iterator[models.Notes] {
+	return newIterator[models.Notes](c, bookPath(bookID)+"/notes", options)
+}
+
+func (c *Client) Note(ctx context.Context, bookID, id primitive.ObjectID) (*models.Notes, error) {
+	var note models.Notes
+	if err := c.do(ctx, request{method: http.MethodGet, path: notePath(bookID, id)}, &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// CreateNote adds a note to note.BookID
+func (c *Client) CreateNote(ctx context.Context, note models.Notes) (*models.Notes, error) {
+	var created models.Notes
+	if err := c.doJSON(ctx, http.MethodPost, bookPath(note.BookID)+"/notes", newNoteInput(note), &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateNote replaces the title and text of note.ID
+func (c *Client) UpdateNote(ctx context.Context, note models.Notes) (*models.Notes, error) {
+	var updated models.Notes
+	if err := c.doJSON(ctx, http.MethodPut, notePath(note.BookID, note.ID), newNoteInput(note), &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteNote moves a note to the trash
+func (c *Client) DeleteNote(ctx context.Context, bookID, id primitive.ObjectID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: notePath(bookID, id)}, nil)
+}
+
+func newNoteInput(note models.Notes) noteInput {
+	return noteInput{Title: note.Title, Text: note.Text, Type: note.Type, VersionID: hex(note.VersionID)}
+}
+
+func notePath(bookID, id primitive.ObjectID) string {
+	return bookPath(bookID) + "/notes/" + id.Hex()
+}
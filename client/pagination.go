@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListOptions narrows and orders a listing, using the parameters the list
+// routes document in /openapi.json
+type ListOptions struct {
+	// Filters holds field=value and field.op=value pairs. Repeating a field
+	// matches any of the values.
+	Filters url.Values
+	// Sort is a comma separated list of fields, - for descending
+	Sort string
+	// Limit is the page size, the server's default when zero
+	Limit int
+}
+
+func (o *ListOptions) query(cursor string) url.Values {
+	query := url.Values{}
+	if o != nil {
+		for key, values := range o.Filters {
+			query[key] = append([]string(nil), values...)
+		}
+		if o.Sort != "" {
+			query.Set("sort", o.Sort)
+		}
+		if o.Limit > 0 {
+			query.Set("limit", strconv.Itoa(o.Limit))
+		}
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	return query
+}
+
+type page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// Iterator walks a listing, fetching a page at a time:
+//
+//	books := c.Books(nil)
+//	for books.Next(ctx) {
+//		book := books.Item()
+//	}
+//	if err := books.Err(); err != nil {
+type Iterator[T any] struct {
+	client  *Client
+	path    string
+	options *ListOptions
+
+	items   []T
+	item    T
+	cursor  string
+	fetched bool
+	err     error
+}
+
+func newIterator[T any](c *Client, path string, options *ListOptions) *Iterator[T] {
+	return &Iterator[T]{client: c, path: path, options: options}
+}
+
+// Next advances to the next item, fetching the next page when needed. It
+// returns false at the end of the listing or on an error.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.items) == 0 {
+		if it.err != nil || it.fetched && it.cursor == "" {
+			return false
+		}
+		var next page[T]
+		err := it.client.do(ctx, request{method: http.MethodGet, path: it.path, query: it.options.query(it.cursor)}, &next)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.fetched = true
+		it.items, it.cursor = next.Items, next.NextCursor
+	}
+	it.item, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// Item is the item Next advanced to
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err is the error that stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the rest of the listing
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var all []T
+	for it.Next(ctx) {
+		all = append(all, it.Item())
+	}
+	return all, it.Err()
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// userInput is what clients may set on an account
+type userInput struct {
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Password  string `json:"password"`
+}
+
+// userView is an account as the server shows it
+type userView struct {
+	ID        primitive.ObjectID `json:"id"`
+	Email     string             `json:"email"`
+	FirstName string             `json:"firstName"`
+	LastName  string             `json:"lastName"`
+}
+
+// CreateUser registers an account. It doesn't sign in.
+func (c *Client) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	var view userView
+	if err := c.doJSON(ctx, http.MethodPost, "/v2/users", newUserInput(user), &view); err != nil {
+		return nil, err
+	}
+	return userFrom(view), nil
+}
+
+// User returns the signed-in user's account
+func (c *Client) User(ctx context.Context) (*models.User, error) {
+	path, err := c.userPath()
+	if err != nil {
+		return nil, err
+	}
+	var view userView
+	if err := c.do(ctx, request{method: http.MethodGet, path: path}, &view); err != nil {
+		return nil, err
+	}
+	return userFrom(view), nil
+}
+
+// UpdateUser replaces the signed-in user's details, password included
+func (c *Client) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	path, err := c.userPath()
+	if err != nil {
+		return nil, err
+	}
+	var view userView
+	if err := c.doJSON(ctx, http.MethodPut, path, newUserInput(user), &view); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.email != "" {
+		c.email, c.password = user.Email, user.Password
+	}
+	c.mu.Unlock()
+	return userFrom(view), nil
+}
+
+// DeleteUser deletes the signed-in user's account and signs out
+func (c *Client) DeleteUser(ctx context.Context) error {
+	path, err := c.userPath()
+	if err != nil {
+		return err
+	}
+	if err := c.do(ctx, request{method: http.MethodDelete, path: path}, nil); err != nil {
+		return err
+	}
+	c.SignOut()
+	return nil
+}
+
+func (c *Client) userPath() (string, error) {
+	id := c.UserID()
+	if id == "" {
+		return "", &Error{Status: http.StatusUnauthorized, Message: "sign in first"}
+	}
+	return "/v2/users/" + id, nil
+}
+
+func newUserInput(user models.User) userInput {
+	return userInput{Email: user.Email, FirstName: user.FirstName, LastName: user.LastName, Password: user.Password}
+}
+
+func userFrom(view userView) *models.User {
+	return &models.User{ID: view.ID, Email: view.Email, FirstName: view.FirstName, LastName: view.LastName}
+}
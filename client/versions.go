@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// versionInput is what clients may set on a version
+type versionInput struct {
+	Type string `json:"type"`
+}
+
+// Versions lists a book's versions
+func (c *Client) Versions(bookID primitive.ObjectID, options *ListOptions) *Iterator[models.Version] {
+	return newIterator[models.Version](c, bookPath(bookID)+"/versions", options)
+}
+
+func (c *Client) Version(ctx context.Context, bookID, id primitive.ObjectID) (*models.Version, error) {
+	var version models.Version
+	if err := c.do(ctx, request{method: http.MethodGet, path: bookPath(bookID) + "/versions/" + id.Hex()}, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// CreateVersion adds a version of version.BookID
+func (c *Client) CreateVersion(ctx context.Context, version models.Version) (*models.Version, error) {
+	var created models.Version
+	if err := c.doJSON(ctx, http.MethodPost, bookPath(version.BookID)+"/versions", versionInput{Type: version.Type}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
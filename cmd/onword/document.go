@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kindChapter = "chapter"
+	kindNote    = "note"
+)
+
+// document is a chapter or note as the folder keeps it
+type document struct {
+	ID         primitive.ObjectID
+	Kind       string
+	Title      string
+	ChapterNum int
+	// Type is a note's type
+	Type string
+	Text string
+}
+
+// frontMatter is the YAML header of a document file
+type frontMatter struct {
+	ID      string `yaml:"id,omitempty"`
+	Chapter int    `yaml:"chapter,omitempty"`
+	Title   string `yaml:"title"`
+	Type    string `yaml:"type,omitempty"`
+}
+
+func fromChapter(chapter models.Chapter) document {
+	return document{ID: chapter.ID, Kind: kindChapter, Title: chapter.Title, ChapterNum: chapter.ChapterNum, Text: chapter.Text}
+}
+
+func fromNote(note models.Notes) document {
+	return document{ID: note.ID, Kind: kindNote, Title: note.Title, Type: note.Type, Text: note.Text}
+}
+
+func (d document) chapter(bookID primitive.ObjectID) models.Chapter {
+	return models.Chapter{ID: d.ID, BookID: bookID, Title: d.Title, ChapterNum: d.ChapterNum, Text: d.Text}
+}
+
+func (d document) note(bookID primitive.ObjectID) models.Notes {
+	return models.Notes{ID: d.ID, BookID: bookID, Title: d.Title, Type: d.Type, Text: d.Text}
+}
+
+// fingerprint identifies a document's content. Comparing the local and
+// server copies with the fingerprint both had at the last sync tells which
+// side changed. Trailing newlines are ignored since editors add them.
+func (d document) fingerprint() string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s\x00%s\x00%d\x00%s\x00%s", d.Kind, d.Title, d.ChapterNum, d.Type, strings.TrimRight(d.Text, "\n"))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// marshal renders the document as Markdown with YAML front matter
+func (d document) marshal() ([]byte, error) {
+	header := frontMatter{Title: d.Title, Type: d.Type}
+	if !d.ID.IsZero() {
+		header.ID = d.ID.Hex()
+	}
+	if d.Kind == kindChapter {
+		header.Chapter = d.ChapterNum
+	}
+	yml, err := yaml.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString("---\n")
+	out.Write(yml)
+	out.WriteString("---\n")
+	out.WriteString(d.Text)
+	if d.Text != "" && !strings.HasSuffix(d.Text, "\n") {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// parseDocument reads a file written by marshal, or by hand in the same shape
+func parseDocument(kind string, data []byte) (document, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if lines[0] != "---" {
+		return document{}, errors.New("missing front matter: the file must start with a --- line")
+	}
+	end := 1
+	for end < len(lines) && lines[end] != "---" {
+		end++
+	}
+	if end == len(lines) {
+		return document{}, errors.New("front matter is not closed by a --- line")
+	}
+
+	var header frontMatter
+	if err := yaml.Unmarshal([]byte(strings.Join(lines[1:end], "\n")), &header); err != nil {
+		return document{}, fmt.Errorf("front matter: %w", err)
+	}
+	if strings.TrimSpace(header.Title) == "" {
+		return document{}, errors.New("front matter needs a title")
+	}
+	doc := document{Kind: kind, Title: header.Title, Type: header.Type, Text: strings.TrimRight(strings.Join(lines[end+1:], "\n"), "\n")}
+	if kind == kindChapter {
+		doc.ChapterNum = header.Chapter
+	}
+	if header.ID != "" {
+		id, err := primitive.ObjectIDFromHex(header.ID)
+		if err != nil {
+			return document{}, fmt.Errorf("front matter id %q is not an ObjectID", header.ID)
+		}
+		doc.ID = id
+	}
+	return doc, nil
+}
@@ -0,0 +1,168 @@
+// Command onword keeps a folder of Markdown files in sync with a book on
+// an OnWord server, so chapters and notes can be written in any editor and
+// kept in git.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/client"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const usage = `usage: onword <command> [flags] [folder]
+
+Commands:
+  pull    download a book's chapters and notes as Markdown files
+  push    upload the chapters and notes changed in the folder
+  status  show what changed in the folder and on the server
+
+The folder defaults to the current directory. Chapters are kept in
+chapters/ and notes in notes/, each with YAML front matter holding the
+title, the ID and, for chapters, the number. Files without an id are
+created on push; deleting a file deletes the document on push.
+
+The server is read from -server or ONWORD_URL on the first pull and
+remembered afterwards. Sign in with ONWORD_EMAIL and ONWORD_PASSWORD, or
+pass a token in ONWORD_TOKEN.`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("onword: ")
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "pull":
+		err = runPull(ctx, args)
+	case "push":
+		err = runPush(ctx, args)
+	case "status":
+		err = runStatus(ctx, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+func runPull(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("pull", flag.ExitOnError)
+	server := flags.String("server", os.Getenv("ONWORD_URL"), "server URL, needed on the first pull")
+	book := flags.String("book", "", "ID of the book, needed on the first pull")
+	force := flags.Bool("force", false, "overwrite local changes that conflict with the server's")
+	flags.Parse(args)
+
+	dir := folder(flags)
+	s, err := loadState(dir)
+	if errors.Is(err, errNoState) && *book != "" {
+		s, err = newState(*server, *book)
+		if err == nil {
+			err = os.MkdirAll(dir, 0o755)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if *book != "" && *book != s.BookID.Hex() {
+		return fmt.Errorf("%s is synced with book %s", dir, s.BookID.Hex())
+	}
+
+	w, err := open(ctx, dir, s)
+	if err != nil {
+		return err
+	}
+	return w.pull(ctx, *force)
+}
+
+func runPush(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("push", flag.ExitOnError)
+	force := flags.Bool("force", false, "overwrite server changes that conflict with the folder's")
+	flags.Parse(args)
+
+	dir := folder(flags)
+	s, err := loadState(dir)
+	if err != nil {
+		return err
+	}
+	w, err := open(ctx, dir, s)
+	if err != nil {
+		return err
+	}
+	return w.push(ctx, *force)
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Parse(args)
+
+	dir := folder(flags)
+	s, err := loadState(dir)
+	if err != nil {
+		return err
+	}
+	w, err := open(ctx, dir, s)
+	if err != nil {
+		return err
+	}
+	return w.status(ctx)
+}
+
+func folder(flags *flag.FlagSet) string {
+	if flags.NArg() > 0 {
+		return flags.Arg(0)
+	}
+	return "."
+}
+
+func newState(server, book string) (*state, error) {
+	if server == "" {
+		return nil, errors.New("the first pull needs -server or ONWORD_URL")
+	}
+	id, err := primitive.ObjectIDFromHex(book)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book ID %q", book)
+	}
+	return &state{Server: strings.TrimSuffix(server, "/"), BookID: id, Files: map[string]entry{}}, nil
+}
+
+// open connects to the folder's server, signing in with the credentials
+// from the environment
+func open(ctx context.Context, dir string, s *state) (*workspace, error) {
+	var opts []client.Option
+	if token := os.Getenv("ONWORD_TOKEN"); token != "" {
+		opts = append(opts, client.WithToken(token))
+	}
+	c, err := client.New(s.Server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if email := os.Getenv("ONWORD_EMAIL"); email != "" {
+		signInCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := c.SignIn(signInCtx, email, os.Getenv("ONWORD_PASSWORD")); err != nil {
+			return nil, fmt.Errorf("signing in: %w", err)
+		}
+	}
+	return &workspace{dir: dir, state: s, client: c}, nil
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/programmingbunny/epub-backend/client"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type changeKind int
+
+const (
+	unchanged changeKind = iota
+	addedHere
+	modifiedHere
+	deletedHere
+	addedOnServer
+	modifiedOnServer
+	deletedOnServer
+	conflict
+)
+
+var changeLabels = map[changeKind]string{
+	addedHere:        "new",
+	modifiedHere:     "modified",
+	deletedHere:      "deleted",
+	addedOnServer:    "new on server",
+	modifiedOnServer: "changed on server",
+	deletedOnServer:  "deleted on server",
+	conflict:         "conflict",
+}
+
+// change is how one document differs between the folder, the server and
+// the last sync
+type change struct {
+	kind changeKind
+	// id is the hex ID of a document already on the server
+	id string
+	// path is empty for documents only the server has
+	path   string
+	local  *document
+	remote *document
+}
+
+func (c change) String() string {
+	name := c.path
+	if name == "" {
+		name = fmt.Sprintf("%s %q", c.remote.Kind, c.remote.Title)
+	}
+	if c.kind != conflict {
+		return fmt.Sprintf("%-18s %s", changeLabels[c.kind]+":", name)
+	}
+	switch {
+	case c.local == nil:
+		return fmt.Sprintf("%-18s %s (deleted here, changed on server)", "conflict:", name)
+	case c.remote == nil:
+		return fmt.Sprintf("%-18s %s (changed here, deleted on server)", "conflict:", name)
+	}
+	return fmt.Sprintf("%-18s %s (changed here and on server)", "conflict:", name)
+}
+
+// errConflicts stops a command that left conflicts unresolved
+type errConflicts int
+
+func (n errConflicts) Error() string {
+	return fmt.Sprintf("%d conflicts left alone; pull -force takes the server's copies, push -force keeps yours", int(n))
+}
+
+// workspace is a folder synced with one book
+type workspace struct {
+	dir    string
+	state  *state
+	client *client.Client
+}
+
+// remote fetches the book's chapters and notes, keyed by hex ID. The notes
+// of the book's series are shared with its other books and left out, so
+// deleting a file here never deletes them for all of them.
+func (w *workspace) remote(ctx context.Context) (map[string]document, error) {
+	docs := map[string]document{}
+	chapters := w.client.Chapters(w.state.BookID, nil)
+	for chapters.Next(ctx) {
+		doc := fromChapter(chapters.Item())
+		docs[doc.ID.Hex()] = doc
+	}
+	if err := chapters.Err(); err != nil {
+		return nil, err
+	}
+	notes := w.client.Notes(w.state.BookID, nil)
+	for notes.Next(ctx) {
+		if notes.Item().BookID != w.state.BookID {
+			continue
+		}
+		doc := fromNote(notes.Item())
+		docs[doc.ID.Hex()] = doc
+	}
+	return docs, notes.Err()
+}
+
+// compare classifies every document against the fingerprint of the last
+// sync: a side whose copy differs from it has changed, and a document
+// changed on both sides to different content is a conflict
+func (w *workspace) compare(ctx context.Context) ([]change, error) {
+	remote, err := w.remote(ctx)
+	if err != nil {
+		return nil, err
+	}
+	locals, err := scan(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	seen := map[string]string{}
+	for i := range locals {
+		file := &locals[i]
+		id := file.doc.ID.Hex()
+		entry, known := w.state.Files[id]
+		server, onServer := remote[id]
+		if file.doc.ID.IsZero() || !known && !onServer {
+			// new, or carrying the ID of a document this book never had
+			file.doc.ID = primitive.NilObjectID
+			changes = append(changes, change{kind: addedHere, path: file.path, local: &file.doc})
+			continue
+		}
+		if other, dup := seen[id]; dup {
+			return nil, fmt.Errorf("%s and %s have the same id", other, file.path)
+		}
+		seen[id] = file.path
+
+		c := change{id: id, path: file.path, local: &file.doc}
+		if onServer {
+			c.remote = &server
+		}
+		c.kind = classify(entry.Base, c.local, c.remote)
+		changes = append(changes, c)
+	}
+
+	for id, entry := range w.state.Files {
+		if _, found := seen[id]; found {
+			continue
+		}
+		c := change{id: id, path: entry.Path}
+		if server, onServer := remote[id]; onServer {
+			c.remote = &server
+		}
+		c.kind = classify(entry.Base, nil, c.remote)
+		changes = append(changes, c)
+	}
+	for id, server := range remote {
+		if _, known := w.state.Files[id]; !known && seen[id] == "" {
+			server := server
+			changes = append(changes, change{kind: addedOnServer, id: id, remote: &server})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return sortKey(changes[i]) < sortKey(changes[j]) })
+	return changes, nil
+}
+
+func sortKey(c change) string {
+	if c.path != "" {
+		return c.path
+	}
+	return folders[c.remote.Kind] + "/~" + c.remote.Title
+}
+
+// classify compares each copy, nil when deleted, with the base fingerprint
+func classify(base string, local, remote *document) changeKind {
+	if local != nil && remote != nil && local.fingerprint() == remote.fingerprint() {
+		return unchanged
+	}
+	localChanged := local == nil || local.fingerprint() != base
+	remoteChanged := remote == nil || remote.fingerprint() != base
+	switch {
+	case local == nil && remote == nil:
+		// gone from both; the entry is dropped on the next sync
+		return unchanged
+	case localChanged && remoteChanged:
+		return conflict
+	case localChanged && local == nil:
+		return deletedHere
+	case localChanged:
+		return modifiedHere
+	case remoteChanged && remote == nil:
+		return deletedOnServer
+	case remoteChanged:
+		return modifiedOnServer
+	}
+	return unchanged
+}
+
+// settle records that both sides now hold doc at path
+func (w *workspace) settle(path string, doc *document) {
+	w.state.Files[doc.ID.Hex()] = entry{Path: path, Kind: doc.Kind, Base: doc.fingerprint()}
+}
+
+func (w *workspace) status(ctx context.Context) error {
+	changes, err := w.compare(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("book %s on %s\n", w.state.BookID.Hex(), w.state.Server)
+	clean := true
+	for _, c := range changes {
+		if c.kind != unchanged {
+			fmt.Println("  " + c.String())
+			clean = false
+		}
+	}
+	if clean {
+		fmt.Println("  up to date")
+	}
+	return nil
+}
+
+// pull brings server changes into the folder. Local changes are kept, and
+// conflicts are only overwritten with force.
+func (w *workspace) pull(ctx context.Context, force bool) (err error) {
+	changes, err := w.compare(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if saveErr := w.state.save(w.dir); err == nil {
+			err = saveErr
+		}
+	}()
+
+	conflicts := 0
+	for _, c := range changes {
+		if c.kind == conflict && !force {
+			fmt.Println(c)
+			conflicts++
+			continue
+		}
+		switch c.kind {
+		case addedOnServer:
+			c.path = newPath(w.dir, *c.remote)
+			fallthrough
+		case modifiedOnServer, conflict:
+			if c.remote == nil {
+				if err := removeDocument(w.dir, c.path); err != nil {
+					return err
+				}
+				delete(w.state.Files, c.id)
+				fmt.Println("removed", c.path)
+				continue
+			}
+			if c.path == "" {
+				c.path = newPath(w.dir, *c.remote)
+			}
+			if err := writeDocument(w.dir, c.path, *c.remote); err != nil {
+				return err
+			}
+			w.settle(c.path, c.remote)
+			fmt.Println("pulled ", c.path)
+		case deletedOnServer:
+			if err := removeDocument(w.dir, c.path); err != nil {
+				return err
+			}
+			delete(w.state.Files, c.id)
+			fmt.Println("removed", c.path)
+		case unchanged:
+			w.agree(c)
+		}
+	}
+	if conflicts > 0 {
+		return errConflicts(conflicts)
+	}
+	return nil
+}
+
+// push sends local changes to the server. Server changes are left for
+// pull, and conflicts are only overwritten with force.
+func (w *workspace) push(ctx context.Context, force bool) (err error) {
+	changes, err := w.compare(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if saveErr := w.state.save(w.dir); err == nil {
+			err = saveErr
+		}
+	}()
+
+	conflicts, behind := 0, 0
+	for _, c := range changes {
+		if c.kind == conflict && !force {
+			fmt.Println(c)
+			conflicts++
+			continue
+		}
+		switch c.kind {
+		case addedHere, modifiedHere, deletedHere, conflict:
+			if err := w.upload(ctx, c); err != nil {
+				return fmt.Errorf("%s: %w", c.path, err)
+			}
+		case addedOnServer, modifiedOnServer, deletedOnServer:
+			behind++
+		case unchanged:
+			w.agree(c)
+		}
+	}
+	if behind > 0 {
+		fmt.Printf("%d server changes not in this folder; run onword pull to get them\n", behind)
+	}
+	if conflicts > 0 {
+		return errConflicts(conflicts)
+	}
+	return nil
+}
+
+// upload makes the server's copy match the local one. The server may
+// adjust what it stores, a chapter number already taken for one, so the
+// file is rewritten with what came back.
+func (w *workspace) upload(ctx context.Context, c change) error {
+	book := w.state.BookID
+	if c.local == nil {
+		var err error
+		if c.remote.Kind == kindChapter {
+			err = w.client.DeleteChapter(ctx, book, c.remote.ID)
+		} else {
+			err = w.client.DeleteNote(ctx, book, c.remote.ID)
+		}
+		if err != nil && !errors.Is(err, client.ErrNotFound) {
+			return err
+		}
+		delete(w.state.Files, c.id)
+		fmt.Println("deleted", c.path)
+		return nil
+	}
+
+	local := *c.local
+	if c.remote == nil {
+		// new here, or deleted on the server and forced back
+		local.ID = primitive.NilObjectID
+	}
+	var saved document
+	switch {
+	case local.Kind == kindChapter && local.ID.IsZero():
+		created, err := w.client.CreateChapter(ctx, local.chapter(book))
+		if err != nil {
+			return err
+		}
+		saved = fromChapter(*created)
+	case local.Kind == kindChapter:
+		updated, err := w.client.UpdateChapter(ctx, local.chapter(book))
+		if err != nil {
+			return err
+		}
+		saved = fromChapter(*updated)
+	case local.ID.IsZero():
+		created, err := w.client.CreateNote(ctx, local.note(book))
+		if err != nil {
+			return err
+		}
+		saved = fromNote(*created)
+	default:
+		updated, err := w.client.UpdateNote(ctx, local.note(book))
+		if err != nil {
+			return err
+		}
+		saved = fromNote(*updated)
+	}
+
+	if err := writeDocument(w.dir, c.path, saved); err != nil {
+		return err
+	}
+	delete(w.state.Files, c.id)
+	w.settle(c.path, &saved)
+	if local.ID.IsZero() {
+		fmt.Println("created", c.path)
+	} else {
+		fmt.Println("updated", c.path)
+	}
+	return nil
+}
+
+// agree refreshes the record of a document both sides hold the same
+// content of, which also drops records of documents gone from both
+func (w *workspace) agree(c change) {
+	switch {
+	case c.local != nil && c.remote != nil:
+		w.settle(c.path, c.remote)
+	case c.local == nil && c.remote == nil:
+		delete(w.state.Files, c.id)
+	}
+}
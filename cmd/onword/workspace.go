@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stateFile records the last sync in the root of the folder
+const stateFile = ".onword.json"
+
+// folders holds the documents of each kind, relative to the root
+var folders = map[string]string{kindChapter: "chapters", kindNote: "notes"}
+
+var errNoState = errors.New("not an onword folder; run onword pull -book <id> first")
+
+// state is what the folder and the server agreed on at the last sync
+type state struct {
+	Server string             `json:"server"`
+	BookID primitive.ObjectID `json:"bookId"`
+	// Files is keyed by the document's hex ID
+	Files map[string]entry `json:"files"`
+}
+
+type entry struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	// Base is the fingerprint both copies had at the last sync
+	Base string `json:"base"`
+}
+
+func loadState(dir string) (*state, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", stateFile, err)
+	}
+	if s.Files == nil {
+		s.Files = map[string]entry{}
+	}
+	return &s, nil
+}
+
+// save replaces the state file in one step so an interrupted sync never
+// leaves it half written
+func (s *state) save(dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, stateFile+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, stateFile))
+}
+
+// localFile is a document file found in the folder
+type localFile struct {
+	// path is relative to the root, with forward slashes
+	path string
+	doc  document
+}
+
+// scan reads every document file in the folder, sorted by path
+func scan(dir string) ([]localFile, error) {
+	var files []localFile
+	for kind, folder := range folders {
+		paths, err := filepath.Glob(filepath.Join(dir, folder, "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			rel := folder + "/" + filepath.Base(path)
+			doc, err := parseDocument(kind, data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rel, err)
+			}
+			files = append(files, localFile{path: rel, doc: doc})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+func writeDocument(dir, path string, doc document) error {
+	data, err := doc.marshal()
+	if err != nil {
+		return err
+	}
+	full := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(full, data, 0o644)
+}
+
+func removeDocument(dir, path string) error {
+	err := os.Remove(filepath.Join(dir, filepath.FromSlash(path)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// newPath names the file for a document pulled for the first time, e.g.
+// chapters/003-the-storm.md, without reusing a name in the folder
+func newPath(dir string, doc document) string {
+	name := slug(doc.Title)
+	if doc.Kind == kindChapter {
+		name = fmt.Sprintf("%03d-%s", doc.ChapterNum, name)
+	}
+	path := folders[doc.Kind] + "/" + name + ".md"
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path))); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		path = fmt.Sprintf("%s/%s-%d.md", folders[doc.Kind], name, n)
+	}
+}
+
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 48 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "untitled"
+	}
+	return b.String()
+}
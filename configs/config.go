@@ -0,0 +1,276 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration. Values come from the
+// defaults below, then the YAML file, then environment variables.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Trash    TrashConfig    `yaml:"trash"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Limits   LimitsConfig   `yaml:"limits"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr" env:"ONWORD_SERVER_ADDR" validate:"required"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" env:"ONWORD_SERVER_READ_TIMEOUT" validate:"gt=0"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" env:"ONWORD_SERVER_READ_HEADER_TIMEOUT" validate:"gt=0"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" env:"ONWORD_SERVER_WRITE_TIMEOUT" validate:"gt=0"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" env:"ONWORD_SERVER_IDLE_TIMEOUT" validate:"gt=0"`
+	// DrainDelay is how long /readyz fails on SIGTERM before the server
+	// stops accepting connections, giving load balancers time to notice
+	DrainDelay time.Duration `yaml:"drainDelay" env:"ONWORD_SERVER_DRAIN_DELAY" validate:"gte=0"`
+	// ShutdownTimeout bounds how long in-flight requests may drain on SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"ONWORD_SERVER_SHUTDOWN_TIMEOUT" validate:"gt=0"`
+}
+
+type DatabaseConfig struct {
+	URI            string        `yaml:"uri" env:"MONGODB_URI" secret:"true" validate:"required"`
+	Name           string        `yaml:"name" env:"ONWORD_DATABASE_NAME" validate:"required"`
+	ConnectTimeout time.Duration `yaml:"connectTimeout" env:"ONWORD_DATABASE_CONNECT_TIMEOUT" validate:"gt=0"`
+	// ConnectRetries is how many more times a failed startup connection is tried
+	ConnectRetries  int               `yaml:"connectRetries" env:"ONWORD_DATABASE_CONNECT_RETRIES" validate:"gte=0"`
+	RetryBackoff    time.Duration     `yaml:"retryBackoff" env:"ONWORD_DATABASE_RETRY_BACKOFF" validate:"gt=0"`
+	MaxRetryBackoff time.Duration     `yaml:"maxRetryBackoff" env:"ONWORD_DATABASE_MAX_RETRY_BACKOFF" validate:"gtefield=RetryBackoff"`
+	Collections     CollectionsConfig `yaml:"collections"`
+	// MigrationsCollection records applied schema migrations
+	MigrationsCollection string `yaml:"migrationsCollection" env:"ONWORD_DATABASE_MIGRATIONS_COLLECTION" validate:"required"`
+	// AutoMigrate applies pending migrations at startup instead of only warning
+	AutoMigrate bool `yaml:"autoMigrate" env:"ONWORD_DATABASE_AUTO_MIGRATE"`
+}
+
+type CollectionsConfig struct {
+	Users    string `yaml:"users" validate:"required"`
+	Books    string `yaml:"books" validate:"required"`
+	Chapters string `yaml:"chapters" validate:"required"`
+	Images   string `yaml:"images" validate:"required"`
+	Versions string `yaml:"versions" validate:"required"`
+	Notes    string `yaml:"notes" validate:"required"`
+	Blobs    string `yaml:"blobs" validate:"required"`
+	Pages    string `yaml:"pages" validate:"required"`
+	Editions string `yaml:"editions" validate:"required"`
+	Series   string `yaml:"series" validate:"required"`
+
+	DeletionLogs string `yaml:"deletionLogs" validate:"required"`
+}
+
+type StorageConfig struct {
+	UploadDir string `yaml:"uploadDir" env:"ONWORD_STORAGE_UPLOAD_DIR" validate:"required"`
+	// LegacyDirs hold uploads from before content addressing. Garbage
+	// collection deletes from them, so they may not climb out of the
+	// working directory with ".."; give directories elsewhere as absolute
+	// paths.
+	LegacyDirs    []string      `yaml:"legacyDirs" env:"ONWORD_STORAGE_LEGACY_DIRS"`
+	GCInterval    time.Duration `yaml:"gcInterval" env:"ONWORD_STORAGE_GC_INTERVAL" validate:"gte=0"`
+	GCGracePeriod time.Duration `yaml:"gcGracePeriod" env:"ONWORD_STORAGE_GC_GRACE_PERIOD" validate:"gte=0"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted items can be restored before they are purged
+	Retention     time.Duration `yaml:"retention" env:"ONWORD_TRASH_RETENTION" validate:"gt=0"`
+	PurgeInterval time.Duration `yaml:"purgeInterval" env:"ONWORD_TRASH_PURGE_INTERVAL" validate:"gte=0"`
+}
+
+type AuthConfig struct {
+	// JWTSecret signs tokens; when empty a random one is generated at startup
+	JWTSecret string        `yaml:"jwtSecret" env:"JWT_SECRET" secret:"true"`
+	TokenTTL  time.Duration `yaml:"tokenTTL" env:"ONWORD_AUTH_TOKEN_TTL" validate:"gt=0"`
+}
+
+type LimitsConfig struct {
+	RequestTimeout time.Duration `yaml:"requestTimeout" env:"ONWORD_LIMITS_REQUEST_TIMEOUT" validate:"gt=0"`
+	MaxUploadBytes int64         `yaml:"maxUploadBytes" env:"ONWORD_LIMITS_MAX_UPLOAD_BYTES" validate:"gt=0"`
+}
+
+// Default returns the values OnWord has always run with
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":3000",
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			DrainDelay:        5 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Name:            "OnWord",
+			ConnectTimeout:  10 * time.Second,
+			ConnectRetries:  5,
+			RetryBackoff:    time.Second,
+			MaxRetryBackoff: 30 * time.Second,
+			Collections: CollectionsConfig{
+				Users:    "UserDetails",
+				Books:    "BookDetails",
+				Chapters: "Chapters",
+				Images:   "Images",
+				Versions: "Versions",
+				Notes:    "Notes",
+				Blobs:    "Blobs",
+				Pages:    "Pages",
+				Editions: "Editions",
+				Series:   "Series",
+
+				DeletionLogs: "DeletionLog",
+			},
+			MigrationsCollection: "Migrations",
+		},
+		Storage: StorageConfig{
+			UploadDir:     "uploads",
+			LegacyDirs:    []string{"cover-images"},
+			GCGracePeriod: time.Hour,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Auth: AuthConfig{TokenTTL: 24 * time.Hour},
+		Limits: LimitsConfig{
+			RequestTimeout: 10 * time.Second,
+			MaxUploadBytes: 10 << 20,
+		},
+	}
+}
+
+// Read reads the YAML file at path, when given, and applies environment
+// overrides. The result is checked with Validate.
+func Read(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks every value is one the server can run with
+func (c *Config) Validate() error {
+	if err := validator.New().Struct(c); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	for _, dir := range c.Storage.LegacyDirs {
+		if climbs(dir) {
+			return fmt.Errorf("invalid configuration: storage.legacyDirs: %q leaves the working directory; give an absolute path instead", dir)
+		}
+	}
+	return nil
+}
+
+// climbs reports whether path has a ".." element
+func climbs(path string) bool {
+	for _, element := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == os.PathSeparator }) {
+		if element == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy with every secret field masked
+func (c *Config) Redacted() *Config {
+	copied := *c
+	copied.Storage.LegacyDirs = append([]string(nil), c.Storage.LegacyDirs...)
+	redact(reflect.ValueOf(&copied).Elem())
+	return &copied
+}
+
+// YAML renders the configuration the same way it is read
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			redact(field)
+			continue
+		}
+		if v.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString("[redacted]")
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides fields tagged with env from the environment
+func applyEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := v.Type().Field(i).Tag.Get("env")
+		value, ok := os.LookupEnv(name)
+		if name == "" || !ok {
+			continue
+		}
+
+		switch {
+		case field.Type() == durationType:
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetInt(int64(d))
+		case field.Kind() == reflect.String:
+			field.SetString(value)
+		case field.Kind() == reflect.Int64 || field.Kind() == reflect.Int:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetInt(n)
+		case field.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetBool(b)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("%s: unsupported setting type %s", name, field.Type())
+		}
+	}
+	return nil
+}
@@ -4,42 +4,53 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func ConnectDB() *mongo.Client {
-	client, err := mongo.NewClient(options.Client().ApplyURI(EnvMongoURI()))
-	if err != nil {
-		log.Fatal(err)
-	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
+// ConnectDB connects to MongoDB, retrying with exponential backoff while the
+// server is unreachable. It gives up after cfg.ConnectRetries retries or when
+// ctx is cancelled.
+func ConnectDB(ctx context.Context, cfg DatabaseConfig) (*mongo.Client, error) {
+	backoff := cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		client, err := connect(ctx, cfg)
+		if err == nil {
+			fmt.Println("Connected to MongoDB")
+			return client, nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return nil, fmt.Errorf("connecting to MongoDB: %w", err)
+		}
 
-	//ping the database
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatal(err)
+		log.Printf("MongoDB unavailable (%v), retrying in %s\n", err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > cfg.MaxRetryBackoff {
+			backoff = cfg.MaxRetryBackoff
+		}
 	}
-	fmt.Println("Connected to MongoDB")
-	return client
 }
 
-func EnvMongoURI() string {
-	return os.Getenv("MONGODB_URI")
-}
+func connect(ctx context.Context, cfg DatabaseConfig) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
 
-//Client instance
-var DB *mongo.Client = ConnectDB()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI).SetServerSelectionTimeout(cfg.ConnectTimeout))
+	if err != nil {
+		return nil, err
+	}
 
-//getting database collections
-func GetCollection(client *mongo.Client, dbName, collectionName string) *mongo.Collection {
-    collection := client.Database(dbName).Collection(collectionName)
-    return collection
+	//ping the database
+	if err = client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return client, nil
 }
@@ -1,213 +1,794 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"log"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
-	"time"
+	"unicode"
 
+	"github.com/programmingbunny/epub-backend/configs"
 	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/docx"
+	"github.com/programmingbunny/epub-backend/epub"
+	"github.com/programmingbunny/epub-backend/isbn"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
 	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/onix"
 	"github.com/programmingbunny/epub-backend/responses"
+	"github.com/programmingbunny/epub-backend/site"
+	"github.com/programmingbunny/epub-backend/typeset"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var validate = validator.New()
+// Controller serves books and their chapter header images on the v1 routes
+type Controller struct {
+	library *library.Service
+	limits  configs.LimitsConfig
+}
+
+func New(library *library.Service, limits configs.LimitsConfig) *Controller {
+	return &Controller{library: library, limits: limits}
+}
 
-func CreateBook() http.HandlerFunc {
-    return func(rw http.ResponseWriter, r *http.Request) {
-        r.ParseMultipartForm(10 << 20)
+func (c *Controller) CreateBook() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		defer cancel()
+		r.ParseMultipartForm(c.limits.MaxUploadBytes)
 
-        var book models.Book
-        err := json.NewDecoder(r.Body).Decode(&book)
-        if err != nil {
-            fmt.Println("Error decoding request body:", err)
-            rw.WriteHeader(http.StatusBadRequest)
-            json.NewEncoder(rw).Encode(map[string]string{"error": "Invalid request body"})
-            return
-        }
+		var book models.Book
+		if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "Invalid request body"))
+			return
+		}
 
-        if file, _, err := r.FormFile("bookPic"); err == nil {
-            defer file.Close()
+		var cover io.Reader
+		if file, _, err := r.FormFile("bookPic"); err == nil {
+			defer file.Close()
+			cover = file
+		}
 
-            tempFile, err := ioutil.TempFile("cover-images", "upload-*.png")
-            if err != nil {
-                fmt.Println(err)
-            }
-            defer tempFile.Close()
+		created, err := c.library.CreateBook(ctx, book, middleware.ActorFromContext(r.Context()), cover)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 
-            fileBytes, err := ioutil.ReadAll(file)
-            if err != nil {
-                fmt.Println(err)
-            }
-            tempFile.Write(fileBytes)
+		json.NewEncoder(rw).Encode(created.ID) // return the //mongodb ID of generated document
+	}
+}
 
-            book.BookCover = forwardSlash(tempFile.Name())
-        }
+func (c *Controller) GetABook() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		params := mux.Vars(r)
+		bookId := params["bookId"]
+		defer cancel()
 
-        err = validate.Struct(book)
-        if err != nil {
-            fmt.Println("Validation error:", err)
-            rw.WriteHeader(http.StatusBadRequest)
-            json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
-            return
-        }
+		objId, _ := primitive.ObjectIDFromHex(bookId)
 
-        insertResult, err := db.InsertBook(context.Background(), book)
-        if err != nil {
-            fmt.Println(err)
-            return
-        }
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 
-        json.NewEncoder(rw).Encode(insertResult.InsertedID) // return the //mongodb ID of generated document
-    }
+		rw.WriteHeader(http.StatusOK)
+		json.NewEncoder(rw).Encode(book)
+	}
 }
 
+// GetBooks lists the books the caller may read, a page at a time
+func (c *Controller) GetBooks() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		defer cancel()
+
+		query, err := db.BookSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 
+		page, err := c.library.Books(ctx, query, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 
+		response, err := responses.Page(page.Items, page.NextCursor, query.Select)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		json.NewEncoder(rw).Encode(response)
+	}
+}
 
-func GetABook() http.HandlerFunc {
+// DeleteBook moves a book and everything belonging to it to the trash
+func (c *Controller) DeleteBook() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		params := mux.Vars(r)
-		bookId := params["bookId"]
-		var book models.Book
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		defer cancel()
 
-		objId, _ := primitive.ObjectIDFromHex(bookId)
+		objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+
+		err = c.library.TrashBook(ctx, objectID, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "book moved to trash"}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
 
-		err := db.BookCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&book)
+// importedChapter is a chapter of an import preview
+type importedChapter struct {
+	Title   string `json:"title"`
+	Words   int    `json:"words"`
+	Images  int    `json:"images"`
+	Excerpt string `json:"excerpt"`
+}
+
+// importPreview is the split an import would make, with the paragraph
+// styles the document uses to pick another one
+type importPreview struct {
+	Title    string            `json:"title,omitempty"`
+	Chapters []importedChapter `json:"chapters"`
+	Styles   []docx.Style      `json:"styles"`
+}
 
+// ImportDocx adds the chapters of an uploaded Word document to the book.
+// The multipart form carries the document as "file". The document is
+// split at every "splitStyle" paragraph, Heading 1 by default, or at the
+// paragraphs matching "splitPattern". With "preview" set the proposed
+// chapters are returned without storing anything.
+func (c *Controller) ImportDocx() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
+			responses.WriteError(rw, err)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(rw, r.Body, c.limits.MaxUploadBytes)
+		if err := r.ParseMultipartForm(c.limits.MaxUploadBytes); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				responses.WriteError(rw, responses.NewProblem(http.StatusRequestEntityTooLarge, responses.CodeInvalidBody, "the document is too large"))
+				return
+			}
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "a multipart form is required"))
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "file is required"))
+			return
+		}
+		defer file.Close()
+
+		opts := docx.Options{SplitStyle: r.FormValue("splitStyle")}
+		if pattern := r.FormValue("splitPattern"); pattern != "" {
+			if opts.SplitPattern, err = regexp.Compile(pattern); err != nil {
+				responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "splitPattern: "+err.Error()))
+				return
+			}
+		}
+		doc, err := docx.Read(file, header.Size, opts)
+		if errors.Is(err, docx.ErrNotDocx) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusUnprocessableEntity, responses.CodeUnprocessableDocument, err.Error()))
+			return
+		}
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+
+		if preview, _ := strconv.ParseBool(r.FormValue("preview")); preview {
+			proposed := importPreview{Title: doc.Title, Chapters: []importedChapter{}, Styles: doc.Styles}
+			for _, chapter := range doc.Chapters {
+				proposed.Chapters = append(proposed.Chapters, importedChapter{Title: chapter.Title, Words: chapter.Words, Images: len(chapter.Images), Excerpt: chapter.Excerpt})
+			}
+			rw.WriteHeader(http.StatusOK)
+			response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": proposed}}
 			json.NewEncoder(rw).Encode(response)
 			return
 		}
 
+		created, err := c.library.ImportChapters(ctx, book.ID, doc, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+
+		rw.WriteHeader(http.StatusCreated)
+		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": created}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// ExportDocx serves the book as a Word manuscript in Standard Manuscript
+// Format. "style=modern" sets it in Times New Roman with italics, and
+// "from" and "to" limit it to a range of chapter numbers.
+func (c *Controller) ExportDocx() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		var opts library.ManuscriptOptions
+		for name, value := range map[string]*int{"from": &opts.From, "to": &opts.To} {
+			if raw := r.URL.Query().Get(name); raw != "" {
+				if *value, err = strconv.Atoi(raw); err != nil || *value < 1 {
+					writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, name+" must be a chapter number"))
+					return
+				}
+			}
+		}
+		if opts.From > 0 && opts.To > 0 && opts.From > opts.To {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, "from must not come after to"))
+			return
+		}
+
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		edition, err := c.edition(ctx, r, book)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		style := r.URL.Query().Get("style")
+		if style == "" && edition != nil {
+			style = edition.Settings.ManuscriptStyle
+		}
+		opts.Modern, opts.Edition = style == "modern", edition
+		manuscript, err := c.library.Manuscript(ctx, book, opts)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		var out bytes.Buffer
+		if err := docx.WriteManuscript(&out, *manuscript); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		rw.Header().Set("Content-Type", docx.ContentType)
+		rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exportName(book, edition) + ".docx"}))
 		rw.WriteHeader(http.StatusOK)
-		json.NewEncoder(rw).Encode(book)
+		rw.Write(out.Bytes())
+	}
+}
+
+// ExportEPUB writes a book, or one of its ebook editions, as an EPUB:
+// pre-paginated for a fixed-layout book, and otherwise a chapter per
+// document ending with its back matter unless backMatter=false
+func (c *Controller) ExportEPUB() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		edition, err := c.edition(ctx, r, book)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		if edition != nil && edition.Print() {
+			writeError(rw, r, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "only ebook editions can be exported as EPUB"))
+			return
+		}
+		withBackMatter, err := backMatter(r)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		var out bytes.Buffer
+		if err := c.writeEPUB(ctx, &out, book, library.ExportOptions{Edition: edition, BackMatter: withBackMatter}); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		rw.Header().Set("Content-Type", epub.ContentType)
+		rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exportName(book, edition) + ".epub"}))
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(out.Bytes())
+	}
+}
+
+// writeEPUB writes a book as the EPUB its layout calls for
+func (c *Controller) writeEPUB(ctx context.Context, w io.Writer, book *models.Book, opts library.ExportOptions) error {
+	if book.Layout == models.LayoutFixed {
+		publication, err := c.library.FixedLayout(ctx, book, opts.Edition)
+		if err != nil {
+			return err
+		}
+		if len(publication.Pages) == 0 {
+			return responses.NewProblem(http.StatusConflict, responses.CodeConflict, "the book has no pages")
+		}
+		return epub.WriteFixedLayout(w, *publication)
+	}
+	publication, err := c.library.Reflowable(ctx, book, opts)
+	if err != nil {
+		return err
+	}
+	if len(publication.Chapters) == 0 && len(publication.Parts) == 0 {
+		return responses.NewProblem(http.StatusConflict, responses.CodeConflict, "the book has no chapters")
+	}
+	return epub.WriteReflowable(w, *publication)
+}
+
+// ExportONIX answers with the book's metadata as an ONIX 3.0 message,
+// listing every edition of the book or the one the edition query names
+func (c *Controller) ExportONIX() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		edition, err := c.edition(ctx, r, book)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		var out bytes.Buffer
+		if edition != nil {
+			err = c.library.WriteEditionONIX(ctx, &out, book, edition)
+		} else {
+			err = c.library.WriteONIX(ctx, &out, []models.Book{*book})
+		}
+		writeONIX(rw, r, &out, err)
 	}
 }
 
-func DeleteBook() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Extract the book ID from the URL path
-		bookID := mux.Vars(r)["bookId"]
-		objectID, err := primitive.ObjectIDFromHex(bookID)
+// ExportCatalog answers with every book the caller may read that matches
+// the query as one ONIX 3.0 message
+func (c *Controller) ExportCatalog() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		query, err := db.BookSchema.Parse(r.URL.Query())
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		books, err := c.library.Catalog(ctx, query, middleware.ActorFromContext(r.Context()))
 		if err != nil {
-			http.Error(w, "Invalid book ID", http.StatusBadRequest)
+			writeError(rw, r, err)
 			return
 		}
+		var out bytes.Buffer
+		err = c.library.WriteONIX(ctx, &out, books)
+		writeONIX(rw, r, &out, err)
+	}
+}
+
+func writeONIX(rw http.ResponseWriter, r *http.Request, out *bytes.Buffer, err error) {
+	if err != nil {
+		writeError(rw, r, err)
+		return
+	}
+	rw.Header().Set("Content-Type", onix.ContentType)
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(out.Bytes())
+}
 
-		// Delete all notes associated with the book
-		notesFilter := bson.M{"bookID": objectID}
-		notesResult, err := db.NoteCollection.DeleteMany(context.Background(), notesFilter)
+// ExportSite renders the book as a static website zip for publishing at
+// the baseURL query, ending with its back matter unless backMatter=false
+func (c *Controller) ExportSite() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
 		if err != nil {
-			http.Error(w, "Failed to delete notes", http.StatusInternalServerError)
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		baseURL, err := url.Parse(r.URL.Query().Get("baseURL"))
+		if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, "baseURL must be an absolute http or https URL"))
 			return
 		}
-		fmt.Printf("Deleted %v notes\n", notesResult.DeletedCount)
 
-		// Delete all chapters associated with the book
-		chaptersFilter := bson.M{"bookID": objectID}
-		chaptersResult, err := db.ChapterCollection.DeleteMany(context.Background(), chaptersFilter)
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		edition, err := c.edition(ctx, r, book)
 		if err != nil {
-			http.Error(w, "Failed to delete chapters", http.StatusInternalServerError)
+			writeError(rw, r, err)
 			return
 		}
-		fmt.Printf("Deleted %v chapters\n", chaptersResult.DeletedCount)
+		withBackMatter, err := backMatter(r)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		s, err := c.library.Site(ctx, book, library.ExportOptions{Edition: edition, BackMatter: withBackMatter}, baseURL.String())
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		var out bytes.Buffer
+		if err := site.Write(&out, s); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		rw.Header().Set("Content-Type", site.ContentType)
+		rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exportName(book, edition) + "-site.zip"}))
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(out.Bytes())
+	}
+}
+
+// ExportPDF lays out the book as a print-ready interior, as the edition
+// query prints it if given. The trim query picks the page size and the
+// margins and gutter can be given in inches, overriding the edition's.
+// The back matter is left out with backMatter=false.
+func (c *Controller) ExportPDF() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
 
-		// Update all chapters with header images associated with the book
-		headerImageErr := db.UpdateChapterWithHeaderImage("", objectID.Hex(), -1)
-		if headerImageErr != nil {
-			http.Error(w, "Failed to update chapter header images", http.StatusInternalServerError)
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
+			return
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		edition, err := c.edition(ctx, r, book)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		if edition != nil && !edition.Print() {
+			writeError(rw, r, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "only print editions have an interior"))
+			return
+		}
+		trimName := r.URL.Query().Get("trim")
+		if trimName == "" && edition != nil {
+			trimName = edition.Settings.Trim
+		}
+		if trimName == "" {
+			trimName = typeset.DefaultTrim
+		}
+		trim, ok := typeset.TrimSizes[trimName]
+		if !ok {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, "trim must be 5x8, 5.5x8.5 or 6x9"))
+			return
+		}
+		layout := library.PrintLayout(trim, edition)
+		margins := map[string]*float64{"top": &layout.Top, "bottom": &layout.Bottom, "inside": &layout.Inside, "outside": &layout.Outside, "gutter": &layout.Gutter}
+		for name, value := range margins {
+			if raw := r.URL.Query().Get(name); raw != "" {
+				if *value, err = strconv.ParseFloat(raw, 64); err != nil {
+					writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, name+" must be a number of inches"))
+					return
+				}
+			}
+		}
+		if err := layout.Validate(); err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, err.Error()))
 			return
 		}
 
-		// Delete the book itself
-		bookFilter := bson.M{"_id": objectID}
-		bookResult, err := db.BookCollection.DeleteOne(context.Background(), bookFilter)
+		withBackMatter, err := backMatter(r)
 		if err != nil {
-			http.Error(w, "Failed to delete book", http.StatusInternalServerError)
+			writeError(rw, r, err)
+			return
+		}
+		interior, err := c.library.Interior(ctx, book, library.ExportOptions{Edition: edition, BackMatter: withBackMatter})
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		var out bytes.Buffer
+		if err := typeset.Write(&out, interior, layout); err != nil {
+			writeError(rw, r, err)
 			return
 		}
 
-		// Return the number of items deleted
-		fmt.Fprintf(w, "Deleted %v book, %v chapters, and %v notes", bookResult.DeletedCount, chaptersResult.DeletedCount, notesResult.DeletedCount)
+		rw.Header().Set("Content-Type", typeset.ContentType)
+		rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exportName(book, edition) + "-" + trimName + ".pdf"}))
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(out.Bytes())
 	}
 }
 
+// coverRequest describes the paperback a cover wrap is made for. Pages
+// left out are counted by laying out the book's interior.
+type coverRequest struct {
+	Trim     string `json:"trim" validate:"omitempty,oneof=5x8 5.5x8.5 6x9"`
+	Pages    int    `json:"pages" validate:"omitempty,min=1,max=1000"`
+	Paper    string `json:"paper" validate:"omitempty,oneof=white cream color"`
+	Blurb    string `json:"blurb" validate:"max=5000"`
+	ISBN     string `json:"isbn" validate:"omitempty,isbn"`
+	Format   string `json:"format" validate:"omitempty,oneof=pdf png json"`
+	Template bool   `json:"template"`
+	Guides   bool   `json:"guides"`
+}
+
+// coverSize is the answer to a cover request for json, in inches
+type coverSize struct {
+	Pages  int     `json:"pages"`
+	Paper  string  `json:"paper"`
+	Spine  float64 `json:"spine"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Bleed  float64 `json:"bleed"`
+}
 
-func CreateChapterHeader() http.HandlerFunc {
+// CoverWrap draws the full paperback cover, back, spine and front, sized
+// for the page count and paper. It answers with a PDF, a PNG or, for
+// json, only the measurements. Counted pages include the back matter
+// unless backMatter=false.
+func (c *Controller) CoverWrap() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		r.ParseMultipartForm(10 << 20)
-		file, _, err := r.FormFile("imageLocation")
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
 		if err != nil {
-			fmt.Println("error while getting the File")
-			fmt.Println(err)
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid book ID"))
+			return
+		}
+		var req coverRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "Invalid request body"))
+			return
+		}
+		if err := library.Validate.Struct(req); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		if req.ISBN != "" {
+			req.ISBN, _ = isbn.Parse(req.ISBN)
+		}
+
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "book not found"))
 			return
 		}
-		defer file.Close()
-		tempFile, err := ioutil.TempFile("../chapter-images", "upload-*.png")
 		if err != nil {
-			fmt.Println(err)
+			writeError(rw, r, err)
+			return
+		}
+		edition, err := c.edition(ctx, r, book)
+		if err != nil {
+			writeError(rw, r, err)
+			return
 		}
-		defer tempFile.Close()
-		fileBytes, err := ioutil.ReadAll(file)
+		if edition != nil {
+			if !edition.Print() {
+				writeError(rw, r, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "only print editions have a cover wrap"))
+				return
+			}
+			if req.Trim == "" {
+				req.Trim = edition.Settings.Trim
+			}
+			if req.Paper == "" {
+				req.Paper = edition.Settings.Paper
+			}
+			if req.ISBN == "" {
+				req.ISBN = edition.Identifier(models.IdentifierISBN13)
+			}
+		}
+		if req.Trim == "" {
+			req.Trim = typeset.DefaultTrim
+		}
+		if req.Paper == "" {
+			req.Paper = typeset.DefaultPaper
+		}
+		withBackMatter, err := backMatter(r)
 		if err != nil {
-			fmt.Println(err)
+			writeError(rw, r, err)
+			return
+		}
+		cover, err := c.library.Cover(ctx, book, library.ExportOptions{Edition: edition, BackMatter: withBackMatter}, typeset.Cover{
+			Trim:     typeset.TrimSizes[req.Trim],
+			Pages:    req.Pages,
+			Paper:    req.Paper,
+			Blurb:    req.Blurb,
+			ISBN:     req.ISBN,
+			Template: req.Template,
+			Guides:   req.Guides,
+		})
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		if err := cover.Validate(); err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusUnprocessableEntity, responses.CodeValidationFailed, err.Error()))
+			return
 		}
-		tempFile.Write(fileBytes)
 
-		pass := r.FormValue("bookID")
+		var out bytes.Buffer
+		contentType, extension := typeset.ContentType, ".pdf"
+		switch req.Format {
+		case "json":
+			width, height := cover.Size()
+			size := coverSize{Pages: cover.Pages, Paper: cover.Paper, Spine: cover.Spine(), Width: width, Height: height, Bleed: typeset.Bleed}
+			rw.WriteHeader(http.StatusOK)
+			response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": size}}
+			json.NewEncoder(rw).Encode(response)
+			return
+		case "png":
+			contentType, extension = "image/png", ".png"
+			err = typeset.WriteCoverPNG(&out, cover)
+		default:
+			err = typeset.WriteCoverPDF(&out, cover)
+		}
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		rw.Header().Set("Content-Type", contentType)
+		rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exportName(book, edition) + "-cover" + extension}))
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(out.Bytes())
+	}
+}
+
+func (c *Controller) CreateChapterHeader() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		defer cancel()
+		r.ParseMultipartForm(c.limits.MaxUploadBytes)
+
+		file, _, err := r.FormFile("imageLocation")
+		if err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "imageLocation file is required"))
+			return
+		}
+		defer file.Close()
 
-		newImage := models.ChapterImages{
-			BookID:        stringToPrimitive(r.FormValue("bookID")),
-			ChapterNum:    stringToInt(r.FormValue("chapterNum")),
-			ImageLocation: forwardSlash(tempFile.Name()),
-			Type:          r.FormValue("type"),
+		image := models.ChapterImages{
+			BookID:     stringToPrimitive(r.FormValue("bookID")),
+			ChapterNum: stringToInt(r.FormValue("chapterNum")),
+			Type:       r.FormValue("type"),
+		}
+		created, err := c.library.CreateImage(ctx, image, file, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
 		}
 
-		insertResult, err := db.InsertImage(context.TODO(), newImage)
+		json.NewEncoder(rw).Encode(created.ID) // return the //mongodb ID of generated document
+	}
+}
+
+// DeleteChapterHeader moves an uploaded chapter image to the trash
+func (c *Controller) DeleteChapterHeader() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["imageId"])
 		if err != nil {
-			log.Fatal(err)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid image ID"))
+			return
 		}
 
-		err = db.UpdateChapterWithHeaderImage(newImage.ImageLocation, pass, newImage.ChapterNum)
+		err = c.library.TrashImage(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "image not found"))
+			return
+		}
 		if err != nil {
-			fmt.Println(err)
+			responses.WriteError(rw, err)
+			return
 		}
-		json.NewEncoder(rw).Encode(insertResult.InsertedID) // return the //mongodb ID of generated document
+
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "image moved to trash"}}
+		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func GetChapterHeader() http.HandlerFunc {
+func (c *Controller) GetChapterHeader() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		bookId := params["bookId"]
-		chNum := params["chNum"]
-		var imageLoc models.ChapterImages
+		chNum := params["chapterId"]
 		defer cancel()
 		objId, _ := primitive.ObjectIDFromHex(bookId)
 		chapterNum, _ := strconv.Atoi(chNum)
 
-		err := db.ImageCollection.FindOne(ctx, bson.M{"bookID": objId, "chapterNum": chapterNum}).Decode(&imageLoc)
-
+		imageLoc, err := c.library.ChapterImage(ctx, objId, chapterNum)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -216,9 +797,65 @@ func GetChapterHeader() http.HandlerFunc {
 	}
 }
 
-func forwardSlash(pathName string) string {
-	replace := strings.ReplaceAll(pathName, `\`, `/`)
-	return replace
+// writeError answers with problem details on v2, which shares the exports,
+// and in the v1 envelope otherwise
+func writeError(rw http.ResponseWriter, r *http.Request, err error) {
+	if responses.WantsProblem(r) {
+		responses.WriteProblem(rw, r, err)
+		return
+	}
+	responses.WriteError(rw, err)
+}
+
+// edition returns the one of the book's editions the edition query names,
+// or nil when there is none
+func (c *Controller) edition(ctx context.Context, r *http.Request, book *models.Book) (*models.Edition, error) {
+	raw := r.URL.Query().Get("edition")
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(raw)
+	if err != nil {
+		return nil, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid edition ID")
+	}
+	edition, err := c.library.Edition(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || err == nil && edition.BookID != book.ID {
+		return nil, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "edition not found")
+	}
+	return edition, err
+}
+
+// backMatter reads the backMatter query. Exports end with the pages
+// library.BackMatter writes unless it is false.
+func backMatter(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("backMatter")
+	if raw == "" {
+		return true, nil
+	}
+	on, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, "backMatter must be true or false")
+	}
+	return on, nil
+}
+
+// exportName names an exported file after the book and its edition
+func exportName(book *models.Book, edition *models.Edition) string {
+	if edition == nil {
+		return fileName(book.Title)
+	}
+	return fileName(book.Title + " " + edition.Name)
+}
+
+// fileName makes a download name from a title
+func fileName(title string) string {
+	name := strings.Join(strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+	if name == "" {
+		return "manuscript"
+	}
+	return name
 }
 
 func stringToInt(input string) int {
@@ -3,134 +3,103 @@ package chapters
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"github.com/go-playground/validator/v10"
+	"errors"
+	"net/http"
+
 	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
 	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
 	"github.com/programmingbunny/epub-backend/models"
 	"github.com/programmingbunny/epub-backend/responses"
-	"net/http"
-	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var validate = validator.New()
+// Controller serves the chapters of a book on the v1 routes
+type Controller struct {
+	library *library.Service
+	limits  configs.LimitsConfig
+}
+
+func New(library *library.Service, limits configs.LimitsConfig) *Controller {
+	return &Controller{library: library, limits: limits}
+}
 
-func CreateChapter() http.HandlerFunc {
+func (c *Controller) CreateChapter() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		var chapter models.Chapter
 		defer cancel()
 
 		//validate the request body
 		if err := json.NewDecoder(r.Body).Decode(&chapter); err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
 
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&chapter); validationErr != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}}
-			json.NewEncoder(rw).Encode(response)
-			return
-		}
-
-		newChapter := models.Chapter{
-			Title:      chapter.Title,
-			ChapterNum: chapter.ChapterNum,
-			Text:       chapter.Text,
-			BookID:     chapter.BookID,
-			VersionID:  chapter.VersionID,
-		}
-
-		nums, err := getBookNumbers(newChapter.BookID)
-		if err != nil {
-			fmt.Println(err)
+		created, err := c.library.CreateChapter(ctx, chapter, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrDuplicate) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "chapter number is already taken"))
 			return
 		}
-
-		for i := range nums {
-			if newChapter.ChapterNum == nums[i] {
-				newChapter.ChapterNum = newChapter.ChapterNum + 1
-			}
-		}
-
-		result, err := db.InsertChapter(ctx, newChapter)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
 		rw.WriteHeader(http.StatusCreated)
-		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": result}}
+		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": db.InsertResult{InsertedID: created.ID}}}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func GetAllChapters() http.HandlerFunc {
+// GetAllChapters lists a book's chapters a page at a time, in chapter order
+// unless the query asks otherwise
+func (c *Controller) GetAllChapters() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		bookId := params["bookId"]
-		var chapters []models.Chapter
 		defer cancel()
 
 		objId, _ := primitive.ObjectIDFromHex(bookId)
 
-		results, err := db.ChapterCollection.Find(ctx, bson.M{"bookID": objId})
-
+		query, err := db.ChapterSchema.Parse(r.URL.Query())
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
-		//reading from the db in an optimal way
-		defer results.Close(ctx)
-		for results.Next(ctx) {
-			var singleChapter models.Chapter
-			if err = results.Decode(&singleChapter); err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-				json.NewEncoder(rw).Encode(response)
-			}
-
-			chapters = append(chapters, singleChapter)
+		page, err := c.library.Chapters(ctx, objId, query)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
 		}
 
-		var everyChapter models.Chapters
-		everyChapter.Chapters = append(everyChapter.Chapters, chapters...)
-		everyChapter.BookID = objId
-
+		response, err := responses.Page(page.Items, page.NextCursor, query.Select)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 		rw.WriteHeader(http.StatusOK)
-		json.NewEncoder(rw).Encode(everyChapter)
+		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func GetSingleChapter() http.HandlerFunc {
+func (c *Controller) GetSingleChapter() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		chapterId := params["chapterId"]
-		var chapter models.Chapter
 		defer cancel()
 
 		objId, _ := primitive.ObjectIDFromHex(chapterId)
 
-		err := db.ChapterCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&chapter)
-
+		chapter, err := c.library.Chapter(ctx, objId)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -139,85 +108,45 @@ func GetSingleChapter() http.HandlerFunc {
 	}
 }
 
-func UpdateChapter() http.HandlerFunc {
+func (c *Controller) UpdateChapter() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		chapterId := params["chapterId"]
-		var existingChapter models.Chapter
 		defer cancel()
 
 		objId, err := primitive.ObjectIDFromHex(chapterId)
 		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, err.Error()))
 			return
 		}
 
-		err = db.ChapterCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&existingChapter)
-		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		var updatedChapter models.Chapter
+		if err := json.NewDecoder(r.Body).Decode(&updatedChapter); err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
 
-		// Update the existing chapter
-		var updatedChapter models.Chapter
-		err = json.NewDecoder(r.Body).Decode(&updatedChapter)
-		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		chapter, err := c.library.UpdateChapter(ctx, objId, updatedChapter, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrDuplicate) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "chapter number is already taken"))
 			return
 		}
-
-		existingChapter.Title = updatedChapter.Title
-		existingChapter.Text = updatedChapter.Text
-		existingChapter.ChapterNum = updatedChapter.ChapterNum
-
-		// Save the updated chapter to the database
-		_, err = db.UpdateChapterByID(ctx, objId, existingChapter)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
 		// Send a success response back to the client
 		rw.WriteHeader(http.StatusOK)
-		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": existingChapter}}
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": chapter}}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func getBookNumbers(bookId primitive.ObjectID) ([]int, error) {
-	var getNumbers []int
-
-	results, err := db.ChapterCollection.Find(context.TODO(), bson.M{"bookID": bookId})
-	if err != nil {
-		return nil, err
-	}
-
-	for results.Next(context.TODO()) {
-		var singleChapter models.Chapter
-		err = results.Decode(&singleChapter)
-		if err != nil {
-			return nil, err
-		}
-		getNumbers = append(getNumbers, singleChapter.ChapterNum)
-	}
-
-	results.Close(context.TODO())
-
-	return getNumbers, nil
-}
-
-func DeleteChapter() http.HandlerFunc {
+func (c *Controller) DeleteChapter() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		defer cancel()
 
 		params := mux.Vars(r)
@@ -225,29 +154,23 @@ func DeleteChapter() http.HandlerFunc {
 
 		objId, err := primitive.ObjectIDFromHex(chapterId)
 		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, err.Error()))
 			return
 		}
 
-		result, err := db.DeleteChapterByID(ctx, objId)
-		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		// the chapter goes to the trash and gives up its number until restored
+		err = c.library.TrashChapter(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "chapter not found"))
 			return
 		}
-
-		if result.DeletedCount == 0 {
-			rw.WriteHeader(http.StatusNotFound)
-			response := responses.Response{Status: http.StatusNotFound, Message: "error", Data: map[string]interface{}{"data": "chapter not found"}}
-			json.NewEncoder(rw).Encode(response)
+		if err != nil {
+			responses.WriteError(rw, err)
 			return
 		}
 
 		rw.WriteHeader(http.StatusOK)
-		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "chapter deleted successfully"}}
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": "chapter moved to trash"}}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
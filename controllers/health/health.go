@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// Controller answers liveness and readiness probes
+type Controller struct {
+	store    *db.Store
+	draining atomic.Bool
+}
+
+func New(store *db.Store) *Controller {
+	return &Controller{store: store}
+}
+
+// Drain makes readiness fail so load balancers stop routing new requests
+// while the server shuts down
+func (c *Controller) Drain() {
+	c.draining.Store(true)
+}
+
+// Healthz reports that the process is up and serving HTTP
+func (c *Controller) Healthz() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"status": "ok"}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// Readyz reports whether the database is reachable and the server is
+// accepting new work
+func (c *Controller) Readyz() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		checks := map[string]interface{}{"database": "ok"}
+		status := http.StatusOK
+
+		if err := c.store.Health.Ping(ctx); err != nil {
+			checks["database"] = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+		if c.draining.Load() {
+			checks["server"] = "shutting down"
+			status = http.StatusServiceUnavailable
+		}
+
+		message := "success"
+		if status != http.StatusOK {
+			message = "error"
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.WriteHeader(status)
+		response := responses.Response{Status: status, Message: message, Data: checks}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
@@ -0,0 +1,293 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/imaging"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+	"github.com/programmingbunny/epub-backend/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Controller streams uploaded images. The handlers serve both API
+// versions, errors are written as problem details on v2.
+type Controller struct {
+	library *library.Service
+	limits  configs.LimitsConfig
+}
+
+func New(library *library.Service, limits configs.LimitsConfig) *Controller {
+	return &Controller{library: library, limits: limits}
+}
+
+// ServeImage streams an uploaded chapter image by its document ID. When
+// the route names a book the image must belong to it.
+func (c *Controller) ServeImage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["imageId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "invalid image ID"))
+			return
+		}
+
+		image, err := c.library.Image(ctx, objId)
+		if err == nil && !inBook(r, image.BookID) {
+			err = db.ErrNotFound
+		}
+		if err != nil {
+			writeLookupError(rw, r, err, "image not found")
+			return
+		}
+
+		// private books are reported as missing rather than forbidden so
+		// their existence isn't leaked
+		book, err := c.library.Book(ctx, image.BookID, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			writeLookupError(rw, r, err, "image not found")
+			return
+		}
+
+		serveFile(rw, r, image.ImageLocation, book.Visibility == models.VisibilityPrivate)
+	}
+}
+
+// GetImages lists a book's chapter images a page at a time
+func (c *Controller) GetImages() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "invalid book ID"))
+			return
+		}
+
+		if _, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context())); err != nil {
+			writeLookupError(rw, r, err, "book not found")
+			return
+		}
+
+		query, err := db.ImageSchema.Parse(r.URL.Query())
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		page, err := c.library.Images(ctx, objId, query)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+
+		response, err := responses.Page(page.Items, page.NextCursor, query.Select)
+		if err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// ServeBookCover streams the cover uploaded for a book
+func (c *Controller) ServeBookCover() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.limits.RequestTimeout)
+		defer cancel()
+
+		objId, err := primitive.ObjectIDFromHex(mux.Vars(r)["bookId"])
+		if err != nil {
+			writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "invalid book ID"))
+			return
+		}
+
+		book, err := c.library.Book(ctx, objId, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			writeLookupError(rw, r, err, "book not found")
+			return
+		}
+
+		serveFile(rw, r, book.BookCover, book.Visibility == models.VisibilityPrivate)
+	}
+}
+
+// inBook reports whether a document of the given book may be served on
+// the request's route, which on v2 is nested under its book
+func inBook(r *http.Request, bookID primitive.ObjectID) bool {
+	routeBook, ok := mux.Vars(r)["bookId"]
+	return !ok || routeBook == bookID.Hex()
+}
+
+// serveFile writes the stored file, or a resized variant when the request
+// carries w or h, letting http.ServeContent handle Range and conditional requests
+func serveFile(rw http.ResponseWriter, r *http.Request, location string, private bool) {
+	file, info, err := storage.Open(location)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, storage.ErrNoLocation) {
+			writeError(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "file not found"))
+			return
+		}
+		writeError(rw, r, err)
+		return
+	}
+	defer file.Close()
+
+	if private {
+		rw.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		rw.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+
+	width, height, err := variantSize(r)
+	if err != nil {
+		writeError(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidQuery, err.Error()))
+		return
+	}
+
+	if width == 0 && height == 0 {
+		sniff := make([]byte, 512)
+		n, _ := io.ReadFull(file, sniff)
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			writeError(rw, r, err)
+			return
+		}
+		rw.Header().Set("Content-Type", http.DetectContentType(sniff[:n]))
+		rw.Header().Set("ETag", `"`+info.SHA256+`"`)
+		http.ServeContent(rw, r, path.Base(location), info.ModTime, file)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%dx%d"`, info.SHA256, width, height)
+	v, err := variants.get(etag, func() (variant, error) {
+		img, format, err := imaging.Decode(file)
+		if err != nil {
+			return variant{}, err
+		}
+		img, err = imaging.Fit(img, width, height)
+		if err != nil {
+			return variant{}, err
+		}
+		var buf bytes.Buffer
+		contentType, err := imaging.Encode(&buf, img, format)
+		if err != nil {
+			return variant{}, err
+		}
+		return variant{contentType: contentType, data: buf.Bytes()}, nil
+	})
+	if err != nil {
+		writeError(rw, r, responses.NewProblem(http.StatusUnprocessableEntity, responses.CodeUnprocessableImage, "cannot resize image: "+err.Error()))
+		return
+	}
+
+	rw.Header().Set("Content-Type", v.contentType)
+	rw.Header().Set("ETag", etag)
+	http.ServeContent(rw, r, path.Base(location), info.ModTime, bytes.NewReader(v.data))
+}
+
+func variantSize(r *http.Request) (int, int, error) {
+	parse := func(name string) (int, error) {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > imaging.MaxDimension {
+			return 0, imaging.ErrBadDimensions
+		}
+		return n, nil
+	}
+	width, err := parse("w")
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := parse("h")
+	if err != nil {
+		return 0, 0, err
+	}
+	return width, height, nil
+}
+
+type variant struct {
+	contentType string
+	data        []byte
+}
+
+// variantCache keeps recently generated resizes keyed by their ETag, up
+// to a total size of their encoded bytes
+type variantCache struct {
+	mu       sync.Mutex
+	entries  map[string]variant
+	order    []string
+	size     int
+	maxBytes int
+}
+
+var variants = &variantCache{entries: map[string]variant{}, maxBytes: 64 << 20}
+
+func (c *variantCache) get(key string, build func() (variant, error)) (variant, error) {
+	c.mu.Lock()
+	v, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	v, err := build()
+	if err != nil {
+		return variant{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// a variant bigger than the whole cache is served but not kept
+	if _, ok := c.entries[key]; !ok && len(v.data) <= c.maxBytes {
+		for c.size+len(v.data) > c.maxBytes {
+			c.size -= len(c.entries[c.order[0]].data)
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.entries[key] = v
+		c.order = append(c.order, key)
+		c.size += len(v.data)
+	}
+	return v, nil
+}
+
+// writeLookupError names what was missing, which for private books is
+// the book or image the caller asked for rather than anything it held
+func writeLookupError(rw http.ResponseWriter, r *http.Request, err error, notFound string) {
+	if errors.Is(err, db.ErrNotFound) {
+		err = responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, notFound)
+	}
+	writeError(rw, r, err)
+}
+
+func writeError(rw http.ResponseWriter, r *http.Request, err error) {
+	if responses.WantsProblem(r) {
+		responses.WriteProblem(rw, r, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	responses.WriteError(rw, err)
+}
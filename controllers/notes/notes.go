@@ -3,88 +3,66 @@ package notes
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
 	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
 	"github.com/programmingbunny/epub-backend/models"
 	"github.com/programmingbunny/epub-backend/responses"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var validate = validator.New()
+// Controller serves the notes attached to books on the v1 routes
+type Controller struct {
+	library *library.Service
+	limits  configs.LimitsConfig
+}
+
+func New(library *library.Service, limits configs.LimitsConfig) *Controller {
+	return &Controller{library: library, limits: limits}
+}
 
-func CreateNotes() http.HandlerFunc {
+func (c *Controller) CreateNotes() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		var notes models.Notes
 		defer cancel()
 
 		//validate the request body
 		if err := json.NewDecoder(r.Body).Decode(&notes); err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
 
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&notes); validationErr != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}}
-			json.NewEncoder(rw).Encode(response)
-			return
-		}
-
-		newNotes := models.Notes{
-			Title:     notes.Title,
-			Text:      notes.Text,
-			Type:      notes.Type,
-			BookID:    notes.BookID,
-			VersionID: notes.VersionID,
-		}
-
-		result, err := db.InsertNotes(ctx, newNotes)
+		created, err := c.library.CreateNote(ctx, notes, middleware.ActorFromContext(r.Context()))
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
 		rw.WriteHeader(http.StatusCreated)
-		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": result}}
+		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": db.InsertResult{InsertedID: created.ID}}}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func GetNotes() http.HandlerFunc {
+func (c *Controller) GetNotes() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		noteId := params["noteId"]
-		query := r.URL.Query()
-		match, err := db.ParseQuery(query)
-		if err != nil {
-			fmt.Println("err while parsing query in GetNotes Func")
-		}
-		fmt.Println(match)
-		var notes models.Notes
 		defer cancel()
 
 		objId, _ := primitive.ObjectIDFromHex(noteId)
 
-		err = db.NoteCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&notes)
-
+		notes, err := c.library.Note(ctx, objId)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -93,103 +71,89 @@ func GetNotes() http.HandlerFunc {
 	}
 }
 
-func GetAllNotes() http.HandlerFunc {
+// GetAllNotes lists notes a page at a time, filtered by the query
+func (c *Controller) GetAllNotes() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		query := r.URL.Query()
-		match, err := db.ParseQuery(query)
-		if err != nil {
-			fmt.Println("err while parsing query in GetNotes Func")
-		}
-
-		var notes models.Notes
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		defer cancel()
 
-		err = db.NoteCollection.FindOne(ctx, match).Decode(&notes)
+		query, err := db.NoteSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 
+		page, err := c.library.Notes(ctx, query)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
+		response, err := responses.Page(page.Items, page.NextCursor, query.Select)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 		rw.WriteHeader(http.StatusOK)
-		json.NewEncoder(rw).Encode(notes)
+		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func UpdateNote() http.HandlerFunc {
+func (c *Controller) UpdateNote() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		noteId := params["noteId"]
-		var existingNote models.Notes
 		defer cancel()
 
 		objId, _ := primitive.ObjectIDFromHex(noteId)
 
-		err := db.NoteCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&existingNote)
-		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
-			return
-		}
-
-		// Update the existing note
 		var updatedNote models.Notes
-		err = json.NewDecoder(r.Body).Decode(&updatedNote)
-		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		if err := json.NewDecoder(r.Body).Decode(&updatedNote); err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
 
-		existingNote.Title = updatedNote.Title
-		existingNote.Text = updatedNote.Text
-
-		// Save the updated note to the database
-		_, err = db.UpdateNoteByID(ctx, objId, existingNote)
+		note, err := c.library.UpdateNote(ctx, objId, updatedNote, middleware.ActorFromContext(r.Context()))
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
 		// Send a success response back to the client
 		rw.WriteHeader(http.StatusOK)
-		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": existingNote}}
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": note}}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
 
 // Delete a single note
-func DeleteNote() http.HandlerFunc {
+func (c *Controller) DeleteNote() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		// Create a context with a timeout of 10 seconds
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		defer cancel()
 
 		// Get the note ID from the request parameters
 		params := mux.Vars(r)
 		noteId := params["noteId"]
-		log.Printf("Note ID: %s\n", noteId)
 
 		// Convert the note ID to an ObjectID
 		objID, err := primitive.ObjectIDFromHex(noteId)
 		if err != nil {
-			log.Printf("Error converting note ID to ObjectID: %s\n", err)
 			rw.WriteHeader(http.StatusBadRequest)
 			response := responses.Response{Status: http.StatusBadRequest, Message: "Invalid note ID", Data: nil}
 			json.NewEncoder(rw).Encode(response)
 			return
 		}
 
-		// Delete the note from the database
-		result, err := db.DeleteNoteByID(ctx, objID)
+		// Move the note to the trash
+		err = c.library.TrashNote(ctx, objID, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			rw.WriteHeader(http.StatusNotFound)
+			response := responses.Response{Status: http.StatusNotFound, Message: "Note not found", Data: nil}
+			json.NewEncoder(rw).Encode(response)
+			return
+		}
 		if err != nil {
 			log.Printf("Error deleting note: %s\n", err)
 			rw.WriteHeader(http.StatusInternalServerError)
@@ -198,17 +162,9 @@ func DeleteNote() http.HandlerFunc {
 			return
 		}
 
-		// Check if the note was deleted
-		if result.DeletedCount == 0 {
-			rw.WriteHeader(http.StatusNotFound)
-			response := responses.Response{Status: http.StatusNotFound, Message: "Note not found", Data: nil}
-			json.NewEncoder(rw).Encode(response)
-			return
-		}
-
 		// Return a success response
 		rw.WriteHeader(http.StatusOK)
-		response := responses.Response{Status: http.StatusOK, Message: "Note deleted", Data: nil}
+		response := responses.Response{Status: http.StatusOK, Message: "Note moved to trash", Data: nil}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
@@ -0,0 +1,129 @@
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/responses"
+	bin "github.com/programmingbunny/epub-backend/trash"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Controller serves each user's trash
+type Controller struct {
+	trash  *bin.Service
+	limits configs.LimitsConfig
+}
+
+func New(trash *bin.Service, limits configs.LimitsConfig) *Controller {
+	return &Controller{trash: trash, limits: limits}
+}
+
+// ListTrash returns the items the signed-in user has deleted
+func (c *Controller) ListTrash() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
+		defer cancel()
+
+		actor, ok := signedIn(rw, r)
+		if !ok {
+			return
+		}
+
+		items, err := c.trash.List(ctx, actor)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": items}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// RestoreItem takes an item out of the trash
+func (c *Controller) RestoreItem() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
+		defer cancel()
+
+		actor, ok := signedIn(rw, r)
+		if !ok {
+			return
+		}
+		kind, id, ok := itemParams(rw, r)
+		if !ok {
+			return
+		}
+
+		if err := c.trash.Restore(ctx, kind, id, actor); err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": kind + " restored"}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// DeleteItem removes an item from the trash for good
+func (c *Controller) DeleteItem() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
+		defer cancel()
+
+		actor, ok := signedIn(rw, r)
+		if !ok {
+			return
+		}
+		kind, id, ok := itemParams(rw, r)
+		if !ok {
+			return
+		}
+
+		summary, err := c.trash.Delete(ctx, kind, id, actor)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": summary}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// signedIn returns the caller's user ID; the trash belongs to a user, so
+// anonymous requests are rejected
+func signedIn(rw http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	actor := middleware.ActorFromContext(r.Context())
+	if actor.IsZero() {
+		rw.WriteHeader(http.StatusUnauthorized)
+		response := responses.Response{Status: http.StatusUnauthorized, Message: "error", Data: map[string]interface{}{"data": "sign in to use the trash"}}
+		json.NewEncoder(rw).Encode(response)
+		return actor, false
+	}
+	return actor, true
+}
+
+func itemParams(rw http.ResponseWriter, r *http.Request) (string, primitive.ObjectID, bool) {
+	params := mux.Vars(r)
+	id, err := primitive.ObjectIDFromHex(params["itemId"])
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": "Invalid item ID"}}
+		json.NewEncoder(rw).Encode(response)
+		return "", id, false
+	}
+	return params["kind"], id, true
+}
+
+func writeError(rw http.ResponseWriter, err error) {
+	responses.WriteError(rw, err)
+}
@@ -5,49 +5,41 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
 	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
 	"github.com/programmingbunny/epub-backend/models"
 	"github.com/programmingbunny/epub-backend/responses"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// GetUsers returns all users in the UserCollection
-func GetUsers(ctx context.Context) ([]models.User, error) {
-	var users []models.User
-	cursor, err := db.UserCollection.Find(ctx, bson.M{})
-	if err != nil {
-		return nil, err
-	}
-	err = cursor.All(ctx, &users)
-	if err != nil {
-		return nil, err
-	}
-	return users, nil
+// Controller serves user accounts and logins on the v1 routes
+type Controller struct {
+	library *library.Service
+	auth    *middleware.Auth
+	limits  configs.LimitsConfig
 }
 
-var validate = validator.New()
+func New(library *library.Service, auth *middleware.Auth, limits configs.LimitsConfig) *Controller {
+	return &Controller{library: library, auth: auth, limits: limits}
+}
 
-// GetSingleUser gets a single user by their ID
-func GetUser() http.HandlerFunc {
+// GetUser gets a single user by their ID
+func (c *Controller) GetUser() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
 		params := mux.Vars(r)
-		id := params["id"]
+		id := params["userId"]
 		objId, _ := primitive.ObjectIDFromHex(id)
 		defer cancel()
 
 		// Get the user from the database
-		user, err := db.GetUserByID(ctx, objId)
+		user, err := c.library.User(ctx, objId)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -58,96 +50,55 @@ func GetUser() http.HandlerFunc {
 	}
 }
 
-// GetUserByEmail retrieves a user by their email address from the UserCollection
-func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	var user models.User
-	err := db.UserCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
 // CreateUser creates a new user in the database
-func CreateUser() http.HandlerFunc {
+func (c *Controller) CreateUser() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		var user models.User
-		err := json.NewDecoder(r.Body).Decode(&user)
-		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
-			return
-		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
+		defer cancel()
 
-		// hash password before storing in the database
-		passwordHash, err := db.HashPassword(user.Password)
-		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		var user models.User
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
-		user.Password = passwordHash
 
-		err = validate.Struct(user)
-		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		created, err := c.library.CreateUser(ctx, user)
+		if errors.Is(err, db.ErrDuplicate) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "email is already registered"))
 			return
 		}
-
-		err = db.InsertUser(r.Context(), user)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
 		rw.WriteHeader(http.StatusCreated)
-		json.NewEncoder(rw).Encode(user)
+		json.NewEncoder(rw).Encode(created)
 	}
 }
 
-func UpdateUser() http.HandlerFunc {
+func (c *Controller) UpdateUser() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
 		params := mux.Vars(r)
-		id := params["id"]
+		id := params["userId"]
 		objId, _ := primitive.ObjectIDFromHex(id)
 		defer cancel()
 
 		// Parse the updated user from the request body
 		var updatedUser models.User
-		err := json.NewDecoder(r.Body).Decode(&updatedUser)
-		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		if err := json.NewDecoder(r.Body).Decode(&updatedUser); err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
 
-		// Validate the updated user
-		validate := validator.New()
-		err = validate.Struct(updatedUser)
-		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		err := c.library.UpdateUser(ctx, objId, updatedUser)
+		if errors.Is(err, db.ErrDuplicate) {
+			responses.WriteError(rw, responses.NewProblem(http.StatusConflict, responses.CodeConflict, "email is already registered"))
 			return
 		}
-
-		// Update the user in the database
-		err = db.UpdateUserById(ctx, objId, updatedUser)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -159,27 +110,22 @@ func UpdateUser() http.HandlerFunc {
 }
 
 // DeleteUser deletes a user by their ID from the UserCollection
-func DeleteUser() http.HandlerFunc {
+func (c *Controller) DeleteUser() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
 		defer cancel()
 
 		params := mux.Vars(r)
-		id := params["id"]
+		id := params["userId"]
 		objId, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": "Invalid ID"}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, "Invalid ID"))
 			return
 		}
 
 		// Delete the user from the database
-		err = db.DeleteUserByID(ctx, objId)
-		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+		if err := c.library.DeleteUser(ctx, objId); err != nil {
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -190,4 +136,49 @@ func DeleteUser() http.HandlerFunc {
 	}
 }
 
+// credentials is the body of a login request
+type credentials struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Login exchanges an email and password for a signed JWT
+func (c *Controller) Login() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.limits.RequestTimeout)
+		defer cancel()
+
+		var creds credentials
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
+			return
+		}
+
+		token, user, err := c.signIn(ctx, creds)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
 
+		rw.WriteHeader(http.StatusOK)
+		response := responses.Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"token": token, "userId": user.ID.Hex()}}
+		json.NewEncoder(rw).Encode(response)
+	}
+}
+
+// signIn checks credentials and returns a token for the user
+func (c *Controller) signIn(ctx context.Context, creds credentials) (string, *models.User, error) {
+	if err := library.Validate.Struct(creds); err != nil {
+		return "", nil, err
+	}
+	// unknown emails and wrong passwords get the same answer
+	user, err := c.library.Authenticate(ctx, creds.Email, creds.Password)
+	if err != nil {
+		return "", nil, err
+	}
+	token, err := c.auth.GenerateToken(user.ID.Hex())
+	if err != nil {
+		return "", nil, err
+	}
+	return token, user, nil
+}
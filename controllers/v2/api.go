@@ -0,0 +1,141 @@
+// Package v2 serves the resource-oriented API under /v2. Books own their
+// chapters, notes, images and versions, which are addressed through the
+// book; every error is an RFC 7807 problem.
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
+	books "github.com/programmingbunny/epub-backend/controllers/books"
+	"github.com/programmingbunny/epub-backend/controllers/images"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+	"github.com/programmingbunny/epub-backend/trash"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// API holds the v2 handlers
+type API struct {
+	library *library.Service
+	trash   *trash.Service
+	auth    *middleware.Auth
+	// files streams covers and images, shared with v1
+	files *images.Controller
+	// exports renders books for download, shared with v1
+	exports *books.Controller
+	limits  configs.LimitsConfig
+}
+
+func New(library *library.Service, trash *trash.Service, auth *middleware.Auth, files *images.Controller, exports *books.Controller, limits configs.LimitsConfig) *API {
+	return &API{library: library, trash: trash, auth: auth, files: files, exports: exports, limits: limits}
+}
+
+// NotFound answers requests for paths the API doesn't have
+func (a *API) NotFound() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		responses.WriteProblem(rw, r, responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, "no such resource"))
+	}
+}
+
+// MethodNotAllowed answers requests with a method the resource doesn't support
+func (a *API) MethodNotAllowed() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		responses.WriteProblem(rw, r, responses.NewProblem(http.StatusMethodNotAllowed, responses.CodeMethodNotAllowed,
+			fmt.Sprintf("%s is not supported on this resource", r.Method)))
+	}
+}
+
+// context bounds the work a request does, ending it early when the client
+// goes away
+func (a *API) context(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), a.limits.RequestTimeout)
+}
+
+// book returns the book named by the route, if the caller may read it
+func (a *API) book(ctx context.Context, r *http.Request) (*models.Book, error) {
+	id, err := pathID(r, "bookId")
+	if err != nil {
+		return nil, err
+	}
+	book, err := a.library.Book(ctx, id, middleware.ActorFromContext(r.Context()))
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, notFound("book")
+	}
+	return book, err
+}
+
+// optionalID parses an ID a request body may leave out
+func optionalID(hex, field string) (primitive.ObjectID, error) {
+	if hex == "" {
+		return primitive.NilObjectID, nil
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return id, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, fmt.Sprintf("%s is not a valid ID", field))
+	}
+	return id, nil
+}
+
+// pathID parses an ObjectID route variable
+func pathID(r *http.Request, name string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)[name])
+	if err != nil {
+		return id, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, fmt.Sprintf("%s is not a valid ID", name))
+	}
+	return id, nil
+}
+
+// notFound names the kind of resource that is missing
+func notFound(kind string) error {
+	return responses.NewProblem(http.StatusNotFound, responses.CodeNotFound, kind+" not found")
+}
+
+// decode reads a JSON body, rejecting fields the resource doesn't have
+func decode(r *http.Request, into interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(into); err != nil {
+		return responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error())
+	}
+	return nil
+}
+
+// signedIn returns the caller, rejecting anonymous requests
+func signedIn(r *http.Request) (primitive.ObjectID, error) {
+	actor := middleware.ActorFromContext(r.Context())
+	if actor.IsZero() {
+		return actor, responses.NewProblem(http.StatusUnauthorized, responses.CodeUnauthorized, "sign in first")
+	}
+	return actor, nil
+}
+
+func writeJSON(rw http.ResponseWriter, status int, body interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(body)
+}
+
+// writeCreated answers a POST to a collection with the new resource
+func writeCreated(rw http.ResponseWriter, r *http.Request, id primitive.ObjectID, body interface{}) {
+	rw.Header().Set("Location", r.URL.Path+"/"+id.Hex())
+	writeJSON(rw, http.StatusCreated, body)
+}
+
+// writePage answers a GET on a collection
+func writePage[T any](rw http.ResponseWriter, r *http.Request, page *db.Page[T], q db.Query) {
+	body, err := responses.NewPageBody(page.Items, page.NextCursor, q.Select)
+	if err != nil {
+		responses.WriteProblem(rw, r, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, body)
+}
@@ -0,0 +1,191 @@
+package v2
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// BookInput is what clients may set on a book
+type BookInput struct {
+	Title      string `json:"title" validate:"required"`
+	Subtitle   string `json:"subtitle" validate:"required"`
+	Author     string `json:"author" validate:"required"`
+	Visibility string `json:"visibility,omitempty" validate:"omitempty,oneof=public private"`
+	// Language is a BCP 47 tag such as en or pt-BR
+	Language string        `json:"language,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Theme    *models.Theme `json:"theme,omitempty"`
+
+	Description     string                   `json:"description,omitempty" validate:"max=20000"`
+	Keywords        []string                 `json:"keywords,omitempty" validate:"max=50,dive,required,max=100"`
+	Subjects        []models.Subject         `json:"subjects,omitempty" validate:"max=20,dive"`
+	Publisher       string                   `json:"publisher,omitempty" validate:"max=200"`
+	Imprint         string                   `json:"imprint,omitempty" validate:"max=200"`
+	PublicationDate string                   `json:"publicationDate,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Series          *models.SeriesMembership `json:"series,omitempty"`
+	Contributors    []models.Contributor     `json:"contributors,omitempty" validate:"max=50,dive"`
+	Identifiers     []models.Identifier      `json:"identifiers,omitempty" validate:"max=20,dive"`
+}
+
+func (input BookInput) book() models.Book {
+	return models.Book{
+		Title:      input.Title,
+		Subtitle:   input.Subtitle,
+		Author:     input.Author,
+		Visibility: input.Visibility,
+		Language:   input.Language,
+		Theme:      input.Theme,
+
+		Description:     input.Description,
+		Keywords:        input.Keywords,
+		Subjects:        input.Subjects,
+		Publisher:       input.Publisher,
+		Imprint:         input.Imprint,
+		PublicationDate: input.PublicationDate,
+		Series:          input.Series,
+		Contributors:    input.Contributors,
+		Identifiers:     input.Identifiers,
+	}
+}
+
+// ListBooks returns the books the caller may read
+func (a *API) ListBooks() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		query, err := db.BookSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.Books(ctx, query, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+// CreateBook adds a book owned by the caller
+func (a *API) CreateBook() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		actor, err := signedIn(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input BookInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		created, err := a.library.CreateBook(ctx, input.book(), actor, nil)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+// GetBook answers with the book or, on Accept: text/markdown, with its
+// chapters as one Markdown document
+func (a *API) GetBook() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if wantsMarkdown(r) {
+			a.writeBookMarkdown(ctx, rw, r, book)
+			return
+		}
+		writeJSON(rw, http.StatusOK, book)
+	}
+}
+
+// UpdateBook replaces the book's details and metadata
+func (a *API) UpdateBook() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input BookInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateBook(ctx, book, input.book(), middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// Accessibility checks the book for accessibility problems
+func (a *API) Accessibility() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		report, err := a.library.Accessibility(ctx, book)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, report)
+	}
+}
+
+// DeleteBook moves a book and everything in it to the caller's trash
+func (a *API) DeleteBook() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		err = a.library.TrashBook(ctx, book.ID, middleware.ActorFromContext(r.Context()))
+		if errors.Is(err, db.ErrNotFound) {
+			err = notFound("book")
+		}
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBookCover streams the book's cover image
+func (a *API) GetBookCover() http.HandlerFunc {
+	return a.files.ServeBookCover()
+}
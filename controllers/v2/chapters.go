@@ -0,0 +1,166 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// ChapterInput is what clients may set on a chapter
+type ChapterInput struct {
+	Title      string `json:"title" validate:"required"`
+	Text       string `json:"text"`
+	ChapterNum int    `json:"chapterNum" validate:"gte=0"`
+	VersionID  string `json:"versionID,omitempty" validate:"omitempty,mongodb"`
+}
+
+// chapter returns the chapter named by the route, in a book the caller may read
+func (a *API) chapter(ctx context.Context, r *http.Request) (*models.Chapter, error) {
+	book, err := a.book(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := pathID(r, "chapterId")
+	if err != nil {
+		return nil, err
+	}
+	chapter, err := a.library.Chapter(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || err == nil && chapter.BookID != book.ID {
+		return nil, notFound("chapter")
+	}
+	return chapter, err
+}
+
+// ListChapters returns a book's chapters in chapter order
+func (a *API) ListChapters() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		query, err := db.ChapterSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.Chapters(ctx, book.ID, query)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+// CreateChapter adds a chapter to the book from JSON or Markdown. A number
+// that is taken moves the chapter up to the next free one.
+func (a *API) CreateChapter() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		input, err := a.decodeChapter(rw, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		versionID, err := optionalID(input.VersionID, "versionID")
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+
+		created, err := a.library.CreateChapter(ctx, models.Chapter{
+			Title:      input.Title,
+			Text:       input.Text,
+			ChapterNum: input.ChapterNum,
+			BookID:     book.ID,
+			VersionID:  versionID,
+		}, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.Header().Set("Location", r.URL.Path+"/"+created.ID.Hex())
+		writeChapter(rw, r, http.StatusCreated, created)
+	}
+}
+
+// GetChapter answers with the chapter as JSON or, on Accept: text/markdown,
+// as Markdown
+func (a *API) GetChapter() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		chapter, err := a.chapter(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeChapter(rw, r, http.StatusOK, chapter)
+	}
+}
+
+// UpdateChapter replaces the chapter's title, text and number. A number
+// another chapter holds is a conflict.
+func (a *API) UpdateChapter() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		chapter, err := a.chapter(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		input, err := a.decodeChapter(rw, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateChapter(ctx, chapter.ID, models.Chapter{
+			Title:      input.Title,
+			Text:       input.Text,
+			ChapterNum: input.ChapterNum,
+		}, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeChapter(rw, r, http.StatusOK, updated)
+	}
+}
+
+// DeleteChapter moves the chapter to the caller's trash
+func (a *API) DeleteChapter() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		chapter, err := a.chapter(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.TrashChapter(ctx, chapter.ID, middleware.ActorFromContext(r.Context())); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
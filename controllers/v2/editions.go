@@ -0,0 +1,159 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// EditionInput is what clients may set on an edition
+type EditionInput struct {
+	Name        string                   `json:"name" validate:"required,max=200"`
+	Format      string                   `json:"format" validate:"required,oneof=ebook paperback hardcover large-print"`
+	Identifiers []models.Identifier      `json:"identifiers,omitempty" validate:"max=20,dive"`
+	Prices      []models.Price           `json:"prices,omitempty" validate:"max=20,unique=Currency,dive"`
+	Settings    models.ExportSettings    `json:"settings"`
+	Overrides   []models.ContentOverride `json:"overrides,omitempty" validate:"max=100,unique=ChapterNum,dive"`
+}
+
+func (input EditionInput) edition() models.Edition {
+	return models.Edition{
+		Name:        input.Name,
+		Format:      input.Format,
+		Identifiers: input.Identifiers,
+		Prices:      input.Prices,
+		Settings:    input.Settings,
+		Overrides:   input.Overrides,
+	}
+}
+
+// edition returns the edition named by the route, of a book the caller may
+// read
+func (a *API) edition(ctx context.Context, r *http.Request) (*models.Edition, error) {
+	book, err := a.book(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := pathID(r, "editionId")
+	if err != nil {
+		return nil, err
+	}
+	edition, err := a.library.Edition(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || err == nil && edition.BookID != book.ID {
+		return nil, notFound("edition")
+	}
+	return edition, err
+}
+
+// ListEditions returns a book's editions
+func (a *API) ListEditions() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		query, err := db.EditionSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.Editions(ctx, book.ID, query)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+func (a *API) CreateEdition() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input EditionInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		created, err := a.library.CreateEdition(ctx, book, input.edition(), middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+func (a *API) GetEdition() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		edition, err := a.edition(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, edition)
+	}
+}
+
+// UpdateEdition replaces the edition's format, identifiers, prices,
+// settings and overrides
+func (a *API) UpdateEdition() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		edition, err := a.edition(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input EditionInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateEdition(ctx, edition, input.edition(), middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// DeleteEdition removes the edition for good
+func (a *API) DeleteEdition() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		edition, err := a.edition(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.DeleteEdition(ctx, edition, middleware.ActorFromContext(r.Context())); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
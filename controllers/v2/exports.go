@@ -0,0 +1,38 @@
+package v2
+
+import "net/http"
+
+// ExportDocx serves the book as a Word manuscript
+func (a *API) ExportDocx() http.HandlerFunc {
+	return a.exports.ExportDocx()
+}
+
+// ExportPDF serves the book as a print-ready interior
+func (a *API) ExportPDF() http.HandlerFunc {
+	return a.exports.ExportPDF()
+}
+
+// ExportSite serves the book as a static website zip
+func (a *API) ExportSite() http.HandlerFunc {
+	return a.exports.ExportSite()
+}
+
+// CoverWrap draws the book's full paperback cover
+func (a *API) CoverWrap() http.HandlerFunc {
+	return a.exports.CoverWrap()
+}
+
+// ExportEPUB serves the book as an EPUB
+func (a *API) ExportEPUB() http.HandlerFunc {
+	return a.exports.ExportEPUB()
+}
+
+// ExportONIX serves the book's metadata as an ONIX message
+func (a *API) ExportONIX() http.HandlerFunc {
+	return a.exports.ExportONIX()
+}
+
+// ExportCatalog serves the books the caller may read as one ONIX message
+func (a *API) ExportCatalog() http.HandlerFunc {
+	return a.exports.ExportCatalog()
+}
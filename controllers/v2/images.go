@@ -0,0 +1,170 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// image returns the image named by the route, in a book the caller may read
+func (a *API) image(ctx context.Context, r *http.Request) (*models.ChapterImages, error) {
+	book, err := a.book(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := pathID(r, "imageId")
+	if err != nil {
+		return nil, err
+	}
+	image, err := a.library.Image(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || err == nil && image.BookID != book.ID {
+		return nil, notFound("image")
+	}
+	return image, err
+}
+
+// ListImages returns a book's chapter images
+func (a *API) ListImages() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		query, err := db.ImageSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.Images(ctx, book.ID, query)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+// CreateImage uploads a chapter header image. The multipart form carries
+// the file as "image" along with chapterNum and type, and may describe it
+// with altText, longDescription and decorative.
+func (a *API) CreateImage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		r.ParseMultipartForm(a.limits.MaxUploadBytes)
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			responses.WriteProblem(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "image file is required"))
+			return
+		}
+		defer file.Close()
+		chapterNum, err := strconv.Atoi(r.FormValue("chapterNum"))
+		if err != nil {
+			responses.WriteProblem(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "chapterNum must be a number"))
+			return
+		}
+		decorative := false
+		if value := r.FormValue("decorative"); value != "" {
+			if decorative, err = strconv.ParseBool(value); err != nil {
+				responses.WriteProblem(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "decorative must be true or false"))
+				return
+			}
+		}
+
+		created, err := a.library.CreateImage(ctx, models.ChapterImages{
+			BookID:     book.ID,
+			ChapterNum: chapterNum,
+			Type:       r.FormValue("type"),
+			ImageDescription: models.ImageDescription{
+				AltText:         r.FormValue("altText"),
+				LongDescription: r.FormValue("longDescription"),
+				Decorative:      decorative,
+			},
+		}, file, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+func (a *API) GetImage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		image, err := a.image(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, image)
+	}
+}
+
+// DescribeImage replaces the image's alt text, long description and
+// decorative flag
+func (a *API) DescribeImage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		image, err := a.image(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var description models.ImageDescription
+		if err := decode(r, &description); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.DescribeImage(ctx, image, description, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// GetImageFile streams the image itself
+func (a *API) GetImageFile() http.HandlerFunc {
+	return a.files.ServeImage()
+}
+
+// DeleteImage moves the image to the caller's trash
+func (a *API) DeleteImage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		image, err := a.image(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.TrashImage(ctx, image.ID, middleware.ActorFromContext(r.Context())); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,142 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/markdown"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// chapterMatter is the front matter of a chapter sent or served as
+// Markdown, the same header the onword CLI writes
+type chapterMatter struct {
+	ID         string `yaml:"id,omitempty"`
+	Title      string `yaml:"title"`
+	ChapterNum int    `yaml:"chapter,omitempty"`
+	VersionID  string `yaml:"versionID,omitempty"`
+}
+
+// bookMatter is the front matter of a book served as Markdown
+type bookMatter struct {
+	ID       string `yaml:"id"`
+	Title    string `yaml:"title"`
+	Subtitle string `yaml:"subtitle,omitempty"`
+	Author   string `yaml:"author,omitempty"`
+}
+
+// sentMarkdown reports whether the request body is Markdown
+func sentMarkdown(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == markdown.ContentType
+}
+
+// wantsMarkdown reports whether the Accept header asks for Markdown before
+// JSON
+func wantsMarkdown(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case markdown.ContentType:
+			return true
+		case "application/json", "*/*":
+			return false
+		}
+	}
+	return false
+}
+
+// decodeChapter reads a JSON chapter or a Markdown one, whose front matter
+// holds the title and number and whose body becomes the stored HTML
+func (a *API) decodeChapter(rw http.ResponseWriter, r *http.Request) (ChapterInput, error) {
+	var input ChapterInput
+	if !sentMarkdown(r) {
+		return input, decode(r, &input)
+	}
+
+	src, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, a.limits.MaxUploadBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return input, responses.NewProblem(http.StatusRequestEntityTooLarge, responses.CodeInvalidBody, "the request body is too large")
+		}
+		return input, err
+	}
+	var header chapterMatter
+	body, err := markdown.Split(src, &header)
+	if err != nil {
+		return input, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, fmt.Sprintf("front matter: %v", err))
+	}
+	if header.ChapterNum < 0 {
+		problem := responses.NewProblem(http.StatusBadRequest, responses.CodeValidationFailed, "the request does not match the API description")
+		problem.Errors = []responses.FieldError{{Field: "chapter", Rule: "gte", Param: "0", Message: "chapter must be 0 or more"}}
+		return input, problem
+	}
+	text, err := markdown.ToHTML(body)
+	if errors.Is(err, markdown.ErrRawHTML) {
+		problem := responses.NewProblem(http.StatusBadRequest, responses.CodeValidationFailed, "the request does not match the API description")
+		problem.Errors = []responses.FieldError{{Field: "text", Rule: "markdown", Message: err.Error()}}
+		return input, problem
+	}
+	if err != nil {
+		return input, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error())
+	}
+	return ChapterInput{Title: header.Title, Text: text, ChapterNum: header.ChapterNum, VersionID: header.VersionID}, nil
+}
+
+// writeChapter answers with the chapter as JSON or, when asked, Markdown
+func writeChapter(rw http.ResponseWriter, r *http.Request, status int, chapter *models.Chapter) {
+	if !wantsMarkdown(r) {
+		writeJSON(rw, status, chapter)
+		return
+	}
+	header := chapterMatter{ID: chapter.ID.Hex(), Title: chapter.Title, ChapterNum: chapter.ChapterNum}
+	if !chapter.VersionID.IsZero() {
+		header.VersionID = chapter.VersionID.Hex()
+	}
+	writeMarkdown(rw, r, status, header, markdown.FromHTML(chapter.Text))
+}
+
+// writeBookMarkdown answers with the whole book as one Markdown document,
+// each chapter under a level one heading with its own footnote labels
+func (a *API) writeBookMarkdown(ctx context.Context, rw http.ResponseWriter, r *http.Request, book *models.Book) {
+	chapters, err := a.library.Chapters(ctx, book.ID, db.Query{Sort: db.ChapterSchema.DefaultSort})
+	if err != nil {
+		responses.WriteProblem(rw, r, err)
+		return
+	}
+	var body strings.Builder
+	for i, chapter := range chapters.Items {
+		if i > 0 {
+			body.WriteString("\n")
+		}
+		body.WriteString("# " + strings.Join(strings.Fields(chapter.Title), " ") + "\n")
+		if text := markdown.FromHTML(chapter.Text, markdown.ShiftHeadings(1), markdown.PrefixFootnotes(fmt.Sprintf("%d-", i+1))); text != "" {
+			body.WriteString("\n" + text)
+		}
+	}
+	header := bookMatter{ID: book.ID.Hex(), Title: book.Title, Subtitle: book.Subtitle, Author: book.Author}
+	writeMarkdown(rw, r, http.StatusOK, header, body.String())
+}
+
+func writeMarkdown(rw http.ResponseWriter, r *http.Request, status int, header interface{}, body string) {
+	doc, err := markdown.Join(header, body)
+	if err != nil {
+		responses.WriteProblem(rw, r, err)
+		return
+	}
+	rw.Header().Set("Content-Type", markdown.ContentType+"; charset=utf-8")
+	rw.Header().Add("Vary", "Accept")
+	rw.WriteHeader(status)
+	rw.Write(doc)
+}
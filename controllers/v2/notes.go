@@ -0,0 +1,168 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// NoteInput is what clients may set on a note
+type NoteInput struct {
+	Title     string `json:"title"`
+	Text      string `json:"text"`
+	Type      string `json:"type"`
+	VersionID string `json:"versionID,omitempty" validate:"omitempty,mongodb"`
+}
+
+// note returns the note named by the route, in a book the caller may read
+// or in the book's series when the caller may see the series
+func (a *API) note(ctx context.Context, r *http.Request) (*models.Notes, error) {
+	book, err := a.book(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := pathID(r, "noteId")
+	if err != nil {
+		return nil, err
+	}
+	note, err := a.library.Note(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, notFound("note")
+	}
+	if err != nil || note.BookID == book.ID {
+		return note, err
+	}
+	seriesID, err := a.bookSeriesID(ctx, r, book)
+	if err != nil {
+		return nil, err
+	}
+	if note.SeriesID.IsZero() || note.SeriesID != seriesID {
+		return nil, notFound("note")
+	}
+	return note, nil
+}
+
+// ListNotes returns a book's notes and those of its series the caller may
+// see, filtered by the query
+func (a *API) ListNotes() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		query, err := db.NoteSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.BookNotes(ctx, book, query, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+func (a *API) CreateNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input NoteInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		versionID, err := optionalID(input.VersionID, "versionID")
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+
+		created, err := a.library.CreateNote(ctx, models.Notes{
+			Title:     input.Title,
+			Text:      input.Text,
+			Type:      input.Type,
+			BookID:    book.ID,
+			VersionID: versionID,
+		}, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+func (a *API) GetNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		note, err := a.note(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, note)
+	}
+}
+
+// UpdateNote replaces the note's title and text
+func (a *API) UpdateNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		note, err := a.note(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input NoteInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateNote(ctx, note.ID, models.Notes{Title: input.Title, Text: input.Text}, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// DeleteNote moves the note to the caller's trash
+func (a *API) DeleteNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		note, err := a.note(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.TrashNote(ctx, note.ID, middleware.ActorFromContext(r.Context())); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,198 @@
+package v2
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// OmnibusPartInput names a book of an omnibus and, optionally, the version
+// of it to take the chapters from
+type OmnibusPartInput struct {
+	BookID    string `json:"bookID" validate:"required,mongodb"`
+	VersionID string `json:"versionID,omitempty" validate:"omitempty,mongodb"`
+}
+
+// OmnibusPartsInput are the parts of an omnibus in reading order
+type OmnibusPartsInput struct {
+	Parts []OmnibusPartInput `json:"parts" validate:"required,min=1,max=20,dive"`
+}
+
+func (input OmnibusPartsInput) parts() ([]models.OmnibusPart, error) {
+	parts := make([]models.OmnibusPart, 0, len(input.Parts))
+	for _, p := range input.Parts {
+		bookID, err := optionalID(p.BookID, "parts.bookID")
+		if err != nil {
+			return nil, err
+		}
+		versionID, err := optionalID(p.VersionID, "parts.versionID")
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, models.OmnibusPart{BookID: bookID, VersionID: versionID})
+	}
+	return parts, nil
+}
+
+// OmnibusInput is what clients may set on a new omnibus: its details as a
+// book and its parts
+type OmnibusInput struct {
+	BookInput
+	OmnibusPartsInput
+}
+
+// OmnibusContents is an omnibus' table of contents: its parts, each with
+// the titles of its chapters
+type OmnibusContents struct {
+	FrozenAt *time.Time     `json:"frozenAt,omitempty"`
+	Parts    []library.Part `json:"parts"`
+}
+
+// omnibus returns the book named by the route if it is an omnibus the
+// caller may read
+func (a *API) omnibus(ctx context.Context, r *http.Request) (*models.Book, error) {
+	book, err := a.book(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if book.Omnibus == nil {
+		return nil, notFound("omnibus")
+	}
+	return book, nil
+}
+
+// CreateOmnibus adds a book owned by the caller made of other books the
+// caller may read
+func (a *API) CreateOmnibus() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		actor, err := signedIn(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input OmnibusInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		parts, err := input.parts()
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		created, err := a.library.CreateOmnibus(ctx, input.book(), parts, actor)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		// an omnibus is read and changed as a book
+		rw.Header().Set("Location", path.Join(path.Dir(r.URL.Path), "books", created.ID.Hex()))
+		writeJSON(rw, http.StatusCreated, created)
+	}
+}
+
+// GetOmnibus returns the omnibus' contents as its exports have them
+func (a *API) GetOmnibus() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.omnibus(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		parts, err := a.library.Parts(ctx, book)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		for _, part := range parts {
+			// the contents list chapters, not their text
+			for i := range part.Chapters {
+				part.Chapters[i].Text = ""
+			}
+		}
+		writeJSON(rw, http.StatusOK, OmnibusContents{FrozenAt: book.Omnibus.FrozenAt, Parts: parts})
+	}
+}
+
+// UpdateOmnibus replaces the omnibus' parts
+func (a *API) UpdateOmnibus() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.omnibus(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input OmnibusPartsInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		parts, err := input.parts()
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateOmnibus(ctx, book, parts, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// FreezeOmnibus copies the parts into the omnibus, so changes to their
+// books no longer reach its exports
+func (a *API) FreezeOmnibus() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.omnibus(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		frozen, err := a.library.FreezeOmnibus(ctx, book, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, frozen)
+	}
+}
+
+// ThawOmnibus drops the copies of the parts, which follow their books again
+func (a *API) ThawOmnibus() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.omnibus(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		thawed, err := a.library.ThawOmnibus(ctx, book, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, thawed)
+	}
+}
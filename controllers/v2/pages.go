@@ -0,0 +1,231 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LayoutInput switches a book between reflowable and fixed layout
+type LayoutInput struct {
+	Layout string `json:"layout" validate:"required,oneof=reflowable fixed"`
+	// Viewport is required for fixed layout
+	Viewport *models.Viewport `json:"viewport,omitempty"`
+}
+
+// PageInput is what clients may set on a page of a fixed-layout book
+type PageInput struct {
+	ImageID string              `json:"imageID" validate:"required,mongodb"`
+	Spread  string              `json:"spread,omitempty" validate:"omitempty,oneof=left right center"`
+	Regions []models.TextRegion `json:"regions,omitempty" validate:"max=50,dive"`
+}
+
+// PageOrderInput lists every page of a book in its new order
+type PageOrderInput struct {
+	Pages []string `json:"pages" validate:"required,dive,mongodb"`
+}
+
+// page returns the page named by the route, in a book the caller may read
+func (a *API) page(ctx context.Context, r *http.Request) (*models.Book, *models.Page, error) {
+	book, err := a.book(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := pathID(r, "pageId")
+	if err != nil {
+		return nil, nil, err
+	}
+	page, err := a.library.Page(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || err == nil && page.BookID != book.ID {
+		return nil, nil, notFound("page")
+	}
+	return book, page, err
+}
+
+// SetLayout makes the book reflowable or fixed-layout
+func (a *API) SetLayout() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input LayoutInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.SetLayout(ctx, book, input.Layout, input.Viewport, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// ListPages returns a book's pages in page order
+func (a *API) ListPages() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		query, err := db.PageSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.Pages(ctx, book.ID, query)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+// CreatePage adds a page after the book's last one
+func (a *API) CreatePage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := decodePage(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		created, err := a.library.CreatePage(ctx, book, page, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+func (a *API) GetPage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		_, page, err := a.page(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, page)
+	}
+}
+
+// UpdatePage replaces the page's image, spread and text
+func (a *API) UpdatePage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, page, err := a.page(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		changes, err := decodePage(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdatePage(ctx, book, page.ID, changes, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// DeletePage removes the page for good, the pages after it move up
+func (a *API) DeletePage() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		_, page, err := a.page(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.DeletePage(ctx, page, middleware.ActorFromContext(r.Context())); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ReorderPages puts the book's pages in a new order and answers with them
+// renumbered, as a listing would
+func (a *API) ReorderPages() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input PageOrderInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		order := make([]primitive.ObjectID, 0, len(input.Pages))
+		for _, hex := range input.Pages {
+			id, err := optionalID(hex, "pages")
+			if err != nil {
+				responses.WriteProblem(rw, r, err)
+				return
+			}
+			order = append(order, id)
+		}
+		pages, err := a.library.ReorderPages(ctx, book.ID, order, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		// the whole book fits on one page of the listing
+		writeJSON(rw, http.StatusOK, responses.PageBody{Items: pages})
+	}
+}
+
+// decodePage reads a page from a JSON body
+func decodePage(r *http.Request) (models.Page, error) {
+	var input PageInput
+	if err := decode(r, &input); err != nil {
+		return models.Page{}, err
+	}
+	imageID, err := optionalID(input.ImageID, "imageID")
+	if err != nil {
+		return models.Page{}, err
+	}
+	return models.Page{ImageID: imageID, Spread: input.Spread, Regions: input.Regions}, nil
+}
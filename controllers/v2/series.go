@@ -0,0 +1,372 @@
+package v2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SeriesInput is what clients may set on a series
+type SeriesInput struct {
+	Name        string `json:"name" validate:"required,max=200"`
+	Description string `json:"description,omitempty" validate:"max=5000"`
+	// Books are the IDs of the series' books in reading order
+	Books []string `json:"books" validate:"max=100,dive,mongodb"`
+}
+
+func (input SeriesInput) series() (models.Series, error) {
+	series := models.Series{Name: input.Name, Description: input.Description}
+	for _, hex := range input.Books {
+		id, err := optionalID(hex, "books")
+		if err != nil {
+			return series, err
+		}
+		series.BookIDs = append(series.BookIDs, id)
+	}
+	return series, nil
+}
+
+// series returns the series named by the route, if the caller may see it
+func (a *API) series(ctx context.Context, r *http.Request) (*models.Series, error) {
+	id, err := pathID(r, "seriesId")
+	if err != nil {
+		return nil, err
+	}
+	series, err := a.library.Series(ctx, id, middleware.ActorFromContext(r.Context()))
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, notFound("series")
+	}
+	return series, err
+}
+
+// seriesNote returns the note named by the route, in a series the caller
+// may see
+func (a *API) seriesNote(ctx context.Context, r *http.Request) (*models.Notes, error) {
+	series, err := a.series(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := pathID(r, "noteId")
+	if err != nil {
+		return nil, err
+	}
+	note, err := a.library.Note(ctx, id)
+	if errors.Is(err, db.ErrNotFound) || err == nil && note.SeriesID != series.ID {
+		return nil, notFound("note")
+	}
+	return note, err
+}
+
+// ListSeries returns the caller's series
+func (a *API) ListSeries() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		query, err := db.SeriesSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.ListSeries(ctx, query, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+// CreateSeries stores a series owned by the caller. Its books must be the
+// caller's and in no other series.
+func (a *API) CreateSeries() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		var input SeriesInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		series, err := input.series()
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		created, err := a.library.CreateSeries(ctx, series, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+func (a *API) GetSeries() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		series, err := a.series(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, series)
+	}
+}
+
+// UpdateSeries replaces the series' name, description and books. Books
+// in the trash keep their place when they are listed again.
+func (a *API) UpdateSeries() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		series, err := a.series(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input SeriesInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		changes, err := input.series()
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateSeries(ctx, series, changes)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// DeleteSeries removes the series and its notes for good, keeping its books
+func (a *API) DeleteSeries() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		series, err := a.series(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.DeleteSeries(ctx, series.ID); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListSeriesBooks returns the series' books in reading order
+func (a *API) ListSeriesBooks() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		series, err := a.series(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		books, err := a.library.SeriesBooks(ctx, series, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		// a series fits on one page of the listing
+		writeJSON(rw, http.StatusOK, responses.PageBody{Items: books})
+	}
+}
+
+// ListSeriesNotes returns the series' notes, filtered by the query
+func (a *API) ListSeriesNotes() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		series, err := a.series(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		query, err := db.NoteSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		page, err := a.library.Notes(ctx, query.Where("seriesID", db.OpEq, series.ID))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writePage(rw, r, page, query)
+	}
+}
+
+// CreateSeriesNote adds a note shared by all of the series' books
+func (a *API) CreateSeriesNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		series, err := a.series(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input NoteInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if input.VersionID != "" {
+			responses.WriteProblem(rw, r, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "series notes don't belong to a book version"))
+			return
+		}
+		created, err := a.library.CreateNote(ctx, models.Notes{
+			Title:    input.Title,
+			Text:     input.Text,
+			Type:     input.Type,
+			SeriesID: series.ID,
+		}, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeCreated(rw, r, created.ID, created)
+	}
+}
+
+func (a *API) GetSeriesNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		note, err := a.seriesNote(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, note)
+	}
+}
+
+// UpdateSeriesNote replaces the note's title and text
+func (a *API) UpdateSeriesNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		note, err := a.seriesNote(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input NoteInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		updated, err := a.library.UpdateNote(ctx, note.ID, models.Notes{Title: input.Title, Text: input.Text}, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, updated)
+	}
+}
+
+// DeleteSeriesNote moves the note to the caller's trash
+func (a *API) DeleteSeriesNote() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		note, err := a.seriesNote(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.library.TrashNote(ctx, note.ID, middleware.ActorFromContext(r.Context())); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBookSeries returns the series the book is in
+func (a *API) GetBookSeries() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		series, err := a.library.BookSeries(ctx, book.ID)
+		if errors.Is(err, db.ErrNotFound) {
+			err = notFound("series")
+		}
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, series)
+	}
+}
+
+// GetBackMatter previews the pages exports add after the book's last
+// chapter
+func (a *API) GetBackMatter() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		book, err := a.book(ctx, r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		pages, err := a.library.BackMatter(ctx, book)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if pages == nil {
+			pages = []models.Chapter{}
+		}
+		writeJSON(rw, http.StatusOK, responses.PageBody{Items: pages})
+	}
+}
+
+// bookSeriesID is the ID of the series the book is in, zero when none or
+// when the caller may not see it
+func (a *API) bookSeriesID(ctx context.Context, r *http.Request, book *models.Book) (primitive.ObjectID, error) {
+	series, err := a.library.BookSeries(ctx, book.ID)
+	if errors.Is(err, db.ErrNotFound) || err == nil && !series.ReadableBy(middleware.ActorFromContext(r.Context()).Hex()) {
+		return primitive.NilObjectID, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return series.ID, nil
+}
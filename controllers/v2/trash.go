@@ -0,0 +1,78 @@
+package v2
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// ListTrash returns the items the caller has deleted
+func (a *API) ListTrash() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		actor, err := signedIn(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		items, err := a.trash.List(ctx, actor)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, responses.PageBody{Items: items})
+	}
+}
+
+// RestoreTrashItem takes an item out of the trash
+func (a *API) RestoreTrashItem() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		actor, err := signedIn(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		id, err := pathID(r, "itemId")
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := a.trash.Restore(ctx, mux.Vars(r)["kind"], id, actor); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteTrashItem removes an item from the trash for good and reports
+// what went with it
+func (a *API) DeleteTrashItem() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		actor, err := signedIn(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		id, err := pathID(r, "itemId")
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		summary, err := a.trash.Delete(ctx, mux.Vars(r)["kind"], id, actor)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, summary)
+	}
+}
@@ -0,0 +1,195 @@
+package v2
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/responses"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserInput is the body of account requests
+type UserInput struct {
+	Email     string `json:"email" validate:"required,email"`
+	FirstName string `json:"firstName" validate:"required"`
+	LastName  string `json:"lastName" validate:"required"`
+	Password  string `json:"password" validate:"required"`
+}
+
+// UserView is an account as clients see it, without the password hash
+type UserView struct {
+	ID        primitive.ObjectID `json:"id"`
+	Email     string             `json:"email"`
+	FirstName string             `json:"firstName"`
+	LastName  string             `json:"lastName"`
+}
+
+// SessionInput is the body of a sign-in
+type SessionInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Session is a signed-in caller's token
+type Session struct {
+	Token  string `json:"token"`
+	UserID string `json:"userId"`
+}
+
+func (input UserInput) user() models.User {
+	return models.User{Email: input.Email, FirstName: input.FirstName, LastName: input.LastName, Password: input.Password}
+}
+
+func viewUser(user *models.User) UserView {
+	return UserView{ID: user.ID, Email: user.Email, FirstName: user.FirstName, LastName: user.LastName}
+}
+
+// self returns the account named by the route, which must be the caller's
+func self(r *http.Request) (primitive.ObjectID, error) {
+	actor, err := signedIn(r)
+	if err != nil {
+		return actor, err
+	}
+	id, err := pathID(r, "userId")
+	if err != nil {
+		return id, err
+	}
+	if id != actor {
+		return id, responses.NewProblem(http.StatusForbidden, responses.CodeForbidden, "you may only manage your own account")
+	}
+	return id, nil
+}
+
+// emailTaken explains the conflict a duplicate email causes
+func emailTaken(err error) error {
+	if errors.Is(err, db.ErrDuplicate) {
+		return responses.NewProblem(http.StatusConflict, responses.CodeConflict, "email is already registered")
+	}
+	return err
+}
+
+// CreateUser registers an account
+func (a *API) CreateUser() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		var input UserInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		created, err := a.library.CreateUser(ctx, input.user())
+		if err != nil {
+			responses.WriteProblem(rw, r, emailTaken(err))
+			return
+		}
+		writeCreated(rw, r, created.ID, viewUser(created))
+	}
+}
+
+func (a *API) GetUser() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		id, err := self(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		user, err := a.library.User(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			err = notFound("user")
+		}
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, viewUser(user))
+	}
+}
+
+// UpdateUser replaces the caller's account details
+func (a *API) UpdateUser() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		id, err := self(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		var input UserInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		err = a.library.UpdateUser(ctx, id, input.user())
+		if errors.Is(err, db.ErrNotFound) {
+			err = notFound("user")
+		}
+		if err != nil {
+			responses.WriteProblem(rw, r, emailTaken(err))
+			return
+		}
+		user := input.user()
+		user.ID = id
+		writeJSON(rw, http.StatusOK, viewUser(&user))
+	}
+}
+
+func (a *API) DeleteUser() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		id, err := self(r)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		err = a.library.DeleteUser(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			err = notFound("user")
+		}
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CreateSession exchanges an email and password for a signed JWT
+func (a *API) CreateSession() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := a.context(r)
+		defer cancel()
+
+		var input SessionInput
+		if err := decode(r, &input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		if err := library.Validate.Struct(input); err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		user, err := a.library.Authenticate(ctx, input.Email, input.Password)
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		token, err := a.auth.GenerateToken(user.ID.Hex())
+		if err != nil {
+			responses.WriteProblem(rw, r, err)
+			return
+		}
+		writeJSON(rw, http.StatusCreated, Session{Token: token, UserID: user.ID.Hex()})
+	}
+}
@@ -4,76 +4,63 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
 	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
 	"github.com/programmingbunny/epub-backend/models"
 	"github.com/programmingbunny/epub-backend/responses"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var validate = validator.New()
+// Controller serves the versions of a book on the v1 routes
+type Controller struct {
+	library *library.Service
+	limits  configs.LimitsConfig
+}
+
+func New(library *library.Service, limits configs.LimitsConfig) *Controller {
+	return &Controller{library: library, limits: limits}
+}
 
-func CreateVersion() http.HandlerFunc {
+func (c *Controller) CreateVersion() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		var version models.Version
 		defer cancel()
 
 		//validate the request body
 		if err := json.NewDecoder(r.Body).Decode(&version); err != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
-			return
-		}
-
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&version); validationErr != nil {
-			rw.WriteHeader(http.StatusBadRequest)
-			response := responses.Response{Status: http.StatusBadRequest, Message: "error", Data: map[string]interface{}{"data": validationErr.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error()))
 			return
 		}
 
-		newVersion := models.Version{
-			Type:   version.Type,
-			BookID: version.BookID,
-		}
-
-		result, err := db.InsertVersion(ctx, newVersion)
+		created, err := c.library.CreateVersion(ctx, version, middleware.ActorFromContext(r.Context()))
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
 		rw.WriteHeader(http.StatusCreated)
-		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": result}}
+		response := responses.Response{Status: http.StatusCreated, Message: "success", Data: map[string]interface{}{"data": db.InsertResult{InsertedID: created.ID}}}
 		json.NewEncoder(rw).Encode(response)
 	}
 }
 
-func GetVersion() http.HandlerFunc {
+func (c *Controller) GetVersion() http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
 		params := mux.Vars(r)
 		versionId := params["versionId"]
-		var version models.Version
 		defer cancel()
 
 		objId, _ := primitive.ObjectIDFromHex(versionId)
 
-		err := db.ChapterCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&version)
-
+		version, err := c.library.Version(ctx, objId)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": err.Error()}}
-			json.NewEncoder(rw).Encode(response)
+			responses.WriteError(rw, err)
 			return
 		}
 
@@ -81,3 +68,35 @@ func GetVersion() http.HandlerFunc {
 		json.NewEncoder(rw).Encode(version)
 	}
 }
+
+// GetVersions lists a book's versions a page at a time
+func (c *Controller) GetVersions() http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(library.Legacy(r.Context()), c.limits.RequestTimeout)
+		params := mux.Vars(r)
+		bookId := params["bookId"]
+		defer cancel()
+
+		objId, _ := primitive.ObjectIDFromHex(bookId)
+
+		query, err := db.VersionSchema.Parse(r.URL.Query())
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+
+		page, err := c.library.Versions(ctx, objId, query)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+
+		response, err := responses.Page(page.Items, page.NextCursor, query.Select)
+		if err != nil {
+			responses.WriteError(rw, err)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		json.NewEncoder(rw).Encode(response)
+	}
+}
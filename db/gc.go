@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/storage"
+)
+
+// GCOptions controls a garbage collection run
+type GCOptions struct {
+	// DryRun reports what would be removed without touching disk or refcounts
+	DryRun bool
+	// GracePeriod protects files written recently, whose documents may not
+	// have been inserted yet
+	GracePeriod time.Duration
+	// LegacyDirs are upload directories from before content addressing
+	LegacyDirs []string
+}
+
+// GCReport summarizes a garbage collection run
+type GCReport struct {
+	DryRun         bool                 `json:"dryRun"`
+	Scanned        int                  `json:"scanned"`
+	Referenced     int                  `json:"referenced"`
+	Orphans        []storage.StoredFile `json:"orphans"`
+	ReclaimedBytes int64                `json:"reclaimedBytes"`
+	RefCountsFixed int                  `json:"refCountsFixed"`
+	Errors         []string             `json:"errors,omitempty"`
+}
+
+// CollectGarbage removes uploaded files that no book cover, image or chapter
+// references any more and brings the blob reference counts back in line.
+func CollectGarbage(ctx context.Context, store *Store, blobs *storage.BlobStore, opts GCOptions) (*GCReport, error) {
+	report := &GCReport{DryRun: opts.DryRun, Orphans: []storage.StoredFile{}}
+
+	refs, err := countBlobReferences(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	// chapters carry a copy of their header image location; they don't own
+	// a reference but their files must survive
+	chapterImages, err := store.Chapters.ImageLocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := map[string]bool{}
+	for _, location := range chapterImages {
+		live[path.Clean(location)] = true
+	}
+	for location := range refs {
+		live[location] = true
+	}
+
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	visit := func(f storage.StoredFile) error {
+		report.Scanned++
+		if live[path.Clean(f.Location)] {
+			report.Referenced++
+			return nil
+		}
+		if f.ModTime.After(cutoff) {
+			return nil
+		}
+		report.Orphans = append(report.Orphans, f)
+		report.ReclaimedBytes += f.Size
+		if !opts.DryRun {
+			if err := os.Remove(filepath.FromSlash(f.Location)); err != nil && !os.IsNotExist(err) {
+				report.Errors = append(report.Errors, err.Error())
+			}
+		}
+		return nil
+	}
+
+	if err := blobs.Walk(visit); err != nil {
+		return nil, err
+	}
+	for _, dir := range opts.LegacyDirs {
+		if err := storage.WalkDir(dir, visit); err != nil {
+			return nil, err
+		}
+	}
+
+	counts := map[string]int{}
+	for location, n := range refs {
+		if hash, ok := blobs.HashOf(location); ok {
+			counts[hash] += n
+		}
+	}
+	fixed, err := syncBlobRefs(ctx, store, counts, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	report.RefCountsFixed = fixed
+
+	return report, nil
+}
+
+// countBlobReferences counts the documents owning each upload location,
+// trashed documents included since they can still be restored
+func countBlobReferences(ctx context.Context, store *Store) (map[string]int, error) {
+	refs := map[string]int{}
+
+	books, err := store.Books.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trashedBooks, err := store.Books.ListTrashed(ctx, TrashFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range append(books, trashedBooks...) {
+		if book.BookCover != "" {
+			refs[path.Clean(book.BookCover)]++
+		}
+	}
+
+	images, err := store.Images.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trashedImages, err := store.Images.ListTrashed(ctx, TrashFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range append(images, trashedImages...) {
+		if image.ImageLocation != "" {
+			refs[path.Clean(image.ImageLocation)]++
+		}
+	}
+
+	return refs, nil
+}
+
+// syncBlobRefs overwrites stored reference counts with the counted ones and
+// drops records for blobs nothing references
+func syncBlobRefs(ctx context.Context, store *Store, counts map[string]int, dryRun bool) (int, error) {
+	stored, err := store.Blobs.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	fixed := 0
+	seen := map[string]bool{}
+	for _, ref := range stored {
+		seen[ref.Hash] = true
+		want := counts[ref.Hash]
+		if ref.Refs == want && want > 0 {
+			continue
+		}
+		fixed++
+		if dryRun {
+			continue
+		}
+		if want == 0 {
+			err = store.Blobs.Delete(ctx, ref.Hash)
+		} else {
+			err = store.Blobs.SetRefs(ctx, ref.Hash, want)
+		}
+		if err != nil {
+			return fixed, err
+		}
+	}
+
+	for hash, want := range counts {
+		if seen[hash] {
+			continue
+		}
+		fixed++
+		if dryRun {
+			continue
+		}
+		if err := store.Blobs.SetRefs(ctx, hash, want); err != nil {
+			return fixed, err
+		}
+	}
+
+	return fixed, nil
+}
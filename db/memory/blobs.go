@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/storage"
+)
+
+type blobs struct {
+	mu   sync.Mutex
+	refs map[string]models.BlobRef
+}
+
+func (b *blobs) Retain(ctx context.Context, blob storage.Blob) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ref, ok := b.refs[blob.Hash]
+	if !ok {
+		ref = models.BlobRef{Hash: blob.Hash, Size: blob.Size, ContentType: blob.ContentType, CreatedAt: time.Now()}
+	}
+	ref.Refs++
+	b.refs[blob.Hash] = ref
+	return nil
+}
+
+func (b *blobs) Release(ctx context.Context, hash string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ref, ok := b.refs[hash]
+	if ok && ref.Refs > 0 {
+		ref.Refs--
+		b.refs[hash] = ref
+	}
+	return ref.Refs, nil
+}
+
+func (b *blobs) List(ctx context.Context) ([]models.BlobRef, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	refs := make([]models.BlobRef, 0, len(b.refs))
+	for _, ref := range b.refs {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Hash < refs[j].Hash })
+	return refs, nil
+}
+
+func (b *blobs) SetRefs(ctx context.Context, hash string, refs int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ref, ok := b.refs[hash]
+	if !ok {
+		ref = models.BlobRef{Hash: hash, CreatedAt: time.Now()}
+	}
+	ref.Refs = refs
+	b.refs[hash] = ref
+	return nil
+}
+
+func (b *blobs) Delete(ctx context.Context, hash string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.refs, hash)
+	return nil
+}
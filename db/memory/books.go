@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type books struct {
+	table *table[models.Book]
+	trashBin[models.Book]
+}
+
+func newBooks() *books {
+	t := newTable[models.Book]()
+	return &books{table: t, trashBin: trashBin[models.Book]{
+		table:  t,
+		mark:   func(book *models.Book) *models.Trash { return &book.Trash },
+		bookID: func(book models.Book) primitive.ObjectID { return book.ID },
+	}}
+}
+
+func (b *books) Insert(ctx context.Context, book models.Book) (primitive.ObjectID, error) {
+	return b.table.insert(book.ID, func(id primitive.ObjectID) models.Book {
+		book.ID = id
+		return book
+	})
+}
+
+func (b *books) Get(ctx context.Context, id primitive.ObjectID) (*models.Book, error) {
+	book, err := b.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (b *books) List(ctx context.Context) ([]models.Book, error) {
+	return b.find(func(models.Book) bool { return true }), nil
+}
+
+func (b *books) Find(ctx context.Context, q db.Query) (*db.Page[models.Book], error) {
+	return findPage(b.find(func(models.Book) bool { return true }), q)
+}
+
+func (b *books) Update(ctx context.Context, id primitive.ObjectID, book models.Book) error {
+	return b.table.updateIf(id, b.live, func(existing *models.Book) {
+		existing.Title = book.Title
+		existing.Subtitle = book.Subtitle
+		existing.Author = book.Author
+		existing.Visibility = book.Visibility
+		existing.Language = book.Language
+		existing.Theme = book.Theme
+		existing.Description = book.Description
+		existing.Keywords = book.Keywords
+		existing.Subjects = book.Subjects
+		existing.Publisher = book.Publisher
+		existing.Imprint = book.Imprint
+		existing.PublicationDate = book.PublicationDate
+		existing.Series = book.Series
+		existing.Contributors = book.Contributors
+		existing.Identifiers = book.Identifiers
+	})
+}
+
+func (b *books) SetLayout(ctx context.Context, id primitive.ObjectID, layout string, viewport *models.Viewport) error {
+	return b.table.updateIf(id, b.live, func(book *models.Book) {
+		book.Layout = layout
+		book.Viewport = viewport
+	})
+}
+
+func (b *books) SetOmnibus(ctx context.Context, id primitive.ObjectID, omnibus *models.Omnibus) error {
+	return b.table.updateIf(id, b.live, func(book *models.Book) {
+		book.Omnibus = omnibus
+	})
+}
+
+func (b *books) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return b.table.delete(id)
+}
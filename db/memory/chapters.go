@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type chapters struct {
+	table *table[models.Chapter]
+	trashBin[models.Chapter]
+}
+
+func newChapters() *chapters {
+	t := newTable(func(chapter models.Chapter) []string {
+		// chapters numbered 0 are exempt, as in the Mongo index
+		if chapter.ChapterNum == 0 {
+			return nil
+		}
+		return []string{fmt.Sprint(chapter.BookID.Hex(), "/", chapter.VersionID.Hex(), "/", chapter.ChapterNum)}
+	})
+	return &chapters{table: t, trashBin: trashBin[models.Chapter]{
+		table:  t,
+		mark:   func(chapter *models.Chapter) *models.Trash { return &chapter.Trash },
+		bookID: func(chapter models.Chapter) primitive.ObjectID { return chapter.BookID },
+		// a trashed chapter gives up its number until it is restored
+		onTrash: func(chapter *models.Chapter) {
+			chapter.DeletedChapterNum, chapter.ChapterNum = chapter.ChapterNum, 0
+		},
+		onRestore: func(chapter *models.Chapter) {
+			chapter.ChapterNum, chapter.DeletedChapterNum = chapter.DeletedChapterNum, 0
+		},
+	}}
+}
+
+func (c *chapters) Insert(ctx context.Context, chapter models.Chapter) (primitive.ObjectID, error) {
+	return c.table.insert(chapter.ID, func(id primitive.ObjectID) models.Chapter {
+		chapter.ID = id
+		return chapter
+	})
+}
+
+func (c *chapters) Get(ctx context.Context, id primitive.ObjectID) (*models.Chapter, error) {
+	chapter, err := c.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+func (c *chapters) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Chapter, error) {
+	return c.find(func(chapter models.Chapter) bool { return chapter.BookID == bookID }), nil
+}
+
+func (c *chapters) Find(ctx context.Context, q db.Query) (*db.Page[models.Chapter], error) {
+	return findPage(c.find(func(models.Chapter) bool { return true }), q)
+}
+
+func (c *chapters) ImageLocations(ctx context.Context) ([]string, error) {
+	locations := []string{}
+	for _, chapter := range c.table.find(func(chapter models.Chapter) bool { return chapter.ImageLocation != "" }) {
+		locations = append(locations, chapter.ImageLocation)
+	}
+	return locations, nil
+}
+
+func (c *chapters) Update(ctx context.Context, id primitive.ObjectID, chapter models.Chapter) error {
+	return c.table.updateIf(id, c.live, func(existing *models.Chapter) {
+		existing.Title = chapter.Title
+		existing.Text = chapter.Text
+		existing.ChapterNum = chapter.ChapterNum
+		existing.BookID = chapter.BookID
+		existing.VersionID = chapter.VersionID
+	})
+}
+
+func (c *chapters) SetHeaderImage(ctx context.Context, bookID primitive.ObjectID, chapterNum int, location string) error {
+	c.table.updateWhere(
+		func(chapter models.Chapter) bool { return chapter.BookID == bookID && chapter.ChapterNum == chapterNum },
+		func(chapter *models.Chapter) { chapter.ImageLocation = location },
+	)
+	return nil
+}
+
+func (c *chapters) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return c.table.delete(id)
+}
+
+func (c *chapters) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return c.table.deleteWhere(func(chapter models.Chapter) bool { return chapter.BookID == bookID }), nil
+}
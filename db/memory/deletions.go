@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type deletionLogs struct {
+	table *table[models.DeletionLog]
+}
+
+func (d *deletionLogs) Insert(ctx context.Context, log models.DeletionLog) (primitive.ObjectID, error) {
+	return d.table.insert(log.ID, func(id primitive.ObjectID) models.DeletionLog {
+		log.ID = id
+		return log
+	})
+}
+
+func (d *deletionLogs) SetState(ctx context.Context, id primitive.ObjectID, state string) error {
+	return d.table.update(id, func(log *models.DeletionLog) {
+		log.State = state
+	})
+}
+
+func (d *deletionLogs) ListAbandoned(ctx context.Context, now time.Time) ([]models.DeletionLog, error) {
+	return d.table.find(func(log models.DeletionLog) bool {
+		return log.State == models.DeletionPending && log.LeaseUntil.Before(now)
+	}), nil
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type editions struct {
+	table *table[models.Edition]
+}
+
+func newEditions() *editions {
+	return &editions{table: newTable(func(edition models.Edition) []string {
+		// an identifier names one edition of one book, as in the Mongo index
+		values := make([]string, 0, len(edition.Identifiers))
+		for _, id := range edition.Identifiers {
+			values = append(values, id.Value)
+		}
+		return values
+	})}
+}
+
+func (e *editions) Insert(ctx context.Context, edition models.Edition) (primitive.ObjectID, error) {
+	return e.table.insert(edition.ID, func(id primitive.ObjectID) models.Edition {
+		edition.ID = id
+		return edition
+	})
+}
+
+func (e *editions) Get(ctx context.Context, id primitive.ObjectID) (*models.Edition, error) {
+	edition, err := e.table.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &edition, nil
+}
+
+func (e *editions) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Edition, error) {
+	return e.table.find(func(edition models.Edition) bool { return edition.BookID == bookID }), nil
+}
+
+func (e *editions) ListByIdentifier(ctx context.Context, value string) ([]models.Edition, error) {
+	return e.table.find(func(edition models.Edition) bool {
+		for _, id := range edition.Identifiers {
+			if id.Value == value {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (e *editions) Find(ctx context.Context, q db.Query) (*db.Page[models.Edition], error) {
+	return findPage(e.table.find(func(models.Edition) bool { return true }), q)
+}
+
+func (e *editions) Update(ctx context.Context, id primitive.ObjectID, edition models.Edition) error {
+	return e.table.update(id, func(existing *models.Edition) {
+		bookID := existing.BookID
+		*existing = edition
+		existing.ID, existing.BookID = id, bookID
+	})
+}
+
+func (e *editions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return e.table.delete(id)
+}
+
+func (e *editions) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return e.table.deleteWhere(func(edition models.Edition) bool { return edition.BookID == bookID }), nil
+}
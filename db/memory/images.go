@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type images struct {
+	table *table[models.ChapterImages]
+	trashBin[models.ChapterImages]
+}
+
+func newImages() *images {
+	t := newTable[models.ChapterImages]()
+	return &images{table: t, trashBin: trashBin[models.ChapterImages]{
+		table:  t,
+		mark:   func(image *models.ChapterImages) *models.Trash { return &image.Trash },
+		bookID: func(image models.ChapterImages) primitive.ObjectID { return image.BookID },
+	}}
+}
+
+func (i *images) Insert(ctx context.Context, image models.ChapterImages) (primitive.ObjectID, error) {
+	return i.table.insert(image.ID, func(id primitive.ObjectID) models.ChapterImages {
+		image.ID = id
+		return image
+	})
+}
+
+func (i *images) Get(ctx context.Context, id primitive.ObjectID) (*models.ChapterImages, error) {
+	image, err := i.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (i *images) GetForChapter(ctx context.Context, bookID primitive.ObjectID, chapterNum int) (*models.ChapterImages, error) {
+	found := i.find(func(image models.ChapterImages) bool {
+		return image.BookID == bookID && image.ChapterNum == chapterNum && image.Type != models.ImageTypeInline && image.Type != models.ImageTypePage
+	})
+	if len(found) == 0 {
+		return nil, db.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (i *images) List(ctx context.Context) ([]models.ChapterImages, error) {
+	return i.find(func(models.ChapterImages) bool { return true }), nil
+}
+
+func (i *images) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.ChapterImages, error) {
+	return i.find(func(image models.ChapterImages) bool { return image.BookID == bookID }), nil
+}
+
+func (i *images) Find(ctx context.Context, q db.Query) (*db.Page[models.ChapterImages], error) {
+	return findPage(i.find(func(models.ChapterImages) bool { return true }), q)
+}
+
+func (i *images) Describe(ctx context.Context, id primitive.ObjectID, description models.ImageDescription) error {
+	return i.table.updateIf(id, i.live, func(image *models.ChapterImages) {
+		image.ImageDescription = description
+	})
+}
+
+func (i *images) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return i.table.delete(id)
+}
+
+func (i *images) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return i.table.deleteWhere(func(image models.ChapterImages) bool { return image.BookID == bookID }), nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type notes struct {
+	table *table[models.Notes]
+	trashBin[models.Notes]
+}
+
+func newNotes() *notes {
+	t := newTable[models.Notes]()
+	return &notes{table: t, trashBin: trashBin[models.Notes]{
+		table:  t,
+		mark:   func(note *models.Notes) *models.Trash { return &note.Trash },
+		bookID: func(note models.Notes) primitive.ObjectID { return note.BookID },
+	}}
+}
+
+func (n *notes) Insert(ctx context.Context, note models.Notes) (primitive.ObjectID, error) {
+	return n.table.insert(note.ID, func(id primitive.ObjectID) models.Notes {
+		note.ID = id
+		return note
+	})
+}
+
+func (n *notes) Get(ctx context.Context, id primitive.ObjectID) (*models.Notes, error) {
+	note, err := n.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (n *notes) Find(ctx context.Context, q db.Query) (*db.Page[models.Notes], error) {
+	return findPage(n.find(func(models.Notes) bool { return true }), q)
+}
+
+func (n *notes) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Notes, error) {
+	return n.find(func(note models.Notes) bool { return note.BookID == bookID }), nil
+}
+
+func (n *notes) Update(ctx context.Context, id primitive.ObjectID, note models.Notes) error {
+	return n.table.updateIf(id, n.live, func(existing *models.Notes) {
+		existing.Title = note.Title
+		existing.Text = note.Text
+		existing.Type = note.Type
+		existing.BookID = note.BookID
+		existing.VersionID = note.VersionID
+	})
+}
+
+func (n *notes) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return n.table.delete(id)
+}
+
+func (n *notes) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return n.table.deleteWhere(func(note models.Notes) bool { return note.BookID == bookID }), nil
+}
+
+func (n *notes) DeleteBySeries(ctx context.Context, seriesID primitive.ObjectID) (int64, error) {
+	return n.table.deleteWhere(func(note models.Notes) bool { return note.SeriesID == seriesID }), nil
+}
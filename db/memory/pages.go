@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type pages struct {
+	table *table[models.Page]
+}
+
+func (p *pages) Insert(ctx context.Context, page models.Page) (primitive.ObjectID, error) {
+	return p.table.insert(page.ID, func(id primitive.ObjectID) models.Page {
+		page.ID = id
+		return page
+	})
+}
+
+func (p *pages) Get(ctx context.Context, id primitive.ObjectID) (*models.Page, error) {
+	page, err := p.table.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (p *pages) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Page, error) {
+	found := p.table.find(func(page models.Page) bool { return page.BookID == bookID })
+	// find keeps insertion order, which settles pages sharing a number
+	sort.SliceStable(found, func(i, j int) bool { return found[i].PageNum < found[j].PageNum })
+	return found, nil
+}
+
+func (p *pages) Find(ctx context.Context, q db.Query) (*db.Page[models.Page], error) {
+	return findPage(p.table.find(func(models.Page) bool { return true }), q)
+}
+
+func (p *pages) Update(ctx context.Context, id primitive.ObjectID, page models.Page) error {
+	return p.table.update(id, func(existing *models.Page) {
+		existing.ImageID = page.ImageID
+		existing.Spread = page.Spread
+		existing.Regions = page.Regions
+	})
+}
+
+func (p *pages) Renumber(ctx context.Context, bookID primitive.ObjectID, order []primitive.ObjectID) error {
+	numbers := make(map[primitive.ObjectID]int, len(order))
+	for i, id := range order {
+		numbers[id] = i + 1
+	}
+	p.table.updateAll(
+		func(page models.Page) bool { return page.BookID == bookID && numbers[page.ID] > 0 },
+		func(page *models.Page) { page.PageNum = numbers[page.ID] },
+	)
+	return nil
+}
+
+func (p *pages) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return p.table.delete(id)
+}
+
+func (p *pages) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return p.table.deleteWhere(func(page models.Page) bool { return page.BookID == bookID }), nil
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// findPage applies a query to rows in process. Rows are compared in their
+// stored form, so filters and cursors behave as they do against Mongo.
+func findPage[T any](rows []T, q db.Query) (*db.Page[T], error) {
+	match, err := q.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	type stored struct {
+		row T
+		doc bson.M
+	}
+	found := make([]stored, 0, len(rows))
+	for _, row := range rows {
+		raw, err := bson.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		var doc bson.M
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		if match(doc) {
+			found = append(found, stored{row: row, doc: doc})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return q.Compare(found[i].doc, found[j].doc) < 0 })
+	if q.Limit > 0 && len(found) > q.Limit+1 {
+		found = found[:q.Limit+1]
+	}
+
+	items := make([]T, len(found))
+	for i := range found {
+		items[i] = found[i].row
+	}
+	return db.NewPage(q, items, func(i int) (bson.M, error) { return found[i].doc, nil })
+}
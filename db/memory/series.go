@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type series struct {
+	table *table[models.Series]
+}
+
+func (s *series) Insert(ctx context.Context, row models.Series) (primitive.ObjectID, error) {
+	return s.table.insert(row.ID, func(id primitive.ObjectID) models.Series {
+		row.ID = id
+		return row
+	})
+}
+
+func (s *series) Get(ctx context.Context, id primitive.ObjectID) (*models.Series, error) {
+	row, err := s.table.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (s *series) GetByBook(ctx context.Context, bookID primitive.ObjectID) (*models.Series, error) {
+	found := s.table.find(func(row models.Series) bool { return row.Position(bookID) > 0 })
+	if len(found) == 0 {
+		return nil, db.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (s *series) Find(ctx context.Context, q db.Query) (*db.Page[models.Series], error) {
+	return findPage(s.table.find(func(models.Series) bool { return true }), q)
+}
+
+func (s *series) Update(ctx context.Context, id primitive.ObjectID, row models.Series) error {
+	return s.table.update(id, func(existing *models.Series) {
+		existing.Name = row.Name
+		existing.Description = row.Description
+		existing.BookIDs = row.BookIDs
+	})
+}
+
+func (s *series) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.table.delete(id)
+}
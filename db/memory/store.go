@@ -0,0 +1,234 @@
+// Package memory implements the db repositories in process memory, for
+// tests and for running the API without MongoDB.
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewStore returns an empty in-memory store
+func NewStore() *db.Store {
+	return &db.Store{
+		Users: &users{table: newTable(func(user models.User) []string {
+			return []string{user.Email}
+		})},
+		Books:    newBooks(),
+		Chapters: newChapters(),
+		Images:   newImages(),
+		Versions: &versions{table: newTable[models.Version]()},
+		Notes:    newNotes(),
+		Blobs:    &blobs{refs: map[string]models.BlobRef{}},
+		Pages:    &pages{table: newTable[models.Page]()},
+		Editions: newEditions(),
+		Series:   &series{table: newTable[models.Series]()},
+		Health:   health{},
+
+		DeletionLogs: &deletionLogs{table: newTable[models.DeletionLog]()},
+		Transactions: transactions{},
+	}
+}
+
+// transactions can't roll the tables back, so callers fall back to their
+// non-transactional path
+type transactions struct{}
+
+func (transactions) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.ErrTransactionsUnsupported
+}
+
+// health is always ready, there is nothing to lose a connection to
+type health struct{}
+
+func (health) Ping(ctx context.Context) error {
+	return nil
+}
+
+// table holds documents by ID and remembers insertion order, so listings
+// come back in the same natural order Mongo would use
+type table[T any] struct {
+	mu    sync.RWMutex
+	rows  map[primitive.ObjectID]T
+	order map[primitive.ObjectID]int
+	next  int
+	// unique mirror the unique indexes, an empty key is not indexed. A row
+	// has several keys in an index over an array, as in Mongo's multikey
+	// indexes.
+	unique []func(T) []string
+}
+
+func newTable[T any](unique ...func(T) []string) *table[T] {
+	return &table[T]{rows: map[primitive.ObjectID]T{}, order: map[primitive.ObjectID]int{}, unique: unique}
+}
+
+// conflicts reports whether row would share a unique key with another row
+func (t *table[T]) conflicts(id primitive.ObjectID, row T) bool {
+	for _, keys := range t.unique {
+		for _, want := range keys(row) {
+			if want == "" {
+				continue
+			}
+			for otherID, other := range t.rows {
+				if otherID == id {
+					continue
+				}
+				for _, key := range keys(other) {
+					if key == want {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// insert stores row under id, generating an ID when none was given
+func (t *table[T]) insert(id primitive.ObjectID, row func(primitive.ObjectID) T) (primitive.ObjectID, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id.IsZero() {
+		id = primitive.NewObjectID()
+	}
+	if _, ok := t.rows[id]; ok {
+		return primitive.NilObjectID, db.ErrDuplicate
+	}
+	inserted := row(id)
+	if t.conflicts(id, inserted) {
+		return primitive.NilObjectID, db.ErrDuplicate
+	}
+	t.rows[id] = inserted
+	t.order[id] = t.next
+	t.next++
+	return id, nil
+}
+
+func (t *table[T]) get(id primitive.ObjectID) (T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	row, ok := t.rows[id]
+	if !ok {
+		return row, db.ErrNotFound
+	}
+	return row, nil
+}
+
+// find returns the rows accepted by match in insertion order
+func (t *table[T]) find(match func(T) bool) []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	ids := make([]primitive.ObjectID, 0, len(t.rows))
+	for id, row := range t.rows {
+		if match(row) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return t.order[ids[i]] < t.order[ids[j]] })
+	found := make([]T, 0, len(ids))
+	for _, id := range ids {
+		found = append(found, t.rows[id])
+	}
+	return found
+}
+
+// updateIf applies change to the row with the given ID when allowed
+// accepts it, and returns ErrNotFound otherwise
+func (t *table[T]) updateIf(id primitive.ObjectID, allowed func(T) bool, change func(*T)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[id]
+	if !ok || !allowed(row) {
+		return db.ErrNotFound
+	}
+	change(&row)
+	if t.conflicts(id, row) {
+		return db.ErrDuplicate
+	}
+	t.rows[id] = row
+	return nil
+}
+
+// updateAll applies change to every row accepted by match, skipping rows
+// the change would make conflict
+func (t *table[T]) updateAll(match func(T) bool, change func(*T)) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var updated int64
+	for id, row := range t.rows {
+		if !match(row) {
+			continue
+		}
+		change(&row)
+		if !t.conflicts(id, row) {
+			t.rows[id] = row
+			updated++
+		}
+	}
+	return updated
+}
+
+// update applies change to the row with the given ID
+func (t *table[T]) update(id primitive.ObjectID, change func(*T)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+	change(&row)
+	if t.conflicts(id, row) {
+		return db.ErrDuplicate
+	}
+	t.rows[id] = row
+	return nil
+}
+
+// updateWhere applies change to the first row accepted by match
+func (t *table[T]) updateWhere(match func(T) bool, change func(*T)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var first primitive.ObjectID
+	found := false
+	for id, row := range t.rows {
+		if match(row) && (!found || t.order[id] < t.order[first]) {
+			first, found = id, true
+		}
+	}
+	if found {
+		row := t.rows[first]
+		change(&row)
+		if !t.conflicts(first, row) {
+			t.rows[first] = row
+		}
+	}
+}
+
+func (t *table[T]) delete(id primitive.ObjectID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[id]; !ok {
+		return db.ErrNotFound
+	}
+	delete(t.rows, id)
+	delete(t.order, id)
+	return nil
+}
+
+func (t *table[T]) deleteWhere(match func(T) bool) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var deleted int64
+	for id, row := range t.rows {
+		if match(row) {
+			delete(t.rows, id)
+			delete(t.order, id)
+			deleted++
+		}
+	}
+	return deleted
+}
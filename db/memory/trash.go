@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// trashBin implements db.BookTrashBin over a table
+type trashBin[T any] struct {
+	table  *table[T]
+	mark   func(*T) *models.Trash
+	bookID func(T) primitive.ObjectID
+	// onTrash and onRestore adjust a row beyond its trash mark
+	onTrash   func(*T)
+	onRestore func(*T)
+}
+
+func (b trashBin[T]) live(row T) bool {
+	return !b.mark(&row).Trashed()
+}
+
+// get returns the row unless it is trashed
+func (b trashBin[T]) get(id primitive.ObjectID) (T, error) {
+	row, err := b.table.get(id)
+	if err == nil && !b.live(row) {
+		var zero T
+		return zero, db.ErrNotFound
+	}
+	return row, err
+}
+
+// find returns the live rows accepted by match
+func (b trashBin[T]) find(match func(T) bool) []T {
+	return b.table.find(func(row T) bool { return b.live(row) && match(row) })
+}
+
+func (b trashBin[T]) trash(row *T, mark models.Trash) {
+	*b.mark(row) = mark
+	if b.onTrash != nil {
+		b.onTrash(row)
+	}
+}
+
+func (b trashBin[T]) Trash(ctx context.Context, id primitive.ObjectID, mark models.Trash) error {
+	return b.table.updateIf(id, b.live, func(row *T) { b.trash(row, mark) })
+}
+
+func (b trashBin[T]) TrashByBook(ctx context.Context, bookID primitive.ObjectID, mark models.Trash) (int64, error) {
+	return b.table.updateAll(
+		func(row T) bool { return b.live(row) && b.bookID(row) == bookID },
+		func(row *T) { b.trash(row, mark) },
+	), nil
+}
+
+func (b trashBin[T]) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return b.table.updateIf(id, func(row T) bool { return !b.live(row) }, func(row *T) {
+		*b.mark(row) = models.Trash{}
+		if b.onRestore != nil {
+			b.onRestore(row)
+		}
+	})
+}
+
+func (b trashBin[T]) GetTrashed(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	row, err := b.table.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if b.live(row) {
+		return nil, db.ErrNotFound
+	}
+	return &row, nil
+}
+
+func (b trashBin[T]) ListTrashed(ctx context.Context, filter db.TrashFilter) ([]T, error) {
+	return b.table.find(func(row T) bool {
+		mark := b.mark(&row)
+		return mark.Trashed() &&
+			(filter.DeletedBy.IsZero() || mark.DeletedByUser(filter.DeletedBy)) &&
+			(filter.BookID.IsZero() || b.bookID(row) == filter.BookID) &&
+			(filter.Before.IsZero() || mark.DeletedAt.Before(filter.Before))
+	}), nil
+}
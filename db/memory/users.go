@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type users struct {
+	table *table[models.User]
+}
+
+func (u *users) Insert(ctx context.Context, user models.User) (primitive.ObjectID, error) {
+	return u.table.insert(user.ID, func(id primitive.ObjectID) models.User {
+		user.ID = id
+		return user
+	})
+}
+
+func (u *users) Get(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	user, err := u.table.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *users) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	found := u.table.find(func(user models.User) bool { return user.Email == email })
+	if len(found) == 0 {
+		return nil, db.ErrNotFound
+	}
+	return &found[0], nil
+}
+
+func (u *users) List(ctx context.Context) ([]models.User, error) {
+	return u.table.find(func(models.User) bool { return true }), nil
+}
+
+func (u *users) Update(ctx context.Context, id primitive.ObjectID, user models.User) error {
+	return u.table.update(id, func(existing *models.User) {
+		user.ID = id
+		*existing = user
+	})
+}
+
+func (u *users) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return u.table.delete(id)
+}
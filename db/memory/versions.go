@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type versions struct {
+	table *table[models.Version]
+}
+
+func (v *versions) Insert(ctx context.Context, version models.Version) (primitive.ObjectID, error) {
+	return v.table.insert(version.ID, func(id primitive.ObjectID) models.Version {
+		version.ID = id
+		return version
+	})
+}
+
+func (v *versions) Get(ctx context.Context, id primitive.ObjectID) (*models.Version, error) {
+	version, err := v.table.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (v *versions) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Version, error) {
+	return v.table.find(func(version models.Version) bool { return version.BookID == bookID }), nil
+}
+
+func (v *versions) Find(ctx context.Context, q db.Query) (*db.Page[models.Version], error) {
+	return findPage(v.table.find(func(models.Version) bool { return true }), q)
+}
+
+func (v *versions) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return v.table.deleteWhere(func(version models.Version) bool { return version.BookID == bookID }), nil
+}
@@ -3,30 +3,60 @@ package db
 import (
 	"context"
 	"errors"
-	"time"
+	"fmt"
 
-	"github.com/go-playground/validator/v10"
-	"github.com/programmingbunny/epub-backend/configs"
-	"github.com/programmingbunny/epub-backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// Collections names the Mongo collection behind each repository
+type Collections struct {
+	Users    string
+	Books    string
+	Chapters string
+	Images   string
+	Versions string
+	Notes    string
+	Blobs    string
+	Pages    string
+	Editions string
+	Series   string
 
-var UserCollection *mongo.Collection = configs.GetCollection(configs.DB, "OnWord", "UserDetails")
-
-var BookCollection *mongo.Collection = configs.GetCollection(configs.DB, "OnWord", "BookDetails")
+	DeletionLogs string
+}
 
-var ChapterCollection *mongo.Collection = configs.GetCollection(configs.DB, "OnWord", "Chapters")
+// NewMongoStore returns repositories backed by the given database
+func NewMongoStore(database *mongo.Database, names Collections) *Store {
+	return &Store{
+		Users:    &mongoUsers{database.Collection(names.Users)},
+		Books:    &mongoBooks{database.Collection(names.Books)},
+		Chapters: &mongoChapters{database.Collection(names.Chapters)},
+		Images:   &mongoImages{database.Collection(names.Images)},
+		Versions: &mongoVersions{database.Collection(names.Versions)},
+		Notes:    &mongoNotes{database.Collection(names.Notes)},
+		Blobs:    &mongoBlobs{database.Collection(names.Blobs)},
+		Pages:    &mongoPages{database.Collection(names.Pages)},
+		Editions: &mongoEditions{database.Collection(names.Editions)},
+		Series:   &mongoSeries{database.Collection(names.Series)},
+		Health:   &mongoHealth{database.Client()},
 
-var ImageCollection *mongo.Collection = configs.GetCollection(configs.DB, "OnWord", "Images")
+		DeletionLogs: &mongoDeletionLogs{database.Collection(names.DeletionLogs)},
+		Transactions: &mongoTransactions{database: database},
+	}
+}
 
-var VersionCollection *mongo.Collection = configs.GetCollection(configs.DB, "OnWord", "Versions")
+type mongoHealth struct {
+	client *mongo.Client
+}
 
-var NoteCollection *mongo.Collection = configs.GetCollection(configs.DB, "OnWord", "Notes")
+func (m *mongoHealth) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, readpref.Primary())
+}
 
 // HashPassword hashes the given password using bcrypt
 func HashPassword(password string) (string, error) {
@@ -37,206 +67,110 @@ func HashPassword(password string) (string, error) {
     return string(passwordHash), nil
 }
 
-// InsertUser inserts the given user into the UserCollection
-func InsertUser(ctx context.Context, newUser models.User) error {
-   
-
-    _, err := UserCollection.InsertOne(ctx, newUser)
-    if err != nil {
-        return err
-    }
-    return nil
-}
-
-// DeleteUserByID deletes a user by their ID from the UserCollection
-func DeleteUserByID(ctx context.Context, id primitive.ObjectID) error {
-	_, err := UserCollection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		return err
+// findOne decodes the first document matching filter, mapping a miss to ErrNotFound
+func findOne(ctx context.Context, collection *mongo.Collection, filter interface{}, out interface{}) error {
+	err := collection.FindOne(ctx, filter).Decode(out)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
 	}
-	return nil
+	return err
 }
 
-func UpdateUserById(ctx context.Context, id primitive.ObjectID, updatedUser models.User) error {
-	validate := validator.New()
-	err := validate.Struct(updatedUser)
-	if err != nil {
-		return err
-	}
-	// Update the user document
-	update := bson.M{
-		"$set": updatedUser,
-	}
-	_, err = UserCollection.UpdateOne(ctx, bson.M{"_id": id}, update)
+func findAll(ctx context.Context, collection *mongo.Collection, filter interface{}, out interface{}) error {
+	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-// GetUserByID retrieves a user by their ID from the UserCollection
-func GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
-	var user models.User
-	err := UserCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
-	}
-	return &user, nil
+	return cursor.All(ctx, out)
 }
 
-func InsertBook(ctx context.Context, newBook models.Book) (result *mongo.InsertOneResult, err error) {
-	result, err = BookCollection.InsertOne(ctx, newBook)
+// findPage loads a page of the documents matching base and the query
+func findPage[T any](ctx context.Context, collection *mongo.Collection, base bson.M, q Query) (*Page[T], error) {
+	filter, err := q.mongoFilter(base)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
-}
-
-func DeleteBookByID(ctx context.Context, id primitive.ObjectID) error {
-	// Delete the book from the BookDetails collection
-	_, err := BookCollection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		return err
-	}
-
-	// Delete all chapters associated with the book ID
-	_, err = ChapterCollection.DeleteMany(ctx, bson.M{"bookID": id})
-	if err != nil {
-		return err
+	opts := options.Find().SetSort(q.mongoSort())
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit + 1))
 	}
-
-	// Delete all images associated with the book ID
-	_, err = ImageCollection.DeleteMany(ctx, bson.M{"bookID": id})
-	if err != nil {
-		return err
+	if projection := q.mongoProjection(); projection != nil {
+		opts.SetProjection(projection)
 	}
-
-	// Delete all versions associated with the book ID
-	_, err = VersionCollection.DeleteMany(ctx, bson.M{"bookID": id})
+	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	// Delete all notes associated with the book ID
-	_, err = NoteCollection.DeleteMany(ctx, bson.M{"bookID": id})
-	if err != nil {
-		return err
+	var items []T
+	var docs []bson.M
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return nil, err
+		}
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		docs = append(docs, doc)
 	}
-
-	return nil
-}
-
-func DeleteNoteByID(ctx context.Context, id primitive.ObjectID) (*mongo.DeleteResult, error) {
-	// Delete the note from the database
-	result, err := NoteCollection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
+	if err := cursor.Err(); err != nil {
 		return nil, err
 	}
-
-	return result, nil
+	return NewPage(q, items, func(i int) (bson.M, error) { return docs[i], nil })
 }
 
-func InsertChapter(ctx context.Context, newChapter models.Chapter) (result *mongo.InsertOneResult, err error) {
-	result, err = ChapterCollection.InsertOne(ctx, newChapter)
+func insertOne(ctx context.Context, collection *mongo.Collection, document interface{}) (primitive.ObjectID, error) {
+	result, err := collection.InsertOne(ctx, document)
 	if err != nil {
-		return nil, err
+		return primitive.NilObjectID, writeError(err)
 	}
-	return result, nil
+	id, _ := result.InsertedID.(primitive.ObjectID)
+	return id, nil
 }
 
-func DeleteChapterByID(ctx context.Context, chapterID primitive.ObjectID) (*mongo.DeleteResult, error) {
-    // Delete the chapter from the database
-    result, err := ChapterCollection.DeleteOne(ctx, bson.M{"_id": chapterID})
-    if err != nil {
-        return nil, err
-    }
-
-    return result, nil
+func updateByID(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID, update interface{}) error {
+	return updateMatching(ctx, collection, bson.M{"_id": id}, update)
 }
 
-func UpdateChapterByID(ctx context.Context, id primitive.ObjectID, updatedChapter models.Chapter) (*mongo.UpdateResult, error) {
-	filter := bson.M{"_id": id}
-	update := bson.M{
-		"$set": bson.M{
-			"title":      updatedChapter.Title,
-			"text":       updatedChapter.Text,
-			"chapterNum": updatedChapter.ChapterNum,
-			"bookID":     updatedChapter.BookID,
-			"versionID":  updatedChapter.VersionID,
-		},
-	}
-
-	result, err := ChapterCollection.UpdateOne(ctx, filter, update)
+// updateMatching updates the first document matching filter
+func updateMatching(ctx context.Context, collection *mongo.Collection, filter bson.M, update interface{}) error {
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return nil, err
+		return writeError(err)
 	}
-
-	return result, nil
-}
-
-func InsertImage(ctx context.Context, newImage models.ChapterImages) (result *mongo.InsertOneResult, err error) {
-	result, err = ImageCollection.InsertOne(ctx, newImage)
-	if err != nil {
-		return nil, err
+	if result.MatchedCount == 0 {
+		return ErrNotFound
 	}
-	return result, nil
+	return nil
 }
 
-func InsertVersion(ctx context.Context, newVersion models.Version) (result *mongo.InsertOneResult, err error) {
-	result, err = VersionCollection.InsertOne(ctx, newVersion)
-	if err != nil {
-		return nil, err
+// writeError maps unique index violations to ErrDuplicate
+func writeError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
 	}
-	return result, nil
+	return err
 }
 
-func InsertNotes(ctx context.Context, newNote models.Notes) (result *mongo.InsertOneResult, err error) {
-	result, err = NoteCollection.InsertOne(ctx, newNote)
+func deleteByID(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID) error {
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return result, nil
-}
-
-func UpdateNoteByID(ctx context.Context, id primitive.ObjectID, updatedNote models.Notes) (*mongo.UpdateResult, error) {
-	filter := bson.M{"_id": id}
-	update := bson.M{"$set": bson.M{
-		"title":     updatedNote.Title,
-		"text":      updatedNote.Text,
-		"type":      updatedNote.Type,
-		"bookID":    updatedNote.BookID,
-		"versionID": updatedNote.VersionID,
-	}}
-
-	result, err := NoteCollection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return nil, err
+	if result.DeletedCount == 0 {
+		return ErrNotFound
 	}
-
-	return result, nil
+	return nil
 }
 
-
-
-func UpdateChapterWithHeaderImage(imageLoc string, book string, chNum int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	objId, _ := primitive.ObjectIDFromHex(book)
-
-	_, err := ChapterCollection.UpdateOne(
-		ctx,
-		bson.M{"bookID": objId, "chapterNum": chNum},
-		bson.M{
-			"$set": bson.M{
-				"imageLocation": imageLoc}},
-	)
-
+func deleteByBook(ctx context.Context, collection *mongo.Collection, bookID primitive.ObjectID) (int64, error) {
+	result, err := collection.DeleteMany(ctx, bson.M{"bookID": bookID})
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	return nil
+	return result.DeletedCount, nil
 }
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoBlobs struct {
+	collection *mongo.Collection
+}
+
+// Retain records one more document referencing the blob
+func (m *mongoBlobs) Retain(ctx context.Context, blob storage.Blob) error {
+	_, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": blob.Hash},
+		bson.M{
+			"$inc": bson.M{"refs": 1},
+			"$setOnInsert": bson.M{
+				"size":        blob.Size,
+				"contentType": blob.ContentType,
+				"createdAt":   time.Now(),
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Release records one less document referencing the blob. The file itself
+// is left alone; callers decide whether an unreferenced file can go.
+func (m *mongoBlobs) Release(ctx context.Context, hash string) (int, error) {
+	var ref models.BlobRef
+	err := m.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": hash, "refs": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"refs": -1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&ref)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ref.Refs, nil
+}
+
+func (m *mongoBlobs) List(ctx context.Context) ([]models.BlobRef, error) {
+	var refs []models.BlobRef
+	if err := findAll(ctx, m.collection, bson.M{}, &refs); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+func (m *mongoBlobs) SetRefs(ctx context.Context, hash string, refs int) error {
+	_, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hash},
+		bson.M{
+			"$set":         bson.M{"refs": refs},
+			"$setOnInsert": bson.M{"createdAt": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (m *mongoBlobs) Delete(ctx context.Context, hash string) error {
+	_, err := m.collection.DeleteOne(ctx, bson.M{"_id": hash})
+	return err
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoBooks struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoBooks) Insert(ctx context.Context, book models.Book) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, book)
+}
+
+func (m *mongoBooks) Get(ctx context.Context, id primitive.ObjectID) (*models.Book, error) {
+	var book models.Book
+	if err := findOne(ctx, m.collection, live(bson.M{"_id": id}), &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (m *mongoBooks) List(ctx context.Context) ([]models.Book, error) {
+	var books []models.Book
+	if err := findAll(ctx, m.collection, live(bson.M{}), &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (m *mongoBooks) Find(ctx context.Context, q Query) (*Page[models.Book], error) {
+	return findPage[models.Book](ctx, m.collection, live(bson.M{}), q)
+}
+
+func (m *mongoBooks) Update(ctx context.Context, id primitive.ObjectID, book models.Book) error {
+	update := bson.M{"$set": bson.M{
+		"title":      book.Title,
+		"subtitle":   book.Subtitle,
+		"author":     book.Author,
+		"visibility": book.Visibility,
+		"language":   book.Language,
+		"theme":      book.Theme,
+
+		"description":     book.Description,
+		"keywords":        book.Keywords,
+		"subjects":        book.Subjects,
+		"publisher":       book.Publisher,
+		"imprint":         book.Imprint,
+		"publicationDate": book.PublicationDate,
+		"series":          book.Series,
+		"contributors":    book.Contributors,
+		"identifiers":     book.Identifiers,
+	}}
+	return updateMatching(ctx, m.collection, live(bson.M{"_id": id}), update)
+}
+
+func (m *mongoBooks) SetLayout(ctx context.Context, id primitive.ObjectID, layout string, viewport *models.Viewport) error {
+	update := bson.M{"$set": bson.M{"layout": layout, "viewport": viewport}}
+	if viewport == nil {
+		update = bson.M{"$set": bson.M{"layout": layout}, "$unset": bson.M{"viewport": ""}}
+	}
+	return updateMatching(ctx, m.collection, live(bson.M{"_id": id}), update)
+}
+
+func (m *mongoBooks) SetOmnibus(ctx context.Context, id primitive.ObjectID, omnibus *models.Omnibus) error {
+	return updateMatching(ctx, m.collection, live(bson.M{"_id": id}), bson.M{"$set": bson.M{"omnibus": omnibus}})
+}
+
+func (m *mongoBooks) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
+
+func (m *mongoBooks) Trash(ctx context.Context, id primitive.ObjectID, mark models.Trash) error {
+	return trashByID(ctx, m.collection, id, mark)
+}
+
+func (m *mongoBooks) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreByID(ctx, m.collection, id)
+}
+
+func (m *mongoBooks) GetTrashed(ctx context.Context, id primitive.ObjectID) (*models.Book, error) {
+	var book models.Book
+	if err := findOne(ctx, m.collection, trashed(bson.M{"_id": id}), &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (m *mongoBooks) ListTrashed(ctx context.Context, filter TrashFilter) ([]models.Book, error) {
+	var books []models.Book
+	if err := findAll(ctx, m.collection, trashMatch(filter, "_id"), &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoChapters struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoChapters) Insert(ctx context.Context, chapter models.Chapter) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, chapter)
+}
+
+func (m *mongoChapters) Get(ctx context.Context, id primitive.ObjectID) (*models.Chapter, error) {
+	var chapter models.Chapter
+	if err := findOne(ctx, m.collection, live(bson.M{"_id": id}), &chapter); err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+func (m *mongoChapters) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Chapter, error) {
+	var chapters []models.Chapter
+	if err := findAll(ctx, m.collection, live(bson.M{"bookID": bookID}), &chapters); err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+func (m *mongoChapters) Find(ctx context.Context, q Query) (*Page[models.Chapter], error) {
+	return findPage[models.Chapter](ctx, m.collection, live(bson.M{}), q)
+}
+
+func (m *mongoChapters) ImageLocations(ctx context.Context) ([]string, error) {
+	cursor, err := m.collection.Find(ctx,
+		bson.M{"imageLocation": bson.M{"$nin": bson.A{"", nil}}},
+		options.Find().SetProjection(bson.M{"imageLocation": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var chapters []models.Chapter
+	if err = cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+	locations := make([]string, 0, len(chapters))
+	for _, chapter := range chapters {
+		locations = append(locations, chapter.ImageLocation)
+	}
+	return locations, nil
+}
+
+func (m *mongoChapters) Update(ctx context.Context, id primitive.ObjectID, chapter models.Chapter) error {
+	update := bson.M{
+		"$set": bson.M{
+			"title":      chapter.Title,
+			"text":       chapter.Text,
+			"chapterNum": chapter.ChapterNum,
+			"bookID":     chapter.BookID,
+			"versionID":  chapter.VersionID,
+		},
+	}
+	return updateMatching(ctx, m.collection, live(bson.M{"_id": id}), update)
+}
+
+func (m *mongoChapters) SetHeaderImage(ctx context.Context, bookID primitive.ObjectID, chapterNum int, location string) error {
+	_, err := m.collection.UpdateOne(
+		ctx,
+		bson.M{"bookID": bookID, "chapterNum": chapterNum},
+		bson.M{
+			"$set": bson.M{
+				"imageLocation": location}},
+	)
+	return err
+}
+
+func (m *mongoChapters) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
+
+func (m *mongoChapters) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return deleteByBook(ctx, m.collection, bookID)
+}
+
+// vacateSlot moves a chapter's number aside while it is trashed, so the
+// unique chapter index no longer covers it
+func vacateSlot(mark models.Trash) bson.A {
+	set := trashSet(mark)
+	set["deletedChapterNum"] = "$chapterNum"
+	return bson.A{bson.M{"$set": set}, bson.M{"$unset": "chapterNum"}}
+}
+
+func (m *mongoChapters) Trash(ctx context.Context, id primitive.ObjectID, mark models.Trash) error {
+	result, err := m.collection.UpdateOne(ctx, live(bson.M{"_id": id}), vacateSlot(mark))
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoChapters) TrashByBook(ctx context.Context, bookID primitive.ObjectID, mark models.Trash) (int64, error) {
+	result, err := m.collection.UpdateMany(ctx, live(bson.M{"bookID": bookID}), vacateSlot(mark))
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (m *mongoChapters) Restore(ctx context.Context, id primitive.ObjectID) error {
+	unset := append(bson.A{"deletedChapterNum"}, trashFields...)
+	result, err := m.collection.UpdateOne(ctx, trashed(bson.M{"_id": id}), bson.A{
+		bson.M{"$set": bson.M{"chapterNum": "$deletedChapterNum"}},
+		bson.M{"$unset": unset},
+	})
+	if err != nil {
+		return writeError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoChapters) GetTrashed(ctx context.Context, id primitive.ObjectID) (*models.Chapter, error) {
+	var chapter models.Chapter
+	if err := findOne(ctx, m.collection, trashed(bson.M{"_id": id}), &chapter); err != nil {
+		return nil, err
+	}
+	return &chapter, nil
+}
+
+func (m *mongoChapters) ListTrashed(ctx context.Context, filter TrashFilter) ([]models.Chapter, error) {
+	var chapters []models.Chapter
+	if err := findAll(ctx, m.collection, trashMatch(filter, "bookID"), &chapters); err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
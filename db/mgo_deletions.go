@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoDeletionLogs struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoDeletionLogs) Insert(ctx context.Context, log models.DeletionLog) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, log)
+}
+
+func (m *mongoDeletionLogs) SetState(ctx context.Context, id primitive.ObjectID, state string) error {
+	return updateByID(ctx, m.collection, id, bson.M{"$set": bson.M{"state": state}})
+}
+
+func (m *mongoDeletionLogs) ListAbandoned(ctx context.Context, now time.Time) ([]models.DeletionLog, error) {
+	var logs []models.DeletionLog
+	// logs from before leases have none and count as abandoned
+	filter := bson.M{"state": models.DeletionPending, "leaseUntil": bson.M{"$not": bson.M{"$gte": now}}}
+	if err := findAll(ctx, m.collection, filter, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// mongoTransactions runs multi-document transactions when the deployment
+// supports them, which needs a replica set or a sharded cluster
+type mongoTransactions struct {
+	database *mongo.Database
+
+	mu        sync.Mutex
+	probed    bool
+	supported bool
+}
+
+func (m *mongoTransactions) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	supported, err := m.isSupported(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return ErrTransactionsUnsupported
+	}
+
+	session, err := m.database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// isSupported remembers the first successful probe, a failed probe is
+// retried on the next call
+func (m *mongoTransactions) isSupported(ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.probed {
+		supported, err := m.probe(ctx)
+		if err != nil {
+			return false, err
+		}
+		m.probed, m.supported = true, supported
+	}
+	return m.supported, nil
+}
+
+// probe asks the server whether it is a replica set member or a mongos
+func (m *mongoTransactions) probe(ctx context.Context) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := m.database.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
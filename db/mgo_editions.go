@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoEditions struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoEditions) Insert(ctx context.Context, edition models.Edition) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, edition)
+}
+
+func (m *mongoEditions) Get(ctx context.Context, id primitive.ObjectID) (*models.Edition, error) {
+	var edition models.Edition
+	if err := findOne(ctx, m.collection, bson.M{"_id": id}, &edition); err != nil {
+		return nil, err
+	}
+	return &edition, nil
+}
+
+func (m *mongoEditions) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Edition, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := m.collection.Find(ctx, bson.M{"bookID": bookID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var editions []models.Edition
+	if err := cursor.All(ctx, &editions); err != nil {
+		return nil, err
+	}
+	return editions, nil
+}
+
+func (m *mongoEditions) ListByIdentifier(ctx context.Context, value string) ([]models.Edition, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"identifiers.value": value})
+	if err != nil {
+		return nil, err
+	}
+	var editions []models.Edition
+	if err := cursor.All(ctx, &editions); err != nil {
+		return nil, err
+	}
+	return editions, nil
+}
+
+func (m *mongoEditions) Find(ctx context.Context, q Query) (*Page[models.Edition], error) {
+	return findPage[models.Edition](ctx, m.collection, bson.M{}, q)
+}
+
+func (m *mongoEditions) Update(ctx context.Context, id primitive.ObjectID, edition models.Edition) error {
+	update := bson.M{"$set": bson.M{
+		"name":        edition.Name,
+		"format":      edition.Format,
+		"identifiers": edition.Identifiers,
+		"prices":      edition.Prices,
+		"settings":    edition.Settings,
+		"overrides":   edition.Overrides,
+	}}
+	return updateByID(ctx, m.collection, id, update)
+}
+
+func (m *mongoEditions) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
+
+func (m *mongoEditions) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return deleteByBook(ctx, m.collection, bookID)
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoImages struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoImages) Insert(ctx context.Context, image models.ChapterImages) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, image)
+}
+
+func (m *mongoImages) Get(ctx context.Context, id primitive.ObjectID) (*models.ChapterImages, error) {
+	var image models.ChapterImages
+	if err := findOne(ctx, m.collection, live(bson.M{"_id": id}), &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (m *mongoImages) GetForChapter(ctx context.Context, bookID primitive.ObjectID, chapterNum int) (*models.ChapterImages, error) {
+	var image models.ChapterImages
+	if err := findOne(ctx, m.collection, live(bson.M{"bookID": bookID, "chapterNum": chapterNum, "type": bson.M{"$nin": []string{models.ImageTypeInline, models.ImageTypePage}}}), &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (m *mongoImages) List(ctx context.Context) ([]models.ChapterImages, error) {
+	var images []models.ChapterImages
+	if err := findAll(ctx, m.collection, live(bson.M{}), &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (m *mongoImages) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.ChapterImages, error) {
+	var images []models.ChapterImages
+	if err := findAll(ctx, m.collection, live(bson.M{"bookID": bookID}), &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (m *mongoImages) Find(ctx context.Context, q Query) (*Page[models.ChapterImages], error) {
+	return findPage[models.ChapterImages](ctx, m.collection, live(bson.M{}), q)
+}
+
+func (m *mongoImages) Describe(ctx context.Context, id primitive.ObjectID, description models.ImageDescription) error {
+	update := bson.M{"$set": bson.M{
+		"altText":         description.AltText,
+		"longDescription": description.LongDescription,
+		"decorative":      description.Decorative,
+	}}
+	return updateMatching(ctx, m.collection, live(bson.M{"_id": id}), update)
+}
+
+func (m *mongoImages) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
+
+func (m *mongoImages) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return deleteByBook(ctx, m.collection, bookID)
+}
+
+func (m *mongoImages) Trash(ctx context.Context, id primitive.ObjectID, mark models.Trash) error {
+	return trashByID(ctx, m.collection, id, mark)
+}
+
+func (m *mongoImages) TrashByBook(ctx context.Context, bookID primitive.ObjectID, mark models.Trash) (int64, error) {
+	return trashByBook(ctx, m.collection, bookID, mark)
+}
+
+func (m *mongoImages) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreByID(ctx, m.collection, id)
+}
+
+func (m *mongoImages) GetTrashed(ctx context.Context, id primitive.ObjectID) (*models.ChapterImages, error) {
+	var image models.ChapterImages
+	if err := findOne(ctx, m.collection, trashed(bson.M{"_id": id}), &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func (m *mongoImages) ListTrashed(ctx context.Context, filter TrashFilter) ([]models.ChapterImages, error) {
+	var images []models.ChapterImages
+	if err := findAll(ctx, m.collection, trashMatch(filter, "bookID"), &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
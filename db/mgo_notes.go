@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoNotes struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoNotes) Insert(ctx context.Context, note models.Notes) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, note)
+}
+
+func (m *mongoNotes) Get(ctx context.Context, id primitive.ObjectID) (*models.Notes, error) {
+	var note models.Notes
+	if err := findOne(ctx, m.collection, live(bson.M{"_id": id}), &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (m *mongoNotes) Find(ctx context.Context, q Query) (*Page[models.Notes], error) {
+	return findPage[models.Notes](ctx, m.collection, live(bson.M{}), q)
+}
+
+func (m *mongoNotes) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Notes, error) {
+	var notes []models.Notes
+	if err := findAll(ctx, m.collection, live(bson.M{"bookID": bookID}), &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (m *mongoNotes) Update(ctx context.Context, id primitive.ObjectID, note models.Notes) error {
+	update := bson.M{"$set": bson.M{
+		"title":     note.Title,
+		"text":      note.Text,
+		"type":      note.Type,
+		"bookID":    note.BookID,
+		"versionID": note.VersionID,
+	}}
+	return updateMatching(ctx, m.collection, live(bson.M{"_id": id}), update)
+}
+
+func (m *mongoNotes) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
+
+func (m *mongoNotes) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return deleteByBook(ctx, m.collection, bookID)
+}
+
+func (m *mongoNotes) DeleteBySeries(ctx context.Context, seriesID primitive.ObjectID) (int64, error) {
+	result, err := m.collection.DeleteMany(ctx, bson.M{"seriesID": seriesID})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (m *mongoNotes) Trash(ctx context.Context, id primitive.ObjectID, mark models.Trash) error {
+	return trashByID(ctx, m.collection, id, mark)
+}
+
+func (m *mongoNotes) TrashByBook(ctx context.Context, bookID primitive.ObjectID, mark models.Trash) (int64, error) {
+	return trashByBook(ctx, m.collection, bookID, mark)
+}
+
+func (m *mongoNotes) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreByID(ctx, m.collection, id)
+}
+
+func (m *mongoNotes) GetTrashed(ctx context.Context, id primitive.ObjectID) (*models.Notes, error) {
+	var note models.Notes
+	if err := findOne(ctx, m.collection, trashed(bson.M{"_id": id}), &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (m *mongoNotes) ListTrashed(ctx context.Context, filter TrashFilter) ([]models.Notes, error) {
+	var notes []models.Notes
+	if err := findAll(ctx, m.collection, trashMatch(filter, "bookID"), &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
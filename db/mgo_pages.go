@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoPages struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoPages) Insert(ctx context.Context, page models.Page) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, page)
+}
+
+func (m *mongoPages) Get(ctx context.Context, id primitive.ObjectID) (*models.Page, error) {
+	var page models.Page
+	if err := findOne(ctx, m.collection, bson.M{"_id": id}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (m *mongoPages) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Page, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "pageNum", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := m.collection.Find(ctx, bson.M{"bookID": bookID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var pages []models.Page
+	if err := cursor.All(ctx, &pages); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+func (m *mongoPages) Find(ctx context.Context, q Query) (*Page[models.Page], error) {
+	return findPage[models.Page](ctx, m.collection, bson.M{}, q)
+}
+
+func (m *mongoPages) Update(ctx context.Context, id primitive.ObjectID, page models.Page) error {
+	update := bson.M{"$set": bson.M{
+		"imageID": page.ImageID,
+		"spread":  page.Spread,
+		"regions": page.Regions,
+	}}
+	return updateByID(ctx, m.collection, id, update)
+}
+
+func (m *mongoPages) Renumber(ctx context.Context, bookID primitive.ObjectID, order []primitive.ObjectID) error {
+	if len(order) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, 0, len(order))
+	for i, id := range order {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "bookID": bookID}).
+			SetUpdate(bson.M{"$set": bson.M{"pageNum": i + 1}}))
+	}
+	_, err := m.collection.BulkWrite(ctx, writes)
+	return err
+}
+
+func (m *mongoPages) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
+
+func (m *mongoPages) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return deleteByBook(ctx, m.collection, bookID)
+}
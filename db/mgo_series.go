@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoSeries struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoSeries) Insert(ctx context.Context, series models.Series) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, series)
+}
+
+func (m *mongoSeries) Get(ctx context.Context, id primitive.ObjectID) (*models.Series, error) {
+	var series models.Series
+	if err := findOne(ctx, m.collection, bson.M{"_id": id}, &series); err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (m *mongoSeries) GetByBook(ctx context.Context, bookID primitive.ObjectID) (*models.Series, error) {
+	var series models.Series
+	if err := findOne(ctx, m.collection, bson.M{"bookIDs": bookID}, &series); err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+func (m *mongoSeries) Find(ctx context.Context, q Query) (*Page[models.Series], error) {
+	return findPage[models.Series](ctx, m.collection, bson.M{}, q)
+}
+
+func (m *mongoSeries) Update(ctx context.Context, id primitive.ObjectID, series models.Series) error {
+	update := bson.M{"$set": bson.M{
+		"name":        series.Name,
+		"description": series.Description,
+		"bookIDs":     series.BookIDs,
+	}}
+	return updateByID(ctx, m.collection, id, update)
+}
+
+func (m *mongoSeries) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
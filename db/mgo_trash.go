@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// trashFields are the fields a models.Trash is stored in
+var trashFields = bson.A{"deletedAt", "deletedBy", "withBook"}
+
+// live restricts filter to documents that aren't trashed
+func live(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// trashed restricts filter to trashed documents
+func trashed(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": true}
+	return filter
+}
+
+func trashSet(mark models.Trash) bson.M {
+	set := bson.M{"deletedAt": mark.DeletedAt}
+	if mark.DeletedBy != nil {
+		set["deletedBy"] = *mark.DeletedBy
+	}
+	if mark.WithBook {
+		set["withBook"] = true
+	}
+	return set
+}
+
+func trashByID(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID, mark models.Trash) error {
+	result, err := collection.UpdateOne(ctx, live(bson.M{"_id": id}), bson.M{"$set": trashSet(mark)})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func trashByBook(ctx context.Context, collection *mongo.Collection, bookID primitive.ObjectID, mark models.Trash) (int64, error) {
+	result, err := collection.UpdateMany(ctx, live(bson.M{"bookID": bookID}), bson.M{"$set": trashSet(mark)})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func restoreByID(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID) error {
+	unset := bson.M{}
+	for _, field := range trashFields {
+		unset[field.(string)] = ""
+	}
+	result, err := collection.UpdateOne(ctx, trashed(bson.M{"_id": id}), bson.M{"$unset": unset})
+	if err != nil {
+		return writeError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// trashMatch builds the Mongo filter for a TrashFilter; bookField names the
+// field holding the book ID, which is _id for books themselves
+func trashMatch(filter TrashFilter, bookField string) bson.M {
+	match := trashed(bson.M{})
+	if !filter.DeletedBy.IsZero() {
+		match["deletedBy"] = filter.DeletedBy
+	}
+	if !filter.BookID.IsZero() {
+		match[bookField] = filter.BookID
+	}
+	if !filter.Before.IsZero() {
+		match["deletedAt"] = bson.M{"$lt": filter.Before}
+	}
+	return match
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoUsers struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoUsers) Insert(ctx context.Context, user models.User) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, user)
+}
+
+func (m *mongoUsers) Get(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, m.collection, bson.M{"_id": id}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m *mongoUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, m.collection, bson.M{"email": email}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m *mongoUsers) List(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	if err := findAll(ctx, m.collection, bson.M{}, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (m *mongoUsers) Update(ctx context.Context, id primitive.ObjectID, user models.User) error {
+	// the ID is never rewritten, it stays whatever the path said
+	user.ID = primitive.NilObjectID
+	return updateByID(ctx, m.collection, id, bson.M{"$set": user})
+}
+
+func (m *mongoUsers) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, m.collection, id)
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoVersions struct {
+	collection *mongo.Collection
+}
+
+func (m *mongoVersions) Insert(ctx context.Context, version models.Version) (primitive.ObjectID, error) {
+	return insertOne(ctx, m.collection, version)
+}
+
+func (m *mongoVersions) Get(ctx context.Context, id primitive.ObjectID) (*models.Version, error) {
+	var version models.Version
+	if err := findOne(ctx, m.collection, bson.M{"_id": id}, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (m *mongoVersions) ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Version, error) {
+	var versions []models.Version
+	if err := findAll(ctx, m.collection, bson.M{"bookID": bookID}, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (m *mongoVersions) Find(ctx context.Context, q Query) (*Page[models.Version], error) {
+	return findPage[models.Version](ctx, m.collection, bson.M{}, q)
+}
+
+func (m *mongoVersions) DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	return deleteByBook(ctx, m.collection, bookID)
+}
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// backfillObjectIDs converts bookID and versionID values stored as hex
+// strings into ObjectIDs, so lookups no longer have to match both forms
+var backfillObjectIDs = Migration{
+	Version: 1,
+	Name:    "backfill_object_ids",
+	Up: func(ctx context.Context, env Env) error {
+		targets := map[string][]string{
+			env.Collections.Chapters: {"bookID", "versionID"},
+			env.Collections.Notes:    {"bookID", "versionID"},
+			env.Collections.Images:   {"bookID"},
+			env.Collections.Versions: {"bookID"},
+		}
+		for collection, fields := range targets {
+			for _, field := range fields {
+				if err := convertHexStrings(ctx, env.Collection(collection), field); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+	// ObjectIDs are what the application writes, there is nothing to restore
+	Down: func(ctx context.Context, env Env) error {
+		return nil
+	},
+}
+
+func convertHexStrings(ctx context.Context, collection *mongo.Collection, field string) error {
+	cursor, err := collection.Find(ctx, bson.M{field: bson.M{"$type": "string"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var writes []mongo.WriteModel
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		value, _ := doc[field].(string)
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			// not an ID at all, leave it for someone to look at
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc["_id"], field: value}).
+			SetUpdate(bson.M{"$set": bson.M{field: id}}))
+
+		if len(writes) == 500 {
+			if _, err := collection.BulkWrite(ctx, writes); err != nil {
+				return err
+			}
+			writes = writes[:0]
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if len(writes) > 0 {
+		_, err = collection.BulkWrite(ctx, writes)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const userEmailIndex = "email_unique"
+
+// uniqueUserEmail stops two accounts from registering the same email
+var uniqueUserEmail = Migration{
+	Version: 2,
+	Name:    "unique_user_email",
+	Up: func(ctx context.Context, env Env) error {
+		users := env.Collection(env.Collections.Users)
+		if err := reportDuplicateEmails(ctx, users); err != nil {
+			return err
+		}
+		_, err := users.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName(userEmailIndex).SetUnique(true),
+		})
+		return err
+	},
+	Down: func(ctx context.Context, env Env) error {
+		return dropIndexes(ctx, env.Collection(env.Collections.Users), userEmailIndex)
+	},
+}
+
+// reportDuplicateEmails fails with the offending addresses, which is more
+// useful than the index build's duplicate key error
+func reportDuplicateEmails(ctx context.Context, users *mongo.Collection) error {
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		Email string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	emails := make([]string, 0, len(duplicates))
+	for _, d := range duplicates {
+		emails = append(emails, fmt.Sprintf("%s (%d accounts)", d.Email, d.Count))
+	}
+	return fmt.Errorf("resolve duplicate user emails first: %v", emails)
+}
+
+func dropIndexes(ctx context.Context, collection *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		_, err := collection.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		// IndexNotFound, already gone
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == 27) {
+			return err
+		}
+	}
+	return nil
+}
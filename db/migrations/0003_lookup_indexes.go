@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	chapterSlotIndex  = "bookID_versionID_chapterNum_unique"
+	noteBookIndex     = "bookID_versionID"
+	imageChapterIndex = "bookID_chapterNum"
+	versionBookIndex  = "bookID"
+	bookOwnerIndex    = "ownerID"
+)
+
+// lookupIndexes covers the bookID lookups every list endpoint makes and
+// keeps two chapters of a book version from sharing a number
+var lookupIndexes = Migration{
+	Version: 3,
+	Name:    "lookup_indexes",
+	Up: func(ctx context.Context, env Env) error {
+		if err := reportDuplicateChapters(ctx, env.Collection(env.Collections.Chapters)); err != nil {
+			return err
+		}
+		indexes := map[string]mongo.IndexModel{
+			env.Collections.Chapters: {
+				Keys: bson.D{{Key: "bookID", Value: 1}, {Key: "versionID", Value: 1}, {Key: "chapterNum", Value: 1}},
+				// chapters numbered 0 don't store the field and are exempt
+				Options: options.Index().SetName(chapterSlotIndex).SetUnique(true).
+					SetPartialFilterExpression(bson.M{"chapterNum": bson.M{"$exists": true}}),
+			},
+			env.Collections.Notes: {
+				Keys:    bson.D{{Key: "bookID", Value: 1}, {Key: "versionID", Value: 1}},
+				Options: options.Index().SetName(noteBookIndex),
+			},
+			env.Collections.Images: {
+				Keys:    bson.D{{Key: "bookID", Value: 1}, {Key: "chapterNum", Value: 1}},
+				Options: options.Index().SetName(imageChapterIndex),
+			},
+			env.Collections.Versions: {
+				Keys:    bson.D{{Key: "bookID", Value: 1}},
+				Options: options.Index().SetName(versionBookIndex),
+			},
+			env.Collections.Books: {
+				Keys:    bson.D{{Key: "ownerID", Value: 1}},
+				Options: options.Index().SetName(bookOwnerIndex),
+			},
+		}
+		for collection, index := range indexes {
+			if _, err := env.Collection(collection).Indexes().CreateOne(ctx, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, env Env) error {
+		drops := map[string]string{
+			env.Collections.Chapters: chapterSlotIndex,
+			env.Collections.Notes:    noteBookIndex,
+			env.Collections.Images:   imageChapterIndex,
+			env.Collections.Versions: versionBookIndex,
+			env.Collections.Books:    bookOwnerIndex,
+		}
+		for collection, name := range drops {
+			if err := dropIndexes(ctx, env.Collection(collection), name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// reportDuplicateChapters fails with the book versions holding two
+// chapters of the same number, which is more useful than the index build's
+// duplicate key error
+func reportDuplicateChapters(ctx context.Context, chapters *mongo.Collection) error {
+	cursor, err := chapters.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"chapterNum": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"bookID": "$bookID", "versionID": "$versionID", "chapterNum": "$chapterNum"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		Slot struct {
+			BookID     primitive.ObjectID `bson:"bookID"`
+			VersionID  primitive.ObjectID `bson:"versionID"`
+			ChapterNum int                `bson:"chapterNum"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	slots := make([]string, 0, len(duplicates))
+	for _, d := range duplicates {
+		slot := fmt.Sprintf("book %s chapter %d (%d chapters)", d.Slot.BookID.Hex(), d.Slot.ChapterNum, d.Count)
+		if !d.Slot.VersionID.IsZero() {
+			slot = fmt.Sprintf("book %s version %s chapter %d (%d chapters)", d.Slot.BookID.Hex(), d.Slot.VersionID.Hex(), d.Slot.ChapterNum, d.Count)
+		}
+		slots = append(slots, slot)
+	}
+	return fmt.Errorf("resolve chapters sharing a number first: %v", slots)
+}
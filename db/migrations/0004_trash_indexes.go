@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const trashIndex = "deletedBy_deletedAt"
+
+// trashIndexes serves the per-user trash listing and the retention purge.
+// Only trashed documents are indexed, so live reads pay nothing for it.
+var trashIndexes = Migration{
+	Version: 4,
+	Name:    "trash_indexes",
+	Up: func(ctx context.Context, env Env) error {
+		index := mongo.IndexModel{
+			Keys: bson.D{{Key: "deletedBy", Value: 1}, {Key: "deletedAt", Value: 1}},
+			Options: options.Index().SetName(trashIndex).
+				SetPartialFilterExpression(bson.M{"deletedAt": bson.M{"$exists": true}}),
+		}
+		for _, collection := range trashCollections(env) {
+			if _, err := env.Collection(collection).Indexes().CreateOne(ctx, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(ctx context.Context, env Env) error {
+		for _, collection := range trashCollections(env) {
+			if err := dropIndexes(ctx, env.Collection(collection), trashIndex); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func trashCollections(env Env) []string {
+	return []string{env.Collections.Books, env.Collections.Chapters, env.Collections.Notes, env.Collections.Images}
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const pageOrderIndex = "bookID_pageNum"
+
+// pageIndexes serves listing a fixed-layout book's pages in order. Pages
+// briefly share numbers while they are renumbered, so it isn't unique.
+var pageIndexes = Migration{
+	Version: 5,
+	Name:    "page_indexes",
+	Up: func(ctx context.Context, env Env) error {
+		index := mongo.IndexModel{
+			Keys:    bson.D{{Key: "bookID", Value: 1}, {Key: "pageNum", Value: 1}},
+			Options: options.Index().SetName(pageOrderIndex),
+		}
+		_, err := env.Collection(env.Collections.Pages).Indexes().CreateOne(ctx, index)
+		return err
+	},
+	Down: func(ctx context.Context, env Env) error {
+		return dropIndexes(ctx, env.Collection(env.Collections.Pages), pageOrderIndex)
+	},
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const editionBookIndex = "bookID"
+
+// editionIndexes serves listing a book's editions, which every export
+// that names one does
+var editionIndexes = Migration{
+	Version: 6,
+	Name:    "edition_indexes",
+	Up: func(ctx context.Context, env Env) error {
+		index := mongo.IndexModel{
+			Keys:    bson.D{{Key: "bookID", Value: 1}},
+			Options: options.Index().SetName(editionBookIndex),
+		}
+		_, err := env.Collection(env.Collections.Editions).Indexes().CreateOne(ctx, index)
+		return err
+	},
+	Down: func(ctx context.Context, env Env) error {
+		return dropIndexes(ctx, env.Collection(env.Collections.Editions), editionBookIndex)
+	},
+}
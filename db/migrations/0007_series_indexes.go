@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	seriesBookIndex  = "bookIDs"
+	seriesOwnerIndex = "ownerID"
+	noteSeriesIndex  = "seriesID"
+)
+
+// seriesIndexes serve finding the series a book is in, listing a user's
+// series and listing a series' notes. Only series notes store seriesID.
+var seriesIndexes = Migration{
+	Version: 7,
+	Name:    "series_indexes",
+	Up: func(ctx context.Context, env Env) error {
+		series := []mongo.IndexModel{
+			{Keys: bson.D{{Key: "bookIDs", Value: 1}}, Options: options.Index().SetName(seriesBookIndex)},
+			{Keys: bson.D{{Key: "ownerID", Value: 1}}, Options: options.Index().SetName(seriesOwnerIndex)},
+		}
+		if _, err := env.Collection(env.Collections.Series).Indexes().CreateMany(ctx, series); err != nil {
+			return err
+		}
+		notes := mongo.IndexModel{
+			Keys: bson.D{{Key: "seriesID", Value: 1}},
+			Options: options.Index().SetName(noteSeriesIndex).
+				SetPartialFilterExpression(bson.M{"seriesID": bson.M{"$exists": true}}),
+		}
+		_, err := env.Collection(env.Collections.Notes).Indexes().CreateOne(ctx, notes)
+		return err
+	},
+	Down: func(ctx context.Context, env Env) error {
+		if err := dropIndexes(ctx, env.Collection(env.Collections.Series), seriesBookIndex, seriesOwnerIndex); err != nil {
+			return err
+		}
+		return dropIndexes(ctx, env.Collection(env.Collections.Notes), noteSeriesIndex)
+	},
+}
@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const editionIdentifierIndex = "identifiers_unique"
+
+// uniqueEditionIdentifiers stops two editions, of the same book or not,
+// from carrying the same ISBN, ASIN or UUID. Editions without identifiers
+// are left out of the index.
+var uniqueEditionIdentifiers = Migration{
+	Version: 8,
+	Name:    "unique_edition_identifiers",
+	Up: func(ctx context.Context, env Env) error {
+		editions := env.Collection(env.Collections.Editions)
+		if err := reportDuplicateIdentifiers(ctx, editions); err != nil {
+			return err
+		}
+		_, err := editions.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "identifiers.value", Value: 1}},
+			Options: options.Index().SetName(editionIdentifierIndex).SetUnique(true).
+				SetPartialFilterExpression(bson.M{"identifiers.value": bson.M{"$exists": true}}),
+		})
+		return err
+	},
+	Down: func(ctx context.Context, env Env) error {
+		return dropIndexes(ctx, env.Collection(env.Collections.Editions), editionIdentifierIndex)
+	},
+}
+
+// reportDuplicateIdentifiers fails with the identifiers more than one
+// edition carries, which is more useful than the index build's duplicate
+// key error
+func reportDuplicateIdentifiers(ctx context.Context, editions *mongo.Collection) error {
+	cursor, err := editions.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$identifiers"}},
+		// an edition listing a value twice conflicts with no other
+		{{Key: "$group", Value: bson.M{"_id": "$identifiers.value", "editions": bson.M{"$addToSet": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"editions.1": bson.M{"$exists": true}}}},
+		{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		Value    string        `bson:"_id"`
+		Editions []interface{} `bson:"editions"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(duplicates))
+	for _, d := range duplicates {
+		values = append(values, fmt.Sprintf("%s (%d editions)", d.Value, len(d.Editions)))
+	}
+	return fmt.Errorf("resolve edition identifiers shared by several editions first: %v", values)
+}
@@ -0,0 +1,213 @@
+// Package migrations applies ordered, versioned changes to the Mongo
+// database and records which ones have run.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrLocked is returned when another process is running migrations
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// staleLock is how long a lock may be held before it is considered abandoned
+const staleLock = 15 * time.Minute
+
+// Env is what a migration may touch
+type Env struct {
+	DB          *mongo.Database
+	Collections db.Collections
+}
+
+// Collection returns a collection of the migrated database
+func (e Env) Collection(name string) *mongo.Collection {
+	return e.DB.Collection(name)
+}
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, env Env) error
+	// Down reverts Up; nil means the migration cannot be reverted
+	Down func(ctx context.Context, env Env) error
+}
+
+// Record is the document stored for every applied migration
+type Record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// Status describes one known migration
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+type Runner struct {
+	env        Env
+	records    *mongo.Collection
+	migrations []Migration
+}
+
+// NewRunner returns a runner for the built-in migrations, recording them
+// in the named collection
+func NewRunner(database *mongo.Database, collections db.Collections, recordsCollection string) *Runner {
+	return &Runner{
+		env:        Env{DB: database, Collections: collections},
+		records:    database.Collection(recordsCollection),
+		migrations: All(),
+	}
+}
+
+// Status lists every known migration and whether it has been applied
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if record, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (r *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations in order, up to and including target.
+// A target of zero applies everything.
+func (r *Runner) Up(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(ctx, func() error {
+		pending, err := r.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			if target > 0 && m.Version > target {
+				break
+			}
+			if err := m.Up(ctx, r.env); err != nil {
+				return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+			}
+			record := Record{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}
+			if _, err := r.records.InsertOne(ctx, record); err != nil {
+				return fmt.Errorf("recording migration %d: %w", m.Version, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the most recently applied migrations, newest first
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(ctx, func() error {
+		applied, err := r.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migration %d %s cannot be reverted", m.Version, m.Name)
+			}
+			if err := m.Down(ctx, r.env); err != nil {
+				return fmt.Errorf("reverting migration %d %s: %w", m.Version, m.Name, err)
+			}
+			if _, err := r.records.DeleteOne(ctx, bson.M{"_id": m.Version}); err != nil {
+				return fmt.Errorf("unrecording migration %d: %w", m.Version, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]Record, error) {
+	// the lock document shares the collection but has a string ID
+	cursor, err := r.records.Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// locked runs fn while holding the migration lock
+func (r *Runner) locked(ctx context.Context, fn func() error) error {
+	now := time.Now().UTC()
+	_, err := r.records.UpdateOne(ctx,
+		bson.M{"_id": "lock", "lockedAt": bson.M{"$lt": now.Add(-staleLock)}},
+		bson.M{"$set": bson.M{"lockedAt": now}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLocked
+	}
+	if err != nil {
+		return err
+	}
+	defer r.records.DeleteOne(context.Background(), bson.M{"_id": "lock", "lockedAt": now})
+
+	return fn()
+}
+
+// All returns the built-in migrations ordered by version
+func All() []Migration {
+	all := []Migration{
+		backfillObjectIDs,
+		uniqueUserEmail,
+		lookupIndexes,
+		trashIndexes,
+		pageIndexes,
+		editionIndexes,
+		seriesIndexes,
+		uniqueEditionIdentifiers,
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
@@ -1,56 +1,429 @@
 package db
 
 import (
-	"net/url"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func ParseQuery(query url.Values) (bson.M, error) {
-	pipe := make([]bson.M, 0)
-	for key, values := range query {
-		switch key {
-		case "bookID":
-			in := make([]interface{}, 0)
-			for _, value := range values {
-				in = append(in, value)
-				if id, err := primitive.ObjectIDFromHex(value); err == nil {
-					in = append(in, id)
+// ErrInvalidQuery is wrapped by every query parsing and cursor error
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Op compares a document field with a filter value
+type Op string
+
+const (
+	OpEq     Op = "eq"
+	OpNe     Op = "ne"
+	OpIn     Op = "in"
+	OpPrefix Op = "prefix"
+	OpGt     Op = "gt"
+	OpGte    Op = "gte"
+	OpLt     Op = "lt"
+	OpLte    Op = "lte"
+	OpExists Op = "exists"
+)
+
+// Filter compares the stored field Field with Value. Values are stored
+// types: primitive.ObjectID, string, int, bool or primitive.DateTime, and
+// []interface{} for OpIn.
+type Filter struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+// SortField orders by a stored field
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Query selects a page of documents. The zero Query returns everything in
+// insertion order.
+type Query struct {
+	// Filters must all match
+	Filters []Filter
+	// Any, when not empty, must have at least one match
+	Any  []Filter
+	Sort []SortField
+	// Fields limits the stored fields loaded; nil loads everything
+	Fields []string
+	// Select names the JSON fields the caller asked for, for shaping responses
+	Select []string
+	// Limit is the page size, 0 means no limit
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// Page is one page of a listing. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Where returns a copy of q with another filter
+func (q Query) Where(field string, op Op, value interface{}) Query {
+	q.Filters = append(append([]Filter{}, q.Filters...), Filter{Field: field, Op: op, Value: value})
+	return q
+}
+
+// order is the sort with _id appended, so every document has a distinct key
+func (q Query) order() []SortField {
+	order := append([]SortField{}, q.Sort...)
+	for _, field := range order {
+		if field.Field == "_id" {
+			return order
+		}
+	}
+	return append(order, SortField{Field: "_id"})
+}
+
+// signature identifies the sort a cursor was made for
+func (q Query) signature() string {
+	parts := make([]string, 0, len(q.Sort)+1)
+	for _, field := range q.order() {
+		if field.Desc {
+			parts = append(parts, "-"+field.Field)
+		} else {
+			parts = append(parts, field.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+type cursorData struct {
+	Sort string        `bson:"s"`
+	Keys []interface{} `bson:"k"`
+}
+
+// cursorFor encodes the sort key of the last document on a page. BSON keeps
+// the key types intact, base64 keeps the cursor opaque.
+func (q Query) cursorFor(doc bson.M) (string, error) {
+	data := cursorData{Sort: q.signature()}
+	for _, field := range q.order() {
+		data.Keys = append(data.Keys, doc[field.Field])
+	}
+	raw, err := bson.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// after decodes the cursor into the sort key to continue after, or nil
+func (q Query) after() ([]interface{}, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var data cursorData
+	if err := bson.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if data.Sort != q.signature() || len(data.Keys) != len(q.order()) {
+		return nil, fmt.Errorf("%w: cursor belongs to a different sort order", ErrInvalidQuery)
+	}
+	return data.Keys, nil
+}
+
+// NewPage trims the limit+1 documents a backend loaded to a page. docs are
+// the stored form of items, used to build the next cursor.
+func NewPage[T any](q Query, items []T, doc func(i int) (bson.M, error)) (*Page[T], error) {
+	page := &Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if q.Limit <= 0 || len(items) <= q.Limit {
+		return page, nil
+	}
+	page.Items = items[:q.Limit]
+	last, err := doc(q.Limit - 1)
+	if err != nil {
+		return nil, err
+	}
+	if page.NextCursor, err = q.cursorFor(last); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Matcher returns a function reporting whether a stored document belongs
+// in the result, for backends that filter in process
+func (q Query) Matcher() (func(doc bson.M) bool, error) {
+	after, err := q.after()
+	if err != nil {
+		return nil, err
+	}
+	order := q.order()
+	return func(doc bson.M) bool {
+		for _, filter := range q.Filters {
+			if !filter.matches(doc) {
+				return false
+			}
+		}
+		if len(q.Any) > 0 {
+			any := false
+			for _, filter := range q.Any {
+				if filter.matches(doc) {
+					any = true
+					break
 				}
 			}
-			if len(in) > 0 {
-				pipe = append(pipe, bson.M{"bookID": bson.M{"$in": in}})
+			if !any {
+				return false
 			}
-		case "versionID":
-			in := make([]interface{}, 0)
-			for _, value := range values {
-				in = append(in, value)
-				if id, err := primitive.ObjectIDFromHex(value); err == nil {
-					in = append(in, id)
+		}
+		if after != nil {
+			for i, field := range order {
+				c := compareValues(doc[field.Field], after[i])
+				if field.Desc {
+					c = -c
+				}
+				if c != 0 {
+					return c > 0
 				}
 			}
-			if len(in) > 0 {
-				pipe = append(pipe, bson.M{"versionID": bson.M{"$in": in}})
+			return false
+		}
+		return true
+	}, nil
+}
+
+// Compare orders two stored documents by the query's sort
+func (q Query) Compare(a, b bson.M) int {
+	for _, field := range q.order() {
+		c := compareValues(a[field.Field], b[field.Field])
+		if field.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (f Filter) matches(doc bson.M) bool {
+	value, present := doc[f.Field]
+	switch f.Op {
+	case OpEq:
+		return compareValues(value, f.Value) == 0
+	case OpNe:
+		return compareValues(value, f.Value) != 0
+	case OpIn:
+		values, _ := f.Value.([]interface{})
+		for _, candidate := range values {
+			if compareValues(value, candidate) == 0 {
+				return true
 			}
-		case "noteID":
-            in := make([]interface{}, 0)
-            for _, value := range values {
-                if primitive.IsValidObjectID(value) {
-                    if id, err := primitive.ObjectIDFromHex(value); err == nil {
-                        in = append(in, id)
-                    }
-                } else {
-                    in = append(in, value)
-                }
-            }
-            if len(in) > 0 {
-                pipe = append(pipe, bson.M{"_id": bson.M{"$in": in}})
-            }
-		}
-	}
-	if len(pipe) > 0 {
-		return bson.M{"$and": pipe}, nil
-	}
-	return bson.M{}, nil
+		}
+		return false
+	case OpPrefix:
+		s, ok := value.(string)
+		prefix, _ := f.Value.(string)
+		return ok && strings.HasPrefix(s, prefix)
+	case OpExists:
+		want, _ := f.Value.(bool)
+		return (present && value != nil) == want
+	case OpGt, OpGte, OpLt, OpLte:
+		if value == nil || typeRank(value) != typeRank(f.Value) {
+			return false
+		}
+		c := compareValues(value, f.Value)
+		switch f.Op {
+		case OpGt:
+			return c > 0
+		case OpGte:
+			return c >= 0
+		case OpLt:
+			return c < 0
+		default:
+			return c <= 0
+		}
+	}
+	return false
+}
+
+// typeRank brackets BSON types the way MongoDB orders them; missing and
+// null sort first
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null:
+		return 0
+	case int, int32, int64, float64:
+		return 1
+	case string:
+		return 2
+	case primitive.ObjectID:
+		return 3
+	case bool:
+		return 4
+	case primitive.DateTime:
+		return 5
+	}
+	return 6
+}
+
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case primitive.ObjectID:
+		y := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareNumbers(float64(x), float64(b.(primitive.DateTime)))
+	case int, int32, int64, float64:
+		return compareNumbers(toFloat(a), toFloat(b))
+	}
+	return 0
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// mongoFilter adds the query's filters and cursor position to base
+func (q Query) mongoFilter(base bson.M) (bson.M, error) {
+	after, err := q.after()
+	if err != nil {
+		return nil, err
+	}
+	and := bson.A{}
+	for _, filter := range q.Filters {
+		and = append(and, filter.mongo())
+	}
+	if len(q.Any) > 0 {
+		or := bson.A{}
+		for _, filter := range q.Any {
+			or = append(or, filter.mongo())
+		}
+		and = append(and, bson.M{"$or": or})
+	}
+	if after != nil {
+		and = append(and, keysetMatch(q.order(), after))
+	}
+	if len(and) > 0 {
+		base["$and"] = and
+	}
+	return base, nil
+}
+
+func (f Filter) mongo() bson.M {
+	switch f.Op {
+	case OpEq:
+		return bson.M{f.Field: f.Value}
+	case OpPrefix:
+		prefix, _ := f.Value.(string)
+		return bson.M{f.Field: bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}
+	default:
+		return bson.M{f.Field: bson.M{"$" + string(f.Op): f.Value}}
+	}
+}
+
+// keysetMatch selects documents sorting after the given key. Missing
+// fields sort first, as in MongoDB, and match null.
+func keysetMatch(order []SortField, after []interface{}) bson.M {
+	or := bson.A{}
+	for i, field := range order {
+		clause := bson.A{}
+		for j := 0; j < i; j++ {
+			clause = append(clause, bson.M{order[j].Field: after[j]})
+		}
+		value := after[i]
+		switch {
+		case value == nil && field.Desc:
+			// nothing sorts below missing
+			continue
+		case value == nil:
+			clause = append(clause, bson.M{field.Field: bson.M{"$ne": nil}})
+		case field.Desc:
+			clause = append(clause, bson.M{"$or": bson.A{
+				bson.M{field.Field: bson.M{"$lt": value}},
+				bson.M{field.Field: nil},
+			}})
+		default:
+			clause = append(clause, bson.M{field.Field: bson.M{"$gt": value}})
+		}
+		or = append(or, bson.M{"$and": clause})
+	}
+	if len(or) == 0 {
+		// the cursor was the very last key
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": or}
+}
+
+func (q Query) mongoSort() bson.D {
+	sort := bson.D{}
+	for _, field := range q.order() {
+		direction := 1
+		if field.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: field.Field, Value: direction})
+	}
+	return sort
+}
+
+// mongoProjection loads the requested fields plus those the cursor needs
+func (q Query) mongoProjection() bson.M {
+	if len(q.Fields) == 0 {
+		return nil
+	}
+	projection := bson.M{}
+	for _, field := range q.Fields {
+		projection[field] = 1
+	}
+	for _, field := range q.order() {
+		projection[field.Field] = 1
+	}
+	return projection
 }
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNotFound is returned when a lookup, update or delete matches no document
+var ErrNotFound = errors.New("document not found")
+
+// ErrDuplicate is returned when a write would break a unique index
+var ErrDuplicate = errors.New("duplicate document")
+
+// ErrTransactionsUnsupported is returned by stores that cannot run
+// multi-document transactions, such as a standalone mongod
+var ErrTransactionsUnsupported = errors.New("transactions are not supported by this database")
+
+// InsertResult mirrors the body the API has always returned for created documents
+type InsertResult struct {
+	InsertedID primitive.ObjectID
+}
+
+// Store bundles the repositories the handlers depend on
+type Store struct {
+	Users    UserRepository
+	Books    BookRepository
+	Chapters ChapterRepository
+	Images   ImageRepository
+	Versions VersionRepository
+	Notes    NoteRepository
+	Blobs    BlobRepository
+	Pages    PageRepository
+	Editions EditionRepository
+	Series   SeriesRepository
+	Health   HealthChecker
+
+	DeletionLogs DeletionLogRepository
+	Transactions Transactor
+}
+
+// Transactor runs fn atomically. Repositories called with the context
+// passed to fn take part in the transaction.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// HealthChecker reports whether the backing database can serve requests
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+}
+
+type UserRepository interface {
+	Insert(ctx context.Context, user models.User) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, id primitive.ObjectID, user models.User) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// TrashFilter selects trashed documents, zero fields match everything
+type TrashFilter struct {
+	DeletedBy primitive.ObjectID
+	BookID    primitive.ObjectID
+	// Before matches documents trashed earlier than this
+	Before time.Time
+}
+
+// TrashBin soft-deletes documents. Reads through the other repository
+// methods skip trashed documents; Delete removes them for good.
+type TrashBin[T any] interface {
+	// Trash returns ErrNotFound unless the document exists and isn't trashed
+	Trash(ctx context.Context, id primitive.ObjectID, mark models.Trash) error
+	// Restore returns ErrNotFound unless the document is trashed
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	GetTrashed(ctx context.Context, id primitive.ObjectID) (*T, error)
+	ListTrashed(ctx context.Context, filter TrashFilter) ([]T, error)
+}
+
+// BookTrashBin is a TrashBin for documents that belong to a book
+type BookTrashBin[T any] interface {
+	TrashBin[T]
+	// TrashByBook trashes every document of the book that isn't already
+	TrashByBook(ctx context.Context, bookID primitive.ObjectID, mark models.Trash) (int64, error)
+}
+
+type BookRepository interface {
+	Insert(ctx context.Context, book models.Book) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Book, error)
+	List(ctx context.Context) ([]models.Book, error)
+	// Find returns a page of the books matching the query
+	Find(ctx context.Context, q Query) (*Page[models.Book], error)
+	// Update replaces the book's details and metadata, leaving its cover,
+	// owner and layout
+	Update(ctx context.Context, id primitive.ObjectID, book models.Book) error
+	// SetLayout switches the book between reflowable and fixed layout
+	SetLayout(ctx context.Context, id primitive.ObjectID, layout string, viewport *models.Viewport) error
+	// SetOmnibus replaces the parts of a book made of other books
+	SetOmnibus(ctx context.Context, id primitive.ObjectID, omnibus *models.Omnibus) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	TrashBin[models.Book]
+}
+
+type ChapterRepository interface {
+	Insert(ctx context.Context, chapter models.Chapter) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Chapter, error)
+	ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Chapter, error)
+	Find(ctx context.Context, q Query) (*Page[models.Chapter], error)
+	// ImageLocations lists the header image locations copied onto chapters
+	ImageLocations(ctx context.Context) ([]string, error)
+	Update(ctx context.Context, id primitive.ObjectID, chapter models.Chapter) error
+	// SetHeaderImage points the chapter with the given number at an image
+	SetHeaderImage(ctx context.Context, bookID primitive.ObjectID, chapterNum int, location string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error)
+	// Trashing a chapter frees its number, restoring it takes the number
+	// back and returns ErrDuplicate when another chapter holds it
+	BookTrashBin[models.Chapter]
+}
+
+type ImageRepository interface {
+	Insert(ctx context.Context, image models.ChapterImages) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.ChapterImages, error)
+	// GetForChapter returns the header image uploaded for a chapter number,
+	// leaving out the images shown in the chapter text and used as pages
+	GetForChapter(ctx context.Context, bookID primitive.ObjectID, chapterNum int) (*models.ChapterImages, error)
+	List(ctx context.Context) ([]models.ChapterImages, error)
+	ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.ChapterImages, error)
+	Find(ctx context.Context, q Query) (*Page[models.ChapterImages], error)
+	// Describe replaces the image's alt text, long description and
+	// decorative flag
+	Describe(ctx context.Context, id primitive.ObjectID, description models.ImageDescription) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error)
+	BookTrashBin[models.ChapterImages]
+}
+
+type VersionRepository interface {
+	Insert(ctx context.Context, version models.Version) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Version, error)
+	ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Version, error)
+	Find(ctx context.Context, q Query) (*Page[models.Version], error)
+	DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error)
+}
+
+type NoteRepository interface {
+	Insert(ctx context.Context, note models.Notes) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Notes, error)
+	Find(ctx context.Context, q Query) (*Page[models.Notes], error)
+	ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Notes, error)
+	Update(ctx context.Context, id primitive.ObjectID, note models.Notes) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error)
+	// DeleteBySeries removes the series' notes, trashed ones included
+	DeleteBySeries(ctx context.Context, seriesID primitive.ObjectID) (int64, error)
+	BookTrashBin[models.Notes]
+}
+
+// PageRepository holds the pages of fixed-layout books. Pages are numbered
+// from 1 in reading order.
+type PageRepository interface {
+	Insert(ctx context.Context, page models.Page) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Page, error)
+	// ListByBook returns the book's pages in page order
+	ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Page, error)
+	Find(ctx context.Context, q Query) (*Page[models.Page], error)
+	// Update replaces the page's image, spread and text, keeping its number
+	Update(ctx context.Context, id primitive.ObjectID, page models.Page) error
+	// Renumber gives the book's pages the numbers of their place in order
+	Renumber(ctx context.Context, bookID primitive.ObjectID, order []primitive.ObjectID) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error)
+}
+
+// EditionRepository holds the formats books are published in
+type EditionRepository interface {
+	Insert(ctx context.Context, edition models.Edition) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Edition, error)
+	// ListByBook returns the book's editions in the order they were added
+	ListByBook(ctx context.Context, bookID primitive.ObjectID) ([]models.Edition, error)
+	// ListByIdentifier returns the editions of any book carrying the
+	// identifier value
+	ListByIdentifier(ctx context.Context, value string) ([]models.Edition, error)
+	Find(ctx context.Context, q Query) (*Page[models.Edition], error)
+	// Update replaces everything about the edition but its book
+	Update(ctx context.Context, id primitive.ObjectID, edition models.Edition) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	DeleteByBook(ctx context.Context, bookID primitive.ObjectID) (int64, error)
+}
+
+// SeriesRepository holds the series books are read in
+type SeriesRepository interface {
+	Insert(ctx context.Context, series models.Series) (primitive.ObjectID, error)
+	Get(ctx context.Context, id primitive.ObjectID) (*models.Series, error)
+	// GetByBook returns the series the book belongs to
+	GetByBook(ctx context.Context, bookID primitive.ObjectID) (*models.Series, error)
+	Find(ctx context.Context, q Query) (*Page[models.Series], error)
+	// Update replaces the series' name, description and books, keeping its
+	// owner
+	Update(ctx context.Context, id primitive.ObjectID, series models.Series) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// BlobRepository tracks how many documents reference each stored blob
+type BlobRepository interface {
+	Retain(ctx context.Context, blob storage.Blob) error
+	// Release returns how many references remain
+	Release(ctx context.Context, hash string) (int, error)
+	List(ctx context.Context) ([]models.BlobRef, error)
+	// SetRefs overwrites the count for a blob, creating its record if needed
+	SetRefs(ctx context.Context, hash string, refs int) error
+	Delete(ctx context.Context, hash string) error
+}
+
+// DeletionLogRepository journals book deletions made without a transaction
+type DeletionLogRepository interface {
+	Insert(ctx context.Context, log models.DeletionLog) (primitive.ObjectID, error)
+	SetState(ctx context.Context, id primitive.ObjectID, state string) error
+	// ListAbandoned returns the pending logs whose lease ended before now
+	ListAbandoned(ctx context.Context, now time.Time) ([]models.DeletionLog, error)
+}
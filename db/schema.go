@@ -0,0 +1,290 @@
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultLimit is the page size when a request doesn't ask for one
+	DefaultLimit = 50
+	// MaxLimit caps the page size a request can ask for
+	MaxLimit = 200
+)
+
+// FieldType decides how a query parameter is parsed and which operators it allows
+type FieldType int
+
+const (
+	StringField FieldType = iota
+	IntField
+	ObjectIDField
+	// CreatedField filters and sorts on the creation time held in the _id
+	CreatedField
+)
+
+// Field maps an API field name to a stored field
+type Field struct {
+	Stored string
+	Type   FieldType
+	// Sortable fields can be used in sort=
+	Sortable bool
+}
+
+func (f Field) allows(op Op) bool {
+	switch f.Type {
+	case StringField:
+		return op == OpEq || op == OpNe || op == OpIn || op == OpPrefix
+	case IntField:
+		return op != OpPrefix && op != OpExists
+	case ObjectIDField:
+		return op == OpEq || op == OpNe || op == OpIn || op == OpExists
+	case CreatedField:
+		return op == OpGt || op == OpGte || op == OpLt || op == OpLte
+	}
+	return false
+}
+
+// Ops lists the operators the field can be filtered with
+func (f Field) Ops() []Op {
+	var ops []Op
+	for _, op := range []Op{OpEq, OpNe, OpIn, OpPrefix, OpGt, OpGte, OpLt, OpLte, OpExists} {
+		if f.allows(op) {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// Schema describes the fields a list endpoint filters, sorts and projects on
+type Schema struct {
+	Fields map[string]Field
+	// DefaultSort applies when the request has no sort parameter
+	DefaultSort []SortField
+}
+
+var commonFields = map[string]Field{
+	"id":      {Stored: "_id", Type: ObjectIDField, Sortable: true},
+	"created": {Stored: "_id", Type: CreatedField, Sortable: true},
+}
+
+func schema(sort []SortField, fields map[string]Field) Schema {
+	for name, field := range commonFields {
+		fields[name] = field
+	}
+	return Schema{Fields: fields, DefaultSort: sort}
+}
+
+// The schemas of the list endpoints. Book fields predate bson tags and are
+// stored under their lower-cased Go names.
+var (
+	BookSchema = schema(nil, map[string]Field{
+		"title":      {Stored: "title", Type: StringField, Sortable: true},
+		"subtitle":   {Stored: "subtitle", Type: StringField, Sortable: true},
+		"author":     {Stored: "author", Type: StringField, Sortable: true},
+		"bookCover":  {Stored: "bookcover", Type: StringField},
+		"ownerID":    {Stored: "ownerID", Type: ObjectIDField},
+		"visibility": {Stored: "visibility", Type: StringField},
+
+		"language":        {Stored: "language", Type: StringField},
+		"publisher":       {Stored: "publisher", Type: StringField, Sortable: true},
+		"imprint":         {Stored: "imprint", Type: StringField, Sortable: true},
+		"publicationDate": {Stored: "publicationDate", Type: StringField, Sortable: true},
+	})
+	ChapterSchema = schema([]SortField{{Field: "chapterNum"}}, map[string]Field{
+		"title":         {Stored: "title", Type: StringField, Sortable: true},
+		"text":          {Stored: "text", Type: StringField},
+		"chapterNum":    {Stored: "chapterNum", Type: IntField, Sortable: true},
+		"imageLocation": {Stored: "imageLocation", Type: StringField},
+		"bookID":        {Stored: "bookID", Type: ObjectIDField},
+		"versionID":     {Stored: "versionID", Type: ObjectIDField},
+	})
+	NoteSchema = schema(nil, map[string]Field{
+		// noteID is the parameter GetAllNotes has always accepted
+		"noteID":    {Stored: "_id", Type: ObjectIDField},
+		"title":     {Stored: "title", Type: StringField, Sortable: true},
+		"text":      {Stored: "text", Type: StringField},
+		"type":      {Stored: "type", Type: StringField, Sortable: true},
+		"bookID":    {Stored: "bookID", Type: ObjectIDField},
+		"versionID": {Stored: "versionID", Type: ObjectIDField},
+		"seriesID":  {Stored: "seriesID", Type: ObjectIDField},
+	})
+	ImageSchema = schema([]SortField{{Field: "chapterNum"}}, map[string]Field{
+		"chapterNum":    {Stored: "chapterNum", Type: IntField, Sortable: true},
+		"imageLocation": {Stored: "imageLocation", Type: StringField},
+		"type":          {Stored: "type", Type: StringField, Sortable: true},
+		"bookID":        {Stored: "bookID", Type: ObjectIDField},
+	})
+	VersionSchema = schema(nil, map[string]Field{
+		"type":   {Stored: "type", Type: StringField, Sortable: true},
+		"bookID": {Stored: "bookID", Type: ObjectIDField},
+	})
+	PageSchema = schema([]SortField{{Field: "pageNum"}}, map[string]Field{
+		"pageNum": {Stored: "pageNum", Type: IntField, Sortable: true},
+		"imageID": {Stored: "imageID", Type: ObjectIDField},
+		"spread":  {Stored: "spread", Type: StringField},
+		"bookID":  {Stored: "bookID", Type: ObjectIDField},
+	})
+	EditionSchema = schema(nil, map[string]Field{
+		"name":   {Stored: "name", Type: StringField, Sortable: true},
+		"format": {Stored: "format", Type: StringField, Sortable: true},
+		"bookID": {Stored: "bookID", Type: ObjectIDField},
+	})
+	SeriesSchema = schema(nil, map[string]Field{
+		"name":    {Stored: "name", Type: StringField, Sortable: true},
+		"ownerID": {Stored: "ownerID", Type: ObjectIDField},
+	})
+)
+
+// Parse reads a list request's query string:
+//
+//	field=value            equality, repeat the parameter to match any of several values
+//	field.op=value         op is ne, prefix, gt, gte, lt, lte or exists
+//	sort=-field,field      ascending unless prefixed with -
+//	fields=field,field     only return these fields
+//	limit=n                page size, DefaultLimit when absent and at most MaxLimit
+//	cursor=...             the nextCursor of the previous page
+func (s Schema) Parse(values url.Values) (Query, error) {
+	q := Query{Sort: s.DefaultSort, Limit: DefaultLimit}
+
+	// map iteration order would otherwise make filter order, and so error
+	// messages, vary between requests
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		params := values[key]
+		last := params[len(params)-1]
+		switch key {
+		case "sort":
+			order, err := s.parseSort(last)
+			if err != nil {
+				return Query{}, err
+			}
+			q.Sort = order
+			continue
+		case "fields":
+			for _, name := range strings.Split(last, ",") {
+				field, ok := s.Fields[name]
+				if !ok {
+					return Query{}, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name)
+				}
+				if field.Type == CreatedField {
+					return Query{}, fmt.Errorf("%w: %q is read from the id and can't be selected", ErrInvalidQuery, name)
+				}
+				// fields stored as the _id are returned under that name
+				if field.Stored == "_id" {
+					name = "_id"
+				}
+				q.Fields = append(q.Fields, field.Stored)
+				q.Select = append(q.Select, name)
+			}
+			continue
+		case "limit":
+			limit, err := strconv.Atoi(last)
+			if err != nil || limit < 1 || limit > MaxLimit {
+				return Query{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxLimit)
+			}
+			q.Limit = limit
+			continue
+		case "cursor":
+			q.Cursor = last
+			continue
+		}
+
+		name, op := key, OpEq
+		if i := strings.LastIndexByte(key, '.'); i >= 0 {
+			name, op = key[:i], Op(key[i+1:])
+		}
+		field, ok := s.Fields[name]
+		if !ok {
+			return Query{}, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, name)
+		}
+		if op == OpEq && len(params) > 1 {
+			op = OpIn
+		}
+		if op == OpIn && !field.allows(OpIn) || op != OpIn && !field.allows(op) {
+			return Query{}, fmt.Errorf("%w: %q does not support %s", ErrInvalidQuery, name, op)
+		}
+
+		if op == OpIn {
+			in := make([]interface{}, 0, len(params))
+			for _, param := range params {
+				value, err := field.parse(name, op, param)
+				if err != nil {
+					return Query{}, err
+				}
+				in = append(in, value)
+			}
+			q.Filters = append(q.Filters, Filter{Field: field.Stored, Op: OpIn, Value: in})
+			continue
+		}
+		value, err := field.parse(name, op, last)
+		if err != nil {
+			return Query{}, err
+		}
+		q.Filters = append(q.Filters, Filter{Field: field.Stored, Op: op, Value: value})
+	}
+	return q, nil
+}
+
+func (s Schema) parseSort(param string) ([]SortField, error) {
+	var order []SortField
+	for _, name := range strings.Split(param, ",") {
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		field, ok := s.Fields[name]
+		if !ok || !field.Sortable {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, name)
+		}
+		order = append(order, SortField{Field: field.Stored, Desc: desc})
+	}
+	return order, nil
+}
+
+// parse converts a parameter to the stored type of the field
+func (f Field) parse(name string, op Op, param string) (interface{}, error) {
+	invalid := func() error {
+		return fmt.Errorf("%w: invalid %s %q", ErrInvalidQuery, name, param)
+	}
+	if op == OpExists {
+		exists, err := strconv.ParseBool(param)
+		if err != nil {
+			return nil, invalid()
+		}
+		return exists, nil
+	}
+	switch f.Type {
+	case IntField:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, invalid()
+		}
+		return n, nil
+	case ObjectIDField:
+		id, err := primitive.ObjectIDFromHex(param)
+		if err != nil {
+			return nil, invalid()
+		}
+		return id, nil
+	case CreatedField:
+		t, err := time.Parse(time.RFC3339, param)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", param); err != nil {
+				return nil, invalid()
+			}
+		}
+		// the smallest ID minted at t, so ranges line up with creation times
+		return primitive.NewObjectIDFromTimestamp(t), nil
+	}
+	return param, nil
+}
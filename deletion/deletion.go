@@ -0,0 +1,315 @@
+// Package deletion removes books together with everything that hangs off
+// them, either inside a Mongo transaction or, on servers without
+// transactions, journaled so a failed deletion can be put back.
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ModeTransaction = "transaction"
+	ModeLogged      = "compensating-log"
+)
+
+// restoreTimeout bounds a rollback, which runs even when the request that
+// started the deletion has already timed out
+const restoreTimeout = 30 * time.Second
+
+// lease is how long a logged deletion, rollback included, may run before
+// another server takes it for abandoned and rolls it back
+const lease = 10 * time.Minute
+
+// Summary reports what a book deletion removed
+type Summary struct {
+	BookID   primitive.ObjectID `json:"bookID"`
+	Mode     string             `json:"mode"`
+	Books    int64              `json:"books"`
+	Chapters int64              `json:"chapters"`
+	Images   int64              `json:"images"`
+	Versions int64              `json:"versions"`
+	Notes    int64              `json:"notes"`
+	Pages    int64              `json:"pages"`
+	Editions int64              `json:"editions"`
+	// Files are the uploads removed from disk because nothing else uses them
+	Files []string `json:"files"`
+	// Errors are file clean-up failures; the documents are gone regardless
+	// and the garbage collector retries the files
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Service is the one place books are deleted
+type Service struct {
+	store *db.Store
+	blobs *storage.BlobStore
+	// gracePeriod leaves recently written files to the garbage collector,
+	// an upload of the same content may be about to reference them
+	gracePeriod time.Duration
+}
+
+func New(store *db.Store, blobs *storage.BlobStore, gracePeriod time.Duration) *Service {
+	return &Service{store: store, blobs: blobs, gracePeriod: gracePeriod}
+}
+
+// DeleteBook removes a book with its chapters, images, versions, notes,
+// pages, editions and uploaded files, whether or not any of them are in
+// the trash. It returns db.ErrNotFound when the book does not exist.
+func (s *Service) DeleteBook(ctx context.Context, bookID primitive.ObjectID) (*Summary, error) {
+	var snapshot *models.DeletionLog
+	var summary *Summary
+	err := s.store.Transactions.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if snapshot, err = s.snapshot(ctx, bookID); err != nil {
+			return err
+		}
+		summary, err = s.remove(ctx, bookID)
+		return err
+	})
+	if errors.Is(err, db.ErrTransactionsUnsupported) {
+		snapshot, summary, err = s.deleteLogged(ctx, bookID)
+	} else if err == nil {
+		summary.Mode = ModeTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.removeFiles(ctx, snapshotLocations(snapshot), summary)
+	return summary, nil
+}
+
+// DeleteImage removes a single chapter image, trashed or not, together with
+// its file when nothing else uses it
+func (s *Service) DeleteImage(ctx context.Context, id primitive.ObjectID) (*Summary, error) {
+	image, err := s.store.Images.Get(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		image, err = s.store.Images.GetTrashed(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Images.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+	summary := &Summary{BookID: image.BookID, Images: 1, Files: []string{}}
+	s.removeFiles(ctx, []string{image.ImageLocation}, summary)
+	return summary, nil
+}
+
+// deleteLogged journals the documents before deleting them one collection
+// at a time, and puts them back if any step fails
+func (s *Service) deleteLogged(ctx context.Context, bookID primitive.ObjectID) (*models.DeletionLog, *Summary, error) {
+	snapshot, err := s.snapshot(ctx, bookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot.LeaseUntil = snapshot.StartedAt.Add(lease)
+	if snapshot.ID, err = s.store.DeletionLogs.Insert(ctx, *snapshot); err != nil {
+		return nil, nil, err
+	}
+
+	summary, err := s.remove(ctx, bookID)
+	if err != nil {
+		restoreCtx, cancel := context.WithTimeout(context.Background(), restoreTimeout)
+		defer cancel()
+		if restoreErr := s.rollBack(restoreCtx, *snapshot); restoreErr != nil {
+			// the log stays pending and Recover retries once its lease ends
+			return nil, nil, fmt.Errorf("deleting book %s: %v, rolling back: %w", bookID.Hex(), err, restoreErr)
+		}
+		return nil, nil, fmt.Errorf("deleting book %s, rolled back: %w", bookID.Hex(), err)
+	}
+	if err := s.store.DeletionLogs.SetState(ctx, snapshot.ID, models.DeletionDone); err != nil {
+		log.Printf("deletion log %s: %v", snapshot.ID.Hex(), err)
+	}
+	summary.Mode = ModeLogged
+	return snapshot, summary, nil
+}
+
+// Recover rolls back logged deletions interrupted by a crash, returning how
+// many were put back. Deletions still within their lease may be running on
+// another server and are left to it.
+func (s *Service) Recover(ctx context.Context) (int, error) {
+	pending, err := s.store.DeletionLogs.ListAbandoned(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for i, snapshot := range pending {
+		if err := s.rollBack(ctx, snapshot); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+func (s *Service) snapshot(ctx context.Context, bookID primitive.ObjectID) (*models.DeletionLog, error) {
+	book, err := s.store.Books.Get(ctx, bookID)
+	if errors.Is(err, db.ErrNotFound) {
+		book, err = s.store.Books.GetTrashed(ctx, bookID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &models.DeletionLog{BookID: bookID, State: models.DeletionPending, StartedAt: time.Now(), Book: *book}
+	trashed := db.TrashFilter{BookID: bookID}
+	if snapshot.Chapters, err = listAll(ctx, bookID, trashed, s.store.Chapters.ListByBook, s.store.Chapters.ListTrashed); err != nil {
+		return nil, err
+	}
+	if snapshot.Images, err = listAll(ctx, bookID, trashed, s.store.Images.ListByBook, s.store.Images.ListTrashed); err != nil {
+		return nil, err
+	}
+	if snapshot.Versions, err = s.store.Versions.ListByBook(ctx, bookID); err != nil {
+		return nil, err
+	}
+	if snapshot.Notes, err = listAll(ctx, bookID, trashed, s.store.Notes.ListByBook, s.store.Notes.ListTrashed); err != nil {
+		return nil, err
+	}
+	if snapshot.Pages, err = s.store.Pages.ListByBook(ctx, bookID); err != nil {
+		return nil, err
+	}
+	if snapshot.Editions, err = s.store.Editions.ListByBook(ctx, bookID); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// listAll returns a book's live and trashed documents
+func listAll[T any](
+	ctx context.Context,
+	bookID primitive.ObjectID,
+	filter db.TrashFilter,
+	listLive func(context.Context, primitive.ObjectID) ([]T, error),
+	listTrashed func(context.Context, db.TrashFilter) ([]T, error),
+) ([]T, error) {
+	rows, err := listLive(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := listTrashed(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return append(rows, trashed...), nil
+}
+
+// remove deletes the book last, so a half-finished deletion never leaves
+// children without a book to find them through
+func (s *Service) remove(ctx context.Context, bookID primitive.ObjectID) (*Summary, error) {
+	summary := &Summary{BookID: bookID, Files: []string{}}
+	var err error
+	if summary.Editions, err = s.store.Editions.DeleteByBook(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting editions: %w", err)
+	}
+	if summary.Pages, err = s.store.Pages.DeleteByBook(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting pages: %w", err)
+	}
+	if summary.Notes, err = s.store.Notes.DeleteByBook(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting notes: %w", err)
+	}
+	if summary.Versions, err = s.store.Versions.DeleteByBook(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting versions: %w", err)
+	}
+	if summary.Images, err = s.store.Images.DeleteByBook(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting images: %w", err)
+	}
+	if summary.Chapters, err = s.store.Chapters.DeleteByBook(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting chapters: %w", err)
+	}
+	if err := s.store.Books.Delete(ctx, bookID); err != nil {
+		return nil, fmt.Errorf("deleting book: %w", err)
+	}
+	summary.Books = 1
+	return summary, nil
+}
+
+// rollBack re-inserts every journaled document that is missing
+func (s *Service) rollBack(ctx context.Context, snapshot models.DeletionLog) error {
+	restore := func(_ primitive.ObjectID, err error) error {
+		if errors.Is(err, db.ErrDuplicate) {
+			return nil
+		}
+		return err
+	}
+	if err := restore(s.store.Books.Insert(ctx, snapshot.Book)); err != nil {
+		return err
+	}
+	for _, chapter := range snapshot.Chapters {
+		if err := restore(s.store.Chapters.Insert(ctx, chapter)); err != nil {
+			return err
+		}
+	}
+	for _, image := range snapshot.Images {
+		if err := restore(s.store.Images.Insert(ctx, image)); err != nil {
+			return err
+		}
+	}
+	for _, version := range snapshot.Versions {
+		if err := restore(s.store.Versions.Insert(ctx, version)); err != nil {
+			return err
+		}
+	}
+	for _, note := range snapshot.Notes {
+		if err := restore(s.store.Notes.Insert(ctx, note)); err != nil {
+			return err
+		}
+	}
+	for _, page := range snapshot.Pages {
+		if err := restore(s.store.Pages.Insert(ctx, page)); err != nil {
+			return err
+		}
+	}
+	for _, edition := range snapshot.Editions {
+		if err := restore(s.store.Editions.Insert(ctx, edition)); err != nil {
+			return err
+		}
+	}
+	return s.store.DeletionLogs.SetState(ctx, snapshot.ID, models.DeletionRolledBack)
+}
+
+// snapshotLocations lists the uploads a deleted book and its images held
+func snapshotLocations(snapshot *models.DeletionLog) []string {
+	locations := []string{snapshot.Book.BookCover}
+	for _, image := range snapshot.Images {
+		locations = append(locations, image.ImageLocation)
+	}
+	return locations
+}
+
+// removeFiles drops the references deleted documents held and removes
+// blobs nobody references any more. Uploads from before content addressing
+// are left to the garbage collector.
+func (s *Service) removeFiles(ctx context.Context, locations []string, summary *Summary) {
+	cutoff := time.Now().Add(-s.gracePeriod)
+	for _, location := range locations {
+		hash, ok := s.blobs.HashOf(location)
+		if !ok {
+			continue
+		}
+		refs, err := s.store.Blobs.Release(ctx, hash)
+		if err != nil {
+			summary.Errors = append(summary.Errors, err.Error())
+			continue
+		}
+		if refs > 0 {
+			continue
+		}
+		info, err := os.Stat(s.blobs.Path(hash))
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := s.blobs.Remove(hash); err != nil {
+			summary.Errors = append(summary.Errors, err.Error())
+			continue
+		}
+		summary.Files = append(summary.Files, location)
+	}
+}
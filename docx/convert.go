@@ -0,0 +1,495 @@
+package docx
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// excerptRunes is how much of a chapter's text the excerpt shows
+const excerptRunes = 200
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockItem
+	blockQuote
+	blockBreak
+	blockTable
+)
+
+// block is a paragraph or table of the body, classified by its style
+type block struct {
+	kind  blockKind
+	node  *node
+	style *style
+	// level is the heading level, or the nesting of a list item
+	level   int
+	ordered bool
+	// text is the plain text, which split patterns are matched against
+	text string
+}
+
+// sceneBreaks are the paragraphs manuscripts use to mark a scene break
+var sceneBreaks = map[string]bool{"#": true, "*": true, "***": true, "* * *": true}
+
+// blocks classifies the paragraphs and tables of the body, leaving out
+// empty paragraphs
+func (c *converter) blocks(parent *node) []block {
+	var blocks []block
+	for _, n := range parent.children {
+		switch n.name {
+		case "p":
+			if b, ok := c.paragraph(n); ok {
+				blocks = append(blocks, b)
+			}
+		case "tbl":
+			blocks = append(blocks, block{kind: blockTable, node: n, text: plain(n)})
+		case "sdt", "sdtContent", "customXml", "ins":
+			blocks = append(blocks, c.blocks(n)...)
+		}
+	}
+	return blocks
+}
+
+func (c *converter) paragraph(p *node) (block, bool) {
+	b := block{kind: blockParagraph, node: p, text: plain(p)}
+	pPr := p.child("pPr")
+	id := pPr.child("pStyle").attr("val")
+	if c.styles[id] == nil {
+		id = c.defaultStyle
+	}
+	b.style = c.styles[id]
+
+	if strings.TrimSpace(b.text) == "" && p.find("drawing") == nil && p.find("imagedata") == nil {
+		return b, false
+	}
+
+	b.level = outline(pPr)
+	numID, level := pPr.child("numPr").child("numId").attr("val"), pPr.child("numPr").child("ilvl").attr("val")
+	quote := false
+	c.resolve(id, func(s *style) bool {
+		if b.level == 0 {
+			b.level = s.outline
+		}
+		if numID == "" {
+			numID = s.numID
+			if level == "" {
+				level = s.level
+			}
+		}
+		quote = quote || strings.Contains(strings.ToLower(s.name), "quote")
+		return false
+	})
+	if level == "" {
+		level = "0"
+	}
+
+	switch {
+	case b.level > 0:
+		b.kind = blockHeading
+	case numID != "" && numID != "0":
+		b.kind = blockItem
+		b.ordered = c.numbering[numID][level]
+		b.level, _ = strconv.Atoi(level)
+	case sceneBreaks[strings.TrimSpace(b.text)]:
+		b.kind = blockBreak
+	case quote:
+		b.kind = blockQuote
+	}
+	return b, true
+}
+
+// plain returns the text of an element without its formatting
+func plain(n *node) string {
+	var text strings.Builder
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			switch c.name {
+			case "t":
+				text.WriteString(c.text)
+			case "tab", "br", "cr":
+				text.WriteString(" ")
+			case "noBreakHyphen":
+				text.WriteString("-")
+			case "del", "moveFrom", "instrText", "delText", "footnoteReference", "endnoteReference":
+			case "p":
+				walk(c)
+				text.WriteString("\n")
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return text.String()
+}
+
+// part is the blocks of one chapter
+type part struct {
+	title  string
+	blocks []block
+}
+
+// split cuts the blocks into chapters at the paragraphs the options pick.
+// The paragraph becomes the chapter's title. Whatever comes before the
+// first one is kept as front matter.
+func (c *converter) split(blocks []block, opts Options) []part {
+	parts := []part{{}}
+	for _, b := range blocks {
+		title := strings.Join(strings.Fields(b.text), " ")
+		if b.kind != blockTable && title != "" && c.starts(b, title, opts) {
+			parts = append(parts, part{title: title})
+			continue
+		}
+		last := &parts[len(parts)-1]
+		last.blocks = append(last.blocks, b)
+	}
+	switch {
+	case len(parts[0].blocks) == 0 && len(parts) > 1:
+		parts = parts[1:]
+	case len(parts) > 1:
+		parts[0].title = "Front matter"
+	}
+	return parts
+}
+
+func (c *converter) starts(b block, title string, opts Options) bool {
+	if opts.SplitPattern != nil {
+		return opts.SplitPattern.MatchString(title)
+	}
+	if b.style == nil {
+		return false
+	}
+	want := styleKey(opts.SplitStyle)
+	return styleKey(b.style.id) == want || styleKey(b.style.name) == want
+}
+
+// styleKey compares style IDs and names, so "heading 1", "Heading 1" and
+// the ID "Heading1" are the same style
+func styleKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
+
+// chapter renders a part as chapter HTML
+func (c *converter) chapter(p part) Chapter {
+	r := &renderer{c: c, seen: map[string]bool{}}
+	for i := 0; i < len(p.blocks); {
+		i = r.block(p.blocks, i)
+	}
+	r.footnotes()
+
+	var text []string
+	for _, b := range p.blocks {
+		if b.kind == blockBreak {
+			continue
+		}
+		text = append(text, strings.Fields(b.text)...)
+	}
+	excerpt := strings.Join(text, " ")
+	if utf8.RuneCountInString(excerpt) > excerptRunes {
+		excerpt = string([]rune(excerpt)[:excerptRunes]) + "…"
+	}
+	return Chapter{Title: p.title, HTML: r.out.String(), Images: r.images, Words: len(text), Excerpt: excerpt}
+}
+
+// renderer writes the HTML of one chapter, numbering its footnotes from 1
+type renderer struct {
+	c      *converter
+	out    strings.Builder
+	notes  []string
+	images []string
+	seen   map[string]bool
+}
+
+// block writes the block at i, with the list items or quote paragraphs
+// that follow it, and returns the index of the next block
+func (r *renderer) block(blocks []block, i int) int {
+	b := blocks[i]
+	switch b.kind {
+	case blockHeading:
+		fmt.Fprintf(&r.out, "<h%d>%s</h%d>\n", b.level, r.inline(b.node), b.level)
+	case blockBreak:
+		r.out.WriteString("<hr>\n")
+	case blockTable:
+		r.out.WriteString(r.table(b.node))
+	case blockItem:
+		end := i
+		for end < len(blocks) && blocks[end].kind == blockItem {
+			end++
+		}
+		r.list(blocks[i:end])
+		return end
+	case blockQuote:
+		r.out.WriteString("<blockquote>\n")
+		for ; i < len(blocks) && blocks[i].kind == blockQuote; i++ {
+			fmt.Fprintf(&r.out, "<p>%s</p>\n", r.inline(blocks[i].node))
+		}
+		r.out.WriteString("</blockquote>\n")
+		return i
+	default:
+		fmt.Fprintf(&r.out, "<p>%s</p>\n", r.inline(b.node))
+	}
+	return i + 1
+}
+
+// list nests list items by their level, leaving an item open while deeper
+// items follow it
+func (r *renderer) list(items []block) {
+	type open struct {
+		level int
+		tag   string
+	}
+	var stack []open
+	for _, item := range items {
+		tag := "ul"
+		if item.ordered {
+			tag = "ol"
+		}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.level < item.level || (top.level == item.level && top.tag == tag) {
+				break
+			}
+			fmt.Fprintf(&r.out, "</li>\n</%s>\n", top.tag)
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 && stack[len(stack)-1].level == item.level {
+			r.out.WriteString("</li>\n<li>")
+		} else {
+			fmt.Fprintf(&r.out, "<%s>\n<li>", tag)
+			stack = append(stack, open{level: item.level, tag: tag})
+		}
+		r.out.WriteString(r.inline(item.node))
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		fmt.Fprintf(&r.out, "</li>\n</%s>\n", stack[i].tag)
+	}
+}
+
+func (r *renderer) table(tbl *node) string {
+	var out strings.Builder
+	out.WriteString("<table>\n")
+	for _, tr := range tbl.childrenNamed("tr") {
+		cell := "td"
+		if tr.child("trPr").child("tblHeader") != nil {
+			cell = "th"
+		}
+		out.WriteString("<tr>")
+		for _, tc := range tr.childrenNamed("tc") {
+			out.WriteString("<" + cell)
+			if span := tc.child("tcPr").child("gridSpan").attr("val"); span != "" && span != "1" {
+				fmt.Fprintf(&out, ` colspan="%s"`, html.EscapeString(span))
+			}
+			out.WriteString(">")
+			var paragraphs []string
+			for _, n := range tc.children {
+				switch n.name {
+				case "p":
+					paragraphs = append(paragraphs, r.inline(n))
+				case "tbl":
+					paragraphs = append(paragraphs, r.table(n))
+				}
+			}
+			out.WriteString(strings.Join(paragraphs, "<br>"))
+			out.WriteString("</" + cell + ">")
+		}
+		out.WriteString("</tr>\n")
+	}
+	out.WriteString("</table>\n")
+	return out.String()
+}
+
+// format is the character formatting of a run
+type format struct {
+	bold, italic, strike, sup, sub bool
+}
+
+// segment is text, a break, an image or a footnote reference with the
+// formatting and link it has
+type segment struct {
+	format format
+	href   string
+	html   string
+}
+
+// inline renders the runs of a paragraph, merging neighbouring runs that
+// are formatted alike
+func (r *renderer) inline(p *node) string {
+	var segments []segment
+	var walk func(n *node, href string)
+	walk = func(n *node, href string) {
+		for _, c := range n.children {
+			switch c.name {
+			case "r":
+				segments = append(segments, r.run(c, href)...)
+			case "hyperlink":
+				walk(c, link(r.c.links[c.attr("id")]))
+			case "ins", "smartTag", "customXml", "sdt", "sdtContent", "fldSimple", "moveTo":
+				walk(c, href)
+			}
+		}
+	}
+	walk(p, "")
+
+	var out strings.Builder
+	for i := 0; i < len(segments); {
+		s := segments[i]
+		var text strings.Builder
+		for ; i < len(segments) && segments[i].format == s.format && segments[i].href == s.href; i++ {
+			text.WriteString(segments[i].html)
+		}
+		var open, close []string
+		if s.href != "" {
+			open, close = append(open, `<a href="`+html.EscapeString(s.href)+`">`), append(close, "</a>")
+		}
+		for _, tag := range []struct {
+			on   bool
+			name string
+		}{{s.format.bold, "strong"}, {s.format.italic, "em"}, {s.format.strike, "s"}, {s.format.sup, "sup"}, {s.format.sub, "sub"}} {
+			if tag.on {
+				open, close = append(open, "<"+tag.name+">"), append([]string{"</" + tag.name + ">"}, close...)
+			}
+		}
+		out.WriteString(strings.Join(open, "") + text.String() + strings.Join(close, ""))
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func (r *renderer) run(run *node, href string) []segment {
+	f := r.format(run.child("rPr"))
+	var segments []segment
+	add := func(f format, s string) {
+		segments = append(segments, segment{format: f, href: href, html: s})
+	}
+	for _, c := range run.children {
+		switch c.name {
+		case "t":
+			add(f, html.EscapeString(c.text))
+		case "tab":
+			add(f, " ")
+		case "noBreakHyphen":
+			add(f, "-")
+		case "br", "cr":
+			if t := c.attr("type"); t != "page" && t != "column" {
+				add(f, "<br>")
+			}
+		case "footnoteReference":
+			add(format{}, r.noteRef("f"+c.attr("id")))
+		case "endnoteReference":
+			add(format{}, r.noteRef("e"+c.attr("id")))
+		case "drawing":
+			add(format{}, r.image(c.find("blip").attr("embed"), c.find("docPr").attr("descr")))
+		case "pict", "object":
+			data := c.find("imagedata")
+			add(format{}, r.image(data.attr("id"), data.attr("title")))
+		}
+	}
+	return segments
+}
+
+// format reads a run's formatting from its character style and its own
+// properties. Underlining counts as italics, as it does in manuscripts.
+func (r *renderer) format(rPr *node) format {
+	var chain []*node
+	r.c.resolve(rPr.child("rStyle").attr("val"), func(s *style) bool {
+		chain = append([]*node{s.props}, chain...)
+		return false
+	})
+	chain = append(chain, rPr)
+
+	var f format
+	var underline bool
+	for _, props := range chain {
+		if props == nil {
+			continue
+		}
+		if b := props.child("b"); b != nil {
+			f.bold = b.on()
+		}
+		if i := props.child("i"); i != nil {
+			f.italic = i.on()
+		}
+		if u := props.child("u"); u != nil {
+			underline = u.on()
+		}
+		if s := props.child("strike"); s != nil {
+			f.strike = s.on()
+		}
+		if s := props.child("dstrike"); s != nil {
+			f.strike = f.strike || s.on()
+		}
+		if v := props.child("vertAlign"); v != nil {
+			f.sup, f.sub = v.attr("val") == "superscript", v.attr("val") == "subscript"
+		}
+	}
+	f.italic = f.italic || underline
+	return f
+}
+
+// image writes an embedded image, remembering it for the chapter
+func (r *renderer) image(id, alt string) string {
+	name := r.c.media[id]
+	if _, ok := r.c.pkg.files[name]; !ok {
+		return ""
+	}
+	if !r.seen[name] {
+		r.seen[name] = true
+		r.images = append(r.images, name)
+	}
+	return fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(ImageSource(name)), html.EscapeString(alt))
+}
+
+// noteRef numbers a footnote or endnote and writes its reference the way
+// Markdown footnotes are written
+func (r *renderer) noteRef(key string) string {
+	note := r.c.notes[key]
+	if note == nil {
+		return ""
+	}
+	// placeholder until the note is rendered, so nested references number
+	// after it
+	r.notes = append(r.notes, "")
+	n := len(r.notes)
+	var paragraphs []string
+	for _, p := range note.childrenNamed("p") {
+		if text := r.inline(p); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	backref := fmt.Sprintf(`&#160;<a href="#fnref:%d" class="footnote-backref" role="doc-backlink">&#x21a9;&#xfe0e;</a>`, n)
+	if len(paragraphs) == 0 {
+		paragraphs = []string{""}
+	}
+	paragraphs[len(paragraphs)-1] += backref
+	r.notes[n-1] = fmt.Sprintf("<li id=\"fn:%d\">\n<p>%s</p>\n</li>\n", n, strings.Join(paragraphs, "</p>\n<p>"))
+	return fmt.Sprintf(`<sup id="fnref:%d"><a href="#fn:%d" class="footnote-ref" role="doc-noteref">%d</a></sup>`, n, n, n)
+}
+
+func (r *renderer) footnotes() {
+	if len(r.notes) == 0 {
+		return
+	}
+	r.out.WriteString("<div class=\"footnotes\" role=\"doc-endnotes\">\n<hr>\n<ol>\n")
+	r.out.WriteString(strings.Join(r.notes, ""))
+	r.out.WriteString("</ol>\n</div>\n")
+}
+
+// link keeps only the hyperlink targets that are safe in a chapter
+func link(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String()
+	}
+	return ""
+}
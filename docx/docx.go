@@ -0,0 +1,359 @@
+// Package docx converts between Word documents and chapters. It reads the
+// document, its styles, numbering, footnotes and embedded images straight
+// from the OOXML package and writes the same HTML chapters are stored as.
+package docx
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNotDocx is returned for files that aren't Word documents or whose
+// parts can't be read
+var ErrNotDocx = errors.New("not a Word document")
+
+// DefaultSplitStyle is the paragraph style that starts a chapter when the
+// options name none
+const DefaultSplitStyle = "Heading 1"
+
+// maxPartBytes caps what is read of any one part of the package, which
+// keeps a small upload from unpacking into gigabytes
+const maxPartBytes = 64 << 20
+
+// imagePrefix marks the source of an embedded image in chapter HTML until
+// the image has been stored
+const imagePrefix = "docx:"
+
+// Options say where the document is split into chapters
+type Options struct {
+	// SplitStyle is the ID or name of the paragraph style that starts a
+	// chapter, DefaultSplitStyle when empty
+	SplitStyle string
+	// SplitPattern, when set, starts a chapter at every paragraph whose
+	// text matches it instead
+	SplitPattern *regexp.Regexp
+}
+
+// Document is a converted Word document
+type Document struct {
+	// Title is the title from the document properties
+	Title    string
+	Chapters []Chapter
+	// Images holds the embedded images the chapters use, by name
+	Images map[string][]byte
+	// Styles are the paragraph styles the body uses, in document order
+	Styles []Style
+}
+
+// Chapter is one part of the split document
+type Chapter struct {
+	Title string
+	// HTML is the chapter text. Embedded images have ImageSource(name) as
+	// their source.
+	HTML string
+	// Images names the embedded images the chapter shows
+	Images  []string
+	Words   int
+	Excerpt string
+}
+
+// Style is a paragraph style and how many body paragraphs use it
+type Style struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Paragraphs int    `json:"paragraphs"`
+}
+
+// ImageSource is the source chapter HTML gives the embedded image name
+func ImageSource(name string) string {
+	return imagePrefix + name
+}
+
+// Read converts the Word document in r, which is size bytes long
+func Read(r io.ReaderAt, size int64, opts Options) (*Document, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotDocx, err)
+	}
+	p := &pkg{files: map[string]*zip.File{}}
+	for _, f := range archive.File {
+		p.files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	body, err := p.xml("word/document.xml", true)
+	if err != nil {
+		return nil, err
+	}
+	if body = body.child("body"); body == nil {
+		return nil, fmt.Errorf("%w: the document has no body", ErrNotDocx)
+	}
+	c := &converter{
+		pkg:       p,
+		styles:    map[string]*style{},
+		numbering: map[string]map[string]bool{},
+		links:     map[string]string{},
+		notes:     map[string]*node{},
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	if opts.SplitStyle == "" {
+		opts.SplitStyle = DefaultSplitStyle
+	}
+
+	doc := &Document{Title: c.title, Images: map[string][]byte{}}
+	blocks := c.blocks(body)
+	used := map[string]int{}
+	for _, b := range blocks {
+		if b.style == nil {
+			continue
+		}
+		i, ok := used[b.style.id]
+		if !ok {
+			i = len(doc.Styles)
+			used[b.style.id] = i
+			doc.Styles = append(doc.Styles, Style{ID: b.style.id, Name: b.style.name})
+		}
+		doc.Styles[i].Paragraphs++
+	}
+
+	for _, part := range c.split(blocks, opts) {
+		chapter := c.chapter(part)
+		for _, name := range chapter.Images {
+			if _, ok := doc.Images[name]; ok {
+				continue
+			}
+			data, err := p.read(name)
+			if err != nil {
+				return nil, err
+			}
+			doc.Images[name] = data
+		}
+		doc.Chapters = append(doc.Chapters, chapter)
+	}
+	if len(doc.Chapters) == 1 && doc.Chapters[0].Title == "" {
+		doc.Chapters[0].Title = doc.Title
+	}
+	for i := range doc.Chapters {
+		if doc.Chapters[i].Title == "" {
+			doc.Chapters[i].Title = "Chapter " + strconv.Itoa(i+1)
+		}
+	}
+	return doc, nil
+}
+
+// pkg is the zip package the document came in
+type pkg struct {
+	files map[string]*zip.File
+}
+
+func (p *pkg) read(name string) ([]byte, error) {
+	f, ok := p.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing", ErrNotDocx, name)
+	}
+	if f.UncompressedSize64 > maxPartBytes {
+		return nil, fmt.Errorf("%w: %s is too large", ErrNotDocx, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotDocx, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxPartBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNotDocx, name, err)
+	}
+	if len(data) > maxPartBytes {
+		return nil, fmt.Errorf("%w: %s is too large", ErrNotDocx, name)
+	}
+	return data, nil
+}
+
+// xml parses a part. Optional parts that are missing come back nil.
+func (p *pkg) xml(name string, required bool) (*node, error) {
+	if _, ok := p.files[name]; !ok && !required {
+		return nil, nil
+	}
+	data, err := p.read(name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := parseXML(strings.NewReader(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNotDocx, name, err)
+	}
+	return n, nil
+}
+
+// style is a paragraph or character style from word/styles.xml
+type style struct {
+	id, name string
+	basedOn  string
+	// outline is the heading level counted from 1, 0 for body text
+	outline int
+	// numID and level put paragraphs of the style in a list
+	numID, level string
+	// props are the run properties the style applies
+	props *node
+}
+
+// converter turns the document body into chapters
+type converter struct {
+	pkg    *pkg
+	title  string
+	styles map[string]*style
+	// defaultStyle is the ID of the style of paragraphs that name none
+	defaultStyle string
+	// numbering tells, per list and level, whether the list is ordered
+	numbering map[string]map[string]bool
+	// links are the external targets of hyperlinks, by relationship ID
+	links map[string]string
+	// media are the images of the package, by relationship ID
+	media map[string]string
+	// notes are the footnotes and endnotes, keyed "f" or "e" and their ID
+	notes map[string]*node
+}
+
+func (c *converter) load() error {
+	styles, err := c.pkg.xml("word/styles.xml", false)
+	if err != nil {
+		return err
+	}
+	for _, s := range styles.childrenNamed("style") {
+		st := &style{id: s.attr("styleId"), name: s.child("name").attr("val"), basedOn: s.child("basedOn").attr("val"), props: s.child("rPr")}
+		if pPr := s.child("pPr"); pPr != nil {
+			st.outline = outline(pPr)
+			if numPr := pPr.child("numPr"); numPr != nil {
+				st.numID, st.level = numPr.child("numId").attr("val"), numPr.child("ilvl").attr("val")
+			}
+		}
+		if st.outline == 0 {
+			st.outline = headingLevel(st.name)
+		}
+		c.styles[st.id] = st
+		if s.attr("type") == "paragraph" && s.attr("default") == "1" {
+			c.defaultStyle = st.id
+		}
+	}
+
+	numbering, err := c.pkg.xml("word/numbering.xml", false)
+	if err != nil {
+		return err
+	}
+	abstract := map[string]map[string]bool{}
+	for _, a := range numbering.childrenNamed("abstractNum") {
+		levels := map[string]bool{}
+		for _, lvl := range a.childrenNamed("lvl") {
+			format := lvl.child("numFmt").attr("val")
+			levels[lvl.attr("ilvl")] = format != "bullet" && format != "none" && format != ""
+		}
+		abstract[a.attr("abstractNumId")] = levels
+	}
+	for _, n := range numbering.childrenNamed("num") {
+		c.numbering[n.attr("numId")] = abstract[n.child("abstractNumId").attr("val")]
+	}
+
+	rels, err := c.pkg.xml("word/_rels/document.xml.rels", false)
+	if err != nil {
+		return err
+	}
+	c.media = map[string]string{}
+	for _, rel := range rels.childrenNamed("Relationship") {
+		target := rel.attr("Target")
+		switch {
+		case strings.HasSuffix(rel.attr("Type"), "/hyperlink"):
+			c.links[rel.attr("Id")] = target
+		case strings.HasSuffix(rel.attr("Type"), "/image") && rel.attr("TargetMode") != "External":
+			if strings.HasPrefix(target, "/") {
+				c.media[rel.attr("Id")] = strings.TrimPrefix(target, "/")
+			} else {
+				c.media[rel.attr("Id")] = path.Join("word", target)
+			}
+		}
+	}
+
+	for key, part := range map[string]string{"f": "word/footnotes.xml", "e": "word/endnotes.xml"} {
+		notes, err := c.pkg.xml(part, false)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes.childrenNamed(map[string]string{"f": "footnote", "e": "endnote"}[key]) {
+			// separators and continuation notices have a type
+			if note.attr("type") == "" {
+				c.notes[key+note.attr("id")] = note
+			}
+		}
+	}
+
+	core, err := c.pkg.xml("docProps/core.xml", false)
+	if err != nil {
+		return err
+	}
+	if title := core.child("title"); title != nil {
+		c.title = strings.TrimSpace(title.text)
+	}
+	return nil
+}
+
+// childrenNamed returns the child elements with the local name. It is safe
+// to call on the nil node of a missing part.
+func (n *node) childrenNamed(local string) []*node {
+	if n == nil {
+		return nil
+	}
+	var found []*node
+	for _, c := range n.children {
+		if c.name == local {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// outline reads a paragraph's outline level as a heading level
+func outline(pPr *node) int {
+	lvl := pPr.child("outlineLvl")
+	if lvl == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(lvl.attr("val"))
+	if err != nil || n < 0 || n > 5 {
+		return 0
+	}
+	return n + 1
+}
+
+// headingLevel reads the level of the built-in heading styles from their
+// name, for documents that don't set an outline level
+func headingLevel(name string) int {
+	rest, ok := strings.CutPrefix(strings.ToLower(name), "heading")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(rest))
+	if err != nil || n < 1 {
+		return 0
+	}
+	if n > 6 {
+		n = 6
+	}
+	return n
+}
+
+// resolve follows the basedOn chain of a style, stopping at loops
+func (c *converter) resolve(id string, fn func(s *style) bool) {
+	seen := map[string]bool{}
+	for s := c.styles[id]; s != nil && !seen[s.id]; s = c.styles[s.basedOn] {
+		seen[s.id] = true
+		if fn(s) {
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package docx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentType is the media type of Word documents
+const ContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// Manuscript is a book laid out in Standard Manuscript Format: a title
+// page with the author's contact details and word count, then the
+// chapters double-spaced in 12 point type, each on a new page, under a
+// running header of surname, title and page number
+type Manuscript struct {
+	Title  string
+	Author string
+	// Contact is the author's legal name and how to reach them, a line each
+	Contact []string
+	// Surname starts the page headers
+	Surname  string
+	Chapters []ManuscriptChapter
+	// Modern sets the text in Times New Roman and keeps italics. Classic
+	// manuscripts use Courier and underline what is to be set in italics.
+	Modern bool
+}
+
+// ManuscriptChapter is a chapter's title and stored HTML
+type ManuscriptChapter struct {
+	Title string
+	HTML  string
+}
+
+// page layout in twentieths of a point, on US letter with one inch margins
+const (
+	pageWidth  = 12240
+	pageHeight = 15840
+	margin     = 1440
+	textWidth  = pageWidth - 2*margin
+)
+
+const (
+	nsMain = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	nsRels = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPkg  = "http://schemas.openxmlformats.org/package/2006/relationships"
+	relDoc = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/"
+)
+
+// WriteManuscript writes the manuscript as a Word document
+func WriteManuscript(w io.Writer, m Manuscript) error {
+	b := &builder{modern: m.Modern}
+	b.out = &b.body
+	for _, chapter := range m.Chapters {
+		b.paragraph("Heading1", "", []piece{{text: chapter.Title}})
+		b.chapter(chapter.HTML)
+	}
+	b.body.WriteString(`<w:p><w:pPr><w:pStyle w:val="SceneBreak"/></w:pPr><w:r><w:t>END</w:t></w:r></w:p>`)
+
+	var body strings.Builder
+	body.WriteString(titlePage(m, b.words))
+	body.WriteString(b.body.String())
+	fmt.Fprintf(&body, `<w:sectPr><w:headerReference w:type="default" r:id="rIdHeader"/><w:headerReference w:type="first" r:id="rIdFirstHeader"/>`+
+		`<w:footnotePr><w:numFmt w:val="decimal"/></w:footnotePr>`+
+		`<w:pgSz w:w="%d" w:h="%d"/><w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="720" w:footer="720" w:gutter="0"/><w:titlePg/></w:sectPr>`,
+		pageWidth, pageHeight, margin, margin, margin, margin)
+
+	title := strings.ToUpper(m.Title)
+	if m.Surname != "" {
+		title = m.Surname + " / " + title
+	}
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", packageRels},
+		{"docProps/core.xml", fmt.Sprintf(coreProps, escape(m.Title), escape(m.Author))},
+		{"docProps/app.xml", fmt.Sprintf(appProps, b.words)},
+		{"word/_rels/document.xml.rels", documentRels},
+		{"word/document.xml", xml.Header + `<w:document xmlns:w="` + nsMain + `" xmlns:r="` + nsRels + `"><w:body>` + body.String() + `</w:body></w:document>`},
+		{"word/styles.xml", styles(m.Modern)},
+		{"word/settings.xml", settings},
+		{"word/footnotes.xml", xml.Header + `<w:footnotes xmlns:w="` + nsMain + `" xmlns:r="` + nsRels + `">` + separators + b.notes.String() + `</w:footnotes>`},
+		{"word/header1.xml", header(`<w:r><w:t xml:space="preserve">` + escape(title) + ` / </w:t></w:r><w:fldSimple w:instr=" PAGE "><w:r><w:t>1</w:t></w:r></w:fldSimple>`)},
+		{"word/header2.xml", header("")},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// titlePage puts the contact details at the top left, the word count at
+// the top right and the title and byline halfway down the page
+func titlePage(m Manuscript, words int) string {
+	var page strings.Builder
+	contact := m.Contact
+	if len(contact) == 0 {
+		contact = []string{m.Author}
+	}
+	for i, line := range contact {
+		page.WriteString(`<w:p><w:pPr><w:pStyle w:val="Contact"/></w:pPr><w:r><w:t xml:space="preserve">` + escape(line) + `</w:t></w:r>`)
+		if i == 0 {
+			page.WriteString(`<w:r><w:tab/><w:t xml:space="preserve">` + escape(wordCount(words)) + `</w:t></w:r>`)
+		}
+		page.WriteString(`</w:p>`)
+	}
+	page.WriteString(`<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t xml:space="preserve">` + escape(m.Title) + `</w:t></w:r></w:p>`)
+	if m.Author != "" {
+		page.WriteString(`<w:p><w:pPr><w:pStyle w:val="Byline"/></w:pPr><w:r><w:t xml:space="preserve">by ` + escape(m.Author) + `</w:t></w:r></w:p>`)
+	}
+	return page.String()
+}
+
+// wordCount rounds the way manuscripts state their length: to the
+// thousand for novels, to the hundred for shorter work
+func wordCount(words int) string {
+	unit := 100
+	if words >= 20000 {
+		unit = 1000
+	}
+	rounded := (words + unit/2) / unit * unit
+	if rounded < unit {
+		rounded = unit
+	}
+	digits := fmt.Sprint(rounded)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return "about " + digits + " words"
+}
+
+func header(content string) string {
+	return xml.Header + `<w:hdr xmlns:w="` + nsMain + `" xmlns:r="` + nsRels + `"><w:p><w:pPr><w:pStyle w:val="Header"/></w:pPr>` + content + `</w:p></w:hdr>`
+}
+
+func escape(s string) string {
+	var out strings.Builder
+	xml.EscapeText(&out, []byte(s))
+	return out.String()
+}
+
+// styles sets the whole manuscript in one 12 point face, double-spaced
+// with indented paragraphs. Chapter titles use Heading 1, which is also
+// where an import splits the document.
+func styles(modern bool) string {
+	font := "Courier New"
+	if modern {
+		font = "Times New Roman"
+	}
+	style := func(kind, id, name, pPr, rPr string) string {
+		s := `<w:style w:type="` + kind + `" w:styleId="` + id + `"><w:name w:val="` + name + `"/>`
+		if kind == "paragraph" && id != "Normal" {
+			s += `<w:basedOn w:val="Normal"/><w:next w:val="Normal"/>`
+		}
+		if pPr != "" {
+			s += "<w:pPr>" + pPr + "</w:pPr>"
+		}
+		if rPr != "" {
+			s += "<w:rPr>" + rPr + "</w:rPr>"
+		}
+		return s + `</w:style>`
+	}
+	single := `<w:spacing w:after="0" w:line="240" w:lineRule="auto"/>`
+	plain := `<w:ind w:firstLine="0"/>`
+	center := plain + `<w:jc w:val="center"/>`
+	return xml.Header + `<w:styles xmlns:w="` + nsMain + `">` +
+		`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="` + font + `" w:hAnsi="` + font + `" w:eastAsia="` + font + `" w:cs="` + font + `"/><w:sz w:val="24"/><w:szCs w:val="24"/><w:lang w:val="en-US"/></w:rPr></w:rPrDefault>` +
+		`<w:pPrDefault><w:pPr><w:spacing w:after="0" w:line="480" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>` +
+		`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:pPr><w:ind w:firstLine="720"/></w:pPr></w:style>` +
+		style("paragraph", "Contact", "Contact", single+plain+fmt.Sprintf(`<w:tabs><w:tab w:val="right" w:pos="%d"/></w:tabs>`, textWidth), "") +
+		style("paragraph", "Title", "Title", center+`<w:spacing w:before="4320"/>`, "") +
+		style("paragraph", "Byline", "Byline", center, "") +
+		style("paragraph", "Heading1", "heading 1", center+`<w:pageBreakBefore/><w:spacing w:before="2880" w:after="480"/><w:outlineLvl w:val="0"/>`, "") +
+		style("paragraph", "Heading2", "heading 2", center+`<w:keepNext/><w:outlineLvl w:val="1"/>`, `<w:b/>`) +
+		style("paragraph", "Heading3", "heading 3", plain+`<w:keepNext/><w:outlineLvl w:val="2"/>`, `<w:b/>`) +
+		style("paragraph", "SceneBreak", "Scene Break", center, "") +
+		style("paragraph", "Quote", "Quote", `<w:ind w:left="720" w:right="720" w:firstLine="0"/>`, "") +
+		style("paragraph", "ListParagraph", "List Paragraph", `<w:ind w:left="720" w:hanging="360"/>`, "") +
+		style("paragraph", "PlainText", "Plain Text", plain+single, `<w:rFonts w:ascii="Courier New" w:hAnsi="Courier New" w:cs="Courier New"/>`) +
+		style("paragraph", "TableText", "Table Text", single+plain, "") +
+		style("paragraph", "Header", "header", single+`<w:ind w:firstLine="0"/><w:jc w:val="right"/>`, "") +
+		style("paragraph", "FootnoteText", "footnote text", single+plain, "") +
+		style("character", "FootnoteReference", "footnote reference", "", `<w:vertAlign w:val="superscript"/>`) +
+		`<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders>` +
+		`<w:top w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:left w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:bottom w:val="single" w:sz="4" w:space="0" w:color="auto"/>` +
+		`<w:right w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideH w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="auto"/>` +
+		`</w:tblBorders></w:tblPr></w:style>` +
+		`</w:styles>`
+}
+
+const contentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>` +
+	`<Override PartName="/word/footnotes.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footnotes+xml"/>` +
+	`<Override PartName="/word/header1.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"/>` +
+	`<Override PartName="/word/header2.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.header+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`<Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>` +
+	`</Types>`
+
+const packageRels = xml.Header + `<Relationships xmlns="` + nsPkg + `">` +
+	`<Relationship Id="rId1" Type="` + relDoc + `officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`<Relationship Id="rId3" Type="` + relDoc + `extended-properties" Target="docProps/app.xml"/>` +
+	`</Relationships>`
+
+const documentRels = xml.Header + `<Relationships xmlns="` + nsPkg + `">` +
+	`<Relationship Id="rIdStyles" Type="` + relDoc + `styles" Target="styles.xml"/>` +
+	`<Relationship Id="rIdSettings" Type="` + relDoc + `settings" Target="settings.xml"/>` +
+	`<Relationship Id="rIdFootnotes" Type="` + relDoc + `footnotes" Target="footnotes.xml"/>` +
+	`<Relationship Id="rIdHeader" Type="` + relDoc + `header" Target="header1.xml"/>` +
+	`<Relationship Id="rIdFirstHeader" Type="` + relDoc + `header" Target="header2.xml"/>` +
+	`</Relationships>`
+
+const settings = xml.Header + `<w:settings xmlns:w="` + nsMain + `">` +
+	`<w:defaultTabStop w:val="720"/><w:characterSpacingControl w:val="doNotCompress"/>` +
+	`<w:footnotePr><w:footnote w:id="-1"/><w:footnote w:id="0"/></w:footnotePr>` +
+	`</w:settings>`
+
+// separators are the notes Word draws the line above the footnotes with
+const separators = `<w:footnote w:type="separator" w:id="-1"><w:p><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:r><w:separator/></w:r></w:p></w:footnote>` +
+	`<w:footnote w:type="continuationSeparator" w:id="0"><w:p><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:r><w:continuationSeparator/></w:r></w:p></w:footnote>`
+
+const coreProps = xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+	`<dc:title>%s</dc:title><dc:creator>%s</dc:creator></cp:coreProperties>`
+
+const appProps = xml.Header + `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">` +
+	`<Application>OnWord</Application><Words>%d</Words></Properties>`
@@ -0,0 +1,487 @@
+package docx
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// piece is text with its formatting, or a run written out already such as
+// a break or a footnote reference
+type piece struct {
+	text string
+	f    format
+	raw  string
+}
+
+// blockContext is how the paragraphs of a block are styled
+type blockContext struct {
+	style string
+	pPr   string
+	f     format
+}
+
+// builder writes chapter HTML as WordprocessingML
+type builder struct {
+	modern bool
+	body   strings.Builder
+	notes  strings.Builder
+	// out is the body, or the notes while a footnote is written
+	out    *strings.Builder
+	words  int
+	noteID int
+	// defs are the footnotes of the chapter being written, by label
+	defs map[string]*html.Node
+	// noteMark starts the first paragraph of the footnote being written
+	noteMark string
+	inNote   bool
+}
+
+// chapter writes the paragraphs of a chapter's HTML
+func (b *builder) chapter(src string) {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		b.paragraph("Normal", "", []piece{{text: src}})
+		return
+	}
+	b.defs = map[string]*html.Node{}
+	for _, n := range nodes {
+		b.findNotes(n)
+	}
+	b.blocks(nodes, blockContext{style: "Normal"})
+}
+
+func (b *builder) findNotes(n *html.Node) {
+	if n.DataAtom == atom.Li && strings.HasPrefix(attr(n, "id"), "fn:") {
+		b.defs[strings.TrimPrefix(attr(n, "id"), "fn:")] = n
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.findNotes(c)
+	}
+}
+
+func (b *builder) blocks(nodes []*html.Node, ctx blockContext) {
+	var pending []*html.Node
+	flush := func() {
+		if len(pending) > 0 {
+			b.paragraph(ctx.style, ctx.pPr, b.inline(pending, ctx.f))
+			pending = nil
+		}
+	}
+	for _, n := range nodes {
+		if n.Type != html.ElementNode || !blockElements[n.DataAtom] {
+			pending = append(pending, n)
+			continue
+		}
+		flush()
+		switch n.DataAtom {
+		case atom.P, atom.Dt, atom.Dd, atom.Figcaption:
+			b.paragraph(ctx.style, ctx.pPr, b.inline(children(n), ctx.f))
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			style := ctx.style
+			if style == "Normal" {
+				style = "Heading3"
+				if n.DataAtom == atom.H1 || n.DataAtom == atom.H2 {
+					style = "Heading2"
+				}
+			}
+			b.paragraph(style, ctx.pPr, b.inline(children(n), ctx.f))
+		case atom.Hr:
+			b.paragraph("SceneBreak", "", []piece{{text: "#"}})
+		case atom.Blockquote:
+			b.blocks(children(n), blockContext{style: "Quote", f: ctx.f})
+		case atom.Ul, atom.Ol:
+			b.list(n, 0)
+		case atom.Pre:
+			b.pre(n)
+		case atom.Table:
+			b.table(n)
+		case atom.Script, atom.Style, atom.Template:
+		default:
+			if hasClass(n, "footnotes") || attr(n, "role") == "doc-endnotes" {
+				continue
+			}
+			b.blocks(children(n), ctx)
+		}
+	}
+	flush()
+}
+
+// list writes each item as a paragraph with a hanging bullet or number,
+// indented further for every level of nesting
+func (b *builder) list(n *html.Node, depth int) {
+	number := 1
+	if start := attr(n, "start"); start != "" {
+		fmt.Sscan(start, &number)
+	}
+	left := 720 * (depth + 1)
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "•"
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		first := true
+		emit := func(pieces []piece) {
+			if first {
+				pieces = append([]piece{{raw: `<w:r><w:t>` + marker + `</w:t></w:r><w:r><w:tab/></w:r>`}}, pieces...)
+				b.paragraph("ListParagraph", fmt.Sprintf(`<w:ind w:left="%d" w:hanging="360"/>`, left), pieces)
+				first = false
+				return
+			}
+			b.paragraph("ListParagraph", fmt.Sprintf(`<w:ind w:left="%d" w:firstLine="0"/>`, left), pieces)
+		}
+		var pending []*html.Node
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case c.DataAtom == atom.Ul || c.DataAtom == atom.Ol:
+				if len(pending) > 0 || first {
+					emit(b.inline(pending, format{}))
+					pending = nil
+				}
+				b.list(c, depth+1)
+			case c.DataAtom == atom.P:
+				if len(pending) > 0 {
+					emit(b.inline(pending, format{}))
+					pending = nil
+				}
+				emit(b.inline(children(c), format{}))
+			default:
+				pending = append(pending, c)
+			}
+		}
+		if len(pending) > 0 || first {
+			emit(b.inline(pending, format{}))
+		}
+	}
+}
+
+// pre keeps the lines and spacing of preformatted text
+func (b *builder) pre(n *html.Node) {
+	text := strings.TrimSuffix(textOf(n), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		b.out.WriteString(`<w:p><w:pPr><w:pStyle w:val="PlainText"/></w:pPr><w:r><w:t xml:space="preserve">` + escape(line) + `</w:t></w:r></w:p>`)
+		b.count(line)
+	}
+}
+
+func (b *builder) table(n *html.Node) {
+	var rows []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Tr:
+				rows = append(rows, c)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	columns := 1
+	for _, tr := range rows {
+		cells := 0
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+				cells += span(c)
+			}
+		}
+		if cells > columns {
+			columns = cells
+		}
+	}
+	width := textWidth / columns
+
+	b.out.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="0" w:type="auto"/></w:tblPr><w:tblGrid>`)
+	for i := 0; i < columns; i++ {
+		fmt.Fprintf(b.out, `<w:gridCol w:w="%d"/>`, width)
+	}
+	b.out.WriteString(`</w:tblGrid>`)
+	for _, tr := range rows {
+		b.out.WriteString(`<w:tr>`)
+		if tr.FirstChild != nil && firstElement(tr).DataAtom == atom.Th {
+			b.out.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+		}
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Td && c.DataAtom != atom.Th {
+				continue
+			}
+			fmt.Fprintf(b.out, `<w:tc><w:tcPr><w:tcW w:w="%d" w:type="dxa"/>`, width*span(c))
+			if span(c) > 1 {
+				fmt.Fprintf(b.out, `<w:gridSpan w:val="%d"/>`, span(c))
+			}
+			b.out.WriteString(`</w:tcPr>`)
+			var cell strings.Builder
+			out := b.out
+			b.out = &cell
+			b.blocks(children(c), blockContext{style: "TableText", f: format{bold: c.DataAtom == atom.Th}})
+			b.out = out
+			if cell.Len() == 0 {
+				cell.WriteString(`<w:p><w:pPr><w:pStyle w:val="TableText"/></w:pPr></w:p>`)
+			}
+			b.out.WriteString(cell.String() + `</w:tc>`)
+		}
+		b.out.WriteString(`</w:tr>`)
+	}
+	b.out.WriteString(`</w:tbl>`)
+}
+
+// inline collects the formatted text of nodes
+func (b *builder) inline(nodes []*html.Node, f format) []piece {
+	var pieces []piece
+	var walk func(n *html.Node, f format)
+	walk = func(n *html.Node, f format) {
+		switch n.Type {
+		case html.TextNode:
+			pieces = append(pieces, piece{text: n.Data, f: f})
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+		switch n.DataAtom {
+		case atom.Em, atom.I, atom.Cite, atom.U:
+			f.italic = true
+		case atom.Strong, atom.B:
+			f.bold = true
+		case atom.S, atom.Del, atom.Strike:
+			f.strike = true
+		case atom.Sub:
+			f.sub = true
+		case atom.Sup:
+			if a := firstElement(n); a != nil && a.DataAtom == atom.A && isNoteRef(a) {
+				walk(a, f)
+				return
+			}
+			f.sup = true
+		case atom.A:
+			if attr(n, "role") == "doc-backlink" || hasClass(n, "footnote-backref") {
+				return
+			}
+			if isNoteRef(n) {
+				if ref := b.noteRef(strings.TrimPrefix(attr(n, "href"), "#fn:")); ref != "" {
+					pieces = append(pieces, piece{raw: ref})
+				}
+				return
+			}
+		case atom.Br:
+			pieces = append(pieces, piece{raw: `<w:r><w:br/></w:r>`})
+			return
+		case atom.Img:
+			if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+				pieces = append(pieces, piece{text: "[Art: " + alt + "]", f: f})
+			} else {
+				pieces = append(pieces, piece{text: "[Art]", f: f})
+			}
+			return
+		case atom.Script, atom.Style, atom.Template:
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, f)
+		}
+	}
+	for _, n := range nodes {
+		walk(n, f)
+	}
+	return pieces
+}
+
+// noteRef writes the footnote with the label and returns the run that
+// refers to it. Footnotes inside footnotes are left out.
+func (b *builder) noteRef(label string) string {
+	def := b.defs[label]
+	if def == nil || b.inNote {
+		return ""
+	}
+	b.noteID++
+	id := b.noteID
+	out := b.out
+	b.out, b.inNote = &b.notes, true
+	b.noteMark = `<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteRef/></w:r><w:r><w:t xml:space="preserve"> </w:t></w:r>`
+	fmt.Fprintf(b.out, `<w:footnote w:id="%d">`, id)
+	b.blocks(children(def), blockContext{style: "FootnoteText"})
+	if b.noteMark != "" {
+		b.out.WriteString(`<w:p><w:pPr><w:pStyle w:val="FootnoteText"/></w:pPr>` + b.noteMark + `</w:p>`)
+		b.noteMark = ""
+	}
+	b.out.WriteString(`</w:footnote>`)
+	b.out, b.inNote = out, false
+	return fmt.Sprintf(`<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteReference w:id="%d"/></w:r>`, id)
+}
+
+// paragraph writes the pieces as a paragraph, collapsing white space the
+// way a browser would. Paragraphs with nothing in them are left out.
+func (b *builder) paragraph(style, pPr string, pieces []piece) {
+	pieces = collapse(pieces)
+	if len(pieces) == 0 {
+		return
+	}
+	mark := ""
+	if b.inNote {
+		mark, b.noteMark = b.noteMark, ""
+	}
+
+	b.out.WriteString(`<w:p><w:pPr><w:pStyle w:val="` + style + `"/>` + pPr + `</w:pPr>` + mark)
+	for i := 0; i < len(pieces); {
+		p := pieces[i]
+		if p.raw != "" {
+			b.out.WriteString(p.raw)
+			i++
+			continue
+		}
+		var text strings.Builder
+		for ; i < len(pieces) && pieces[i].raw == "" && pieces[i].f == p.f; i++ {
+			text.WriteString(pieces[i].text)
+		}
+		b.out.WriteString(`<w:r>` + b.runProps(p.f) + `<w:t xml:space="preserve">` + escape(text.String()) + `</w:t></w:r>`)
+		if !b.inNote {
+			b.count(text.String())
+		}
+	}
+	b.out.WriteString(`</w:p>`)
+}
+
+func (b *builder) count(text string) {
+	b.words += len(strings.Fields(text))
+}
+
+func (b *builder) runProps(f format) string {
+	var props strings.Builder
+	if f.bold {
+		props.WriteString(`<w:b/>`)
+	}
+	if f.italic && b.modern {
+		props.WriteString(`<w:i/>`)
+	}
+	if f.strike {
+		props.WriteString(`<w:strike/>`)
+	}
+	if f.italic && !b.modern {
+		props.WriteString(`<w:u w:val="single"/>`)
+	}
+	switch {
+	case f.sup:
+		props.WriteString(`<w:vertAlign w:val="superscript"/>`)
+	case f.sub:
+		props.WriteString(`<w:vertAlign w:val="subscript"/>`)
+	}
+	if props.Len() == 0 {
+		return ""
+	}
+	return `<w:rPr>` + props.String() + `</w:rPr>`
+}
+
+// collapse turns runs of HTML white space into single spaces and trims
+// the ends of the paragraph
+func collapse(pieces []piece) []piece {
+	var out []piece
+	space := true
+	for _, p := range pieces {
+		if p.raw != "" {
+			out = append(out, p)
+			space = true
+			continue
+		}
+		var text strings.Builder
+		for _, r := range p.text {
+			switch r {
+			case ' ', '\t', '\n', '\r', '\f':
+				if !space {
+					text.WriteByte(' ')
+				}
+				space = true
+			default:
+				text.WriteRune(r)
+				space = false
+			}
+		}
+		if text.Len() > 0 {
+			out = append(out, piece{text: text.String(), f: p.f})
+		}
+	}
+	for len(out) > 0 && out[len(out)-1].raw == "" {
+		last := &out[len(out)-1]
+		last.text = strings.TrimRight(last.text, " \u00a0")
+		if last.text != "" {
+			break
+		}
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// blockElements start a paragraph of their own
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Dd: true,
+	atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Section: true, atom.Table: true, atom.Ul: true, atom.Script: true, atom.Style: true, atom.Template: true,
+}
+
+func children(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
+
+func firstElement(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text.WriteString(textOf(c))
+	}
+	return text.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func isNoteRef(a *html.Node) bool {
+	return (attr(a, "role") == "doc-noteref" || hasClass(a, "footnote-ref")) && strings.HasPrefix(attr(a, "href"), "#fn:")
+}
+
+func span(cell *html.Node) int {
+	n := 1
+	fmt.Sscan(attr(cell, "colspan"), &n)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
@@ -0,0 +1,105 @@
+package docx
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// node is an element of a package part. WordprocessingML is matched on
+// local names, which covers both the transitional and strict namespaces.
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []*node
+	// text is the character data of elements such as w:t
+	text string
+}
+
+func parseXML(r io.Reader) (*node, error) {
+	decoder := xml.NewDecoder(r)
+	root := &node{}
+	stack := []*node{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: t.Attr}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			top.text += string(t)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return root.children[0], nil
+}
+
+// attr returns the value of the attribute with the local name, e.g. "val"
+// for w:val
+func (n *node) attr(local string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the local name
+func (n *node) child(local string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// find returns the first descendant with the local name, depth first
+func (n *node) find(local string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == local {
+			return c
+		}
+		if found := c.find(local); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// on reports whether a toggle property such as w:b is set. A missing val
+// means on.
+func (n *node) on() bool {
+	if n == nil {
+		return false
+	}
+	switch strings.ToLower(n.attr("val")) {
+	case "0", "false", "off", "none":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+// Package epub writes books as EPUB 3 publications. Fixed-layout books are
+// written pre-paginated: every page is an XHTML document drawn at the
+// book's viewport, with the page image filling it and text placed over it.
+// Reflowable books are written a chapter per XHTML document.
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/accessibility"
+	"github.com/programmingbunny/epub-backend/models"
+)
+
+// ContentType is the media type of EPUB publications
+const ContentType = "application/epub+zip"
+
+// FixedLayout is a fixed-layout book ready to be written
+type FixedLayout struct {
+	// Identifier is the publication's unique identifier
+	Identifier string
+	// Book is written as the package metadata
+	Book     models.Book
+	Modified time.Time
+	Viewport models.Viewport
+	Pages    []Page
+	// Accessibility is written as schema.org metadata
+	Accessibility accessibility.Metadata
+}
+
+// Page is a page of a fixed-layout book
+type Page struct {
+	// Image fills the page, nil leaves the page blank behind its text
+	Image []byte
+	// Description is the image's alt text and long description
+	Description models.ImageDescription
+	Spread      string
+	Regions     []models.TextRegion
+}
+
+// imageTypes are the core media types of EPUB 3 images, by the extension
+// they are saved with
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// spreadProperties are the spine properties placing a page in a spread
+var spreadProperties = map[string]string{
+	models.SpreadLeft:   "page-spread-left",
+	models.SpreadRight:  "page-spread-right",
+	models.SpreadCenter: "rendition:page-spread-center",
+}
+
+// WriteFixedLayout writes the book as a pre-paginated EPUB. The first
+// page's image doubles as the cover. Images that aren't JPEG, PNG, GIF or
+// WebP are left out.
+func WriteFixedLayout(w io.Writer, book FixedLayout) error {
+	if len(book.Pages) == 0 {
+		return fmt.Errorf("epub: a book needs at least one page")
+	}
+	if book.Book.Language == "" {
+		book.Book.Language = "en"
+	}
+
+	var parts []part
+	var manifest, spine, toc strings.Builder
+	for i, page := range book.Pages {
+		n := i + 1
+		image := ""
+		if ext, ok := imageTypes[http.DetectContentType(page.Image)]; ok && len(page.Image) > 0 {
+			image = fmt.Sprintf("images/page-%d%s", n, ext)
+			properties := ""
+			if n == 1 {
+				properties = ` properties="cover-image"`
+			}
+			fmt.Fprintf(&manifest, `<item id="image-%d" href="%s" media-type="%s"%s/>`+"\n", n, image, http.DetectContentType(page.Image), properties)
+			parts = append(parts, part{"OEBPS/" + image, page.Image})
+		}
+		fmt.Fprintf(&manifest, `<item id="page-%d" href="page-%d.xhtml" media-type="application/xhtml+xml"/>`+"\n", n, n)
+		parts = append(parts, part{fmt.Sprintf("OEBPS/page-%d.xhtml", n), []byte(pageDocument(book, n, image, page))})
+
+		if property, ok := spreadProperties[page.Spread]; ok {
+			fmt.Fprintf(&spine, `<itemref idref="page-%d" properties="%s"/>`+"\n", n, property)
+		} else {
+			fmt.Fprintf(&spine, `<itemref idref="page-%d"/>`+"\n", n)
+		}
+		fmt.Fprintf(&toc, `<li><a href="page-%d.xhtml">Page %d</a></li>`+"\n", n, n)
+	}
+
+	packageDocument := xml.Header +
+		`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + escape(book.Book.Language) + `">` + "\n" +
+		`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n" +
+		`<dc:identifier id="book-id">` + escape(book.Identifier) + `</dc:identifier>` + "\n" +
+		packageMetadata(book.Book) +
+		`<meta property="dcterms:modified">` + book.Modified.UTC().Format("2006-01-02T15:04:05Z") + `</meta>` + "\n" +
+		`<meta property="rendition:layout">pre-paginated</meta>` + "\n" +
+		`<meta property="rendition:orientation">auto</meta>` + "\n" +
+		`<meta property="rendition:spread">auto</meta>` + "\n" +
+		accessibilityMetadata(book.Accessibility) +
+		`</metadata>` + "\n" +
+		`<manifest>` + "\n" +
+		`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n" +
+		`<item id="style" href="style.css" media-type="text/css"/>` + "\n" +
+		manifest.String() +
+		`</manifest>` + "\n" +
+		`<spine page-progression-direction="ltr">` + "\n" + spine.String() + `</spine>` + "\n" +
+		`</package>` + "\n"
+
+	navDocument := xml.Header + "<!DOCTYPE html>\n" +
+		`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + escape(book.Book.Language) + `" lang="` + escape(book.Book.Language) + `">` + "\n" +
+		`<head><meta charset="utf-8"/><title>` + escape(book.Book.Title) + `</title></head>` + "\n" +
+		`<body>` + "\n" +
+		`<nav epub:type="toc" id="toc"><h1>` + escape(book.Book.Title) + `</h1>` + "\n<ol>\n" + toc.String() + "</ol>\n</nav>\n" +
+		`<nav epub:type="page-list" hidden=""><ol>` + "\n" + toc.String() + "</ol>\n</nav>\n" +
+		`</body>` + "\n" + `</html>` + "\n"
+
+	parts = append([]part{
+		{"META-INF/container.xml", []byte(container)},
+		{"OEBPS/package.opf", []byte(packageDocument)},
+		{"OEBPS/nav.xhtml", []byte(navDocument)},
+		{"OEBPS/style.css", []byte(style)},
+	}, parts...)
+
+	return writeArchive(w, parts)
+}
+
+// part is a file of a publication by its path in the zip
+type part struct {
+	name    string
+	content []byte
+}
+
+// writeArchive writes the container of a publication holding parts
+func writeArchive(w io.Writer, parts []part) error {
+	archive := zip.NewWriter(w)
+	// the mimetype comes first and uncompressed, so readers can sniff it
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, ContentType); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// pageDocument is the XHTML of a page: the image stretched over the whole
+// viewport and the text regions placed on top
+func pageDocument(book FixedLayout, n int, image string, p Page) string {
+	var page strings.Builder
+	page.WriteString(xml.Header + "<!DOCTYPE html>\n")
+	fmt.Fprintf(&page, `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">`+"\n", escape(book.Book.Language), escape(book.Book.Language))
+	fmt.Fprintf(&page, `<head>`+"\n"+`<meta charset="utf-8"/>`+"\n"+`<title>%s, page %d</title>`+"\n", escape(book.Book.Title), n)
+	fmt.Fprintf(&page, `<meta name="viewport" content="width=%d, height=%d"/>`+"\n", book.Viewport.Width, book.Viewport.Height)
+	page.WriteString(`<link rel="stylesheet" type="text/css" href="style.css"/>` + "\n</head>\n")
+	fmt.Fprintf(&page, `<body style="width: %dpx; height: %dpx;">`+"\n", book.Viewport.Width, book.Viewport.Height)
+	if image != "" {
+		page.WriteString(pageImage(n, image, p.Description))
+	}
+	for _, region := range p.Regions {
+		css := fmt.Sprintf("left: %dpx; top: %dpx; width: %dpx; height: %dpx;", region.X, region.Y, region.Width, region.Height)
+		if region.FontSize > 0 {
+			css += fmt.Sprintf(" font-size: %dpx;", region.FontSize)
+		}
+		if region.Align != "" {
+			css += " text-align: " + region.Align + ";"
+		}
+		if region.Color != "" {
+			css += " color: " + region.Color + ";"
+		}
+		lines := strings.Split(strings.ReplaceAll(region.Text, "\r\n", "\n"), "\n")
+		for i := range lines {
+			lines[i] = escape(lines[i])
+		}
+		fmt.Fprintf(&page, `<div class="text" style="%s">%s</div>`+"\n", escape(css), strings.Join(lines, "<br/>"))
+	}
+	page.WriteString("</body>\n</html>\n")
+	return page.String()
+}
+
+// pageImage is the img element of a page. Decorative images are hidden
+// from assistive technology; long descriptions follow the image, hidden
+// from view.
+func pageImage(n int, image string, description models.ImageDescription) string {
+	if description.Decorative {
+		return fmt.Sprintf(`<img class="page" src="%s" alt="" role="presentation"/>`+"\n", image)
+	}
+	if description.LongDescription == "" {
+		return fmt.Sprintf(`<img class="page" src="%s" alt="%s"/>`+"\n", image, escape(description.AltText))
+	}
+	return fmt.Sprintf(`<img class="page" src="%s" alt="%s" aria-describedby="description-%d"/>`+"\n", image, escape(description.AltText), n) +
+		fmt.Sprintf(`<div id="description-%d" class="description" hidden="hidden">%s</div>`+"\n", n, escape(description.LongDescription))
+}
+
+// accessibilityMetadata writes the schema.org accessibility properties as
+// package metadata
+func accessibilityMetadata(m accessibility.Metadata) string {
+	var out strings.Builder
+	property := func(name string, values ...string) {
+		for _, value := range values {
+			fmt.Fprintf(&out, `<meta property="schema:%s">%s</meta>`+"\n", name, escape(value))
+		}
+	}
+	property("accessMode", m.AccessModes...)
+	property("accessModeSufficient", m.AccessModeSufficient...)
+	property("accessibilityFeature", m.Features...)
+	property("accessibilityHazard", m.Hazards...)
+	if m.Summary != "" {
+		property("accessibilitySummary", m.Summary)
+	}
+	return out.String()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const container = xml.Header + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/package.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+const style = `html, body {
+  margin: 0;
+  padding: 0;
+  overflow: hidden;
+}
+
+body {
+  position: relative;
+}
+
+img.page {
+  position: absolute;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+}
+
+div.text {
+  position: absolute;
+  margin: 0;
+  overflow: hidden;
+  font-family: serif;
+  line-height: 1.3;
+}
+`
@@ -0,0 +1,99 @@
+package epub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/models"
+)
+
+// identifierURNs prefix identifiers that have a URN namespace
+var identifierURNs = map[string]string{
+	models.IdentifierISBN13: "urn:isbn:",
+	models.IdentifierUUID:   "urn:uuid:",
+}
+
+// subjectAuthorities name the subject schemes as EPUB authorities
+var subjectAuthorities = map[string]string{
+	models.SubjectBISAC: "BISAC",
+	models.SubjectThema: "THEMA",
+}
+
+// packageMetadata is the Dublin Core metadata of a book, refined with
+// roles, title types and its series. The unique identifier is written by
+// the caller.
+func packageMetadata(book models.Book) string {
+	var out strings.Builder
+	element := func(name, id, value string) {
+		if id != "" {
+			id = ` id="` + id + `"`
+		}
+		fmt.Fprintf(&out, "<dc:%s%s>%s</dc:%s>\n", name, id, escape(value), name)
+	}
+	refine := func(id, property, scheme, value string) {
+		if scheme != "" {
+			scheme = ` scheme="` + scheme + `"`
+		}
+		fmt.Fprintf(&out, `<meta refines="#%s" property="%s"%s>%s</meta>`+"\n", id, property, scheme, escape(value))
+	}
+
+	for i, identifier := range book.Identifiers {
+		id := fmt.Sprintf("identifier-%d", i+1)
+		element("identifier", id, identifierURNs[identifier.Type]+identifier.Value)
+		if identifier.Type == models.IdentifierISBN13 {
+			refine(id, "identifier-type", "onix:codelist5", "15")
+		}
+	}
+
+	element("title", "title", book.Title)
+	refine("title", "title-type", "", "main")
+	if book.Subtitle != "" {
+		element("title", "subtitle", book.Subtitle)
+		refine("subtitle", "title-type", "", "subtitle")
+	}
+
+	element("creator", "creator-1", book.Author)
+	refine("creator-1", "role", "marc:relators", "aut")
+	for i, contributor := range book.Contributors {
+		id := fmt.Sprintf("creator-%d", i+2)
+		name := "contributor"
+		if contributor.Role == "aut" {
+			name = "creator"
+		}
+		element(name, id, contributor.Name)
+		refine(id, "role", "marc:relators", contributor.Role)
+		if contributor.FileAs != "" {
+			refine(id, "file-as", "", contributor.FileAs)
+		}
+	}
+
+	element("language", "", book.Language)
+	if book.Description != "" {
+		element("description", "", book.Description)
+	}
+	if book.Publisher != "" {
+		element("publisher", "", book.Publisher)
+	}
+	if book.PublicationDate != "" {
+		element("date", "", book.PublicationDate)
+	}
+	for i, subject := range book.Subjects {
+		id := fmt.Sprintf("subject-%d", i+1)
+		element("subject", id, subject.Code)
+		refine(id, "authority", "", subjectAuthorities[subject.Scheme])
+		refine(id, "term", "", subject.Code)
+	}
+	for _, keyword := range book.Keywords {
+		element("subject", "", keyword)
+	}
+
+	if book.Series != nil {
+		fmt.Fprintf(&out, `<meta property="belongs-to-collection" id="series">%s</meta>`+"\n", escape(book.Series.Name))
+		refine("series", "collection-type", "", "series")
+		if book.Series.Position > 0 {
+			refine("series", "group-position", "", strconv.Itoa(book.Series.Position))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,231 @@
+package epub
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/accessibility"
+	"github.com/programmingbunny/epub-backend/models"
+)
+
+// Reflowable is a reflowable book ready to be written
+type Reflowable struct {
+	// Identifier is the publication's unique identifier
+	Identifier string
+	// Book is written as the package metadata
+	Book     models.Book
+	Modified time.Time
+	// Cover is the cover image, if any
+	Cover    []byte
+	Chapters []Chapter
+	// Parts are the books an omnibus is made of, after its own chapters,
+	// each with a title page and its chapters nested under it in the
+	// contents
+	Parts []Part
+	// BackMatter are unnumbered chapters after the last one
+	BackMatter []Chapter
+	// Accessibility is written as schema.org metadata
+	Accessibility accessibility.Metadata
+	// Image returns the content of an image shown in chapter text by its
+	// src, or nil to leave the image out
+	Image func(src string) []byte
+}
+
+// Chapter is a chapter of a reflowable book
+type Chapter struct {
+	Title string
+	// HTML is the chapter's stored text
+	HTML string
+	// Header is the image shown above the chapter, if any
+	Header []byte
+	// HeaderAlt is the header's alt text; headers without it are decorative
+	HeaderAlt string
+}
+
+// Part is a book of an omnibus
+type Part struct {
+	Title, Subtitle, Author string
+	Chapters                []Chapter
+}
+
+// reflowable collects the files of a reflowable book
+type reflowable struct {
+	book  Reflowable
+	parts []part
+	// documents counts the XHTML documents, which are named by it
+	documents int
+	// assets are the names images were saved under, by content hash
+	assets               map[string]string
+	manifest, spine, toc strings.Builder
+}
+
+// WriteReflowable writes the book a chapter per XHTML document, after a
+// title page showing the cover. Images that aren't JPEG, PNG, GIF or WebP
+// are left out, as are images on other websites, which EPUB readers may
+// not load.
+func WriteReflowable(w io.Writer, book Reflowable) error {
+	if len(book.Chapters) == 0 && len(book.Parts) == 0 {
+		return fmt.Errorf("epub: a book needs at least one chapter")
+	}
+	if book.Book.Language == "" {
+		book.Book.Language = "en"
+	}
+	b := &reflowable{book: book, assets: map[string]string{}}
+
+	var title strings.Builder
+	if cover := b.asset(book.Cover, "cover-image"); cover != "" {
+		fmt.Fprintf(&title, `<img class="cover" src="%s" alt="%s"/>`+"\n", cover, escape("Cover of "+book.Book.Title))
+	}
+	fmt.Fprintf(&title, "<h1>%s</h1>\n", escape(book.Book.Title))
+	if book.Book.Subtitle != "" {
+		fmt.Fprintf(&title, `<p class="subtitle">%s</p>`+"\n", escape(book.Book.Subtitle))
+	}
+	fmt.Fprintf(&title, `<p class="author">%s</p>`+"\n", escape(book.Book.Author))
+	b.document(book.Book.Title, "titlepage", title.String())
+
+	for _, chapter := range book.Chapters {
+		b.chapter(chapter, "chapter")
+	}
+	for _, p := range book.Parts {
+		var page strings.Builder
+		fmt.Fprintf(&page, "<h1>%s</h1>\n", escape(p.Title))
+		if p.Subtitle != "" {
+			fmt.Fprintf(&page, `<p class="subtitle">%s</p>`+"\n", escape(p.Subtitle))
+		}
+		fmt.Fprintf(&page, `<p class="author">by %s</p>`+"\n", escape(p.Author))
+		name := b.document(p.Title, "part", page.String())
+		fmt.Fprintf(&b.toc, `<li><a href="%s">%s</a>`+"\n<ol>\n", name, escape(p.Title))
+		for _, chapter := range p.Chapters {
+			b.chapter(chapter, "chapter")
+		}
+		b.toc.WriteString("</ol>\n</li>\n")
+	}
+	for _, chapter := range book.BackMatter {
+		b.chapter(chapter, "backmatter")
+	}
+
+	packageDocument := xml.Header +
+		`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + escape(book.Book.Language) + `">` + "\n" +
+		`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n" +
+		`<dc:identifier id="book-id">` + escape(book.Identifier) + `</dc:identifier>` + "\n" +
+		packageMetadata(book.Book) +
+		`<meta property="dcterms:modified">` + book.Modified.UTC().Format("2006-01-02T15:04:05Z") + `</meta>` + "\n" +
+		accessibilityMetadata(book.Accessibility) +
+		`</metadata>` + "\n" +
+		`<manifest>` + "\n" +
+		`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n" +
+		`<item id="style" href="style.css" media-type="text/css"/>` + "\n" +
+		b.manifest.String() +
+		`</manifest>` + "\n" +
+		`<spine>` + "\n" + b.spine.String() + `</spine>` + "\n" +
+		`</package>` + "\n"
+
+	navDocument := xml.Header + "<!DOCTYPE html>\n" +
+		`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + escape(book.Book.Language) + `" lang="` + escape(book.Book.Language) + `">` + "\n" +
+		`<head><meta charset="utf-8"/><title>` + escape(book.Book.Title) + `</title></head>` + "\n" +
+		`<body>` + "\n" +
+		`<nav epub:type="toc" id="toc"><h1>Contents</h1>` + "\n<ol>\n" + b.toc.String() + "</ol>\n</nav>\n" +
+		`</body>` + "\n" + `</html>` + "\n"
+
+	return writeArchive(w, append([]part{
+		{"META-INF/container.xml", []byte(container)},
+		{"OEBPS/package.opf", []byte(packageDocument)},
+		{"OEBPS/nav.xhtml", []byte(navDocument)},
+		{"OEBPS/style.css", []byte(reflowableStyle)},
+	}, b.parts...))
+}
+
+// chapter adds a chapter's document, headed by its image and title, and
+// lists it in the contents
+func (b *reflowable) chapter(chapter Chapter, epubType string) {
+	var body strings.Builder
+	if header := b.asset(chapter.Header, ""); header != "" {
+		if chapter.HeaderAlt == "" {
+			fmt.Fprintf(&body, `<img class="header" src="%s" alt="" role="presentation"/>`+"\n", header)
+		} else {
+			fmt.Fprintf(&body, `<img class="header" src="%s" alt="%s"/>`+"\n", header, escape(chapter.HeaderAlt))
+		}
+	}
+	fmt.Fprintf(&body, "<h1>%s</h1>\n", escape(chapter.Title))
+	body.WriteString(b.xhtml(chapter.HTML))
+	name := b.document(chapter.Title, epubType, body.String())
+	fmt.Fprintf(&b.toc, `<li><a href="%s">%s</a></li>`+"\n", name, escape(chapter.Title))
+}
+
+// document adds an XHTML document in reading order and returns its name
+func (b *reflowable) document(title, epubType, body string) string {
+	b.documents++
+	name := fmt.Sprintf("text-%d.xhtml", b.documents)
+	language := escape(b.book.Book.Language)
+	content := xml.Header + "<!DOCTYPE html>\n" +
+		`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + language + `" lang="` + language + `">` + "\n" +
+		`<head>` + "\n" + `<meta charset="utf-8"/>` + "\n" + `<title>` + escape(title) + `</title>` + "\n" +
+		`<link rel="stylesheet" type="text/css" href="style.css"/>` + "\n</head>\n" +
+		`<body>` + "\n" + `<section epub:type="` + epubType + `">` + "\n" + body + "</section>\n</body>\n</html>\n"
+	fmt.Fprintf(&b.manifest, `<item id="text-%d" href="%s" media-type="application/xhtml+xml"/>`+"\n", b.documents, name)
+	fmt.Fprintf(&b.spine, `<itemref idref="text-%d"/>`+"\n", b.documents)
+	b.parts = append(b.parts, part{"OEBPS/" + name, []byte(content)})
+	return name
+}
+
+// asset adds an image to the publication and returns its path. The same
+// image is only saved once. Nothing is saved for content that isn't an
+// image EPUB readers must support.
+func (b *reflowable) asset(content []byte, properties string) string {
+	mediaType := http.DetectContentType(content)
+	ext, ok := imageTypes[mediaType]
+	if len(content) == 0 || !ok {
+		return ""
+	}
+	sum := sha1.Sum(content)
+	hash := hex.EncodeToString(sum[:])
+	if name, ok := b.assets[hash]; ok {
+		return name
+	}
+	name := "images/" + hash[:16] + ext
+	b.assets[hash] = name
+	if properties != "" {
+		properties = ` properties="` + properties + `"`
+	}
+	fmt.Fprintf(&b.manifest, `<item id="image-%s" href="%s" media-type="%s"%s/>`+"\n", hash[:16], name, mediaType, properties)
+	b.parts = append(b.parts, part{"OEBPS/" + name, content})
+	return name
+}
+
+const reflowableStyle = `body {
+  font-family: serif;
+  line-height: 1.5;
+}
+
+h1 {
+  text-align: center;
+  margin: 2em 0 1em;
+}
+
+img {
+  max-width: 100%;
+}
+
+img.cover, img.header {
+  display: block;
+  margin: 0 auto;
+}
+
+img.cover {
+  max-height: 60vh;
+}
+
+p.subtitle, p.author {
+  text-align: center;
+}
+
+p.subtitle {
+  font-style: italic;
+}
+`
@@ -0,0 +1,125 @@
+package epub
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// dropped are elements left out of chapters with everything in them.
+// Scripts would make the publication scripted, and forms and embedded
+// documents have no place in a book.
+var dropped = map[atom.Atom]bool{
+	atom.Script: true, atom.Noscript: true, atom.Style: true, atom.Template: true,
+	atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Form: true,
+}
+
+// unwrapped are elements XHTML in EPUB 3 doesn't have, whose content is
+// kept without them
+var unwrapped = map[atom.Atom]bool{
+	atom.Font: true, atom.Center: true, atom.Big: true, atom.Strike: true, atom.Tt: true,
+	atom.Html: true, atom.Head: true, atom.Body: true,
+}
+
+var voidElements = map[atom.Atom]bool{
+	atom.Area: true, atom.Br: true, atom.Col: true, atom.Hr: true, atom.Img: true,
+	atom.Input: true, atom.Source: true, atom.Track: true, atom.Wbr: true,
+}
+
+// attributeName matches the attribute names that are also XML names
+var attributeName = regexp.MustCompile(`^[A-Za-z_][-A-Za-z0-9_.]*$`)
+
+// xhtml writes a chapter's stored HTML as well-formed XHTML, with its
+// images copied into the publication. Event handlers, javascript: links
+// and elements that aren't HTML are left out.
+func (b *reflowable) xhtml(src string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return "<p>" + escape(src) + "</p>\n"
+	}
+	var out strings.Builder
+	for _, n := range nodes {
+		b.node(&out, n)
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+func (b *reflowable) node(out *strings.Builder, n *html.Node) {
+	switch {
+	case n.Type == html.TextNode:
+		out.WriteString(escape(n.Data))
+		return
+	case n.Type != html.ElementNode, n.Namespace != "", dropped[n.DataAtom]:
+		return
+	case n.DataAtom == 0, unwrapped[n.DataAtom]:
+		b.children(out, n)
+		return
+	case n.DataAtom == atom.Img:
+		b.image(out, n)
+		return
+	}
+
+	out.WriteString("<" + n.Data + attributes(n))
+	if voidElements[n.DataAtom] {
+		out.WriteString("/>")
+		return
+	}
+	out.WriteString(">")
+	b.children(out, n)
+	out.WriteString("</" + n.Data + ">")
+}
+
+func (b *reflowable) children(out *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.node(out, c)
+	}
+}
+
+// image writes an img with its image copied into the publication, or
+// nothing when the image can't be read
+func (b *reflowable) image(out *strings.Builder, n *html.Node) {
+	src := attr(n, "src")
+	if u, err := url.Parse(src); err != nil || u.Scheme != "" || b.book.Image == nil {
+		return
+	}
+	name := b.asset(b.book.Image(src), "")
+	if name == "" {
+		return
+	}
+	out.WriteString(`<img src="` + escape(name) + `" alt="` + escape(attr(n, "alt")) + `"` + attributes(n, "src", "alt") + "/>")
+}
+
+// attributes writes an element's attributes but the ones skipped
+func attributes(n *html.Node, skipped ...string) string {
+	var out strings.Builder
+outer:
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		for _, skip := range skipped {
+			if key == skip {
+				continue outer
+			}
+		}
+		switch {
+		case a.Namespace != "", !attributeName.MatchString(key), strings.HasPrefix(key, "on"), key == "xmlns":
+			continue
+		case (key == "href" || key == "src") && strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:"):
+			continue
+		}
+		out.WriteString(" " + key + `="` + escape(a.Val) + `"`)
+	}
+	return out.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/storage"
+)
+
+func gcOptions(cfg *configs.Config, dryRun bool) db.GCOptions {
+	return db.GCOptions{DryRun: dryRun, GracePeriod: cfg.Storage.GCGracePeriod, LegacyDirs: cfg.Storage.LegacyDirs}
+}
+
+// runGC implements the "gc" command and prints the report as JSON
+func runGC(ctx context.Context, cfg *configs.Config, store *db.Store, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report orphaned files without removing them")
+	flags.Parse(args)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	report, err := db.CollectGarbage(ctx, store, storage.NewBlobStore(cfg.Storage.UploadDir), gcOptions(cfg, *dryRun))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// scheduleGC collects garbage every storage.gcInterval until ctx is cancelled
+func scheduleGC(ctx context.Context, cfg *configs.Config, store *db.Store, blobs *storage.BlobStore) {
+	go func() {
+		ticker := time.NewTicker(cfg.Storage.GCInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			runCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			report, err := db.CollectGarbage(runCtx, store, blobs, gcOptions(cfg, false))
+			cancel()
+			if err != nil {
+				log.Println("garbage collection failed:", err)
+				continue
+			}
+			log.Printf("garbage collection removed %d files (%d bytes)\n", len(report.Orphans), report.ReclaimedBytes)
+		}
+	}()
+}
@@ -0,0 +1,142 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// MaxDimension caps the size of generated variants
+const MaxDimension = 4096
+
+// MaxPixels caps the canvas of the images Decode reads. A small file can
+// declare a canvas far larger than it is, and decoding it would take that
+// much memory.
+const MaxPixels = 50_000_000
+
+var (
+	ErrBadDimensions = errors.New("width and height must be between 0 and 4096")
+	ErrTooLarge      = errors.New("image is larger than 50 megapixels")
+)
+
+// FitSize returns the largest size that fits inside maxW x maxH while keeping
+// the aspect ratio of src. A zero bound leaves that axis unconstrained.
+// Images are never enlarged.
+func FitSize(src image.Rectangle, maxW, maxH int) (int, int) {
+	w, h := src.Dx(), src.Dy()
+	if w == 0 || h == 0 {
+		return w, h
+	}
+	scale := 1.0
+	if maxW > 0 && float64(maxW)/float64(w) < scale {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && float64(maxH)/float64(h) < scale {
+		scale = float64(maxH) / float64(h)
+	}
+	nw, nh := int(float64(w)*scale+0.5), int(float64(h)*scale+0.5)
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	return nw, nh
+}
+
+// Fit scales src down to fit inside maxW x maxH
+func Fit(src image.Image, maxW, maxH int) (image.Image, error) {
+	if maxW < 0 || maxH < 0 || maxW > MaxDimension || maxH > MaxDimension {
+		return nil, ErrBadDimensions
+	}
+	w, h := FitSize(src.Bounds(), maxW, maxH)
+	if w == src.Bounds().Dx() && h == src.Bounds().Dy() {
+		return src, nil
+	}
+	return Resize(src, w, h), nil
+}
+
+// Resize scales src to exactly w x h. Each destination pixel averages the
+// source pixels it covers, which keeps downscaled images free of aliasing.
+func Resize(src image.Image, w, h int) *image.NRGBA {
+	sb := src.Bounds()
+	rgba := image.NewNRGBA(sb)
+	draw.Draw(rgba, sb, src, sb.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sx := float64(sb.Dx()) / float64(w)
+	sy := float64(sb.Dy()) / float64(h)
+
+	for y := 0; y < h; y++ {
+		y0 := int(float64(y) * sy)
+		y1 := int(float64(y+1) * sy)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := int(float64(x) * sx)
+			x1 := int(float64(x+1) * sx)
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			// premultiply while averaging so transparent pixels don't bleed colour
+			var r, g, b, a, n uint64
+			for yy := y0; yy < y1 && yy < sb.Dy(); yy++ {
+				off := yy*rgba.Stride + x0*4
+				for xx := x0; xx < x1 && xx < sb.Dx(); xx++ {
+					pa := uint64(rgba.Pix[off+3])
+					r += uint64(rgba.Pix[off]) * pa
+					g += uint64(rgba.Pix[off+1]) * pa
+					b += uint64(rgba.Pix[off+2]) * pa
+					a += pa
+					n++
+					off += 4
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			c := color.NRGBA{A: uint8(a / n)}
+			if a > 0 {
+				c.R, c.G, c.B = uint8(r/a), uint8(g/a), uint8(b/a)
+			}
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// Decode reads a PNG, JPEG or GIF image and reports its format. Images
+// whose header declares more than MaxPixels are rejected with ErrTooLarge
+// before any pixels are read.
+func Decode(r io.Reader) (image.Image, string, error) {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+	return image.Decode(io.MultiReader(&header, r))
+}
+
+// Encode writes img in the given format, falling back to PNG for formats
+// the standard library cannot encode. It returns the matching content type.
+func Encode(w io.Writer, img image.Image, format string) (string, error) {
+	switch format {
+	case "jpeg":
+		return "image/jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case "gif":
+		return "image/gif", gif.Encode(w, img, nil)
+	default:
+		return "image/png", png.Encode(w, img)
+	}
+}
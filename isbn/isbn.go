@@ -0,0 +1,82 @@
+// Package isbn checks International Standard Book Numbers
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for numbers that aren't a valid ISBN-10 or ISBN-13
+var ErrInvalid = errors.New("not a valid ISBN")
+
+// Parse checks an ISBN-10 or ISBN-13, which may be written with hyphens or
+// spaces, and returns it as the 13 digits of an ISBN-13
+func Parse(s string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || r == ' ':
+			return -1
+		case r == 'x':
+			return 'X'
+		}
+		return r
+	}, s)
+
+	switch len(digits) {
+	case 10:
+		if !valid10(digits) {
+			return "", ErrInvalid
+		}
+		digits = "978" + digits[:9]
+		return digits + string(CheckDigit(digits)), nil
+	case 13:
+		if !allDigits(digits) || (!strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979")) || CheckDigit(digits[:12]) != digits[12] {
+			return "", ErrInvalid
+		}
+		return digits, nil
+	}
+	return "", ErrInvalid
+}
+
+// CheckDigit computes the last digit of an EAN-13 from the first twelve
+func CheckDigit(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(first12[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// valid10 checks the weighted sum of an ISBN-10, whose check digit may be X
+func valid10(digits string) bool {
+	if !allDigits(digits[:9]) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		d := int(digits[i] - '0')
+		if i == 9 {
+			switch {
+			case digits[9] == 'X':
+				d = 10
+			case digits[9] < '0' || digits[9] > '9':
+				return false
+			}
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"0-306-40615-2", "9780306406157"},
+		{"0306406152", "9780306406157"},
+		{"0 8044 2957 X", "9780804429573"},
+		{"080442957x", "9780804429573"},
+		{"978-0-306-40615-7", "9780306406157"},
+		{"9781861972712", "9781861972712"},
+		{"979-10-90636-07-1", "9791090636071"},
+
+		// wrong check digits
+		{"0-306-40615-3", ""},
+		{"978-0-306-40615-8", ""},
+		{"979-10-90636-07-2", ""},
+		// X only stands for 10 as an ISBN-10 check digit
+		{"X306406152", ""},
+		{"978030640615X", ""},
+		// an EAN-13 that isn't a book
+		{"4006381333931", ""},
+		{"978-0-306-4061", ""},
+		{"97803064061570", ""},
+		{"978O306406157", ""},
+		{"", ""},
+	} {
+		got, err := Parse(c.in)
+		if c.want == "" {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) = %q, %v; want ErrInvalid", c.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("Parse(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+}
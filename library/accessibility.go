@@ -0,0 +1,37 @@
+package library
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/accessibility"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+)
+
+// Accessibility checks a book's chapters, images and pages for
+// accessibility problems
+func (s *Service) Accessibility(ctx context.Context, book *models.Book) (*accessibility.Report, error) {
+	checked, err := s.accessibilityBook(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	report := accessibility.Check(*checked)
+	return &report, nil
+}
+
+// accessibilityBook gathers what an accessibility check looks at
+func (s *Service) accessibilityBook(ctx context.Context, book *models.Book) (*accessibility.Book, error) {
+	chapters, err := s.Chapters(ctx, book.ID, db.Query{Sort: db.ChapterSchema.DefaultSort})
+	if err != nil {
+		return nil, err
+	}
+	images, err := s.store.Images.ListByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := s.store.Pages.ListByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &accessibility.Book{Book: *book, Chapters: chapters.Items, Images: images, Pages: pages}, nil
+}
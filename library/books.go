@@ -0,0 +1,171 @@
+package library
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateBook stores a new book owned by owner, which is zero for
+// anonymous requests. cover, when not nil, is the cover image upload.
+func (s *Service) CreateBook(ctx context.Context, book models.Book, owner primitive.ObjectID, cover io.Reader) (*models.Book, error) {
+	// omnibus parts are checked by CreateOmnibus
+	book.Omnibus = nil
+	return s.createBook(ctx, book, owner, cover)
+}
+
+func (s *Service) createBook(ctx context.Context, book models.Book, owner primitive.ObjectID, cover io.Reader) (*models.Book, error) {
+	// ownership comes from the caller, never from the request body
+	book.ID = primitive.NilObjectID
+	book.Trash = models.Trash{}
+	book.OwnerID = owner
+	if book.Visibility == "" {
+		book.Visibility = models.VisibilityPublic
+	}
+	if err := Validate.Struct(book); err != nil {
+		return nil, err
+	}
+	normalizeMetadata(&book)
+
+	var blob *storage.Blob
+	if cover != nil {
+		stored, err := s.blobs.Put(cover)
+		if err != nil {
+			return nil, err
+		}
+		blob = &stored
+		book.BookCover = stored.Location
+		if err := s.store.Blobs.Retain(ctx, stored); err != nil {
+			log.Println(err)
+		}
+	}
+
+	id, err := s.store.Books.Insert(ctx, book)
+	if err != nil {
+		if blob != nil {
+			s.release(ctx, blob.Location)
+		}
+		return nil, err
+	}
+	book.ID = id
+	return &book, nil
+}
+
+// Book returns a book the reader may see. Private books of other users
+// are reported as missing so their existence isn't leaked.
+func (s *Service) Book(ctx context.Context, id, reader primitive.ObjectID) (*models.Book, error) {
+	book, err := s.store.Books.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !book.ReadableBy(readerID(reader)) {
+		return nil, db.ErrNotFound
+	}
+	return book, nil
+}
+
+// writableBook returns a book the writer may change. Books the writer
+// can't read are reported as missing, as Book does.
+func (s *Service) writableBook(ctx context.Context, id, writer primitive.ObjectID) (*models.Book, error) {
+	book, err := s.Book(ctx, id, writer)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// checkWriter returns ErrForbidden unless the writer may change the book
+func checkWriter(ctx context.Context, book *models.Book, writer primitive.ObjectID) error {
+	if book.WritableBy(readerID(writer)) || book.OwnerID.IsZero() && legacy(ctx) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// legacyKey marks contexts of requests on the v1 routes
+type legacyKey struct{}
+
+// Legacy marks ctx as serving a v1 route. Books without an owner predate
+// access control and their v1 clients may still change them; on v2 they
+// can only be read.
+func Legacy(ctx context.Context) context.Context {
+	return context.WithValue(ctx, legacyKey{}, true)
+}
+
+func legacy(ctx context.Context) bool {
+	on, _ := ctx.Value(legacyKey{}).(bool)
+	return on
+}
+
+// UpdateBook replaces a book's details and metadata. The cover, owner,
+// layout and omnibus parts are kept.
+func (s *Service) UpdateBook(ctx context.Context, book *models.Book, changes models.Book, writer primitive.ObjectID) (*models.Book, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	updated := changes
+	updated.ID = book.ID
+	updated.BookCover = book.BookCover
+	updated.OwnerID = book.OwnerID
+	updated.Layout = book.Layout
+	updated.Viewport = book.Viewport
+	updated.Omnibus = book.Omnibus
+	updated.Trash = book.Trash
+	if updated.Visibility == "" {
+		updated.Visibility = models.VisibilityPublic
+	}
+	if err := Validate.Struct(updated); err != nil {
+		return nil, err
+	}
+	normalizeMetadata(&updated)
+	if err := s.store.Books.Update(ctx, book.ID, updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Books lists the books the reader may see
+func (s *Service) Books(ctx context.Context, q db.Query, reader primitive.ObjectID) (*db.Page[models.Book], error) {
+	// the same rule as models.Book.ReadableBy
+	q.Any = []db.Filter{
+		{Field: "visibility", Op: db.OpNe, Value: models.VisibilityPrivate},
+		{Field: "ownerID", Op: db.OpExists, Value: false},
+	}
+	if !reader.IsZero() {
+		q.Any = append(q.Any, db.Filter{Field: "ownerID", Op: db.OpEq, Value: reader})
+	}
+	return s.store.Books.Find(ctx, q)
+}
+
+// TrashBook moves a book and everything belonging to it to the trash
+func (s *Service) TrashBook(ctx context.Context, id, actor primitive.ObjectID) error {
+	if _, err := s.writableBook(ctx, id, actor); err != nil {
+		return err
+	}
+	return s.trash.TrashBook(ctx, id, actor)
+}
+
+// release drops a blob reference held by a document that wasn't stored
+func (s *Service) release(ctx context.Context, location string) {
+	if hash, ok := s.blobs.HashOf(location); ok {
+		if _, err := s.store.Blobs.Release(ctx, hash); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// readerID is the form models.Book.ReadableBy and WritableBy take
+func readerID(reader primitive.ObjectID) string {
+	if reader.IsZero() {
+		return ""
+	}
+	return reader.Hex()
+}
@@ -0,0 +1,122 @@
+package library
+
+import (
+	"context"
+	"errors"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateChapter adds a chapter to its book. A number that is already taken
+// moves the chapter up to the next free one.
+func (s *Service) CreateChapter(ctx context.Context, chapter models.Chapter, writer primitive.ObjectID) (*models.Chapter, error) {
+	newChapter := models.Chapter{
+		Title:      chapter.Title,
+		ChapterNum: chapter.ChapterNum,
+		Text:       chapter.Text,
+		BookID:     chapter.BookID,
+		VersionID:  chapter.VersionID,
+	}
+	if err := Validate.Struct(&newChapter); err != nil {
+		return nil, err
+	}
+	book, err := s.writableBook(ctx, newChapter.BookID, writer)
+	if err != nil {
+		return nil, err
+	}
+
+	chapters, err := s.store.Chapters.ListByBook(ctx, newChapter.BookID)
+	if err != nil {
+		return nil, err
+	}
+	taken := map[int]bool{}
+	for _, existing := range ownChapters(book, chapters) {
+		taken[existing.ChapterNum] = true
+	}
+	for newChapter.ChapterNum != 0 && taken[newChapter.ChapterNum] {
+		newChapter.ChapterNum++
+	}
+
+	id, err := s.store.Chapters.Insert(ctx, newChapter)
+	if err != nil {
+		return nil, err
+	}
+	newChapter.ID = id
+	return &newChapter, nil
+}
+
+// Chapter returns a chapter. The copies of an omnibus' frozen parts aren't
+// chapters of the omnibus and return db.ErrNotFound.
+func (s *Service) Chapter(ctx context.Context, id primitive.ObjectID) (*models.Chapter, error) {
+	chapter, err := s.store.Chapters.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	book, err := s.store.Books.Get(ctx, chapter.BookID)
+	switch {
+	case err == nil && isCopy(book, *chapter):
+		return nil, db.ErrNotFound
+	case err != nil && !errors.Is(err, db.ErrNotFound):
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// Chapters lists a book's chapters, in chapter order unless the query
+// asks otherwise. An omnibus' chapters are the ones written for it, not
+// the copies of its frozen parts.
+func (s *Service) Chapters(ctx context.Context, bookID primitive.ObjectID, q db.Query) (*db.Page[models.Chapter], error) {
+	book, err := s.store.Books.Get(ctx, bookID)
+	switch {
+	case err == nil:
+		q = withoutCopies(book, q)
+	case !errors.Is(err, db.ErrNotFound):
+		return nil, err
+	}
+	return s.store.Chapters.Find(ctx, q.Where("bookID", db.OpEq, bookID))
+}
+
+// UpdateChapter replaces a chapter's title, text and number. It returns
+// db.ErrDuplicate when another chapter holds the number.
+func (s *Service) UpdateChapter(ctx context.Context, id primitive.ObjectID, changes models.Chapter, writer primitive.ObjectID) (*models.Chapter, error) {
+	chapter, err := s.store.Chapters.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	book, err := s.writableBook(ctx, chapter.BookID, writer)
+	if err != nil {
+		return nil, err
+	}
+	if isCopy(book, *chapter) {
+		return nil, db.ErrNotFound
+	}
+	chapter.Title = changes.Title
+	chapter.Text = changes.Text
+	chapter.ChapterNum = changes.ChapterNum
+	if err := Validate.Struct(chapter); err != nil {
+		return nil, err
+	}
+	if err := s.store.Chapters.Update(ctx, id, *chapter); err != nil {
+		return nil, err
+	}
+	return chapter, nil
+}
+
+// TrashChapter moves a chapter to the trash, where it gives up its number
+// until restored
+func (s *Service) TrashChapter(ctx context.Context, id, actor primitive.ObjectID) error {
+	chapter, err := s.store.Chapters.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	book, err := s.writableBook(ctx, chapter.BookID, actor)
+	if err != nil {
+		return err
+	}
+	if isCopy(book, *chapter) {
+		return db.ErrNotFound
+	}
+	return s.trash.TrashChapter(ctx, id, actor)
+}
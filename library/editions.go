@@ -0,0 +1,190 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/typeset"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidEdition is returned for export settings that can't be printed
+var ErrInvalidEdition = errors.New("invalid edition")
+
+// largePrintScale is the text scale large-print editions get unless they
+// choose one
+const largePrintScale = 1.3
+
+// CreateEdition adds an edition to a book. It returns db.ErrDuplicate when
+// another edition, of any book, has one of its identifiers.
+func (s *Service) CreateEdition(ctx context.Context, book *models.Book, edition models.Edition, writer primitive.ObjectID) (*models.Edition, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	edition.ID = primitive.NilObjectID
+	edition.BookID = book.ID
+	if err := s.checkEdition(ctx, &edition); err != nil {
+		return nil, err
+	}
+	id, err := s.store.Editions.Insert(ctx, edition)
+	if err != nil {
+		return nil, err
+	}
+	edition.ID = id
+	return &edition, nil
+}
+
+func (s *Service) Edition(ctx context.Context, id primitive.ObjectID) (*models.Edition, error) {
+	return s.store.Editions.Get(ctx, id)
+}
+
+// Editions lists a book's editions, in the order they were added unless
+// the query asks otherwise
+func (s *Service) Editions(ctx context.Context, bookID primitive.ObjectID, q db.Query) (*db.Page[models.Edition], error) {
+	return s.store.Editions.Find(ctx, q.Where("bookID", db.OpEq, bookID))
+}
+
+// UpdateEdition replaces everything about an edition but its book
+func (s *Service) UpdateEdition(ctx context.Context, edition *models.Edition, changes models.Edition, writer primitive.ObjectID) (*models.Edition, error) {
+	if _, err := s.writableBook(ctx, edition.BookID, writer); err != nil {
+		return nil, err
+	}
+	updated := changes
+	updated.ID, updated.BookID = edition.ID, edition.BookID
+	if err := s.checkEdition(ctx, &updated); err != nil {
+		return nil, err
+	}
+	if err := s.store.Editions.Update(ctx, updated.ID, updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteEdition removes an edition for good. The book keeps its chapters.
+func (s *Service) DeleteEdition(ctx context.Context, edition *models.Edition, writer primitive.ObjectID) error {
+	if _, err := s.writableBook(ctx, edition.BookID, writer); err != nil {
+		return err
+	}
+	return s.store.Editions.Delete(ctx, edition.ID)
+}
+
+// checkEdition validates an edition, writes it the way it is stored and
+// checks that no other edition has its identifiers. An ISBN, ASIN or UUID
+// names one product, so this holds across books too.
+func (s *Service) checkEdition(ctx context.Context, edition *models.Edition) error {
+	if err := Validate.Struct(edition); err != nil {
+		return err
+	}
+	for i, id := range edition.Identifiers {
+		if value, err := normalizeIdentifier(id); err == nil {
+			edition.Identifiers[i].Value = value
+		}
+	}
+	if edition.Format == models.FormatLargePrint && edition.Settings.TextScale == 0 {
+		edition.Settings.TextScale = largePrintScale
+	}
+	trim := edition.Settings.Trim
+	if trim == "" {
+		trim = typeset.DefaultTrim
+	}
+	if err := PrintLayout(typeset.TrimSizes[trim], edition).Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEdition, err)
+	}
+
+	for _, id := range edition.Identifiers {
+		others, err := s.store.Editions.ListByIdentifier(ctx, id.Value)
+		if err != nil {
+			return err
+		}
+		for _, other := range others {
+			if other.ID == edition.ID {
+				continue
+			}
+			if other.BookID == edition.BookID {
+				return fmt.Errorf("%w: %s %s belongs to the %q edition", db.ErrDuplicate, identifierNames[id.Type], id.Value, other.Name)
+			}
+			return fmt.Errorf("%w: %s %s belongs to an edition of another book", db.ErrDuplicate, identifierNames[id.Type], id.Value)
+		}
+	}
+	return nil
+}
+
+// identifierNames are how errors name the kinds of identifier
+var identifierNames = map[string]string{
+	models.IdentifierISBN13: "ISBN",
+	models.IdentifierASIN:   "ASIN",
+	models.IdentifierUUID:   "UUID",
+}
+
+// editionBook is the book as an edition presents it: with the edition's
+// identifiers in place of its own. Without an edition it is the book.
+func editionBook(book *models.Book, edition *models.Edition) *models.Book {
+	if edition == nil {
+		return book
+	}
+	out := *book
+	out.Identifiers = edition.Identifiers
+	return &out
+}
+
+// editionChapters applies an edition's content overrides to a book's
+// chapters in number order. Chapters an override adds have no ID, so
+// nothing uploaded for their number is shown with them.
+func editionChapters(chapters []models.Chapter, edition *models.Edition) []models.Chapter {
+	if edition == nil || len(edition.Overrides) == 0 {
+		return chapters
+	}
+	overrides := make(map[int]models.ContentOverride, len(edition.Overrides))
+	for _, o := range edition.Overrides {
+		overrides[o.ChapterNum] = o
+	}
+	out := make([]models.Chapter, 0, len(chapters)+len(overrides))
+	for _, chapter := range chapters {
+		o, ok := overrides[chapter.ChapterNum]
+		delete(overrides, chapter.ChapterNum)
+		switch {
+		case !ok:
+		case o.Omit:
+			continue
+		default:
+			if o.Title != "" {
+				chapter.Title = o.Title
+			}
+			if o.Text != "" {
+				chapter.Text = o.Text
+			}
+		}
+		out = append(out, chapter)
+	}
+	for _, o := range overrides {
+		if !o.Omit {
+			out = append(out, models.Chapter{ChapterNum: o.ChapterNum, Title: o.Title, Text: o.Text, BookID: edition.BookID})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ChapterNum < out[j].ChapterNum })
+	return out
+}
+
+// PrintLayout is the page geometry of an edition printed at a trim size:
+// the trim's usual margins unless the edition sets its own, and the
+// edition's text scale
+func PrintLayout(trim typeset.Trim, edition *models.Edition) typeset.Layout {
+	layout := typeset.DefaultLayout(trim)
+	if edition == nil {
+		return layout
+	}
+	if m := edition.Settings.Margins; m != nil {
+		layout.Top, layout.Bottom, layout.Inside, layout.Outside, layout.Gutter = m.Top, m.Bottom, m.Inside, m.Outside, m.Gutter
+	}
+	layout.Scale = edition.Settings.TextScale
+	return layout
+}
+
+// editionURN identifies an edition in exported metadata
+func editionURN(id primitive.ObjectID) string {
+	return "urn:onword:edition:" + id.Hex()
+}
@@ -0,0 +1,403 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"html"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/accessibility"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/docx"
+	"github.com/programmingbunny/epub-backend/epub"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/site"
+	"github.com/programmingbunny/epub-backend/storage"
+	"github.com/programmingbunny/epub-backend/typeset"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ManuscriptOptions pick the chapters of a manuscript and its look
+type ManuscriptOptions struct {
+	// From and To are the first and last chapter numbers, 0 for no limit
+	From, To int
+	Modern   bool
+	// Edition changes the chapters by its content overrides
+	Edition *models.Edition
+}
+
+// ExportOptions pick what an interior, cover or site is made of besides
+// the book's chapters
+type ExportOptions struct {
+	// Edition changes the chapters by its content overrides
+	Edition *models.Edition
+	// BackMatter adds the pages BackMatter writes after the last chapter
+	BackMatter bool
+}
+
+// Manuscript gathers a book's chapters for a Standard Manuscript Format
+// export. The title page lists the book owner's name and email. An
+// omnibus' parts follow its own chapters, each opening with its title,
+// and the range picks chapters in every part alike.
+func (s *Service) Manuscript(ctx context.Context, book *models.Book, opts ManuscriptOptions) (*docx.Manuscript, error) {
+	q := db.Query{Sort: db.ChapterSchema.DefaultSort}
+	if opts.From > 0 {
+		q = q.Where("chapterNum", db.OpGte, opts.From)
+	}
+	if opts.To > 0 {
+		q = q.Where("chapterNum", db.OpLte, opts.To)
+	}
+	chapters, err := s.Chapters(ctx, book.ID, q)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &docx.Manuscript{Title: book.Title, Author: book.Author, Modern: opts.Modern}
+	if names := strings.Fields(book.Author); len(names) > 0 {
+		m.Surname = names[len(names)-1]
+	}
+	if !book.OwnerID.IsZero() {
+		owner, err := s.store.Users.Get(ctx, book.OwnerID)
+		switch {
+		case err == nil:
+			m.Contact = []string{strings.TrimSpace(owner.FirstName + " " + owner.LastName), owner.Email}
+			if m.Surname == "" {
+				m.Surname = owner.LastName
+			}
+		case !errors.Is(err, db.ErrNotFound):
+			return nil, err
+		}
+	}
+	inRange := func(chapter models.Chapter) bool {
+		return (opts.From == 0 || chapter.ChapterNum >= opts.From) && (opts.To == 0 || chapter.ChapterNum <= opts.To)
+	}
+	for _, chapter := range editionChapters(chapters.Items, opts.Edition) {
+		// chapters an edition adds may fall outside the range
+		if inRange(chapter) {
+			m.Chapters = append(m.Chapters, docx.ManuscriptChapter{Title: chapter.Title, HTML: chapter.Text})
+		}
+	}
+	parts, err := s.Parts(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		m.Chapters = append(m.Chapters, docx.ManuscriptChapter{Title: part.Title, HTML: partTitle(part)})
+		for _, chapter := range part.Chapters {
+			if inRange(chapter) {
+				m.Chapters = append(m.Chapters, docx.ManuscriptChapter{Title: chapter.Title, HTML: chapter.Text})
+			}
+		}
+	}
+	return m, nil
+}
+
+// partTitle is the text under a part's title in a manuscript
+func partTitle(part Part) string {
+	text := "<p>by " + html.EscapeString(part.Author) + "</p>"
+	if part.Subtitle != "" {
+		text = "<p><em>" + html.EscapeString(part.Subtitle) + "</em></p>\n" + text
+	}
+	return text
+}
+
+// Interior gathers a book's chapters, as the edition has them if one is
+// given, for a printed interior, followed by the parts of an omnibus.
+// Each chapter is headed by the image uploaded for its number, and the
+// images in its text are read from the book's own images.
+func (s *Service) Interior(ctx context.Context, book *models.Book, opts ExportOptions) (*typeset.Book, error) {
+	chapters, err := s.Chapters(ctx, book.ID, db.Query{Sort: db.ChapterSchema.DefaultSort})
+	if err != nil {
+		return nil, err
+	}
+
+	interior := &typeset.Book{
+		Title:    book.Title,
+		Subtitle: book.Subtitle,
+		Author:   book.Author,
+		Image:    s.bookImage(ctx, book),
+	}
+	for _, chapter := range editionChapters(chapters.Items, opts.Edition) {
+		header, err := s.header(ctx, chapter)
+		if err != nil {
+			return nil, err
+		}
+		interior.Chapters = append(interior.Chapters, typeset.Chapter{
+			Number: chapter.ChapterNum,
+			Title:  chapter.Title,
+			HTML:   chapter.Text,
+			Header: header,
+		})
+	}
+	parts, err := s.Parts(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		printed := typeset.Part{Title: part.Title, Subtitle: part.Subtitle, Author: part.Author}
+		for _, chapter := range part.Chapters {
+			printed.Chapters = append(printed.Chapters, typeset.Chapter{
+				Number: chapter.ChapterNum,
+				Title:  chapter.Title,
+				HTML:   chapter.Text,
+				Header: readFile(chapter.ImageLocation),
+			})
+		}
+		interior.Parts = append(interior.Parts, printed)
+	}
+	if opts.BackMatter {
+		pages, err := s.BackMatter(ctx, book)
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			interior.BackMatter = append(interior.BackMatter, typeset.Chapter{Title: page.Title, HTML: page.Text})
+		}
+	}
+	return interior, nil
+}
+
+// Site gathers a book, as the edition has it if one is given, for a
+// static website published at baseURL, with its cover, chapter header
+// images, the images in its text and the parts of an omnibus
+func (s *Service) Site(ctx context.Context, book *models.Book, opts ExportOptions, baseURL string) (*site.Site, error) {
+	chapters, err := s.Chapters(ctx, book.ID, db.Query{Sort: db.ChapterSchema.DefaultSort})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &site.Site{
+		BaseURL:   baseURL,
+		Book:      *editionBook(book, opts.Edition),
+		Chapters:  editionChapters(chapters.Items, opts.Edition),
+		Cover:     readFile(book.BookCover),
+		Headers:   map[int][]byte{},
+		HeaderAlt: map[int]string{},
+		Image:     s.bookImage(ctx, book),
+	}
+	for _, chapter := range out.Chapters {
+		if out.Headers[chapter.ChapterNum], err = s.header(ctx, chapter); err != nil {
+			return nil, err
+		}
+		if image, err := s.ChapterImage(ctx, book.ID, chapter.ChapterNum); err == nil {
+			out.HeaderAlt[chapter.ChapterNum] = image.AltText
+		}
+	}
+	parts, err := s.Parts(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		published := site.Part{Title: part.Title, Subtitle: part.Subtitle, Author: part.Author, Chapters: part.Chapters}
+		for _, chapter := range part.Chapters {
+			published.Headers = append(published.Headers, readFile(chapter.ImageLocation))
+			alt := ""
+			if image, err := s.ChapterImage(ctx, part.BookID, chapter.ChapterNum); err == nil && image.ImageLocation == chapter.ImageLocation {
+				alt = image.AltText
+			}
+			published.HeaderAlt = append(published.HeaderAlt, alt)
+		}
+		out.Parts = append(out.Parts, published)
+	}
+	if opts.BackMatter {
+		if out.BackMatter, err = s.BackMatter(ctx, book); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// FixedLayout gathers a fixed-layout book's pages for an EPUB, described
+// by the book's accessibility check, placed in its series and identified
+// as the edition if one is given. Pages whose image is gone are left blank
+// behind their text.
+func (s *Service) FixedLayout(ctx context.Context, book *models.Book, edition *models.Edition) (*epub.FixedLayout, error) {
+	checked, err := s.accessibilityBook(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	placed, err := s.seriesBook(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	out := &epub.FixedLayout{
+		Identifier:    bookURN(book.ID),
+		Book:          *editionBook(placed, edition),
+		Modified:      time.Now(),
+		Accessibility: accessibility.Check(*checked).Metadata,
+	}
+	if edition != nil {
+		out.Identifier = editionURN(edition.ID)
+	}
+	if book.Viewport != nil {
+		out.Viewport = *book.Viewport
+	}
+	images := map[primitive.ObjectID]models.ChapterImages{}
+	for _, image := range checked.Images {
+		images[image.ID] = image
+	}
+	for _, page := range checked.Pages {
+		image, ok := images[page.ImageID]
+		var content []byte
+		if ok {
+			content = readFile(image.ImageLocation)
+		}
+		out.Pages = append(out.Pages, epub.Page{Image: content, Description: image.ImageDescription, Spread: page.Spread, Regions: page.Regions})
+	}
+	return out, nil
+}
+
+// Reflowable gathers a reflowable book for an EPUB, as the edition has it
+// if one is given, with its cover, chapter header images, the images in
+// its text and the parts of an omnibus. Like a fixed-layout book, it is
+// described by its accessibility check, placed in its series and
+// identified as the edition.
+func (s *Service) Reflowable(ctx context.Context, book *models.Book, opts ExportOptions) (*epub.Reflowable, error) {
+	checked, err := s.accessibilityBook(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	placed, err := s.seriesBook(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	out := &epub.Reflowable{
+		Identifier:    bookURN(book.ID),
+		Book:          *editionBook(placed, opts.Edition),
+		Modified:      time.Now(),
+		Cover:         readFile(book.BookCover),
+		Accessibility: accessibility.Check(*checked).Metadata,
+		Image:         s.bookImage(ctx, book),
+	}
+	if opts.Edition != nil {
+		out.Identifier = editionURN(opts.Edition.ID)
+	}
+	for _, chapter := range editionChapters(checked.Chapters, opts.Edition) {
+		header, err := s.header(ctx, chapter)
+		if err != nil {
+			return nil, err
+		}
+		alt := ""
+		if image, err := s.ChapterImage(ctx, book.ID, chapter.ChapterNum); err == nil && !chapter.ID.IsZero() {
+			alt = image.AltText
+		}
+		out.Chapters = append(out.Chapters, epub.Chapter{Title: chapter.Title, HTML: chapter.Text, Header: header, HeaderAlt: alt})
+	}
+	parts, err := s.Parts(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		published := epub.Part{Title: part.Title, Subtitle: part.Subtitle, Author: part.Author}
+		for _, chapter := range part.Chapters {
+			alt := ""
+			if image, err := s.ChapterImage(ctx, part.BookID, chapter.ChapterNum); err == nil && image.ImageLocation == chapter.ImageLocation {
+				alt = image.AltText
+			}
+			published.Chapters = append(published.Chapters, epub.Chapter{Title: chapter.Title, HTML: chapter.Text, Header: readFile(chapter.ImageLocation), HeaderAlt: alt})
+		}
+		out.Parts = append(out.Parts, published)
+	}
+	if opts.BackMatter {
+		pages, err := s.BackMatter(ctx, book)
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			out.BackMatter = append(out.BackMatter, epub.Chapter{Title: page.Title, HTML: page.Text})
+		}
+	}
+	return out, nil
+}
+
+// bookImage returns a function reading the images chapter text shows by
+// their src. Only the book's own images are read, and those of the books
+// an omnibus is made of.
+func (s *Service) bookImage(ctx context.Context, book *models.Book) func(src string) []byte {
+	books := map[primitive.ObjectID]bool{book.ID: true}
+	if book.Omnibus != nil {
+		for _, part := range book.Omnibus.Parts {
+			books[part.BookID] = true
+		}
+	}
+	return func(src string) []byte {
+		id, err := primitive.ObjectIDFromHex(path.Base(src))
+		if err != nil || !strings.HasPrefix(src, "/images/") {
+			return nil
+		}
+		image, err := s.store.Images.Get(ctx, id)
+		if err != nil || !books[image.BookID] {
+			return nil
+		}
+		return readFile(image.ImageLocation)
+	}
+}
+
+// header reads the image shown above a chapter
+func (s *Service) header(ctx context.Context, chapter models.Chapter) ([]byte, error) {
+	location, err := s.headerLocation(ctx, chapter)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(location), nil
+}
+
+// headerLocation is where the image shown above a chapter is stored: the
+// one set on it, or else the one uploaded for its number. Chapters an
+// edition adds have none.
+func (s *Service) headerLocation(ctx context.Context, chapter models.Chapter) (string, error) {
+	location := chapter.ImageLocation
+	if location == "" && !chapter.ID.IsZero() {
+		image, err := s.ChapterImage(ctx, chapter.BookID, chapter.ChapterNum)
+		switch {
+		case err == nil:
+			location = image.ImageLocation
+		case !errors.Is(err, db.ErrNotFound):
+			return "", err
+		}
+	}
+	return location, nil
+}
+
+// Cover fills in a printed cover from the book: its title and author,
+// its cover image for the front and, unless given, the page count of its
+// interior laid out for the cover's trim size, as the edition prints it
+// if one is given
+func (s *Service) Cover(ctx context.Context, book *models.Book, opts ExportOptions, cover typeset.Cover) (*typeset.Cover, error) {
+	cover.Title, cover.Subtitle, cover.Author = book.Title, book.Subtitle, book.Author
+	cover.Front = readFile(book.BookCover)
+	if cover.Pages == 0 {
+		interior, err := s.Interior(ctx, book, opts)
+		if err != nil {
+			return nil, err
+		}
+		if cover.Pages, err = typeset.Pages(interior, PrintLayout(cover.Trim, opts.Edition)); err != nil {
+			return nil, err
+		}
+	}
+	return &cover, nil
+}
+
+// readFile returns the content of an uploaded file, or nil when there is
+// none. Files that can't be read are logged and left out.
+func readFile(location string) []byte {
+	if location == "" {
+		return nil
+	}
+	file, _, err := storage.Open(location)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return content
+}
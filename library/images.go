@@ -0,0 +1,103 @@
+package library
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateImage stores a chapter header image and points the chapter with
+// the image's number at it. Page images are only stored.
+func (s *Service) CreateImage(ctx context.Context, image models.ChapterImages, file io.Reader, writer primitive.ObjectID) (*models.ChapterImages, error) {
+	if err := Validate.Struct(image.ImageDescription); err != nil {
+		return nil, err
+	}
+	if _, err := s.writableBook(ctx, image.BookID, writer); err != nil {
+		return nil, err
+	}
+	newImage, err := s.storeImage(ctx, image, file)
+	if err != nil {
+		return nil, err
+	}
+	if newImage.Type == models.ImageTypePage {
+		return newImage, nil
+	}
+	err = s.store.Chapters.SetHeaderImage(ctx, newImage.BookID, newImage.ChapterNum, newImage.ImageLocation)
+	if err != nil {
+		log.Println(err)
+	}
+	return newImage, nil
+}
+
+// storeImage saves the file and the image document that refers to it
+func (s *Service) storeImage(ctx context.Context, image models.ChapterImages, file io.Reader) (*models.ChapterImages, error) {
+	blob, err := s.blobs.Put(file)
+	if err != nil {
+		return nil, err
+	}
+	newImage := models.ChapterImages{
+		BookID:        image.BookID,
+		ChapterNum:    image.ChapterNum,
+		ImageLocation: blob.Location,
+		Type:          image.Type,
+
+		ImageDescription: image.ImageDescription,
+	}
+	if err := s.store.Blobs.Retain(ctx, blob); err != nil {
+		log.Println(err)
+	}
+
+	id, err := s.store.Images.Insert(ctx, newImage)
+	if err != nil {
+		s.release(ctx, newImage.ImageLocation)
+		return nil, err
+	}
+	newImage.ID = id
+	return &newImage, nil
+}
+
+func (s *Service) Image(ctx context.Context, id primitive.ObjectID) (*models.ChapterImages, error) {
+	return s.store.Images.Get(ctx, id)
+}
+
+// DescribeImage replaces an image's alt text, long description and
+// decorative flag
+func (s *Service) DescribeImage(ctx context.Context, image *models.ChapterImages, description models.ImageDescription, writer primitive.ObjectID) (*models.ChapterImages, error) {
+	if err := Validate.Struct(description); err != nil {
+		return nil, err
+	}
+	if _, err := s.writableBook(ctx, image.BookID, writer); err != nil {
+		return nil, err
+	}
+	if err := s.store.Images.Describe(ctx, image.ID, description); err != nil {
+		return nil, err
+	}
+	updated := *image
+	updated.ImageDescription = description
+	return &updated, nil
+}
+
+// ChapterImage returns the header image uploaded for a chapter number.
+// Images shown in the chapter text don't count.
+func (s *Service) ChapterImage(ctx context.Context, bookID primitive.ObjectID, chapterNum int) (*models.ChapterImages, error) {
+	return s.store.Images.GetForChapter(ctx, bookID, chapterNum)
+}
+
+func (s *Service) Images(ctx context.Context, bookID primitive.ObjectID, q db.Query) (*db.Page[models.ChapterImages], error) {
+	return s.store.Images.Find(ctx, q.Where("bookID", db.OpEq, bookID))
+}
+
+func (s *Service) TrashImage(ctx context.Context, id, actor primitive.ObjectID) error {
+	image, err := s.store.Images.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.writableBook(ctx, image.BookID, actor); err != nil {
+		return err
+	}
+	return s.trash.TrashImage(ctx, id, actor)
+}
@@ -0,0 +1,93 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/docx"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportChapters adds the chapters of a converted Word document to a book,
+// numbered after its last chapter. Each embedded image is stored once and
+// the chapter text links to it. Nothing is kept when any part fails.
+func (s *Service) ImportChapters(ctx context.Context, bookID primitive.ObjectID, doc *docx.Document, writer primitive.ObjectID) ([]models.Chapter, error) {
+	book, err := s.writableBook(ctx, bookID, writer)
+	if err != nil {
+		return nil, err
+	}
+	var created []models.Chapter
+	var images []models.ChapterImages
+	imported := func(ctx context.Context) error {
+		created, images = nil, nil
+		existing, err := s.store.Chapters.ListByBook(ctx, bookID)
+		if err != nil {
+			return err
+		}
+		next := 1
+		for _, chapter := range ownChapters(book, existing) {
+			if chapter.ChapterNum >= next {
+				next = chapter.ChapterNum + 1
+			}
+		}
+
+		sources := map[string]string{}
+		for i, chapter := range doc.Chapters {
+			newChapter := models.Chapter{Title: chapter.Title, ChapterNum: next + i, Text: chapter.HTML, BookID: bookID}
+			for _, name := range chapter.Images {
+				if _, ok := sources[name]; !ok {
+					image, err := s.storeImage(ctx, models.ChapterImages{BookID: bookID, ChapterNum: newChapter.ChapterNum, Type: models.ImageTypeInline}, bytes.NewReader(doc.Images[name]))
+					if err != nil {
+						return err
+					}
+					images = append(images, *image)
+					sources[name] = "/images/" + image.ID.Hex()
+				}
+				newChapter.Text = strings.ReplaceAll(newChapter.Text, `src="`+html.EscapeString(docx.ImageSource(name))+`"`, `src="`+sources[name]+`"`)
+			}
+			if err := Validate.Struct(&newChapter); err != nil {
+				return err
+			}
+			id, err := s.store.Chapters.Insert(ctx, newChapter)
+			if err != nil {
+				return err
+			}
+			newChapter.ID = id
+			created = append(created, newChapter)
+		}
+		return nil
+	}
+
+	err = s.store.Transactions.WithTransaction(ctx, imported)
+	if errors.Is(err, db.ErrTransactionsUnsupported) {
+		if err = imported(ctx); err != nil {
+			s.undoImport(ctx, created, images)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// undoImport removes what an import stored before it failed, when the
+// store couldn't roll it back
+func (s *Service) undoImport(ctx context.Context, chapters []models.Chapter, images []models.ChapterImages) {
+	for _, chapter := range chapters {
+		if err := s.store.Chapters.Delete(ctx, chapter.ID); err != nil {
+			log.Println(err)
+		}
+	}
+	for _, image := range images {
+		if err := s.store.Images.Delete(ctx, image.ID); err != nil {
+			log.Println(err)
+		}
+		s.release(ctx, image.ImageLocation)
+	}
+}
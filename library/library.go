@@ -0,0 +1,77 @@
+// Package library holds the operations behind the HTTP APIs, so the v1
+// routes and the v2 resources share one implementation and differ only
+// in how they read requests and shape responses.
+package library
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/isbn"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/storage"
+	"github.com/programmingbunny/epub-backend/trash"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email or a wrong password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrForbidden is returned for changes to a book someone may read but
+	// doesn't own
+	ErrForbidden = errors.New("only the book's owner may change it")
+)
+
+// Validate checks request models, naming fields as they appear in JSON
+var Validate = newValidator()
+
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	// isbn takes ISBN-10 and ISBN-13 with or without hyphens
+	validate.RegisterValidation("isbn", func(fl validator.FieldLevel) bool {
+		_, err := isbn.Parse(fl.Field().String())
+		return err == nil
+	})
+	validate.RegisterValidation("relator", func(fl validator.FieldLevel) bool {
+		_, ok := models.Relators[fl.Field().String()]
+		return ok
+	})
+	// identifiers and subject codes are checked by their type and scheme,
+	// the error naming those as the rule that failed
+	validate.RegisterStructValidation(func(sl validator.StructLevel) {
+		id := sl.Current().Interface().(models.Identifier)
+		if _, err := normalizeIdentifier(id); err != nil {
+			sl.ReportError(id.Value, "value", "Value", id.Type, "")
+		}
+	}, models.Identifier{})
+	validate.RegisterStructValidation(func(sl validator.StructLevel) {
+		subject := sl.Current().Interface().(models.Subject)
+		if pattern, ok := subjectCodes[subject.Scheme]; ok && !pattern.MatchString(subject.Code) {
+			sl.ReportError(subject.Code, "code", "Code", subject.Scheme, "")
+		}
+	}, models.Subject{})
+	return validate
+}
+
+// Service reads and writes books and everything that belongs to them
+type Service struct {
+	store *db.Store
+	blobs *storage.BlobStore
+	trash *trash.Service
+}
+
+func New(store *db.Store, blobs *storage.BlobStore, trash *trash.Service) *Service {
+	return &Service{store: store, blobs: blobs, trash: trash}
+}
@@ -0,0 +1,124 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/isbn"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/onix"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// onixSender names OnWord as the sender of ONIX messages
+const onixSender = "OnWord"
+
+var (
+	asinPattern = regexp.MustCompile(`^[A-Z0-9]{10}$`)
+	uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+	// subjectCodes are the shapes of codes in each subject scheme: BISAC
+	// codes are three letters and six digits, Thema codes a letter or
+	// digit followed by up to eight more, with qualifiers like 1KBB-US-NA
+	subjectCodes = map[string]*regexp.Regexp{
+		models.SubjectBISAC: regexp.MustCompile(`^[A-Z]{3}[0-9]{6}$`),
+		models.SubjectThema: regexp.MustCompile(`^[A-Z0-9][A-Z0-9]{0,8}(-[A-Z0-9]{2,3})*$`),
+	}
+)
+
+var errInvalidIdentifier = errors.New("invalid identifier")
+
+// normalizeIdentifier checks an identifier and writes it the one way it
+// is stored: ISBN-13s as 13 digits, ASINs in capitals and UUIDs in
+// lower case
+func normalizeIdentifier(id models.Identifier) (string, error) {
+	switch id.Type {
+	case models.IdentifierISBN13:
+		digits := strings.NewReplacer("-", "", " ", "").Replace(id.Value)
+		if len(digits) != 13 {
+			return "", isbn.ErrInvalid
+		}
+		return isbn.Parse(digits)
+	case models.IdentifierASIN:
+		if value := strings.ToUpper(id.Value); asinPattern.MatchString(value) {
+			return value, nil
+		}
+	case models.IdentifierUUID:
+		if value := strings.ToLower(strings.TrimPrefix(id.Value, "urn:uuid:")); uuidPattern.MatchString(value) {
+			return value, nil
+		}
+	}
+	return "", errInvalidIdentifier
+}
+
+// normalizeMetadata writes a validated book's identifiers the way they
+// are stored
+func normalizeMetadata(book *models.Book) {
+	for i, id := range book.Identifiers {
+		if value, err := normalizeIdentifier(id); err == nil {
+			book.Identifiers[i].Value = value
+		}
+	}
+}
+
+// Catalog lists every book the reader may see that matches the query,
+// fetching them a page at a time
+func (s *Service) Catalog(ctx context.Context, q db.Query, reader primitive.ObjectID) ([]models.Book, error) {
+	var books []models.Book
+	for {
+		page, err := s.Books(ctx, q, reader)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, page.Items...)
+		if page.NextCursor == "" {
+			return books, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// WriteONIX writes the books as an ONIX 3.0 message, with a product for
+// each edition of a book or for the book itself when it has none. Books in
+// a series are listed in its collection.
+func (s *Service) WriteONIX(ctx context.Context, w io.Writer, books []models.Book) error {
+	products := make([]onix.Product, 0, len(books))
+	for _, book := range books {
+		editions, err := s.store.Editions.ListByBook(ctx, book.ID)
+		if err != nil {
+			return err
+		}
+		placed, err := s.seriesBook(ctx, &book)
+		if err != nil {
+			return err
+		}
+		book := *placed
+		if len(editions) == 0 {
+			products = append(products, onix.Product{RecordReference: bookURN(book.ID), Book: book})
+		}
+		for i := range editions {
+			products = append(products, onix.Product{RecordReference: editionURN(editions[i].ID), Book: book, Edition: &editions[i]})
+		}
+	}
+	return onix.Write(w, onixSender, time.Now(), products)
+}
+
+// WriteEditionONIX writes one edition of a book as an ONIX 3.0 message
+func (s *Service) WriteEditionONIX(ctx context.Context, w io.Writer, book *models.Book, edition *models.Edition) error {
+	placed, err := s.seriesBook(ctx, book)
+	if err != nil {
+		return err
+	}
+	products := []onix.Product{{RecordReference: editionURN(edition.ID), Book: *placed, Edition: edition}}
+	return onix.Write(w, onixSender, time.Now(), products)
+}
+
+// bookURN identifies a book in exported metadata
+func bookURN(id primitive.ObjectID) string {
+	return "urn:onword:book:" + id.Hex()
+}
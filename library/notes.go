@@ -0,0 +1,82 @@
+package library
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Service) CreateNote(ctx context.Context, note models.Notes, writer primitive.ObjectID) (*models.Notes, error) {
+	newNote := models.Notes{
+		Title:     note.Title,
+		Text:      note.Text,
+		Type:      note.Type,
+		BookID:    note.BookID,
+		VersionID: note.VersionID,
+		SeriesID:  note.SeriesID,
+	}
+	if err := Validate.Struct(&newNote); err != nil {
+		return nil, err
+	}
+	if err := s.checkNoteWriter(ctx, newNote, writer); err != nil {
+		return nil, err
+	}
+	id, err := s.store.Notes.Insert(ctx, newNote)
+	if err != nil {
+		return nil, err
+	}
+	newNote.ID = id
+	return &newNote, nil
+}
+
+func (s *Service) Note(ctx context.Context, id primitive.ObjectID) (*models.Notes, error) {
+	return s.store.Notes.Get(ctx, id)
+}
+
+func (s *Service) Notes(ctx context.Context, q db.Query) (*db.Page[models.Notes], error) {
+	return s.store.Notes.Find(ctx, q)
+}
+
+// UpdateNote replaces a note's title and text
+func (s *Service) UpdateNote(ctx context.Context, id primitive.ObjectID, changes models.Notes, writer primitive.ObjectID) (*models.Notes, error) {
+	note, err := s.store.Notes.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNoteWriter(ctx, *note, writer); err != nil {
+		return nil, err
+	}
+	note.Title = changes.Title
+	note.Text = changes.Text
+	if err := Validate.Struct(note); err != nil {
+		return nil, err
+	}
+	if err := s.store.Notes.Update(ctx, id, *note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+func (s *Service) TrashNote(ctx context.Context, id, actor primitive.ObjectID) error {
+	note, err := s.store.Notes.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkNoteWriter(ctx, *note, actor); err != nil {
+		return err
+	}
+	return s.trash.TrashNote(ctx, id, actor)
+}
+
+// checkNoteWriter checks the writer may change the book or the series the
+// note belongs to. Series are only seen by their owners.
+func (s *Service) checkNoteWriter(ctx context.Context, note models.Notes, writer primitive.ObjectID) error {
+	if !note.SeriesID.IsZero() {
+		_, err := s.Series(ctx, note.SeriesID, writer)
+		return err
+	}
+	_, err := s.writableBook(ctx, note.BookID, writer)
+	return err
+}
@@ -0,0 +1,359 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrInvalidOmnibus is returned for omnibus parts that can't go into
+	// one: books the owner can't read, other omnibuses, fixed-layout books
+	// or versions of another book
+	ErrInvalidOmnibus = errors.New("invalid omnibus")
+	// ErrOmnibusFrozen is returned for changes to the parts of a frozen
+	// omnibus
+	ErrOmnibusFrozen = errors.New("omnibus is frozen")
+)
+
+// Part is a book of an omnibus as it is exported: the title page of its
+// book and its chapters in order, with their header image locations
+// filled in
+type Part struct {
+	BookID    primitive.ObjectID `json:"bookID"`
+	VersionID primitive.ObjectID `json:"versionID,omitempty"`
+	Title     string             `json:"title"`
+	Subtitle  string             `json:"subtitle,omitempty"`
+	Author    string             `json:"author"`
+	Frozen    bool               `json:"frozen"`
+	Chapters  []models.Chapter   `json:"chapters"`
+}
+
+// CreateOmnibus stores a new book made of the given parts, owned by owner,
+// which is zero for anonymous requests. Every part must be a book the
+// owner may read.
+func (s *Service) CreateOmnibus(ctx context.Context, book models.Book, parts []models.OmnibusPart, owner primitive.ObjectID) (*models.Book, error) {
+	omnibus := models.Omnibus{Parts: parts}
+	if err := s.checkOmnibus(ctx, &omnibus, owner); err != nil {
+		return nil, err
+	}
+	book.Omnibus = &omnibus
+	book.Layout, book.Viewport = "", nil
+	return s.createBook(ctx, book, owner, nil)
+}
+
+// UpdateOmnibus replaces an omnibus' parts. Frozen omnibuses keep theirs
+// until they are thawed.
+func (s *Service) UpdateOmnibus(ctx context.Context, book *models.Book, parts []models.OmnibusPart, writer primitive.ObjectID) (*models.Book, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	if book.Omnibus.Frozen() {
+		return nil, fmt.Errorf("%w: thaw it before changing its parts", ErrOmnibusFrozen)
+	}
+	omnibus := models.Omnibus{Parts: parts}
+	if err := s.checkOmnibus(ctx, &omnibus, book.OwnerID); err != nil {
+		return nil, err
+	}
+	if err := s.store.Books.SetOmnibus(ctx, book.ID, &omnibus); err != nil {
+		return nil, err
+	}
+	updated := *book
+	updated.Omnibus = &omnibus
+	return &updated, nil
+}
+
+// Parts gathers an omnibus' parts in reading order. Frozen parts are their
+// copies; the others are their books as they are now, leaving out books
+// that are gone, in the trash or no longer readable by the omnibus' owner.
+func (s *Service) Parts(ctx context.Context, book *models.Book) ([]Part, error) {
+	if book.Omnibus == nil {
+		return nil, nil
+	}
+	parts := make([]Part, 0, len(book.Omnibus.Parts))
+	for _, p := range book.Omnibus.Parts {
+		if p.Frozen == nil {
+			part, err := s.livePart(ctx, book, p)
+			if errors.Is(err, db.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, *part)
+			continue
+		}
+		chapters, err := s.store.Chapters.Find(ctx, db.Query{Sort: db.ChapterSchema.DefaultSort}.
+			Where("bookID", db.OpEq, book.ID).
+			Where("versionID", db.OpEq, p.Frozen.ChaptersID))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, Part{
+			BookID:    p.BookID,
+			VersionID: p.VersionID,
+			Title:     p.Frozen.Title,
+			Subtitle:  p.Frozen.Subtitle,
+			Author:    p.Frozen.Author,
+			Frozen:    true,
+			Chapters:  chapters.Items,
+		})
+	}
+	return parts, nil
+}
+
+// FreezeOmnibus copies the parts' chapters into the omnibus so later
+// changes to their books don't reach its exports. The copies aren't
+// chapters of the omnibus: they are only listed as its parts. Freezing a
+// frozen omnibus copies its parts afresh. Parts whose book is gone stay
+// as they are.
+func (s *Service) FreezeOmnibus(ctx context.Context, book *models.Book, writer primitive.ObjectID) (*models.Book, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	omnibus := models.Omnibus{Parts: make([]models.OmnibusPart, len(book.Omnibus.Parts)), FrozenAt: &now}
+	var copies []primitive.ObjectID
+	for i, p := range book.Omnibus.Parts {
+		frozen := models.OmnibusPart{BookID: p.BookID, VersionID: p.VersionID}
+		part, err := s.livePart(ctx, book, p)
+		if errors.Is(err, db.ErrNotFound) {
+			// the copies made last time are all that is left of it
+			omnibus.Parts[i] = p
+			continue
+		}
+		if err != nil {
+			s.deleteChapters(ctx, copies)
+			return nil, err
+		}
+		frozen.Frozen = &models.FrozenPart{Title: part.Title, Subtitle: part.Subtitle, Author: part.Author, ChaptersID: primitive.NewObjectID()}
+		numbers := copyNumbers(part.Chapters)
+		for j, chapter := range part.Chapters {
+			id, err := s.store.Chapters.Insert(ctx, models.Chapter{
+				ImageLocation: chapter.ImageLocation,
+				ChapterNum:    numbers[j],
+				Title:         chapter.Title,
+				Text:          chapter.Text,
+				BookID:        book.ID,
+				VersionID:     frozen.Frozen.ChaptersID,
+			})
+			if err != nil {
+				s.deleteChapters(ctx, copies)
+				return nil, err
+			}
+			copies = append(copies, id)
+		}
+		omnibus.Parts[i] = frozen
+	}
+	if err := s.store.Books.SetOmnibus(ctx, book.ID, &omnibus); err != nil {
+		s.deleteChapters(ctx, copies)
+		return nil, err
+	}
+	s.dropCopies(ctx, book, &omnibus)
+	updated := *book
+	updated.Omnibus = &omnibus
+	return &updated, nil
+}
+
+// copyNumbers are the numbers a part's chapters are copied with. A part
+// without a version holds the chapters of all its book's versions, whose
+// numbers repeat; as the copies share one version, they are numbered in
+// reading order instead. Unnumbered chapters stay unnumbered.
+func copyNumbers(chapters []models.Chapter) []int {
+	numbers := make([]int, len(chapters))
+	seen := map[int]bool{}
+	repeated := false
+	for i, chapter := range chapters {
+		numbers[i] = chapter.ChapterNum
+		repeated = repeated || chapter.ChapterNum != 0 && seen[chapter.ChapterNum]
+		seen[chapter.ChapterNum] = true
+	}
+	if !repeated {
+		return numbers
+	}
+	next := 1
+	for i := range numbers {
+		if numbers[i] != 0 {
+			numbers[i] = next
+			next++
+		}
+	}
+	return numbers
+}
+
+// ThawOmnibus drops the copies of a frozen omnibus' parts, which follow
+// their books again
+func (s *Service) ThawOmnibus(ctx context.Context, book *models.Book, writer primitive.ObjectID) (*models.Book, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	omnibus := models.Omnibus{Parts: make([]models.OmnibusPart, len(book.Omnibus.Parts))}
+	for i, p := range book.Omnibus.Parts {
+		omnibus.Parts[i] = models.OmnibusPart{BookID: p.BookID, VersionID: p.VersionID}
+	}
+	if err := s.store.Books.SetOmnibus(ctx, book.ID, &omnibus); err != nil {
+		return nil, err
+	}
+	s.dropCopies(ctx, book, &omnibus)
+	updated := *book
+	updated.Omnibus = &omnibus
+	return &updated, nil
+}
+
+// checkOmnibus validates an omnibus and checks its parts against what the
+// owner may read. Parts given by clients are never frozen.
+func (s *Service) checkOmnibus(ctx context.Context, omnibus *models.Omnibus, owner primitive.ObjectID) error {
+	if err := Validate.Struct(omnibus); err != nil {
+		return err
+	}
+	seen := make(map[models.OmnibusPart]bool, len(omnibus.Parts))
+	for i := range omnibus.Parts {
+		part := &omnibus.Parts[i]
+		part.Frozen = nil
+		if seen[*part] {
+			return fmt.Errorf("%w: book %s is listed twice", ErrInvalidOmnibus, part.BookID.Hex())
+		}
+		seen[*part] = true
+
+		book, err := s.Book(ctx, part.BookID, owner)
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return fmt.Errorf("%w: book %s is not one you can read", ErrInvalidOmnibus, part.BookID.Hex())
+		case err != nil:
+			return err
+		case book.Omnibus != nil:
+			return fmt.Errorf("%w: %q is an omnibus itself", ErrInvalidOmnibus, book.Title)
+		case book.Layout == models.LayoutFixed:
+			return fmt.Errorf("%w: %q is fixed-layout", ErrInvalidOmnibus, book.Title)
+		}
+		if part.VersionID.IsZero() {
+			continue
+		}
+		version, err := s.store.Versions.Get(ctx, part.VersionID)
+		if errors.Is(err, db.ErrNotFound) || err == nil && version.BookID != book.ID {
+			return fmt.Errorf("%w: version %s is not one of %q's", ErrInvalidOmnibus, part.VersionID.Hex(), book.Title)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// livePart is a part as its book is now: the chapters of its version, or
+// all of them when it has none. It returns db.ErrNotFound when the book is
+// gone, in the trash or no longer readable by the omnibus' owner.
+func (s *Service) livePart(ctx context.Context, omnibus *models.Book, p models.OmnibusPart) (*Part, error) {
+	book, err := s.Book(ctx, p.BookID, omnibus.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	q := db.Query{Sort: db.ChapterSchema.DefaultSort}
+	if !p.VersionID.IsZero() {
+		q = q.Where("versionID", db.OpEq, p.VersionID)
+	}
+	chapters, err := s.Chapters(ctx, book.ID, q)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chapters.Items {
+		if chapters.Items[i].ImageLocation, err = s.headerLocation(ctx, chapters.Items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &Part{
+		BookID:    p.BookID,
+		VersionID: p.VersionID,
+		Title:     book.Title,
+		Subtitle:  book.Subtitle,
+		Author:    book.Author,
+		Chapters:  chapters.Items,
+	}, nil
+}
+
+// ownChapters leaves out the copies of an omnibus' frozen parts, keeping
+// the chapters written for the book itself
+func ownChapters(book *models.Book, chapters []models.Chapter) []models.Chapter {
+	if book.Omnibus == nil {
+		return chapters
+	}
+	own := make([]models.Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		if !isCopy(book, chapter) {
+			own = append(own, chapter)
+		}
+	}
+	return own
+}
+
+// isCopy reports whether a chapter of the book is a copy of one of its
+// frozen parts. Copies are only changed by freezing and thawing.
+func isCopy(book *models.Book, chapter models.Chapter) bool {
+	if book.Omnibus == nil || chapter.BookID != book.ID {
+		return false
+	}
+	for _, p := range book.Omnibus.Parts {
+		if p.Frozen != nil && p.Frozen.ChaptersID == chapter.VersionID {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutCopies narrows a query for the book's chapters to the ones
+// ownChapters keeps
+func withoutCopies(book *models.Book, q db.Query) db.Query {
+	if book.Omnibus == nil {
+		return q
+	}
+	for _, p := range book.Omnibus.Parts {
+		if p.Frozen != nil {
+			q = q.Where("versionID", db.OpNe, p.Frozen.ChaptersID)
+		}
+	}
+	return q
+}
+
+// dropCopies deletes the chapters copied when the omnibus was last frozen,
+// but the ones its parts in kept still refer to. Failures are only logged,
+// as the parts no longer refer to the copies.
+func (s *Service) dropCopies(ctx context.Context, book *models.Book, kept *models.Omnibus) {
+	referred := map[primitive.ObjectID]bool{}
+	for _, p := range kept.Parts {
+		if p.Frozen != nil {
+			referred[p.Frozen.ChaptersID] = true
+		}
+	}
+	for _, p := range book.Omnibus.Parts {
+		if p.Frozen == nil || referred[p.Frozen.ChaptersID] {
+			continue
+		}
+		chapters, err := s.store.Chapters.Find(ctx, db.Query{}.
+			Where("bookID", db.OpEq, book.ID).
+			Where("versionID", db.OpEq, p.Frozen.ChaptersID))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		ids := make([]primitive.ObjectID, len(chapters.Items))
+		for i, chapter := range chapters.Items {
+			ids[i] = chapter.ID
+		}
+		s.deleteChapters(ctx, ids)
+	}
+}
+
+// deleteChapters removes chapters for good, logging the ones it can't
+func (s *Service) deleteChapters(ctx context.Context, ids []primitive.ObjectID) {
+	for _, id := range ids {
+		if err := s.store.Chapters.Delete(ctx, id); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidPages is returned for pages that don't fit their book: an
+// image from another book, text outside the viewport or an order that
+// doesn't list every page once
+var ErrInvalidPages = errors.New("invalid pages")
+
+// SetLayout makes a book reflowable or fixed-layout. Fixed-layout books
+// need the viewport their pages are drawn at; reflowable ones drop it.
+// Omnibuses stay reflowable.
+func (s *Service) SetLayout(ctx context.Context, book *models.Book, layout string, viewport *models.Viewport, writer primitive.ObjectID) (*models.Book, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	if book.Omnibus != nil && layout == models.LayoutFixed {
+		return nil, fmt.Errorf("%w: an omnibus can't be fixed-layout", ErrInvalidOmnibus)
+	}
+	updated := *book
+	updated.Layout = layout
+	updated.Viewport = viewport
+	if layout != models.LayoutFixed {
+		updated.Viewport = nil
+	}
+	if err := Validate.Struct(updated); err != nil {
+		return nil, err
+	}
+	if err := s.store.Books.SetLayout(ctx, book.ID, updated.Layout, updated.Viewport); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// CreatePage adds a page after the book's last one
+func (s *Service) CreatePage(ctx context.Context, book *models.Book, page models.Page, writer primitive.ObjectID) (*models.Page, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	newPage := models.Page{
+		BookID:  book.ID,
+		ImageID: page.ImageID,
+		Spread:  page.Spread,
+		Regions: page.Regions,
+	}
+	if err := s.checkPage(ctx, book, newPage); err != nil {
+		return nil, err
+	}
+	pages, err := s.store.Pages.ListByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	newPage.PageNum = 1
+	if len(pages) > 0 {
+		newPage.PageNum = pages[len(pages)-1].PageNum + 1
+	}
+
+	id, err := s.store.Pages.Insert(ctx, newPage)
+	if err != nil {
+		return nil, err
+	}
+	newPage.ID = id
+	return &newPage, nil
+}
+
+func (s *Service) Page(ctx context.Context, id primitive.ObjectID) (*models.Page, error) {
+	return s.store.Pages.Get(ctx, id)
+}
+
+// Pages lists a book's pages, in page order unless the query asks
+// otherwise
+func (s *Service) Pages(ctx context.Context, bookID primitive.ObjectID, q db.Query) (*db.Page[models.Page], error) {
+	return s.store.Pages.Find(ctx, q.Where("bookID", db.OpEq, bookID))
+}
+
+// UpdatePage replaces a page's image, spread and text. Its place in the
+// book doesn't change.
+func (s *Service) UpdatePage(ctx context.Context, book *models.Book, id primitive.ObjectID, changes models.Page, writer primitive.ObjectID) (*models.Page, error) {
+	if err := checkWriter(ctx, book, writer); err != nil {
+		return nil, err
+	}
+	page, err := s.store.Pages.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	page.ImageID = changes.ImageID
+	page.Spread = changes.Spread
+	page.Regions = changes.Regions
+	if err := s.checkPage(ctx, book, *page); err != nil {
+		return nil, err
+	}
+	if err := s.store.Pages.Update(ctx, id, *page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// DeletePage removes a page for good and closes the gap it leaves. The
+// image stays with the book.
+func (s *Service) DeletePage(ctx context.Context, page *models.Page, writer primitive.ObjectID) error {
+	if _, err := s.writableBook(ctx, page.BookID, writer); err != nil {
+		return err
+	}
+	if err := s.store.Pages.Delete(ctx, page.ID); err != nil {
+		return err
+	}
+	pages, err := s.store.Pages.ListByBook(ctx, page.BookID)
+	if err != nil {
+		return err
+	}
+	return s.store.Pages.Renumber(ctx, page.BookID, pageIDs(pages))
+}
+
+// ReorderPages puts a book's pages in the given order, which must list
+// each of them once, and returns them renumbered
+func (s *Service) ReorderPages(ctx context.Context, bookID primitive.ObjectID, order []primitive.ObjectID, writer primitive.ObjectID) ([]models.Page, error) {
+	if _, err := s.writableBook(ctx, bookID, writer); err != nil {
+		return nil, err
+	}
+	pages, err := s.store.Pages.ListByBook(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	listed := map[primitive.ObjectID]bool{}
+	for _, id := range order {
+		listed[id] = true
+	}
+	if len(listed) != len(order) || len(order) != len(pages) {
+		return nil, fmt.Errorf("%w: the order must list each of the book's %d pages once", ErrInvalidPages, len(pages))
+	}
+	for _, page := range pages {
+		if !listed[page.ID] {
+			return nil, fmt.Errorf("%w: the order leaves out page %s", ErrInvalidPages, page.ID.Hex())
+		}
+	}
+	if err := s.store.Pages.Renumber(ctx, bookID, order); err != nil {
+		return nil, err
+	}
+	return s.store.Pages.ListByBook(ctx, bookID)
+}
+
+// checkPage validates a page and checks that its image belongs to the book
+// and its text fits the book's viewport
+func (s *Service) checkPage(ctx context.Context, book *models.Book, page models.Page) error {
+	if err := Validate.Struct(page); err != nil {
+		return err
+	}
+	image, err := s.store.Images.Get(ctx, page.ImageID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+	if err != nil || image.BookID != book.ID {
+		return fmt.Errorf("%w: image %s is not one of the book's images", ErrInvalidPages, page.ImageID.Hex())
+	}
+	if book.Viewport == nil {
+		return nil
+	}
+	for i, region := range page.Regions {
+		if region.X+region.Width > book.Viewport.Width || region.Y+region.Height > book.Viewport.Height {
+			return fmt.Errorf("%w: text region %d runs outside the %dx%d viewport", ErrInvalidPages, i+1, book.Viewport.Width, book.Viewport.Height)
+		}
+	}
+	return nil
+}
+
+func pageIDs(pages []models.Page) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(pages))
+	for _, page := range pages {
+		ids = append(ids, page.ID)
+	}
+	return ids
+}
@@ -0,0 +1,272 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidSeries is returned for series listing books they can't hold
+var ErrInvalidSeries = errors.New("invalid series")
+
+// CreateSeries stores a new series owned by owner, which is zero for
+// anonymous requests. Its books must be the owner's and in no other series.
+func (s *Service) CreateSeries(ctx context.Context, series models.Series, owner primitive.ObjectID) (*models.Series, error) {
+	series.ID = primitive.NilObjectID
+	series.OwnerID = owner
+	if err := s.checkSeries(ctx, &series, nil); err != nil {
+		return nil, err
+	}
+	id, err := s.store.Series.Insert(ctx, series)
+	if err != nil {
+		return nil, err
+	}
+	series.ID = id
+	return &series, nil
+}
+
+// Series returns a series the reader may see. Other users' series are
+// reported as missing.
+func (s *Service) Series(ctx context.Context, id, reader primitive.ObjectID) (*models.Series, error) {
+	series, err := s.store.Series.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !series.ReadableBy(readerID(reader)) {
+		return nil, db.ErrNotFound
+	}
+	return series, nil
+}
+
+// ListSeries lists the reader's series
+func (s *Service) ListSeries(ctx context.Context, q db.Query, reader primitive.ObjectID) (*db.Page[models.Series], error) {
+	if reader.IsZero() {
+		return s.store.Series.Find(ctx, q.Where("ownerID", db.OpExists, false))
+	}
+	return s.store.Series.Find(ctx, q.Where("ownerID", db.OpEq, reader))
+}
+
+// BookSeries returns the series a book is in, or db.ErrNotFound
+func (s *Service) BookSeries(ctx context.Context, bookID primitive.ObjectID) (*models.Series, error) {
+	return s.store.Series.GetByBook(ctx, bookID)
+}
+
+// UpdateSeries replaces a series' name, description and books
+func (s *Service) UpdateSeries(ctx context.Context, series *models.Series, changes models.Series) (*models.Series, error) {
+	updated := changes
+	updated.ID, updated.OwnerID = series.ID, series.OwnerID
+	if err := s.checkSeries(ctx, &updated, series); err != nil {
+		return nil, err
+	}
+	if err := s.store.Series.Update(ctx, updated.ID, updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteSeries removes a series and its notes for good. Its books are
+// kept.
+func (s *Service) DeleteSeries(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := s.store.Notes.DeleteBySeries(ctx, id); err != nil {
+		return err
+	}
+	return s.store.Series.Delete(ctx, id)
+}
+
+// SeriesBooks returns the series' books in reading order, leaving out the
+// ones in the trash or gone and the ones the reader may not see
+func (s *Service) SeriesBooks(ctx context.Context, series *models.Series, reader primitive.ObjectID) ([]models.Book, error) {
+	books := make([]models.Book, 0, len(series.BookIDs))
+	for _, id := range series.BookIDs {
+		book, err := s.store.Books.Get(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !book.ReadableBy(readerID(reader)) {
+			continue
+		}
+		books = append(books, *book)
+	}
+	return books, nil
+}
+
+// BookNotes lists a book's notes together with the notes of its series
+// when the reader may see those
+func (s *Service) BookNotes(ctx context.Context, book *models.Book, q db.Query, reader primitive.ObjectID) (*db.Page[models.Notes], error) {
+	series, err := s.BookSeries(ctx, book.ID)
+	if errors.Is(err, db.ErrNotFound) || err == nil && !series.ReadableBy(readerID(reader)) {
+		return s.store.Notes.Find(ctx, q.Where("bookID", db.OpEq, book.ID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.Any = []db.Filter{
+		{Field: "bookID", Op: db.OpEq, Value: book.ID},
+		{Field: "seriesID", Op: db.OpEq, Value: series.ID},
+	}
+	return s.store.Notes.Find(ctx, q)
+}
+
+// checkSeries validates a series and checks its books. Books the series
+// held before, in previous, keep their place even when they have since
+// gone to the trash.
+func (s *Service) checkSeries(ctx context.Context, series *models.Series, previous *models.Series) error {
+	if err := Validate.Struct(series); err != nil {
+		return err
+	}
+	if series.BookIDs == nil {
+		series.BookIDs = []primitive.ObjectID{}
+	}
+	seen := make(map[primitive.ObjectID]bool, len(series.BookIDs))
+	for _, id := range series.BookIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: book %s is listed twice", ErrInvalidSeries, id.Hex())
+		}
+		seen[id] = true
+		if previous != nil && previous.Position(id) > 0 {
+			continue
+		}
+		book, err := s.store.Books.Get(ctx, id)
+		if errors.Is(err, db.ErrNotFound) || err == nil && book.OwnerID != series.OwnerID {
+			return fmt.Errorf("%w: book %s is not one of yours", ErrInvalidSeries, id.Hex())
+		}
+		if err != nil {
+			return err
+		}
+		other, err := s.store.Series.GetByBook(ctx, id)
+		switch {
+		case err == nil && other.ID != series.ID:
+			return fmt.Errorf("%w: %q is already in the series %q", db.ErrDuplicate, book.Title, other.Name)
+		case err != nil && !errors.Is(err, db.ErrNotFound):
+			return err
+		}
+	}
+	return nil
+}
+
+// seriesBook is the book with its place in its series, when it is in one,
+// for exported metadata. Without a series the book's own series metadata
+// is kept.
+func (s *Service) seriesBook(ctx context.Context, book *models.Book) (*models.Book, error) {
+	series, err := s.BookSeries(ctx, book.ID)
+	if errors.Is(err, db.ErrNotFound) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := *book
+	out.Series = &models.SeriesMembership{Name: series.Name, Position: series.Position(book.ID)}
+	return &out, nil
+}
+
+// BackMatter writes the pages that follow a book's last chapter: "Next in"
+// the series, about the book after it, and "Also by" the author, listing
+// the owner's other books by the same author grouped by series. Anyone may
+// read them in an export, so private books are left out, as are pages with
+// nothing to show. Back matter chapters are numbered 0.
+func (s *Service) BackMatter(ctx context.Context, book *models.Book) ([]models.Chapter, error) {
+	var out []models.Chapter
+	series, err := s.BookSeries(ctx, book.ID)
+	switch {
+	case err == nil:
+		books, err := s.SeriesBooks(ctx, series, primitive.NilObjectID)
+		if err != nil {
+			return nil, err
+		}
+		// the book itself may be private and so not among them
+		for i := range books {
+			if series.Position(books[i].ID) > series.Position(book.ID) {
+				out = append(out, nextInSeries(series, &books[i]))
+				break
+			}
+		}
+	case !errors.Is(err, db.ErrNotFound):
+		return nil, err
+	}
+
+	// books without an owner can't be told apart from anyone else's
+	if book.OwnerID.IsZero() {
+		return out, nil
+	}
+	others, err := s.store.Books.Find(ctx, db.Query{Sort: []db.SortField{{Field: "title"}}, Limit: db.MaxLimit}.
+		Where("ownerID", db.OpEq, book.OwnerID).
+		Where("author", db.OpEq, book.Author).
+		Where("_id", db.OpNe, book.ID).
+		Where("visibility", db.OpNe, models.VisibilityPrivate))
+	if err != nil {
+		return nil, err
+	}
+	if len(others.Items) == 0 {
+		return out, nil
+	}
+	owned, err := s.store.Series.Find(ctx, db.Query{}.Where("ownerID", db.OpEq, book.OwnerID))
+	if err != nil {
+		return nil, err
+	}
+	return append(out, alsoBy(book.Author, others.Items, owned.Items)), nil
+}
+
+// nextInSeries is the back matter page introducing the next book
+func nextInSeries(series *models.Series, next *models.Book) models.Chapter {
+	var text strings.Builder
+	fmt.Fprintf(&text, "<p>Book %d of %s</p>\n", series.Position(next.ID), html.EscapeString(series.Name))
+	fmt.Fprintf(&text, "<h2>%s</h2>\n", html.EscapeString(next.Title))
+	if next.Subtitle != "" {
+		fmt.Fprintf(&text, "<p><em>%s</em></p>\n", html.EscapeString(next.Subtitle))
+	}
+	for _, paragraph := range strings.Split(next.Description, "\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(&text, "<p>%s</p>\n", html.EscapeString(paragraph))
+		}
+	}
+	return models.Chapter{Title: "Next in " + series.Name, Text: text.String()}
+}
+
+// alsoBy is the back matter page listing an author's other books: each of
+// their series in reading order, then the books in no series by title
+func alsoBy(author string, books []models.Book, series []models.Series) models.Chapter {
+	written := make(map[primitive.ObjectID]models.Book, len(books))
+	for _, book := range books {
+		written[book.ID] = book
+	}
+	sort.SliceStable(series, func(i, j int) bool { return series[i].Name < series[j].Name })
+
+	var text strings.Builder
+	listed := map[primitive.ObjectID]bool{}
+	for _, s := range series {
+		var items []string
+		for _, id := range s.BookIDs {
+			if book, ok := written[id]; ok {
+				items = append(items, "<li><cite>"+html.EscapeString(book.Title)+"</cite></li>\n")
+				listed[id] = true
+			}
+		}
+		if len(items) > 0 {
+			fmt.Fprintf(&text, "<h2>%s</h2>\n<ol>\n%s</ol>\n", html.EscapeString(s.Name), strings.Join(items, ""))
+		}
+	}
+	var rest []string
+	for _, book := range books {
+		if !listed[book.ID] {
+			rest = append(rest, "<li><cite>"+html.EscapeString(book.Title)+"</cite></li>\n")
+		}
+	}
+	if len(rest) > 0 {
+		if len(listed) > 0 {
+			text.WriteString("<h2>Other books</h2>\n")
+		}
+		fmt.Fprintf(&text, "<ul>\n%s</ul>\n", strings.Join(rest, ""))
+	}
+	return models.Chapter{Title: "Also by " + author, Text: text.String()}
+}
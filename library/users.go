@@ -0,0 +1,67 @@
+package library
+
+import (
+	"context"
+	"errors"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CreateUser registers a user, storing a hash of the password. It returns
+// db.ErrDuplicate when the email is already registered.
+func (s *Service) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	user.ID = primitive.NilObjectID
+	if err := Validate.Struct(user); err != nil {
+		return nil, err
+	}
+	hash, err := db.HashPassword(user.Password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hash
+
+	if user.ID, err = s.store.Users.Insert(ctx, user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Service) User(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.store.Users.Get(ctx, id)
+}
+
+// UpdateUser replaces a user's details, hashing the new password
+func (s *Service) UpdateUser(ctx context.Context, id primitive.ObjectID, user models.User) error {
+	if err := Validate.Struct(user); err != nil {
+		return err
+	}
+	hash, err := db.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return s.store.Users.Update(ctx, id, user)
+}
+
+func (s *Service) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	return s.store.Users.Delete(ctx, id)
+}
+
+// Authenticate returns the user with the given email and password, or
+// ErrInvalidCredentials without saying which of the two was wrong
+func (s *Service) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	user, err := s.store.Users.GetByEmail(ctx, email)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
@@ -0,0 +1,36 @@
+package library
+
+import (
+	"context"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *Service) CreateVersion(ctx context.Context, version models.Version, writer primitive.ObjectID) (*models.Version, error) {
+	newVersion := models.Version{
+		Type:   version.Type,
+		BookID: version.BookID,
+	}
+	if err := Validate.Struct(&newVersion); err != nil {
+		return nil, err
+	}
+	if _, err := s.writableBook(ctx, newVersion.BookID, writer); err != nil {
+		return nil, err
+	}
+	id, err := s.store.Versions.Insert(ctx, newVersion)
+	if err != nil {
+		return nil, err
+	}
+	newVersion.ID = id
+	return &newVersion, nil
+}
+
+func (s *Service) Version(ctx context.Context, id primitive.ObjectID) (*models.Version, error) {
+	return s.store.Versions.Get(ctx, id)
+}
+
+func (s *Service) Versions(ctx context.Context, bookID primitive.ObjectID, q db.Query) (*db.Page[models.Version], error) {
+	return s.store.Versions.Find(ctx, q.Where("bookID", db.OpEq, bookID))
+}
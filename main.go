@@ -1,24 +1,146 @@
 package main
 
 import (
-	
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/controllers/health"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/deletion"
+	"github.com/programmingbunny/epub-backend/middleware"
 	routes "github.com/programmingbunny/epub-backend/service"
+	"github.com/programmingbunny/epub-backend/storage"
+	"github.com/programmingbunny/epub-backend/trash"
 
 	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("ONWORD_CONFIG"), "path to a YAML configuration file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	cfg, err := configs.Read(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// printed before validation, to see what is wrong with it
+	if *printConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(out))
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// SIGINT and SIGTERM cancel ctx, which aborts startup retries or
+	// begins a graceful shutdown once serving
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := configs.ConnectDB(ctx, cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer disconnect(client)
+	store := db.NewMongoStore(client.Database(cfg.Database.Name), db.Collections(cfg.Database.Collections))
+
+	runner := migrationRunner(cfg, client)
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, runner, flag.Args()[1:]); err != nil {
+			log.Println(err)
+			disconnect(client)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := checkMigrations(ctx, cfg, runner); err != nil {
+		log.Println(err)
+		disconnect(client)
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "gc" {
+		if err := runGC(ctx, cfg, store, flag.Args()[1:]); err != nil {
+			log.Println(err)
+			disconnect(client)
+			os.Exit(1)
+		}
+		return
+	}
+
 	router := mux.NewRouter()
 
-	client := configs.DB
+	blobs := storage.NewBlobStore(cfg.Storage.UploadDir)
+
+	secret := cfg.Auth.JWTSecret
+	if secret == "" {
+		// tokens won't survive a restart, but the server stays usable in development
+		log.Println("auth.jwtSecret is not set, using a random secret")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal(err)
+		}
+		secret = hex.EncodeToString(key)
+	}
+	auth, err := middleware.NewAuth(secret, cfg.Auth.TokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deleter := deletion.New(store, blobs, cfg.Storage.GCGracePeriod)
+	if restored, err := deleter.Recover(ctx); err != nil {
+		log.Println("recovering interrupted book deletions:", err)
+	} else if restored > 0 {
+		log.Printf("rolled back %d interrupted book deletions", restored)
+	}
+
+	probes := health.New(store)
 
 	//routes
-	routes.Routes(router, client) 
+	routes.Health(router, probes)
+	// the probes stay outside authentication, so a stale token can't fail them
+	api := router.PathPrefix("/").Subrouter()
+	bin := trash.New(store, deleter, cfg.Trash.Retention)
+	routes.Routes(api, store, blobs, bin, auth, cfg.Limits)
+	if err := routes.Docs(router, cfg.Limits); err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.Storage.GCInterval > 0 {
+		scheduleGC(ctx, cfg, store, blobs)
+	}
+	if cfg.Trash.PurgeInterval > 0 {
+		schedulePurge(ctx, cfg, bin)
+	}
 
 	log.Println("Hello, This is OnWord!")
-	log.Fatal(http.ListenAndServe(":3000", router))
+	if err := serve(ctx, cfg.Server, middleware.Recover(router), probes); err != nil {
+		log.Println(err)
+	}
+}
+
+func disconnect(client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Disconnect(ctx); err != nil {
+		log.Println("disconnecting from MongoDB:", err)
+		return
+	}
+	log.Println("Disconnected from MongoDB")
 }
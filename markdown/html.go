@@ -0,0 +1,511 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Option adjusts FromHTML
+type Option func(*writer)
+
+// ShiftHeadings moves headings n levels down, so chapter headings can sit
+// under a chapter title when chapters are joined into a book
+func ShiftHeadings(n int) Option {
+	return func(w *writer) { w.shift = n }
+}
+
+// PrefixFootnotes starts footnote labels with prefix, which keeps them
+// apart when several chapters are joined into one document
+func PrefixFootnotes(prefix string) Option {
+	return func(w *writer) { w.footnotePrefix = prefix }
+}
+
+// FromHTML converts stored chapter HTML to Markdown. Elements Markdown has
+// no syntax for keep their text. Older chapters hold plain text, whose
+// blank lines are kept as paragraph breaks.
+func FromHTML(src string, opts ...Option) string {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(src), body)
+	if err != nil {
+		// the parser only fails on reader errors, which a string can't have
+		return src
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+
+	w := &writer{}
+	for _, opt := range opts {
+		opt(w)
+	}
+	blocks := w.blocks(body, true)
+	blocks = append(blocks, w.footnotes...)
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+type writer struct {
+	shift          int
+	footnotePrefix string
+	// footnotes are the definitions found, written at the end
+	footnotes []string
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Body: true,
+	atom.Dd: true, atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Table: true,
+	atom.Ul: true,
+}
+
+// blocks renders the children of n as Markdown blocks. Runs of inline
+// content between blocks become paragraphs; in loose runs, such as the
+// plain text of older chapters, blank lines also separate paragraphs.
+func (w *writer) blocks(n *html.Node, loose bool) []string {
+	var out []string
+	var run strings.Builder
+	flush := func() {
+		text := run.String()
+		run.Reset()
+		paragraphs := []string{text}
+		if loose {
+			paragraphs = strings.Split(text, paragraphBreak)
+		}
+		for _, p := range paragraphs {
+			if p = trimLines(p); p != "" {
+				out = append(out, escapeLineStart(p))
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockElements[c.DataAtom] {
+			flush()
+			if block := w.block(c); block != "" {
+				out = append(out, block)
+			}
+			continue
+		}
+		run.WriteString(w.inline(c, loose))
+	}
+	flush()
+	return out
+}
+
+func (w *writer) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.P:
+		return escapeLineStart(trimLines(w.inlineChildren(n)))
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.Join(strings.Fields(w.inlineChildren(n)), " ")
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1]-'0') + w.shift
+		if level > 6 {
+			level = 6
+		}
+		return strings.Repeat("#", level) + " " + text
+	case atom.Hr:
+		return "***"
+	case atom.Blockquote:
+		return prefixLines(strings.Join(w.blocks(n, false), "\n\n"), "> ", ">")
+	case atom.Pre:
+		return w.codeBlock(n)
+	case atom.Ul, atom.Ol:
+		return w.list(n)
+	case atom.Table:
+		return w.table(n)
+	case atom.Div, atom.Section:
+		if hasClass(n, "footnotes") || attr(n, "role") == "doc-endnotes" {
+			w.collectFootnotes(n)
+			return ""
+		}
+	}
+	return strings.Join(w.blocks(n, false), "\n\n")
+}
+
+// paragraphBreak marks blank lines in loose text until runs are split
+const paragraphBreak = "\x00"
+
+var (
+	space      = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines = regexp.MustCompile(`[ \t\r\f]*\n[ \t\r\f]*\n[ \t\r\n\f]*`)
+)
+
+func (w *writer) inline(n *html.Node, loose bool) string {
+	switch n.Type {
+	case html.TextNode:
+		text := n.Data
+		if loose {
+			text = blankLines.ReplaceAllString(text, paragraphBreak)
+		}
+		return escape(space.ReplaceAllString(text, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Template:
+		return ""
+	case atom.Br:
+		return "\\\n"
+	case atom.Em, atom.I, atom.Cite:
+		return wrap(w.inlineChildren(n), "*")
+	case atom.Strong, atom.B:
+		return wrap(w.inlineChildren(n), "**")
+	case atom.Code, atom.Kbd, atom.Samp:
+		return codeSpan(textOf(n))
+	case atom.Img:
+		return "![" + escape(attr(n, "alt")) + "](" + destination(attr(n, "src")) + title(n) + ")"
+	case atom.A:
+		if attr(n, "role") == "doc-backlink" || hasClass(n, "footnote-backref") {
+			return ""
+		}
+		if label, ok := footnoteRef(n); ok {
+			return "[^" + w.footnotePrefix + label + "]"
+		}
+		text := w.inlineChildren(n)
+		href := attr(n, "href")
+		if href == "" {
+			return text
+		}
+		return "[" + text + "](" + destination(href) + title(n) + ")"
+	case atom.Sup:
+		if a := onlyChild(n); a != nil && a.DataAtom == atom.A {
+			if label, ok := footnoteRef(a); ok {
+				return "[^" + w.footnotePrefix + label + "]"
+			}
+		}
+	}
+	return w.inlineChildren(n)
+}
+
+func (w *writer) inlineChildren(n *html.Node) string {
+	var out strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockElements[c.DataAtom] {
+			// a block inside inline content, which Markdown can't nest
+			out.WriteString(" " + strings.Join(w.blocks(c, false), " ") + " ")
+			continue
+		}
+		out.WriteString(w.inline(c, false))
+	}
+	return out.String()
+}
+
+func (w *writer) codeBlock(n *html.Node) string {
+	code := textOf(n)
+	language := ""
+	if c := onlyChild(n); c != nil && c.DataAtom == atom.Code {
+		for _, class := range strings.Fields(attr(c, "class")) {
+			if strings.HasPrefix(class, "language-") {
+				language = strings.TrimPrefix(class, "language-")
+			}
+		}
+	}
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+	return fence + language + "\n" + strings.TrimSuffix(code, "\n") + "\n" + fence
+}
+
+func (w *writer) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		number = start
+	}
+
+	// a list is loose when its items hold paragraphs
+	loose := false
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.P {
+				loose = true
+			}
+		}
+	}
+
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		separator := "\n"
+		if loose {
+			separator = "\n\n"
+		}
+		content := strings.Join(w.blocks(li, false), separator)
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.TrimPrefix(prefixLines(content, indent, ""), indent))
+	}
+	if loose {
+		return strings.Join(items, "\n\n")
+	}
+	return strings.Join(items, "\n")
+}
+
+func (w *writer) table(n *html.Node) string {
+	var rows [][]string
+	var aligns []string
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				visit(c)
+			case atom.Tr:
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom != atom.Th && cell.DataAtom != atom.Td {
+						continue
+					}
+					if len(rows) == 0 {
+						aligns = append(aligns, alignment(cell))
+					}
+					text := strings.Join(strings.Fields(w.inlineChildren(cell)), " ")
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	visit(n)
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return ""
+	}
+
+	columns := len(rows[0])
+	line := func(cells []string) string {
+		for len(cells) < columns {
+			cells = append(cells, "")
+		}
+		return "| " + strings.Join(cells[:columns], " | ") + " |"
+	}
+	var out []string
+	out = append(out, line(rows[0]))
+	var rule []string
+	for _, align := range aligns {
+		switch align {
+		case "left":
+			rule = append(rule, ":---")
+		case "center":
+			rule = append(rule, ":---:")
+		case "right":
+			rule = append(rule, "---:")
+		default:
+			rule = append(rule, "---")
+		}
+	}
+	out = append(out, line(rule))
+	for _, row := range rows[1:] {
+		out = append(out, line(row))
+	}
+	return strings.Join(out, "\n")
+}
+
+// collectFootnotes turns the endnotes list goldmark renders back into
+// [^label]: definitions
+func (w *writer) collectFootnotes(n *html.Node) {
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Li {
+				visit(c)
+				continue
+			}
+			label := strings.TrimPrefix(attr(c, "id"), "fn:")
+			if label == "" {
+				continue
+			}
+			content := strings.Join(w.blocks(c, false), "\n\n")
+			w.footnotes = append(w.footnotes, "[^"+w.footnotePrefix+label+"]: "+strings.TrimPrefix(prefixLines(content, "    ", ""), "    "))
+		}
+	}
+	visit(n)
+}
+
+func footnoteRef(a *html.Node) (string, bool) {
+	if attr(a, "role") != "doc-noteref" && !hasClass(a, "footnote-ref") {
+		return "", false
+	}
+	label := strings.TrimPrefix(attr(a, "href"), "#fn:")
+	return label, label != ""
+}
+
+func alignment(cell *html.Node) string {
+	if align := attr(cell, "align"); align != "" {
+		return align
+	}
+	for _, decl := range strings.Split(attr(cell, "style"), ";") {
+		if name, value, ok := strings.Cut(decl, ":"); ok && strings.TrimSpace(name) == "text-align" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// wrap puts emphasis markers around text, outside its edge spaces, which
+// CommonMark doesn't allow inside them
+func wrap(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	start := text[:strings.Index(text, trimmed)]
+	end := text[len(start)+len(trimmed):]
+	return start + marker + trimmed + marker + end
+}
+
+func codeSpan(code string) string {
+	code = space.ReplaceAllString(code, " ")
+	fence := strings.Repeat("`", longestRun(code, '`')+1)
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+	return fence + code + fence
+}
+
+func destination(url string) string {
+	if url == "" || strings.ContainsAny(url, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(url) + ">"
+	}
+	return url
+}
+
+func title(n *html.Node) string {
+	if t := attr(n, "title"); t != "" {
+		return ` "` + strings.ReplaceAll(t, `"`, `\"`) + `"`
+	}
+	return ""
+}
+
+var (
+	markdownSpecial = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`)
+	entity          = regexp.MustCompile(`&(#?[0-9A-Za-z]+;)`)
+)
+
+// escape keeps text from reading as Markdown syntax or an entity
+func escape(text string) string {
+	return entity.ReplaceAllString(markdownSpecial.Replace(text), `\&$1`)
+}
+
+var (
+	blockStart   = regexp.MustCompile(`^(#{1,6}(\s|$)|[-+=](\s|$)|>|(-\s*){3,}$|~~~|\|)`)
+	orderedStart = regexp.MustCompile(`^(\d{1,9})([.)])(\s|$)`)
+)
+
+// escapeLineStart keeps each line of a paragraph from reading as the start
+// of a heading, list, rule or table
+func escapeLineStart(p string) string {
+	lines := strings.Split(p, "\n")
+	for i, line := range lines {
+		switch {
+		case blockStart.MatchString(line):
+			lines[i] = `\` + line
+		case orderedStart.MatchString(line):
+			lines[i] = orderedStart.ReplaceAllString(line, `$1\$2$3`)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimLines trims a paragraph and the spaces around its hard breaks
+func trimLines(p string) string {
+	lines := strings.Split(strings.TrimSpace(p), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func prefixLines(text, prefix, blankPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var out strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		out.WriteString(textOf(c))
+	}
+	return out.String()
+}
+
+// onlyChild is the single element child of n, ignoring whitespace
+func onlyChild(n *html.Node) *html.Node {
+	var only *html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode && strings.TrimSpace(c.Data) == "":
+		case c.Type == html.ElementNode && only == nil:
+			only = c
+		default:
+			return nil
+		}
+	}
+	return only
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func longestRun(s string, r byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == r {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,117 @@
+// Package markdown converts between Markdown and the HTML chapters are
+// stored as. Markdown means CommonMark with GitHub tables and footnotes.
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+// ContentType is the media type of Markdown bodies
+const ContentType = "text/markdown"
+
+// ErrRawHTML is returned for Markdown holding HTML tags, which would let
+// it smuggle scripts into stored chapters. Text meant as a literal < is
+// written \<.
+var ErrRawHTML = errors.New("raw HTML is not allowed in Markdown")
+
+var converter = goldmark.New(goldmark.WithExtensions(extension.Table, extension.Footnote))
+
+// ToHTML converts Markdown to the stored HTML form. It returns ErrRawHTML
+// rather than dropping HTML tags from the text.
+func ToHTML(src []byte) (string, error) {
+	doc := converter.Parser().Parse(text.NewReader(src))
+	if raw := rawHTML(doc, src); raw != "" {
+		return "", fmt.Errorf("%w: %q", ErrRawHTML, raw)
+	}
+	var out bytes.Buffer
+	if err := converter.Renderer().Render(&out, src, doc); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// rawHTML returns the first HTML in a parsed document, or "" when there
+// is none
+func rawHTML(doc ast.Node, src []byte) string {
+	var raw string
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.RawHTML:
+			raw = segments(n.Segments, src)
+		case *ast.HTMLBlock:
+			raw = segments(n.Lines(), src)
+		default:
+			return ast.WalkContinue, nil
+		}
+		return ast.WalkStop, nil
+	})
+	return strings.TrimSpace(raw)
+}
+
+func segments(segs *text.Segments, src []byte) string {
+	var out strings.Builder
+	for i := 0; i < segs.Len(); i++ {
+		segment := segs.At(i)
+		out.Write(segment.Value(src))
+	}
+	return out.String()
+}
+
+// Split separates a document's YAML front matter, decoding it into header,
+// from the Markdown body. A document that doesn't start with a --- line
+// is all body.
+func Split(src []byte, header interface{}) ([]byte, error) {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return []byte(text), nil
+	}
+	rest := text[len("---\n"):]
+	end := strings.Index(rest, "\n---\n")
+	switch {
+	case strings.HasPrefix(rest, "---\n"):
+		return []byte(rest[len("---\n"):]), nil
+	case end < 0 && strings.HasSuffix(rest, "\n---"):
+		end = len(rest) - len("\n---")
+	case end < 0:
+		return nil, errors.New("front matter is not closed by a --- line")
+	}
+	if err := yaml.Unmarshal([]byte(rest[:end]), header); err != nil {
+		return nil, err
+	}
+	if end+len("\n---\n") > len(rest) {
+		return nil, nil
+	}
+	return []byte(rest[end+len("\n---\n"):]), nil
+}
+
+// Join renders header as YAML front matter followed by the Markdown body
+func Join(header interface{}, body string) ([]byte, error) {
+	yml, err := yaml.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteString("---\n")
+	out.Write(yml)
+	out.WriteString("---\n")
+	if body != "" {
+		out.WriteString("\n")
+		out.WriteString(body)
+		if !strings.HasSuffix(body, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package markdown
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// stable converts Markdown to HTML, back to Markdown and to HTML again,
+// expecting the same HTML both times
+func stable(t *testing.T, src string) string {
+	t.Helper()
+	first, err := ToHTML([]byte(src))
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	back := FromHTML(first)
+	second, err := ToHTML([]byte(back))
+	if err != nil {
+		t.Fatalf("%q came back as %q: %v", src, back, err)
+	}
+	if first != second {
+		t.Errorf("%q is not stable:\nfirst  %q\nback   %q\nsecond %q", src, first, back, second)
+	}
+	return first
+}
+
+func TestCommonMarkRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"Plain *emphasis*, **strong** and `code`.\n",
+		"# Title\n\n## Section\n\nText under it.\n",
+		"A line  \nbroken in two.\n",
+		"> A quote\n>\n> with two paragraphs\n",
+		"- one\n- two\n  - nested\n- three\n",
+		"3. three\n4. four\n",
+		"- loose\n\n- list\n",
+		"```go\nfunc main() {}\n```\n",
+		"Some ``code with ` inside``.\n",
+		"[a link](https://example.com \"Example\") and ![alt](/images/1)\n",
+		"Text that reads as syntax: 1\\. not a list, \\# not a heading, a \\< b & c\n",
+		"Entities stay text: \\&amp; and 5 \\* 3\n",
+		"***\n\nAfter a rule.\n",
+		"Autolinks like <https://example.com> are links.\n",
+	} {
+		stable(t, src)
+	}
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	html := stable(t, "| Name | Age |\n| :--- | ---: |\n| Ann | 31 |\n| Bob \\| Jr | 4 |\n")
+	for _, want := range []string{"<table>", `<th style="text-align:left">Name</th>`, `<td style="text-align:right">31</td>`, "Bob | Jr"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("%q does not contain %q", html, want)
+		}
+	}
+}
+
+func TestFootnoteRoundTrip(t *testing.T) {
+	html := stable(t, "A claim[^1] and another[^note].\n\n[^1]: The source.\n\n[^note]: A longer note.\n")
+	if !strings.Contains(html, "The source.") || !strings.Contains(html, "A longer note.") {
+		t.Errorf("the footnotes are missing from %q", html)
+	}
+}
+
+func TestRawHTMLIsRejected(t *testing.T) {
+	for _, src := range []string{
+		"<script>alert(1)</script>\n",
+		"<div>\nA block\n</div>\n",
+		"Inline <span onclick=\"x()\">HTML</span> too.\n",
+		"A comment <!-- hidden -->\n",
+	} {
+		if _, err := ToHTML([]byte(src)); !errors.Is(err, ErrRawHTML) {
+			t.Errorf("%q: got %v, want ErrRawHTML", src, err)
+		}
+	}
+}
+
+func TestStoredHTMLComesBackAsText(t *testing.T) {
+	// chapters written in the editor may hold tags Markdown has no syntax
+	// for; their text survives and nothing reads as raw HTML
+	back := FromHTML(`<p>Keep <span class="x">this</span> and &lt;tags&gt; as text</p>`)
+	html, err := ToHTML([]byte(back))
+	if err != nil {
+		t.Fatalf("%q: %v", back, err)
+	}
+	if want := "<p>Keep this and &lt;tags&gt; as text</p>\n"; html != want {
+		t.Errorf("got %q, want %q", html, want)
+	}
+}
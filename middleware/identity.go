@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/responses"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type contextKey string
+
+const userIDKey contextKey = "userID"
+
+// Authenticate attaches the user ID of a valid bearer token to the request context.
+// Requests without a token continue anonymously, requests with a bad token are rejected.
+func (a *Auth) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		userID, err := a.VerifyToken(tokenString)
+		if err != nil {
+			if responses.WantsProblem(r) {
+				responses.WriteProblem(w, r, responses.NewProblem(http.StatusUnauthorized, responses.CodeUnauthorized, "invalid or expired token"))
+				return
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
+
+// WithUserID returns a copy of ctx carrying the authenticated user ID
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext returns the authenticated user ID, if any
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey).(string)
+	return userID, ok && userID != ""
+}
+
+// ActorFromContext returns the authenticated user ID as an ObjectID, or the
+// zero ID for anonymous requests
+func ActorFromContext(ctx context.Context) primitive.ObjectID {
+	userID, _ := UserIDFromContext(ctx)
+	actor, _ := primitive.ObjectIDFromHex(userID)
+	return actor
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// Recover turns a panicking handler into a 500 response instead of letting
+// it take down the process
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// let net/http abort the connection as it was asked to
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			if tracked.wroteHeader {
+				return
+			}
+			if responses.WantsProblem(r) {
+				responses.WriteProblem(w, r, responses.NewProblem(http.StatusInternalServerError, responses.CodeInternal, "internal server error"))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			response := responses.Response{Status: http.StatusInternalServerError, Message: "error", Data: map[string]interface{}{"data": "internal server error"}}
+			json.NewEncoder(w).Encode(response)
+		}()
+
+		next.ServeHTTP(tracked, r)
+	})
+}
+
+// statusRecorder remembers whether a response has been started
+type statusRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.wroteHeader = true
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
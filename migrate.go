@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/db/migrations"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func migrationRunner(cfg *configs.Config, client *mongo.Client) *migrations.Runner {
+	return migrations.NewRunner(client.Database(cfg.Database.Name), db.Collections(cfg.Database.Collections), cfg.Database.MigrationsCollection)
+}
+
+// runMigrate implements "migrate up [-to version]", "migrate down [steps]"
+// and "migrate status"
+func runMigrate(ctx context.Context, runner *migrations.Runner, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "up":
+		flags := flag.NewFlagSet("migrate up", flag.ExitOnError)
+		to := flags.Int("to", 0, "apply migrations up to this version, default all")
+		flags.Parse(args[1:])
+
+		applied, err := runner.Up(ctx, *to)
+		for _, m := range applied {
+			fmt.Printf("applied %04d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := runner.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("nothing to revert")
+		}
+		return err
+
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// checkMigrations applies pending migrations when database.autoMigrate is
+// set and otherwise warns about them
+func checkMigrations(ctx context.Context, cfg *configs.Config, runner *migrations.Runner) error {
+	if cfg.Database.AutoMigrate {
+		applied, err := runner.Up(ctx, 0)
+		for _, m := range applied {
+			log.Printf("applied migration %04d %s\n", m.Version, m.Name)
+		}
+		return err
+	}
+
+	pending, err := runner.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		log.Printf("migration %04d %s is pending, run \"migrate up\"\n", m.Version, m.Name)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import "strings"
+
+// ImageDescription is what assistive technology reads in place of an
+// image. Decorative images carry no meaning and are skipped, so they
+// take no alt text.
+type ImageDescription struct {
+	AltText string `json:"altText,omitempty" bson:"altText,omitempty" validate:"max=1000,excluded_if=Decorative true"`
+	// LongDescription explains images alt text can't, such as charts and
+	// maps
+	LongDescription string `json:"longDescription,omitempty" bson:"longDescription,omitempty" validate:"max=20000"`
+	Decorative      bool   `json:"decorative,omitempty" bson:"decorative,omitempty"`
+}
+
+// Described reports whether the image has alt text or is marked decorative
+func (d ImageDescription) Described() bool {
+	return d.Decorative || strings.TrimSpace(d.AltText) != ""
+}
+
+// Theme is the colors a book is displayed in, as hex colors
+type Theme struct {
+	Text       string `json:"text" bson:"text" validate:"required,hexcolor"`
+	Background string `json:"background" bson:"background" validate:"required,hexcolor"`
+	Link       string `json:"link,omitempty" bson:"link,omitempty" validate:"omitempty,hexcolor"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// BlobRef counts the documents that point at a content-addressed upload
+type BlobRef struct {
+	Hash        string    `json:"hash" bson:"_id"`
+	Refs        int       `json:"refs" bson:"refs"`
+	Size        int64     `json:"size" bson:"size"`
+	ContentType string    `json:"contentType,omitempty" bson:"contentType,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+}
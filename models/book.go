@@ -1,8 +1,56 @@
 package models
 
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
 type Book struct {
-	Title     string `json:"title,omitempty" validate:"required"`
-	Subtitle  string `json:"subtitle,omitempty" validate:"required"`
-	Author    string `json:"author,omitempty" validate:"required"`
-	BookCover string `json:"bookCover,omitempty"`
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Title      string             `json:"title,omitempty" validate:"required"`
+	Subtitle   string             `json:"subtitle,omitempty" validate:"required"`
+	Author     string             `json:"author,omitempty" validate:"required"`
+	BookCover  string             `json:"bookCover,omitempty"`
+	OwnerID    primitive.ObjectID `json:"ownerID,omitempty" bson:"ownerID,omitempty"`
+	Visibility string             `json:"visibility,omitempty" bson:"visibility,omitempty" validate:"omitempty,oneof=public private"`
+	// Language is a BCP 47 tag such as en or pt-BR
+	Language string `json:"language,omitempty" bson:"language,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Theme    *Theme `json:"theme,omitempty" bson:"theme,omitempty"`
+
+	Description string    `json:"description,omitempty" bson:"description,omitempty" validate:"max=20000"`
+	Keywords    []string  `json:"keywords,omitempty" bson:"keywords,omitempty" validate:"max=50,dive,required,max=100"`
+	Subjects    []Subject `json:"subjects,omitempty" bson:"subjects,omitempty" validate:"max=20,dive"`
+	Publisher   string    `json:"publisher,omitempty" bson:"publisher,omitempty" validate:"max=200"`
+	Imprint     string    `json:"imprint,omitempty" bson:"imprint,omitempty" validate:"max=200"`
+	// PublicationDate is written 2006-01-02
+	PublicationDate string            `json:"publicationDate,omitempty" bson:"publicationDate,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Series          *SeriesMembership `json:"series,omitempty" bson:"series,omitempty"`
+	Contributors    []Contributor     `json:"contributors,omitempty" bson:"contributors,omitempty" validate:"max=50,dive"`
+	Identifiers     []Identifier      `json:"identifiers,omitempty" bson:"identifiers,omitempty" validate:"max=20,dive"`
+
+	// Layout is reflowable unless set to fixed, when the book is made of
+	// pages drawn at the size of the viewport
+	Layout   string    `json:"layout,omitempty" bson:"layout,omitempty" validate:"omitempty,oneof=reflowable fixed"`
+	Viewport *Viewport `json:"viewport,omitempty" bson:"viewport,omitempty" validate:"required_if=Layout fixed,omitempty"`
+	// Omnibus is set on books made of other books
+	Omnibus *Omnibus `json:"omnibus,omitempty" bson:"omnibus,omitempty"`
+	Trash   `bson:",inline"`
+}
+
+// ReadableBy reports whether the given user may read the book and its files.
+// Books without an owner predate access control and stay public.
+func (b Book) ReadableBy(userID string) bool {
+	if b.OwnerID.IsZero() || b.Visibility != VisibilityPrivate {
+		return true
+	}
+	return b.OwnerID.Hex() == userID
+}
+
+// WritableBy reports whether the given user may change the book and
+// everything belonging to it, which only its owner may. Books without an
+// owner predate access control and are left to the v1 routes.
+func (b Book) WritableBy(userID string) bool {
+	return !b.OwnerID.IsZero() && b.OwnerID.Hex() == userID
 }
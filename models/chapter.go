@@ -10,6 +10,10 @@ type Chapter struct {
     Text          string             `json:"text,omitempty"`
     BookID        primitive.ObjectID `json:"bookID,omitempty" bson:"bookID,omitempty"`
     VersionID     primitive.ObjectID `json:"versionID,omitempty" bson:"versionID,omitempty"`
+    // DeletedChapterNum keeps the number of a trashed chapter, which gives
+    // up its slot until it is restored
+    DeletedChapterNum int `json:"deletedChapterNum,omitempty" bson:"deletedChapterNum,omitempty"`
+    Trash             `bson:",inline"`
 }
 
 type Chapters struct {
@@ -17,9 +21,16 @@ type Chapters struct {
 	Chapters []Chapter          `json:"chapters" bson:"chapters"`
 }
 
+// ImageTypeInline marks images shown in a chapter's text rather than
+// above it, such as the ones imported with a Word document
+const ImageTypeInline = "inline"
+
 type ChapterImages struct {
+	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	BookID        primitive.ObjectID `json:"bookID,omitempty" bson:"bookID,omitempty"`
 	ChapterNum    int                `json:"chapterNum,omitempty" bson:"chapterNum,omitempty"`
 	ImageLocation string             `json:"imageLocation,omitempty" bson:"imageLocation,omitempty"`
 	Type          string             `json:"type,omitempty" bson:"type,omitempty"`
+	ImageDescription `bson:",inline"`
+	Trash         `bson:",inline"`
 }
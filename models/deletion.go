@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DeletionPending    = "pending"
+	DeletionDone       = "done"
+	DeletionRolledBack = "rolled-back"
+)
+
+// DeletionLog snapshots everything a book deletion is about to remove, so
+// a deletion that fails half-way can be put back
+type DeletionLog struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	BookID    primitive.ObjectID `json:"bookID" bson:"bookID"`
+	State     string             `json:"state" bson:"state"`
+	StartedAt time.Time          `json:"startedAt" bson:"startedAt"`
+	Book      Book               `json:"book" bson:"book"`
+	Chapters  []Chapter          `json:"chapters" bson:"chapters"`
+	Images    []ChapterImages    `json:"images" bson:"images"`
+	Versions  []Version          `json:"versions" bson:"versions"`
+	Notes     []Notes            `json:"notes" bson:"notes"`
+	Pages     []Page             `json:"pages" bson:"pages"`
+	Editions  []Edition          `json:"editions" bson:"editions"`
+
+	// LeaseUntil is when the server running the deletion is presumed gone;
+	// until then other servers leave the log alone
+	LeaseUntil time.Time `json:"leaseUntil" bson:"leaseUntil"`
+}
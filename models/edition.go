@@ -0,0 +1,76 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// The formats an edition is published in
+const (
+	FormatEbook      = "ebook"
+	FormatPaperback  = "paperback"
+	FormatHardcover  = "hardcover"
+	FormatLargePrint = "large-print"
+)
+
+// Edition is a book as published in one format. Editions share the book's
+// chapters, changed by their content overrides, and carry their own
+// identifiers, prices and export settings.
+type Edition struct {
+	ID     primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	BookID primitive.ObjectID `json:"bookID,omitempty" bson:"bookID,omitempty"`
+	Name   string             `json:"name" bson:"name" validate:"required,max=200"`
+	Format string             `json:"format" bson:"format" validate:"required,oneof=ebook paperback hardcover large-print"`
+	// Identifiers take the place of the book's in the edition's exports
+	Identifiers []Identifier      `json:"identifiers,omitempty" bson:"identifiers,omitempty" validate:"max=20,dive"`
+	Prices      []Price           `json:"prices,omitempty" bson:"prices,omitempty" validate:"max=20,unique=Currency,dive"`
+	Settings    ExportSettings    `json:"settings" bson:"settings"`
+	Overrides   []ContentOverride `json:"overrides,omitempty" bson:"overrides,omitempty" validate:"max=100,unique=ChapterNum,dive"`
+}
+
+// Price is what an edition sells for in one currency
+type Price struct {
+	Amount   float64 `json:"amount" bson:"amount" validate:"min=0,max=100000"`
+	Currency string  `json:"currency" bson:"currency" validate:"required,iso4217"`
+}
+
+// ExportSettings are what an edition's files are made with. Settings left
+// out keep each export's defaults, and query parameters given with an
+// export still win.
+type ExportSettings struct {
+	Trim  string `json:"trim,omitempty" bson:"trim,omitempty" validate:"omitempty,oneof=5x8 5.5x8.5 6x9"`
+	Paper string `json:"paper,omitempty" bson:"paper,omitempty" validate:"omitempty,oneof=white cream color"`
+	// Margins replace the trim size's usual margins
+	Margins *Margins `json:"margins,omitempty" bson:"margins,omitempty"`
+	// TextScale enlarges printed text; large-print editions default to 1.3
+	TextScale float64 `json:"textScale,omitempty" bson:"textScale,omitempty" validate:"omitempty,min=1,max=2"`
+	// ManuscriptStyle is the look of DOCX manuscripts
+	ManuscriptStyle string `json:"manuscriptStyle,omitempty" bson:"manuscriptStyle,omitempty" validate:"omitempty,oneof=classic modern"`
+}
+
+// Margins of a printed page in inches
+type Margins struct {
+	Top     float64 `json:"top" bson:"top" validate:"min=0"`
+	Bottom  float64 `json:"bottom" bson:"bottom" validate:"min=0"`
+	Inside  float64 `json:"inside" bson:"inside" validate:"min=0"`
+	Outside float64 `json:"outside" bson:"outside" validate:"min=0"`
+	Gutter  float64 `json:"gutter" bson:"gutter" validate:"min=0"`
+}
+
+// ContentOverride changes one chapter in an edition. It replaces the
+// title or text of the chapter with its number, or adds a chapter where
+// the book has none, such as a copyright page numbered 0 to come before
+// chapter 1. Omit leaves the chapter out of the edition.
+type ContentOverride struct {
+	ChapterNum int    `json:"chapterNum" bson:"chapterNum" validate:"min=0"`
+	Title      string `json:"title,omitempty" bson:"title,omitempty" validate:"excluded_if=Omit true,max=500"`
+	Text       string `json:"text,omitempty" bson:"text,omitempty" validate:"excluded_if=Omit true"`
+	Omit       bool   `json:"omit,omitempty" bson:"omit,omitempty"`
+}
+
+// Print reports whether the edition is printed rather than read on screen
+func (e Edition) Print() bool {
+	return e.Format != FormatEbook
+}
+
+// Identifier returns the edition's first identifier of a kind, or ""
+func (e Edition) Identifier(kind string) string {
+	return Book{Identifiers: e.Identifiers}.Identifier(kind)
+}
@@ -0,0 +1,69 @@
+package models
+
+// The kinds of identifier a book may carry
+const (
+	IdentifierISBN13 = "isbn13"
+	IdentifierASIN   = "asin"
+	IdentifierUUID   = "uuid"
+)
+
+// The subject schemes books are classified in
+const (
+	SubjectBISAC = "bisac"
+	SubjectThema = "thema"
+)
+
+// Relators are the MARC relator codes contributors may have, with their
+// ONIX contributor role codes
+var Relators = map[string]string{
+	"aut": "A01", // author
+	"ill": "A12", // illustrator
+	"pht": "A13", // photographer
+	"aft": "A19", // author of afterword
+	"aui": "A23", // author of foreword
+	"win": "A24", // writer of introduction
+	"cov": "A36", // cover designer
+	"edt": "B01", // editor
+	"trl": "B06", // translator
+	"nrt": "E07", // narrator
+	"ctb": "Z99", // contributor
+}
+
+// Identifier is a number a book is known by outside OnWord. ISBN-13s are
+// stored as their 13 digits.
+type Identifier struct {
+	Type  string `json:"type" bson:"type" validate:"required,oneof=isbn13 asin uuid"`
+	Value string `json:"value" bson:"value" validate:"required"`
+}
+
+// Subject is a BISAC or Thema subject code
+type Subject struct {
+	Scheme string `json:"scheme" bson:"scheme" validate:"required,oneof=bisac thema"`
+	Code   string `json:"code" bson:"code" validate:"required"`
+}
+
+// Contributor is someone other than the author who worked on a book, or a
+// co-author
+type Contributor struct {
+	Name string `json:"name" bson:"name" validate:"required,max=200"`
+	// Role is a MARC relator code, one of Relators
+	Role string `json:"role" bson:"role" validate:"required,relator"`
+	// FileAs is the name as sorted, such as "Austen, Jane"
+	FileAs string `json:"fileAs,omitempty" bson:"fileAs,omitempty" validate:"max=200"`
+}
+
+// SeriesMembership places a book in a series
+type SeriesMembership struct {
+	Name     string `json:"name" bson:"name" validate:"required,max=200"`
+	Position int    `json:"position,omitempty" bson:"position,omitempty" validate:"min=0"`
+}
+
+// Identifier returns the book's first identifier of a kind, or ""
+func (b Book) Identifier(kind string) string {
+	for _, id := range b.Identifiers {
+		if id.Type == kind {
+			return id.Value
+		}
+	}
+	return ""
+}
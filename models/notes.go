@@ -2,6 +2,7 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+// Notes belong to a book, or to a series when SeriesID is set instead
 type Notes struct {
 	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	Title     string             `json:"title,omitempty" bson:"title,omitempty"`
@@ -9,4 +10,6 @@ type Notes struct {
 	Type      string             `json:"type,omitempty" bson:"type,omitempty"`
 	BookID    primitive.ObjectID `json:"bookID,omitempty" bson:"bookID,omitempty"`
 	VersionID primitive.ObjectID `json:"versionID,omitempty" bson:"versionID,omitempty"`
+	SeriesID  primitive.ObjectID `json:"seriesID,omitempty" bson:"seriesID,omitempty"`
+	Trash     `bson:",inline"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Omnibus makes a book out of other books, its parts, in reading order.
+// Parts follow their books as they change until the omnibus is frozen,
+// when their chapters are copied into the omnibus.
+type Omnibus struct {
+	Parts []OmnibusPart `json:"parts" bson:"parts" validate:"min=1,max=20,dive"`
+	// FrozenAt is when the parts were copied, nil while they follow their
+	// books
+	FrozenAt *time.Time `json:"frozenAt,omitempty" bson:"frozenAt,omitempty"`
+}
+
+// OmnibusPart is a book of an omnibus: all of its chapters, or those of
+// one of its versions
+type OmnibusPart struct {
+	BookID    primitive.ObjectID `json:"bookID" bson:"bookID"`
+	VersionID primitive.ObjectID `json:"versionID,omitempty" bson:"versionID,omitempty"`
+	// Frozen is the copy of the part made when the omnibus was frozen
+	Frozen *FrozenPart `json:"frozen,omitempty" bson:"frozen,omitempty"`
+}
+
+// FrozenPart is the title page of a part's book as it was copied. The
+// copied chapters belong to the omnibus and are filed under ChaptersID as
+// their version.
+type FrozenPart struct {
+	Title      string             `json:"title" bson:"title"`
+	Subtitle   string             `json:"subtitle,omitempty" bson:"subtitle,omitempty"`
+	Author     string             `json:"author" bson:"author"`
+	ChaptersID primitive.ObjectID `json:"chaptersID" bson:"chaptersID"`
+}
+
+// Frozen reports whether the omnibus' parts are copies rather than their
+// books as they are now
+func (o Omnibus) Frozen() bool {
+	return o.FrozenAt != nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// How a book's content is laid out. Reflowable books are chapters of text
+// that adapt to the screen; fixed-layout books are pages drawn at one size,
+// as picture books are.
+const (
+	LayoutReflowable = "reflowable"
+	LayoutFixed      = "fixed"
+)
+
+// Where a page sits in a two-page spread
+const (
+	SpreadLeft   = "left"
+	SpreadRight  = "right"
+	SpreadCenter = "center"
+)
+
+// ImageTypePage marks images used as the pages of a fixed-layout book,
+// which are never chapter headers
+const ImageTypePage = "page"
+
+// Viewport is the size in CSS pixels every page of a fixed-layout book is
+// drawn at
+type Viewport struct {
+	Width  int `json:"width" bson:"width" validate:"min=1,max=10000"`
+	Height int `json:"height" bson:"height" validate:"min=1,max=10000"`
+}
+
+// Page is a page of a fixed-layout book: one of the book's images with
+// text placed over it
+type Page struct {
+	ID      primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	BookID  primitive.ObjectID `json:"bookID,omitempty" bson:"bookID,omitempty"`
+	PageNum int                `json:"pageNum" bson:"pageNum"`
+	ImageID primitive.ObjectID `json:"imageID" bson:"imageID" validate:"required"`
+	// Spread places the page on the left or right of a spread, or centers
+	// it across both; empty leaves it to the reading system
+	Spread  string       `json:"spread,omitempty" bson:"spread,omitempty" validate:"omitempty,oneof=left right center"`
+	Regions []TextRegion `json:"regions,omitempty" bson:"regions,omitempty" validate:"max=50,dive"`
+}
+
+// TextRegion is text placed on a page, positioned in viewport pixels from
+// the top left corner
+type TextRegion struct {
+	X      int    `json:"x" bson:"x" validate:"min=0"`
+	Y      int    `json:"y" bson:"y" validate:"min=0"`
+	Width  int    `json:"width" bson:"width" validate:"min=1"`
+	Height int    `json:"height" bson:"height" validate:"min=1"`
+	Text   string `json:"text" bson:"text" validate:"required,max=5000"`
+	// FontSize is in pixels, 0 for the reading system's default
+	FontSize int    `json:"fontSize,omitempty" bson:"fontSize,omitempty" validate:"min=0,max=500"`
+	Align    string `json:"align,omitempty" bson:"align,omitempty" validate:"omitempty,oneof=left center right"`
+	Color    string `json:"color,omitempty" bson:"color,omitempty" validate:"omitempty,hexcolor"`
+}
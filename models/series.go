@@ -0,0 +1,32 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Series is a run of books read in order. Its notes are the story bible
+// shared by all of its books.
+type Series struct {
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name" validate:"required,max=200"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty" validate:"max=5000"`
+	// BookIDs are the series' books in reading order. A book belongs to
+	// one series at most.
+	BookIDs []primitive.ObjectID `json:"bookIDs" bson:"bookIDs" validate:"max=100"`
+	OwnerID primitive.ObjectID   `json:"ownerID,omitempty" bson:"ownerID,omitempty"`
+}
+
+// Position is the book's place in the series counted from 1, or 0 when
+// the book isn't in it
+func (s Series) Position(bookID primitive.ObjectID) int {
+	for i, id := range s.BookIDs {
+		if id == bookID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ReadableBy reports whether the given user may see the series and its
+// notes. Series made without signing in are open to everyone, like books.
+func (s Series) ReadableBy(userID string) bool {
+	return s.OwnerID.IsZero() || s.OwnerID.Hex() == userID
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trash marks a soft-deleted document. Trashed documents are hidden from
+// reads until they are restored or purged.
+type Trash struct {
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	// DeletedBy is nil for anonymous deletes
+	DeletedBy *primitive.ObjectID `json:"deletedBy,omitempty" bson:"deletedBy,omitempty"`
+	// WithBook is set on documents trashed because their book was
+	WithBook bool `json:"withBook,omitempty" bson:"withBook,omitempty"`
+}
+
+// Trashed reports whether the document is in the trash
+func (t Trash) Trashed() bool {
+	return t.DeletedAt != nil
+}
+
+// DeletedByUser reports whether the given user trashed the document
+func (t Trash) DeletedByUser(userID primitive.ObjectID) bool {
+	return t.DeletedBy != nil && *t.DeletedBy == userID
+}
@@ -0,0 +1,133 @@
+package onix
+
+import "encoding/xml"
+
+// The elements of a message, in the order the ONIX schema requires
+
+type message struct {
+	XMLName  xml.Name        `xml:"ONIXMessage"`
+	Release  string          `xml:"release,attr"`
+	XMLNS    string          `xml:"xmlns,attr"`
+	Header   header          `xml:"Header"`
+	Products []productRecord `xml:"Product"`
+}
+
+type header struct {
+	Sender       string `xml:"Sender>SenderName"`
+	SentDateTime string `xml:"SentDateTime"`
+}
+
+type productRecord struct {
+	RecordReference  string              `xml:"RecordReference"`
+	NotificationType string              `xml:"NotificationType"`
+	Identifiers      []productIdentifier `xml:"ProductIdentifier"`
+	Descriptive      descriptiveDetail   `xml:"DescriptiveDetail"`
+	Collateral       *collateral         `xml:"CollateralDetail"`
+	Publishing       publishingDetail    `xml:"PublishingDetail"`
+	Supply           *productSupply      `xml:"ProductSupply"`
+}
+
+type productIdentifier struct {
+	Type     string `xml:"ProductIDType"`
+	TypeName string `xml:"IDTypeName,omitempty"`
+	Value    string `xml:"IDValue"`
+}
+
+type descriptiveDetail struct {
+	Composition  string          `xml:"ProductComposition"`
+	Form         string          `xml:"ProductForm"`
+	FormDetail   string          `xml:"ProductFormDetail,omitempty"`
+	Collection   *collection     `xml:"Collection"`
+	Title        titleDetail     `xml:"TitleDetail"`
+	Contributors []contributor   `xml:"Contributor"`
+	EditionType  string          `xml:"EditionType,omitempty"`
+	Language     *languageDetail `xml:"Language"`
+	Subjects     []subject       `xml:"Subject"`
+}
+
+type collection struct {
+	Type  string      `xml:"CollectionType"`
+	Title titleDetail `xml:"TitleDetail"`
+}
+
+type titleDetail struct {
+	Type    string       `xml:"TitleType"`
+	Element titleElement `xml:"TitleElement"`
+}
+
+type titleElement struct {
+	Level      string `xml:"TitleElementLevel"`
+	PartNumber string `xml:"PartNumber,omitempty"`
+	Text       string `xml:"TitleText"`
+	Subtitle   string `xml:"Subtitle,omitempty"`
+}
+
+type contributor struct {
+	Sequence int    `xml:"SequenceNumber"`
+	Role     string `xml:"ContributorRole"`
+	Name     string `xml:"PersonName"`
+	Inverted string `xml:"PersonNameInverted,omitempty"`
+}
+
+type languageDetail struct {
+	Role string `xml:"LanguageRole"`
+	Code string `xml:"LanguageCode"`
+}
+
+type subject struct {
+	Main    *struct{} `xml:"MainSubject"`
+	Scheme  string    `xml:"SubjectSchemeIdentifier"`
+	Code    string    `xml:"SubjectCode,omitempty"`
+	Heading string    `xml:"SubjectHeadingText,omitempty"`
+}
+
+type collateral struct {
+	Text textContent `xml:"TextContent"`
+}
+
+type textContent struct {
+	Type     string `xml:"TextType"`
+	Audience string `xml:"ContentAudience"`
+	Text     string `xml:"Text"`
+}
+
+type publishingDetail struct {
+	Imprint   *imprint        `xml:"Imprint"`
+	Publisher *publisher      `xml:"Publisher"`
+	Status    string          `xml:"PublishingStatus"`
+	Date      *publishingDate `xml:"PublishingDate"`
+}
+
+type imprint struct {
+	Name string `xml:"ImprintName"`
+}
+
+type publisher struct {
+	Role string `xml:"PublishingRole"`
+	Name string `xml:"PublisherName"`
+}
+
+type publishingDate struct {
+	Role string `xml:"PublishingDateRole"`
+	Date string `xml:"Date"`
+}
+
+type productSupply struct {
+	Detail supplyDetail `xml:"SupplyDetail"`
+}
+
+type supplyDetail struct {
+	Supplier struct {
+		Role string `xml:"SupplierRole"`
+		Name string `xml:"SupplierName"`
+	} `xml:"Supplier"`
+	Availability string  `xml:"ProductAvailability"`
+	Unpriced     string  `xml:"UnpricedItemType,omitempty"`
+	Prices       []price `xml:"Price"`
+}
+
+type price struct {
+	Type     string `xml:"PriceType"`
+	Amount   string `xml:"PriceAmount"`
+	Currency string `xml:"CurrencyCode"`
+}
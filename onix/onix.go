@@ -0,0 +1,211 @@
+// Package onix writes book metadata as ONIX for Books 3.0 messages, the
+// feed retailers and distributors import listings from. Only the
+// reference tag names are written.
+package onix
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"golang.org/x/text/language"
+)
+
+// ContentType is the media type of ONIX messages
+const ContentType = "application/xml"
+
+// Namespace is the namespace of ONIX 3.0 reference tags
+const Namespace = "http://ns.editeur.org/onix/3.0/reference"
+
+// Product is a book as listed in a message
+type Product struct {
+	// RecordReference identifies the record for as long as it's sent
+	RecordReference string
+	Book            models.Book
+	// Edition is the format listed, with its identifiers and prices. The
+	// book alone is listed as an ebook with the book's identifiers.
+	Edition *models.Edition
+}
+
+// forms are the ONIX product forms of edition formats
+var forms = map[string]string{
+	models.FormatEbook:      "ED", // digital download
+	models.FormatPaperback:  "BC", // paperback
+	models.FormatHardcover:  "BB", // hardback
+	models.FormatLargePrint: "BC",
+}
+
+// Write writes an ONIX message from the sender listing the products
+func Write(w io.Writer, sender string, sent time.Time, products []Product) error {
+	message := message{
+		Release: "3.0",
+		XMLNS:   Namespace,
+		Header: header{
+			Sender:       sender,
+			SentDateTime: sent.UTC().Format("20060102T1504Z"),
+		},
+	}
+	for _, p := range products {
+		message.Products = append(message.Products, product(p))
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(message); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// product maps a book onto the blocks of an ONIX product record
+func product(p Product) productRecord {
+	book, format, identifiers := p.Book, models.FormatEbook, p.Book.Identifiers
+	record := productRecord{
+		RecordReference:  p.RecordReference,
+		NotificationType: "03", // confirmed on publication
+		Identifiers: []productIdentifier{
+			{Type: "01", TypeName: "OnWord", Value: book.ID.Hex()},
+		},
+	}
+	if p.Edition != nil {
+		format, identifiers = p.Edition.Format, p.Edition.Identifiers
+		record.Identifiers[0].Value = p.Edition.ID.Hex()
+	}
+	for _, id := range identifiers {
+		switch id.Type {
+		case models.IdentifierISBN13:
+			record.Identifiers = append(record.Identifiers,
+				productIdentifier{Type: "03", Value: id.Value},
+				productIdentifier{Type: "15", Value: id.Value})
+		case models.IdentifierASIN:
+			record.Identifiers = append(record.Identifiers, productIdentifier{Type: "01", TypeName: "ASIN", Value: id.Value})
+		case models.IdentifierUUID:
+			record.Identifiers = append(record.Identifiers, productIdentifier{Type: "01", TypeName: "UUID", Value: id.Value})
+		}
+	}
+
+	detail := &record.Descriptive
+	detail.Composition = "00" // single-component product
+	detail.Form = forms[format]
+	if format == models.FormatEbook {
+		detail.FormDetail = "E101" // EPUB
+	}
+	if book.Series != nil {
+		collection := &collection{Type: "10"} // publisher collection
+		collection.Title.Type = "01"
+		collection.Title.Element = titleElement{Level: "02", Text: book.Series.Name}
+		if book.Series.Position > 0 {
+			collection.Title.Element.PartNumber = strconv.Itoa(book.Series.Position)
+		}
+		detail.Collection = collection
+	}
+	detail.Title.Type = "01" // distinctive title
+	detail.Title.Element = titleElement{Level: "01", Text: book.Title, Subtitle: book.Subtitle}
+
+	detail.Contributors = append(detail.Contributors, contributor{Sequence: 1, Role: "A01", Name: book.Author})
+	for i, c := range book.Contributors {
+		detail.Contributors = append(detail.Contributors, contributor{Sequence: i + 2, Role: models.Relators[c.Role], Name: c.Name, Inverted: c.FileAs})
+	}
+	if format == models.FormatLargePrint {
+		detail.EditionType = "LTE" // large type
+	}
+	if code := languageCode(book.Language); code != "" {
+		detail.Language = &languageDetail{Role: "01", Code: code}
+	}
+	for i, s := range book.Subjects {
+		scheme := "10" // BISAC
+		if s.Scheme == models.SubjectThema {
+			scheme = "93"
+		}
+		subject := subject{Scheme: scheme, Code: s.Code}
+		if i == 0 {
+			subject.Main = &struct{}{}
+		}
+		detail.Subjects = append(detail.Subjects, subject)
+	}
+	if len(book.Keywords) > 0 {
+		detail.Subjects = append(detail.Subjects, subject{Scheme: "20", Heading: strings.Join(book.Keywords, "; ")})
+	}
+
+	if book.Description != "" {
+		record.Collateral = &collateral{Text: textContent{Type: "03", Audience: "00", Text: book.Description}}
+	}
+
+	publishing := &record.Publishing
+	if book.Imprint != "" {
+		publishing.Imprint = &imprint{Name: book.Imprint}
+	}
+	if book.Publisher != "" {
+		publishing.Publisher = &publisher{Role: "01", Name: book.Publisher}
+	}
+	publishing.Status = "04" // active
+	if date, err := time.Parse("2006-01-02", book.PublicationDate); err == nil {
+		publishing.Date = &publishingDate{Role: "01", Date: date.Format("20060102")}
+		if date.After(time.Now()) {
+			publishing.Status = "02" // forthcoming
+		}
+	}
+	if p.Edition != nil && len(p.Edition.Prices) > 0 {
+		record.Supply = supply(book, *p.Edition, publishing.Status)
+	}
+	return record
+}
+
+// supply lists an edition's prices as supplied by its publisher.
+// An edition priced at nothing is listed as free of charge.
+func supply(book models.Book, edition models.Edition, status string) *productSupply {
+	out := &productSupply{}
+	detail := &out.Detail
+	detail.Supplier.Role = "01" // publisher to retailers
+	detail.Supplier.Name = book.Publisher
+	if detail.Supplier.Name == "" {
+		detail.Supplier.Name = book.Author
+	}
+	detail.Availability = "20" // available
+	if status == "02" {
+		detail.Availability = "10" // not yet available
+	}
+	for _, p := range edition.Prices {
+		if p.Amount > 0 {
+			// recommended retail price including tax
+			detail.Prices = append(detail.Prices, price{Type: "02", Amount: strconv.FormatFloat(p.Amount, 'f', 2, 64), Currency: p.Currency})
+		}
+	}
+	if len(detail.Prices) == 0 {
+		detail.Unpriced = "01" // free of charge
+	}
+	return out
+}
+
+// bibliographic are the ISO 639-2/B codes ONIX uses where they differ
+// from the terminology codes
+var bibliographic = map[string]string{
+	"sqi": "alb", "hye": "arm", "eus": "baq", "mya": "bur", "zho": "chi",
+	"ces": "cze", "nld": "dut", "fra": "fre", "kat": "geo", "deu": "ger",
+	"ell": "gre", "isl": "ice", "mkd": "mac", "mri": "mao", "msa": "may",
+	"fas": "per", "ron": "rum", "slk": "slo", "bod": "tib", "cym": "wel",
+}
+
+// languageCode is the ONIX code of a BCP 47 language tag, or "" for tags
+// that have none
+func languageCode(tag string) string {
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return ""
+	}
+	base, confidence := parsed.Base()
+	if confidence == language.No {
+		return ""
+	}
+	code := base.ISO3()
+	if b, ok := bibliographic[code]; ok {
+		return b
+	}
+	return code
+}
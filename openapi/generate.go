@@ -0,0 +1,356 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// Route says what a route takes and returns beyond what the router knows
+type Route struct {
+	Summary string
+	// Tag groups the route in the docs; v1 routes are marked as such
+	Tag string
+	// Body is a value of the JSON request body type
+	Body interface{}
+	// Form is a struct describing a multipart request body. Fields of type
+	// File carry uploads.
+	Form interface{}
+	// Query is the filter schema of a list route, whose response is then a
+	// page of Response
+	Query *db.Schema
+	// Params describes path and query parameters. Path parameters not listed
+	// here are ObjectIDs when their name ends in Id and strings otherwise.
+	Params []Parameter
+	// Status is the success status, 200 when zero
+	Status int
+	// Response is a value of the success body type, nil for no body
+	Response interface{}
+	// Envelope wraps Response in the v1 {status, message, data: {data}} body
+	Envelope bool
+	// Content is the media type of routes that stream files
+	Content string
+	// Formats are text media types, such as text/markdown, the route also
+	// takes as its body and answers with when the Accept header asks
+	Formats []string
+}
+
+// Routes describes routes keyed by "METHOD /path/{param}". HEAD routes
+// share the description of GET.
+type Routes map[string]Route
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Generate describes every route on the router. Each route needs an entry
+// in routes and each entry a route, so the document can't drift from what
+// is served.
+func Generate(router *mux.Router, info Info, routes Routes) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		// signing in is optional; handlers decide what anonymous callers see
+		Security: []map[string][]string{{}, {"bearerAuth": {}}},
+	}
+	g := &generator{doc: doc, schemas: newSchemas(doc.Components.Schemas)}
+
+	described := map[string]bool{}
+	var undescribed []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			// subrouter prefixes
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return fmt.Errorf("openapi: route %s does not declare its methods", template)
+		}
+		path := pathParam.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			key := method + " " + path
+			desc, ok := routes[key]
+			if !ok && method == http.MethodHead {
+				key = http.MethodGet + " " + path
+				desc, ok = routes[key]
+			}
+			if !ok {
+				undescribed = append(undescribed, method+" "+path)
+				continue
+			}
+			described[key] = true
+			if err := g.add(method, path, desc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var unrouted []string
+	for key := range routes {
+		if !described[key] {
+			unrouted = append(unrouted, key)
+		}
+	}
+	sort.Strings(unrouted)
+	if len(undescribed) > 0 {
+		return nil, fmt.Errorf("openapi: routes without a description: %s", strings.Join(undescribed, ", "))
+	}
+	if len(unrouted) > 0 {
+		return nil, fmt.Errorf("openapi: descriptions without a route: %s", strings.Join(unrouted, ", "))
+	}
+	return doc, nil
+}
+
+type generator struct {
+	doc     *Document
+	schemas *schemas
+}
+
+func (g *generator) add(method, path string, route Route) error {
+	v2 := strings.HasPrefix(path, "/v2/")
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     route.Summary,
+		Responses:   map[string]Response{},
+	}
+	if route.Tag != "" {
+		tag := route.Tag
+		if !v2 {
+			tag += " (v1)"
+		}
+		op.Tags = []string{tag}
+	}
+
+	params, err := g.parameters(path, route)
+	if err != nil {
+		return err
+	}
+	op.Parameters = params
+
+	switch {
+	case route.Body != nil:
+		schema := g.schemas.of(route.Body)
+		if v2 {
+			// v2 handlers reject fields the body type doesn't have
+			if component := g.doc.resolve(schema); component != nil && component.Type == "object" {
+				component.AdditionalProperties = false
+			}
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+		for _, format := range route.Formats {
+			op.RequestBody.Content[format] = MediaType{Schema: &Schema{Type: "string"}}
+		}
+	case route.Form != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"multipart/form-data": {Schema: g.schemas.of(route.Form)}}}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = g.success(method, route, status)
+	op.Responses["default"] = g.failure(v2)
+
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = PathItem{}
+		g.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+	return nil
+}
+
+func (g *generator) parameters(path string, route Route) ([]Parameter, error) {
+	var params []Parameter
+	given := map[string]Parameter{}
+	for _, param := range route.Params {
+		given[param.In+" "+param.Name] = param
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		name := match[1]
+		param, ok := given["path "+name]
+		if !ok {
+			param = Parameter{Name: name, In: "path", Schema: &Schema{Type: "string"}}
+			if strings.HasSuffix(name, "Id") {
+				param.Schema.Pattern = ObjectIDPattern
+			}
+		}
+		param.Required = true
+		delete(given, "path "+name)
+		params = append(params, param)
+	}
+
+	for _, param := range route.Params {
+		if param.In == "query" {
+			params = append(params, param)
+			delete(given, "query "+param.Name)
+		}
+	}
+	for key := range given {
+		return nil, fmt.Errorf("openapi: %s describes %s, which it doesn't have", path, key)
+	}
+
+	if route.Query != nil {
+		params = append(params, queryParameters(*route.Query)...)
+	}
+	return params, nil
+}
+
+// queryParameters describes the filters, sort, projection and paging a
+// list route accepts, as parsed by db.Schema.Parse
+func queryParameters(schema db.Schema) []Parameter {
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	explode := true
+	var params []Parameter
+	var sortable []string
+	for _, name := range names {
+		field := schema.Fields[name]
+		if field.Sortable {
+			sortable = append(sortable, name)
+		}
+		for _, op := range field.Ops() {
+			value := fieldSchema(field.Type)
+			switch op {
+			case db.OpIn:
+				// repeating the equality parameter matches any of the values
+				continue
+			case db.OpEq:
+				params = append(params, Parameter{
+					Name: name, In: "query", Explode: &explode,
+					Description: "equal to; repeat to match any of several values",
+					Schema:      &Schema{Type: "array", Items: value},
+				})
+				continue
+			case db.OpExists:
+				value = &Schema{Type: "boolean"}
+			}
+			params = append(params, Parameter{Name: name + "." + string(op), In: "query", Schema: value})
+		}
+	}
+
+	maxLimit := float64(db.MaxLimit)
+	minLimit := float64(1)
+	return append(params,
+		Parameter{Name: "sort", In: "query", Schema: &Schema{Type: "string"},
+			Description: "comma separated, - for descending; one of " + strings.Join(sortable, ", ")},
+		Parameter{Name: "fields", In: "query", Schema: &Schema{Type: "string"},
+			Description: "comma separated fields to return"},
+		Parameter{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Minimum: &minLimit, Maximum: &maxLimit},
+			Description: fmt.Sprintf("page size, %d when absent", db.DefaultLimit)},
+		Parameter{Name: "cursor", In: "query", Schema: &Schema{Type: "string"},
+			Description: "the nextCursor of the previous page"},
+	)
+}
+
+func fieldSchema(t db.FieldType) *Schema {
+	switch t {
+	case db.IntField:
+		return &Schema{Type: "integer"}
+	case db.ObjectIDField:
+		return &Schema{Type: "string", Pattern: ObjectIDPattern}
+	case db.CreatedField:
+		return &Schema{Type: "string", Description: "an RFC 3339 time or a date"}
+	}
+	return &Schema{Type: "string"}
+}
+
+func (g *generator) success(method string, route Route, status int) Response {
+	response := Response{Description: http.StatusText(status)}
+	switch {
+	case method == http.MethodHead || status == http.StatusNoContent:
+	case route.Content != "":
+		response.Content = map[string]MediaType{route.Content: {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case route.Response != nil:
+		schema := g.schemas.of(route.Response)
+		if route.Query != nil {
+			schema = &Schema{
+				Type:     "object",
+				Required: []string{"items"},
+				Properties: map[string]*Schema{
+					"items":      {Type: "array", Items: schema},
+					"nextCursor": {Type: "string", Description: "absent on the last page"},
+				},
+			}
+		}
+		if route.Envelope {
+			schema = envelope(schema)
+		}
+		response.Content = map[string]MediaType{"application/json": {Schema: schema}}
+		for _, format := range route.Formats {
+			response.Content[format] = MediaType{Schema: &Schema{Type: "string"}}
+		}
+	}
+	return response
+}
+
+// failure describes the errors of a route, problem details on v2 and
+// the envelope on v1
+func (g *generator) failure(v2 bool) Response {
+	if v2 {
+		return Response{
+			Description: "the request failed",
+			Content:     map[string]MediaType{responses.ProblemContentType: {Schema: g.schemas.of(responses.Problem{})}},
+		}
+	}
+	return Response{
+		Description: "the request failed",
+		Content:     map[string]MediaType{"application/json": {Schema: envelope(&Schema{Type: "string"})}},
+	}
+}
+
+func envelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":  {Type: "integer"},
+			"message": {Type: "string"},
+			"data": {
+				Type:       "object",
+				Properties: map[string]*Schema{"data": data},
+			},
+		},
+	}
+}
+
+// operationID names an operation after its method and path, for example
+// getV2BooksByBookIdChapters
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") {
+			segment = "By" + strings.Trim(segment, "{}")
+		}
+		segment = strings.NewReplacer(".", "", "-", "", "_", "").Replace(segment)
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// File marks a multipart form field that carries an uploaded file
+type File struct{}
+
+var (
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	timeType     = reflect.TypeOf(time.Time{})
+	fileType     = reflect.TypeOf(File{})
+)
+
+// schemas turns Go types into schemas, adding named struct types to the
+// document's components
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas(components map[string]*Schema) *schemas {
+	return &schemas{components: components, names: map[reflect.Type]string{}}
+}
+
+// of returns the schema of the type of v
+func (s *schemas) of(v interface{}) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Pointer {
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			// siblings of $ref are ignored, so nullability can't be stated
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	}
+
+	switch t {
+	case objectIDType:
+		return &Schema{Type: "string", Pattern: ObjectIDPattern}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case fileType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: componentPrefix + s.component(t)}
+	}
+	// interfaces hold anything
+	return &Schema{}
+}
+
+// component adds a named struct to the components and returns its name
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.components[name]; taken {
+		// another package has a type of the same name
+		pkg := t.PkgPath()[strings.LastIndexByte(t.PkgPath(), '/')+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.names[t] = name
+	// claim the name before recursing so self-referencing types terminate
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.object(t)
+	return name
+}
+
+// object describes a struct the way encoding/json writes it
+func (s *schemas) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(t, object)
+	return object
+}
+
+func (s *schemas) fields(t reflect.Type, object *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				// encoding/json promotes the fields of untagged embedded structs
+				s.fields(embedded, object)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schema(field.Type)
+		if name == "_id" {
+			property.ReadOnly = true
+		}
+		if constrain(property, field.Tag.Get("validate")) {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = property
+	}
+}
+
+// constrain applies the validate tag rules OpenAPI can express and
+// reports whether the field is required
+func constrain(schema *Schema, tag string) bool {
+	if schema.Ref != "" || tag == "" {
+		return false
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "required":
+			required = true
+			if schema.Type == "string" {
+				schema.MinLength = intPtr(1)
+			}
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "mongodb":
+			schema.Pattern = ObjectIDPattern
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "gte":
+			bound(schema, param, func(n float64) { schema.Minimum = &n })
+		case "max", "lte":
+			bound(schema, param, func(n float64) { schema.Maximum = &n })
+		case "dive":
+			// the rules that follow apply to elements
+			return required
+		}
+	}
+	return required
+}
+
+// bound sets a numeric limit; length limits aren't described
+func bound(schema *Schema, param string, set func(float64)) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil || schema.Type != "integer" && schema.Type != "number" {
+		return
+	}
+	set(n)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
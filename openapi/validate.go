@@ -0,0 +1,463 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/responses"
+)
+
+// Validator rejects requests that don't match the operation they are
+// routed to, before any handler sees them
+type Validator struct {
+	doc *Document
+	// operations are keyed by "METHOD /path/{param}"
+	operations   map[string]*Operation
+	patterns     map[string]*regexp.Regexp
+	maxBodyBytes int64
+}
+
+func NewValidator(doc *Document, maxBodyBytes int64) (*Validator, error) {
+	v := &Validator{doc: doc, operations: map[string]*Operation{}, patterns: map[string]*regexp.Regexp{}, maxBodyBytes: maxBodyBytes}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			v.operations[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+	// compile every pattern up front so a bad one fails startup
+	var err error
+	walk := func(s *Schema) {
+		if s.Pattern == "" || v.patterns[s.Pattern] != nil {
+			return
+		}
+		var compiled *regexp.Regexp
+		if compiled, err = regexp.Compile(s.Pattern); err == nil {
+			v.patterns[s.Pattern] = compiled
+		}
+	}
+	for _, op := range v.operations {
+		for _, param := range op.Parameters {
+			eachSchema(param.Schema, walk)
+		}
+		if op.RequestBody != nil {
+			for _, media := range op.RequestBody.Content {
+				eachSchema(media.Schema, walk)
+			}
+		}
+	}
+	for _, component := range doc.Components.Schemas {
+		eachSchema(component, walk)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+	return v, nil
+}
+
+func eachSchema(s *Schema, fn func(*Schema)) {
+	if s == nil {
+		return
+	}
+	fn(s)
+	eachSchema(s.Items, fn)
+	for _, property := range s.Properties {
+		eachSchema(property, fn)
+	}
+	if values, ok := s.AdditionalProperties.(*Schema); ok {
+		eachSchema(values, fn)
+	}
+}
+
+// Middleware checks path and query parameters and the request body.
+// Routes the document doesn't describe, such as the docs themselves, pass
+// through unchecked.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		op := v.operations[r.Method+" "+pathParam.ReplaceAllString(template, "{$1}")]
+		if op == nil {
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		if err := v.check(rw, r, op); err != nil {
+			if responses.WantsProblem(r) {
+				responses.WriteProblem(rw, r, err)
+				return
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			responses.WriteError(rw, err)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (v *Validator) check(rw http.ResponseWriter, r *http.Request, op *Operation) error {
+	var invalid []responses.FieldError
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			if failed := v.checkString(param.Name, param.Schema, vars[param.Name]); failed != nil {
+				if param.Schema.Pattern == ObjectIDPattern {
+					return responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidID, fmt.Sprintf("%s is not a valid ID", param.Name))
+				}
+				invalid = append(invalid, *failed)
+			}
+		case "query":
+			values, ok := query[param.Name]
+			if !ok {
+				if param.Required {
+					invalid = append(invalid, fieldError(param.Name, "required", "", "%s is required", param.Name))
+				}
+				continue
+			}
+			schema := param.Schema
+			if schema.Type == "array" {
+				schema = schema.Items
+			} else {
+				values = values[len(values)-1:]
+			}
+			for _, value := range values {
+				if failed := v.checkString(param.Name, schema, value); failed != nil {
+					invalid = append(invalid, *failed)
+				}
+			}
+		}
+	}
+
+	if op.RequestBody != nil {
+		failed, err := v.checkBody(rw, r, op.RequestBody)
+		if err != nil {
+			return err
+		}
+		invalid = append(invalid, failed...)
+	}
+
+	if len(invalid) > 0 {
+		problem := responses.NewProblem(http.StatusBadRequest, responses.CodeValidationFailed, "the request does not match the API description")
+		problem.Errors = invalid
+		return problem
+	}
+	return nil
+}
+
+func (v *Validator) checkBody(rw http.ResponseWriter, r *http.Request, body *RequestBody) ([]responses.FieldError, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if form, ok := body.Content["multipart/form-data"]; ok {
+		if mediaType != "multipart/form-data" {
+			return nil, responses.NewProblem(http.StatusUnsupportedMediaType, responses.CodeInvalidBody, "the body must be multipart/form-data")
+		}
+		r.Body = http.MaxBytesReader(rw, r.Body, v.maxBodyBytes)
+		if err := r.ParseMultipartForm(v.maxBodyBytes); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, responses.NewProblem(http.StatusRequestEntityTooLarge, responses.CodeInvalidBody, "the request body is too large")
+			}
+			return nil, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error())
+		}
+		return v.checkForm(r, v.doc.resolve(form.Schema)), nil
+	}
+
+	media, ok := body.Content["application/json"]
+	if _, text := body.Content[mediaType]; !ok || text && mediaType != "application/json" {
+		// other formats are plain text the handler converts
+		return nil, nil
+	}
+	// clients have never had to send a content type for JSON, so only
+	// the body itself is checked
+	data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, v.maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, responses.NewProblem(http.StatusRequestEntityTooLarge, responses.CodeInvalidBody, "the request body is too large")
+		}
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return nil, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, "a request body is required")
+		}
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, responses.NewProblem(http.StatusBadRequest, responses.CodeInvalidBody, err.Error())
+	}
+	var invalid []responses.FieldError
+	v.checkJSON("", media.Schema, value, &invalid)
+	return invalid, nil
+}
+
+func (v *Validator) checkForm(r *http.Request, schema *Schema) []responses.FieldError {
+	var invalid []responses.FieldError
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	for _, name := range sortedKeys(schema.Properties) {
+		property := schema.Properties[name]
+		if property.Format == "binary" {
+			if required[name] && len(r.MultipartForm.File[name]) == 0 {
+				invalid = append(invalid, fieldError(name, "required", "", "%s file is required", name))
+			}
+			continue
+		}
+		values := r.MultipartForm.Value[name]
+		if len(values) == 0 || values[0] == "" {
+			if required[name] {
+				invalid = append(invalid, fieldError(name, "required", "", "%s is required", name))
+			}
+			continue
+		}
+		if failed := v.checkString(name, property, values[0]); failed != nil {
+			invalid = append(invalid, *failed)
+		}
+	}
+	return invalid
+}
+
+// checkString checks a path, query or form value, which arrive as text
+func (v *Validator) checkString(name string, schema *Schema, value string) *responses.FieldError {
+	schema = v.doc.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			failed := fieldError(name, "type", "integer", "%s must be an integer", name)
+			return &failed
+		}
+		return v.checkNumber(name, schema, float64(n))
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			failed := fieldError(name, "type", "number", "%s must be a number", name)
+			return &failed
+		}
+		return v.checkNumber(name, schema, n)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			failed := fieldError(name, "type", "boolean", "%s must be true or false", name)
+			return &failed
+		}
+		return nil
+	}
+	return v.checkText(name, schema, value)
+}
+
+func (v *Validator) checkNumber(name string, schema *Schema, n float64) *responses.FieldError {
+	if schema.Minimum != nil && n < *schema.Minimum {
+		failed := fieldError(name, "minimum", formatNumber(*schema.Minimum), "%s must be at least %s", name, formatNumber(*schema.Minimum))
+		return &failed
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		failed := fieldError(name, "maximum", formatNumber(*schema.Maximum), "%s must be at most %s", name, formatNumber(*schema.Maximum))
+		return &failed
+	}
+	return nil
+}
+
+func (v *Validator) checkText(name string, schema *Schema, s string) *responses.FieldError {
+	if schema.MinLength != nil && len([]rune(s)) < *schema.MinLength {
+		failed := fieldError(name, "required", "", "%s is required", name)
+		return &failed
+	}
+	if s == "" {
+		// empty optional strings decode to the zero value, which the
+		// validate tags' omitempty lets through
+		return nil
+	}
+	if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+		param := strings.Join(schema.Enum, " ")
+		failed := fieldError(name, "enum", param, "%s must be one of: %s", name, param)
+		return &failed
+	}
+	if pattern := v.patterns[schema.Pattern]; pattern != nil && !pattern.MatchString(s) {
+		if schema.Pattern == ObjectIDPattern {
+			failed := fieldError(name, "pattern", schema.Pattern, "%s must be an ID", name)
+			return &failed
+		}
+		failed := fieldError(name, "pattern", schema.Pattern, "%s must match %s", name, schema.Pattern)
+		return &failed
+	}
+	if schema.Format == "email" {
+		if _, err := mail.ParseAddress(s); err != nil {
+			failed := fieldError(name, "format", "email", "%s must be an email address", name)
+			return &failed
+		}
+	}
+	return nil
+}
+
+// checkJSON checks a decoded JSON value. Property names match the way
+// encoding/json matches them, preferring an exact match but otherwise
+// ignoring case, and null counts as absent, which is what decoding into the
+// handlers' structs does with it.
+func (v *Validator) checkJSON(path string, schema *Schema, value interface{}, invalid *[]responses.FieldError) {
+	schema = v.doc.resolve(schema)
+	if schema == nil || value == nil {
+		return
+	}
+	name := path
+	if name == "" {
+		name = "body"
+	}
+	mismatch := func(want string) {
+		*invalid = append(*invalid, fieldError(name, "type", want, "%s must be %s", name, article(want)))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch("object")
+			return
+		}
+		v.checkObject(path, schema, object, invalid)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			mismatch("array")
+			return
+		}
+		for i, item := range items {
+			v.checkJSON(fmt.Sprintf("%s[%d]", name, i), schema.Items, item, invalid)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			mismatch("string")
+			return
+		}
+		if failed := v.checkText(name, schema, s); failed != nil {
+			*invalid = append(*invalid, *failed)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok || schema.Type == "integer" && n != math.Trunc(n) {
+			mismatch(schema.Type)
+			return
+		}
+		if failed := v.checkNumber(name, schema, n); failed != nil {
+			*invalid = append(*invalid, *failed)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			mismatch("boolean")
+		}
+	}
+}
+
+func (v *Validator) checkObject(path string, schema *Schema, object map[string]interface{}, invalid *[]responses.FieldError) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	// sorted so errors come back in the same order every time
+	for _, key := range sortedKeys(object) {
+		value := object[key]
+		property, name := lookup(schema.Properties, key)
+		if property == nil {
+			if values, ok := schema.AdditionalProperties.(*Schema); ok {
+				v.checkJSON(join(key), values, value, invalid)
+			} else if schema.closed() {
+				*invalid = append(*invalid, fieldError(join(key), "additionalProperties", "", "%s is not a known field", join(key)))
+			}
+			continue
+		}
+		if property.ReadOnly {
+			continue
+		}
+		v.checkJSON(join(name), property, value, invalid)
+	}
+
+	for _, name := range schema.Required {
+		key := ""
+		for candidate := range object {
+			if candidate == name || key == "" && strings.EqualFold(candidate, name) {
+				key = candidate
+			}
+		}
+		if key == "" || object[key] == nil {
+			*invalid = append(*invalid, fieldError(join(name), "required", "", "%s is required", join(name)))
+		}
+	}
+}
+
+// lookup finds the property a JSON key decodes into
+func lookup(properties map[string]*Schema, key string) (*Schema, string) {
+	if property, ok := properties[key]; ok {
+		return property, key
+	}
+	for name, property := range properties {
+		if strings.EqualFold(name, key) {
+			return property, name
+		}
+	}
+	return nil, ""
+}
+
+func fieldError(field, rule, param, format string, args ...interface{}) responses.FieldError {
+	return responses.FieldError{Field: field, Rule: rule, Param: param, Message: fmt.Sprintf(format, args...)}
+}
+
+func article(typ string) string {
+	switch typ {
+	case "object", "array", "integer":
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
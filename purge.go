@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/trash"
+)
+
+// schedulePurge empties expired trash every trash.purgeInterval until ctx
+// is cancelled
+func schedulePurge(ctx context.Context, cfg *configs.Config, bin *trash.Service) {
+	go func() {
+		ticker := time.NewTicker(cfg.Trash.PurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			runCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			report, err := bin.Purge(runCtx)
+			cancel()
+			if err != nil {
+				log.Println("trash purge failed:", err)
+				continue
+			}
+			for _, msg := range report.Errors {
+				log.Println("trash purge:", msg)
+			}
+			if purged := report.Books + report.Chapters + report.Notes + report.Images; purged > 0 {
+				log.Printf("trash purge removed %d books, %d chapters, %d notes and %d images\n", report.Books, report.Chapters, report.Notes, report.Images)
+			}
+		}
+	}()
+}
@@ -0,0 +1,103 @@
+package responses
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/db"
+)
+
+// PageBody is one page of a listing and the cursor to pass back for the
+// next, empty on the last page. When fields were selected each item only
+// carries those and its _id.
+type PageBody struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+func NewPageBody[T any](items []T, nextCursor string, fields []string) (PageBody, error) {
+	body := PageBody{Items: items, NextCursor: nextCursor}
+	if len(fields) > 0 {
+		trimmed, err := selectFields(items, fields)
+		if err != nil {
+			return PageBody{}, err
+		}
+		body.Items = trimmed
+	}
+	return body, nil
+}
+
+// Page wraps a page in the envelope the v1 list endpoints return
+func Page[T any](items []T, nextCursor string, fields []string) (Response, error) {
+	body, err := NewPageBody(items, nextCursor, fields)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": body}}, nil
+}
+
+// selectFields trims the items to the fields, which must be names the
+// items have in JSON
+func selectFields[T any](items []T, fields []string) ([]map[string]json.RawMessage, error) {
+	known := jsonFields(reflect.TypeOf((*T)(nil)).Elem())
+	keep := map[string]bool{"_id": true}
+	for _, field := range fields {
+		if !known[field] {
+			return nil, fmt.Errorf("%w: unknown field %q", db.ErrInvalidQuery, field)
+		}
+		keep[field] = true
+	}
+	trimmed := make([]map[string]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &all); err != nil {
+			return nil, err
+		}
+		for key := range all {
+			if !keep[key] {
+				delete(all, key)
+			}
+		}
+		trimmed = append(trimmed, all)
+	}
+	return trimmed, nil
+}
+
+// jsonFields are the names encoding/json gives the fields of t, including
+// those of embedded structs
+func jsonFields(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		switch {
+		case tag == "-":
+			continue
+		case field.Anonymous && name == "":
+			for embedded := range jsonFields(field.Type) {
+				names[embedded] = true
+			}
+			continue
+		case !field.IsExported():
+			continue
+		case name == "":
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
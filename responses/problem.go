@@ -0,0 +1,170 @@
+package responses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/trash"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Machine-readable problem codes. Clients should switch on these, the
+// titles and details are for people.
+const (
+	CodeInvalidID             = "invalid_id"
+	CodeInvalidBody           = "invalid_body"
+	CodeValidationFailed      = "validation_failed"
+	CodeInvalidQuery          = "invalid_query"
+	CodeNotFound              = "not_found"
+	CodeConflict              = "conflict"
+	CodeUnauthorized          = "unauthorized"
+	CodeInvalidCredentials    = "invalid_credentials"
+	CodeForbidden             = "forbidden"
+	CodeUnknownKind           = "unknown_kind"
+	CodeBookTrashed           = "book_trashed"
+	CodeUnprocessableImage    = "unprocessable_image"
+	CodeUnprocessableDocument = "unprocessable_document"
+	CodeMethodNotAllowed      = "method_not_allowed"
+	CodeInternal              = "internal"
+)
+
+// Problem is an RFC 7807 problem details body with a machine-readable
+// code and, for validation failures, the fields that failed
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is one failed validation rule
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "title"
+	Field string `json:"field"`
+	// Rule is the validator tag that failed, e.g. "required"
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// NewProblem returns a problem that isn't described by a more specific URI
+func NewProblem(status int, code, detail string) Problem {
+	return Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail, Code: code}
+}
+
+// ProblemFor maps an error from the store, the trash or the validator to a
+// problem. Unknown errors become a 500 whose detail doesn't leak internals.
+func ProblemFor(err error) Problem {
+	var problem Problem
+	var invalid validator.ValidationErrors
+	switch {
+	case errors.As(err, &problem):
+		return problem
+	case errors.As(err, &invalid):
+		problem = NewProblem(http.StatusBadRequest, CodeValidationFailed, "the request body failed validation")
+		problem.Errors = fieldErrors(invalid)
+		return problem
+	case errors.Is(err, db.ErrNotFound):
+		return NewProblem(http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, db.ErrDuplicate):
+		return NewProblem(http.StatusConflict, CodeConflict, err.Error())
+	case errors.Is(err, db.ErrInvalidQuery):
+		return NewProblem(http.StatusBadRequest, CodeInvalidQuery, err.Error())
+	case errors.Is(err, library.ErrInvalidPages), errors.Is(err, library.ErrInvalidEdition), errors.Is(err, library.ErrInvalidSeries),
+		errors.Is(err, library.ErrInvalidOmnibus):
+		return NewProblem(http.StatusBadRequest, CodeValidationFailed, err.Error())
+	case errors.Is(err, library.ErrOmnibusFrozen):
+		return NewProblem(http.StatusConflict, CodeConflict, err.Error())
+	case errors.Is(err, library.ErrInvalidCredentials):
+		return NewProblem(http.StatusUnauthorized, CodeInvalidCredentials, err.Error())
+	case errors.Is(err, trash.ErrUnknownKind):
+		return NewProblem(http.StatusBadRequest, CodeUnknownKind, err.Error())
+	case errors.Is(err, library.ErrForbidden), errors.Is(err, trash.ErrForbidden):
+		return NewProblem(http.StatusForbidden, CodeForbidden, err.Error())
+	case errors.Is(err, trash.ErrBookTrashed):
+		return NewProblem(http.StatusConflict, CodeBookTrashed, err.Error())
+	}
+	log.Printf("internal error: %v", err)
+	return NewProblem(http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+func fieldErrors(invalid validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(invalid))
+	for _, failed := range invalid {
+		// the namespace starts with the struct name, which means nothing to clients
+		path := failed.Namespace()
+		if i := strings.IndexByte(path, '.'); i >= 0 {
+			path = path[i+1:]
+		}
+		fields = append(fields, FieldError{
+			Field:   path,
+			Rule:    failed.Tag(),
+			Param:   failed.Param(),
+			Message: fieldMessage(failed),
+		})
+	}
+	return fields
+}
+
+func fieldMessage(failed validator.FieldError) string {
+	switch failed.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", failed.Field())
+	case "email":
+		return fmt.Sprintf("%s must be an email address", failed.Field())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", failed.Field(), failed.Param())
+	}
+	return fmt.Sprintf("%s failed the %s rule", failed.Field(), failed.Tag())
+}
+
+// WriteProblem writes err as problem details
+func WriteProblem(rw http.ResponseWriter, r *http.Request, err error) {
+	problem := ProblemFor(err)
+	problem.Instance = r.URL.Path
+	rw.Header().Set("Content-Type", ProblemContentType)
+	rw.WriteHeader(problem.Status)
+	json.NewEncoder(rw).Encode(problem)
+}
+
+// WriteError writes err in the v1 envelope, with the status problem
+// details would use
+func WriteError(rw http.ResponseWriter, err error) {
+	problem := ProblemFor(err)
+	detail := problem.Error()
+	if len(problem.Errors) > 0 {
+		messages := make([]string, 0, len(problem.Errors))
+		for _, field := range problem.Errors {
+			messages = append(messages, field.Message)
+		}
+		detail = strings.Join(messages, "; ")
+	}
+	rw.WriteHeader(problem.Status)
+	response := Response{Status: problem.Status, Message: "error", Data: map[string]interface{}{"data": detail}}
+	json.NewEncoder(rw).Encode(response)
+}
+
+// WantsProblem reports whether errors for the request should be problem
+// details, which is the case for the v2 API and clients that ask for them
+func WantsProblem(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/v2/") || strings.Contains(r.Header.Get("Accept"), ProblemContentType)
+}
@@ -0,0 +1,297 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/accessibility"
+	"github.com/programmingbunny/epub-backend/configs"
+	v2 "github.com/programmingbunny/epub-backend/controllers/v2"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/deletion"
+	"github.com/programmingbunny/epub-backend/docx"
+	"github.com/programmingbunny/epub-backend/epub"
+	"github.com/programmingbunny/epub-backend/markdown"
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/onix"
+	"github.com/programmingbunny/epub-backend/openapi"
+	"github.com/programmingbunny/epub-backend/responses"
+	"github.com/programmingbunny/epub-backend/site"
+	"github.com/programmingbunny/epub-backend/trash"
+	"github.com/programmingbunny/epub-backend/typeset"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Docs describes the routes registered so far in an OpenAPI document,
+// serves it at /openapi.json with a page at /docs, and rejects requests
+// that don't match it. Call it after every other route is registered.
+func Docs(router *mux.Router, limits configs.LimitsConfig) error {
+	doc, err := openapi.Generate(router, openapi.Info{
+		Title:       "OnWord API",
+		Description: "Books, their chapters, notes, images and versions. The v2 routes are resource oriented and answer errors with RFC 7807 problem details; the v1 routes are kept for existing clients.",
+		Version:     "2.0.0",
+	}, described)
+	if err != nil {
+		return err
+	}
+	validator, err := openapi.NewValidator(doc, limits.MaxUploadBytes)
+	if err != nil {
+		return err
+	}
+	spec, err := openapi.Handler(doc)
+	if err != nil {
+		return err
+	}
+
+	router.Use(validator.Middleware)
+	router.HandleFunc("/openapi.json", spec).Methods("GET")
+	router.HandleFunc("/docs", openapi.Docs("/openapi.json")).Methods("GET")
+	return nil
+}
+
+// bodies that have no named type; aliases keep them inline in the document
+type (
+	credentials = struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+	login = struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+		Data    struct {
+			Token  string `json:"token"`
+			UserID string `json:"userId"`
+		} `json:"data"`
+	}
+	chapterImageForm = struct {
+		ImageLocation openapi.File `json:"imageLocation" validate:"required"`
+		BookID        string       `json:"bookID" validate:"required,mongodb"`
+		ChapterNum    int          `json:"chapterNum"`
+		Type          string       `json:"type"`
+	}
+	trashList = struct {
+		Items []trash.Item `json:"items"`
+	}
+	pageList = struct {
+		Items []models.Page `json:"items"`
+	}
+	bookList = struct {
+		Items []models.Book `json:"items"`
+	}
+	chapterList = struct {
+		Items []models.Chapter `json:"items"`
+	}
+	importForm = struct {
+		File         openapi.File `json:"file" validate:"required"`
+		SplitStyle   string       `json:"splitStyle"`
+		SplitPattern string       `json:"splitPattern"`
+		Preview      bool         `json:"preview"`
+	}
+	coverBody = struct {
+		Trim     string `json:"trim" validate:"omitempty,oneof=5x8 5.5x8.5 6x9"`
+		Pages    int    `json:"pages" validate:"omitempty,min=1,max=1000"`
+		Paper    string `json:"paper" validate:"omitempty,oneof=white cream color"`
+		Blurb    string `json:"blurb" validate:"max=5000"`
+		ISBN     string `json:"isbn"`
+		Format   string `json:"format" validate:"omitempty,oneof=pdf png json"`
+		Template bool   `json:"template"`
+		Guides   bool   `json:"guides"`
+	}
+	imageForm = struct {
+		Image      openapi.File `json:"image" validate:"required"`
+		ChapterNum int          `json:"chapterNum" validate:"required"`
+		Type       string       `json:"type"`
+
+		AltText         string `json:"altText" validate:"max=1000"`
+		LongDescription string `json:"longDescription" validate:"max=20000"`
+		Decorative      bool   `json:"decorative"`
+	}
+)
+
+var (
+	sizeParams = []openapi.Parameter{
+		{Name: "w", In: "query", Description: "resize to this width", Schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+		{Name: "h", In: "query", Description: "resize to this height", Schema: &openapi.Schema{Type: "integer", Minimum: float(0)}},
+	}
+	// editionParam makes an export for one of the book's editions, with its
+	// settings, overrides and identifiers
+	editionParam = openapi.Parameter{Name: "edition", In: "query", Description: "the ID of the edition to export", Schema: &openapi.Schema{Type: "string"}}
+	// backMatterParam can leave out the "Next in" and "Also by" pages that
+	// exports end with
+	backMatterParam  = openapi.Parameter{Name: "backMatter", In: "query", Description: "false to end with the last chapter", Schema: &openapi.Schema{Type: "boolean"}}
+	manuscriptParams = []openapi.Parameter{
+		editionParam,
+		{Name: "style", In: "query", Description: "modern sets Times New Roman with italics instead of Courier with underlining", Schema: &openapi.Schema{Type: "string", Enum: []string{"classic", "modern"}}},
+		{Name: "from", In: "query", Description: "the first chapter number", Schema: &openapi.Schema{Type: "integer", Minimum: float(1)}},
+		{Name: "to", In: "query", Description: "the last chapter number", Schema: &openapi.Schema{Type: "integer", Minimum: float(1)}},
+	}
+	interiorParams = []openapi.Parameter{
+		editionParam,
+		backMatterParam,
+		{Name: "trim", In: "query", Description: "the page size in inches, 6x9 by default", Schema: &openapi.Schema{Type: "string", Enum: []string{"5x8", "5.5x8.5", "6x9"}}},
+		{Name: "top", In: "query", Description: "the top margin in inches", Schema: &openapi.Schema{Type: "number", Minimum: float(0.25)}},
+		{Name: "bottom", In: "query", Description: "the bottom margin in inches", Schema: &openapi.Schema{Type: "number", Minimum: float(0.25)}},
+		{Name: "inside", In: "query", Description: "the margin at the spine in inches, not counting the gutter", Schema: &openapi.Schema{Type: "number", Minimum: float(0.25)}},
+		{Name: "outside", In: "query", Description: "the margin at the fore edge in inches", Schema: &openapi.Schema{Type: "number", Minimum: float(0.25)}},
+		{Name: "gutter", In: "query", Description: "extra inside margin for the binding in inches", Schema: &openapi.Schema{Type: "number", Minimum: float(0)}},
+	}
+	// epubParams' backMatter only applies to reflowable books
+	epubParams = []openapi.Parameter{editionParam, backMatterParam}
+	siteParams = []openapi.Parameter{
+		editionParam,
+		backMatterParam,
+		{Name: "baseURL", In: "query", Description: "the absolute URL the site will be published at, for Open Graph, the sitemap and the feed", Required: true, Schema: &openapi.Schema{Type: "string", Format: "uri"}},
+	}
+	kindParam = openapi.Parameter{Name: "kind", In: "path", Schema: &openapi.Schema{
+		Type: "string", Enum: []string{trash.KindBook, trash.KindChapter, trash.KindNote, trash.KindImage},
+	}}
+	// markdownFormat routes take and serve chapters as Markdown with YAML
+	// front matter holding the title and number
+	markdownFormat = []string{markdown.ContentType}
+	insertResult   = db.InsertResult{}
+	message        = ""
+)
+
+func float(n float64) *float64 {
+	return &n
+}
+
+// described is what the routes above take and return. Generate fails when
+// a route is missing here or an entry here has no route.
+var described = openapi.Routes{
+	"GET /healthz": {Tag: "Health", Summary: "The process is up", Response: responses.Response{}},
+	"GET /readyz":  {Tag: "Health", Summary: "The database is reachable and the server accepts work", Response: responses.Response{}},
+
+	"POST /login":                 {Tag: "Users", Summary: "Exchange an email and password for a token", Body: credentials{}, Response: login{}},
+	"POST /createUser":            {Tag: "Users", Summary: "Register an account", Body: models.User{}, Status: 201, Response: models.User{}},
+	"GET /getUser/{userId}":       {Tag: "Users", Response: models.User{}, Envelope: true},
+	"DELETE /deleteUser/{userId}": {Tag: "Users", Response: message, Envelope: true},
+	"PUT /updateUser/{userId}":    {Tag: "Users", Body: models.User{}, Response: message, Envelope: true},
+
+	"POST /createBook":         {Tag: "Books", Body: models.Book{}, Response: primitive.ObjectID{}},
+	"GET /getBooks":            {Tag: "Books", Summary: "The books the caller may read", Query: &db.BookSchema, Response: models.Book{}, Envelope: true},
+	"GET /book/{bookId}":       {Tag: "Books", Response: models.Book{}},
+	"GET /book/{bookId}/cover": {Tag: "Books", Params: sizeParams, Content: "image/*"},
+	"POST /book/{bookId}/importDocx": {Tag: "Books", Summary: "Add the chapters of a Word document, split at Heading 1 or the given style or pattern; preview answers 200 with the proposed split instead",
+		Form: importForm{}, Status: 201, Response: models.Chapter{}, Envelope: true},
+	"GET /book/{bookId}/export.docx":  {Tag: "Books", Summary: "The book as a Word manuscript in Standard Manuscript Format; an edition's style is used unless one is asked for", Params: manuscriptParams, Content: docx.ContentType},
+	"GET /book/{bookId}/interior.pdf": {Tag: "Books", Summary: "The book as a print-ready interior, with chapters starting on right-hand pages; print editions set the trim, margins and text size unless the query does", Params: interiorParams, Content: typeset.ContentType},
+	"GET /book/{bookId}/site.zip":     {Tag: "Books", Summary: "The book as a static website: an index with the cover and contents, a page per chapter, a stylesheet, a sitemap and an Atom feed", Params: siteParams, Content: site.ContentType},
+	"POST /book/{bookId}/coverWrap": {Tag: "Books", Summary: "The full paperback cover with the spine sized for the page count and paper, as PDF, PNG or its measurements for json; pages default to the laid out interior",
+		Body: coverBody{}, Params: []openapi.Parameter{editionParam, backMatterParam}, Content: typeset.ContentType},
+	"GET /book/{bookId}/onix.xml":    {Tag: "Books", Summary: "The book's metadata as an ONIX 3.0 message, a product for each edition", Params: []openapi.Parameter{editionParam}, Content: onix.ContentType},
+	"GET /onix.xml":                  {Tag: "Books", Summary: "Every book the caller may read that matches the filters, as one ONIX 3.0 message", Query: &db.BookSchema, Content: onix.ContentType},
+	"GET /book/{bookId}/export.epub": {Tag: "Books", Summary: "The book as an EPUB: a fixed-layout book pre-paginated with its text over each page image, any other a chapter per document", Params: epubParams, Content: epub.ContentType},
+	"DELETE /deleteBook/{bookId}":    {Tag: "Books", Summary: "Move a book and everything in it to the trash", Response: message, Envelope: true},
+
+	"POST /createChapter":                     {Tag: "Chapters", Body: models.Chapter{}, Status: 201, Response: insertResult, Envelope: true},
+	"GET /getChapters/{bookId}":               {Tag: "Chapters", Query: &db.ChapterSchema, Response: models.Chapter{}, Envelope: true},
+	"GET /getChapter/{chapterId}":             {Tag: "Chapters", Response: models.Chapter{}},
+	"PUT /updateChapter/{bookId}/{chapterId}": {Tag: "Chapters", Body: models.Chapter{}, Response: models.Chapter{}, Envelope: true},
+	"DELETE /deleteChapter/{chapterId}":       {Tag: "Chapters", Response: message, Envelope: true},
+
+	"GET /getChapterImage/{bookId}/{chapterId}": {Tag: "Images", Summary: "The header image of a chapter number", Response: models.ChapterImages{},
+		Params: []openapi.Parameter{{Name: "chapterId", In: "path", Description: "the chapter number", Schema: &openapi.Schema{Type: "integer"}}}},
+	"POST /createChapterImage":             {Tag: "Images", Form: chapterImageForm{}, Response: primitive.ObjectID{}},
+	"DELETE /deleteChapterImage/{imageId}": {Tag: "Images", Response: message, Envelope: true},
+	"GET /images/{imageId}":                {Tag: "Images", Params: sizeParams, Content: "image/*"},
+	"GET /getImages/{bookId}":              {Tag: "Images", Query: &db.ImageSchema, Response: models.ChapterImages{}, Envelope: true},
+
+	"POST /createVersion":         {Tag: "Versions", Body: models.Version{}, Status: 201, Response: insertResult, Envelope: true},
+	"GET /getVersion/{versionId}": {Tag: "Versions", Response: models.Version{}},
+	"GET /getVersions/{bookId}":   {Tag: "Versions", Query: &db.VersionSchema, Response: models.Version{}, Envelope: true},
+
+	"GET /getNotes":                {Tag: "Notes", Query: &db.NoteSchema, Response: models.Notes{}, Envelope: true},
+	"GET /getNotes/{noteId}":       {Tag: "Notes", Response: models.Notes{}},
+	"POST /createNotes":            {Tag: "Notes", Body: models.Notes{}, Status: 201, Response: insertResult, Envelope: true},
+	"PUT /updateNotes/{noteId}":    {Tag: "Notes", Body: models.Notes{}, Response: models.Notes{}, Envelope: true},
+	"DELETE /deleteNotes/{noteId}": {Tag: "Notes", Response: responses.Response{}},
+
+	"GET /trash":                          {Tag: "Trash", Summary: "The caller's deleted items", Response: []trash.Item{}, Envelope: true},
+	"POST /trash/{kind}/{itemId}/restore": {Tag: "Trash", Params: []openapi.Parameter{kindParam}, Response: message, Envelope: true},
+	"DELETE /trash/{kind}/{itemId}":       {Tag: "Trash", Summary: "Delete an item for good", Params: []openapi.Parameter{kindParam}, Response: deletion.Summary{}, Envelope: true},
+
+	"POST /v2/sessions":         {Tag: "Users", Summary: "Exchange an email and password for a token", Body: v2.SessionInput{}, Status: 201, Response: v2.Session{}},
+	"POST /v2/users":            {Tag: "Users", Summary: "Register an account", Body: v2.UserInput{}, Status: 201, Response: v2.UserView{}},
+	"GET /v2/users/{userId}":    {Tag: "Users", Summary: "The caller's own account", Response: v2.UserView{}},
+	"PUT /v2/users/{userId}":    {Tag: "Users", Summary: "Replace the caller's account details", Body: v2.UserInput{}, Response: v2.UserView{}},
+	"DELETE /v2/users/{userId}": {Tag: "Users", Summary: "Delete the caller's account", Status: 204},
+
+	"GET /v2/books":             {Tag: "Books", Summary: "The books the caller may read", Query: &db.BookSchema, Response: models.Book{}},
+	"POST /v2/books":            {Tag: "Books", Summary: "Add a book owned by the caller", Body: v2.BookInput{}, Status: 201, Response: models.Book{}},
+	"GET /v2/books/{bookId}":    {Tag: "Books", Summary: "The book, or with Accept: text/markdown all its chapters as one document", Response: models.Book{}, Formats: markdownFormat},
+	"PUT /v2/books/{bookId}":    {Tag: "Books", Summary: "Replace the book's details and metadata; ISBN-13s must have a valid check digit", Body: v2.BookInput{}, Response: models.Book{}},
+	"DELETE /v2/books/{bookId}": {Tag: "Books", Summary: "Move a book and everything in it to the trash", Status: 204},
+	"GET /v2/books/{bookId}/accessibility": {Tag: "Books", Summary: "Check the book for images without alt text, skipped heading levels, a missing language, tables without headers and low-contrast theme colors, with the accessibility metadata its EPUB gets",
+		Response: accessibility.Report{}},
+	"GET /v2/books/{bookId}/cover": {Tag: "Books", Params: sizeParams, Content: "image/*"},
+
+	"GET /v2/books/{bookId}/export.docx":  {Tag: "Exports", Summary: "The book as a Word manuscript in Standard Manuscript Format; an edition's style is used unless one is asked for", Params: manuscriptParams, Content: docx.ContentType},
+	"GET /v2/books/{bookId}/export.epub":  {Tag: "Exports", Summary: "The book as an EPUB: a fixed-layout book pre-paginated with its text over each page image, any other a chapter per document", Params: epubParams, Content: epub.ContentType},
+	"GET /v2/books/{bookId}/interior.pdf": {Tag: "Exports", Summary: "The book as a print-ready interior, with chapters starting on right-hand pages; print editions set the trim, margins and text size unless the query does", Params: interiorParams, Content: typeset.ContentType},
+	"POST /v2/books/{bookId}/cover-wrap": {Tag: "Exports", Summary: "The full paperback cover with the spine sized for the page count and paper, as PDF, PNG or its measurements for json; pages default to the laid out interior",
+		Body: coverBody{}, Params: []openapi.Parameter{editionParam, backMatterParam}, Content: typeset.ContentType},
+	"GET /v2/books/{bookId}/site.zip": {Tag: "Exports", Summary: "The book as a static website: an index with the cover and contents, a page per chapter, a stylesheet, a sitemap and an Atom feed", Params: siteParams, Content: site.ContentType},
+	"GET /v2/books/{bookId}/onix.xml": {Tag: "Exports", Summary: "The book's metadata as an ONIX 3.0 message, a product for each edition", Params: []openapi.Parameter{editionParam}, Content: onix.ContentType},
+	"GET /v2/onix.xml":                {Tag: "Exports", Summary: "Every book the caller may read that matches the filters, as one ONIX 3.0 message", Query: &db.BookSchema, Content: onix.ContentType},
+
+	"GET /v2/books/{bookId}/chapters":                {Tag: "Chapters", Query: &db.ChapterSchema, Response: models.Chapter{}},
+	"POST /v2/books/{bookId}/chapters":               {Tag: "Chapters", Summary: "Add a chapter; a taken number moves it to the next free one", Body: v2.ChapterInput{}, Status: 201, Response: models.Chapter{}, Formats: markdownFormat},
+	"GET /v2/books/{bookId}/chapters/{chapterId}":    {Tag: "Chapters", Response: models.Chapter{}, Formats: markdownFormat},
+	"PUT /v2/books/{bookId}/chapters/{chapterId}":    {Tag: "Chapters", Summary: "Replace the title, text and number", Body: v2.ChapterInput{}, Response: models.Chapter{}, Formats: markdownFormat},
+	"DELETE /v2/books/{bookId}/chapters/{chapterId}": {Tag: "Chapters", Summary: "Move a chapter to the trash", Status: 204},
+
+	"GET /v2/books/{bookId}/notes":             {Tag: "Notes", Summary: "The book's notes and those of its series", Query: &db.NoteSchema, Response: models.Notes{}},
+	"POST /v2/books/{bookId}/notes":            {Tag: "Notes", Body: v2.NoteInput{}, Status: 201, Response: models.Notes{}},
+	"GET /v2/books/{bookId}/notes/{noteId}":    {Tag: "Notes", Response: models.Notes{}},
+	"PUT /v2/books/{bookId}/notes/{noteId}":    {Tag: "Notes", Summary: "Replace the title and text", Body: v2.NoteInput{}, Response: models.Notes{}},
+	"DELETE /v2/books/{bookId}/notes/{noteId}": {Tag: "Notes", Summary: "Move a note to the trash", Status: 204},
+
+	"GET /v2/books/{bookId}/images":                       {Tag: "Images", Query: &db.ImageSchema, Response: models.ChapterImages{}},
+	"POST /v2/books/{bookId}/images":                      {Tag: "Images", Summary: "Upload a chapter header image", Form: imageForm{}, Status: 201, Response: models.ChapterImages{}},
+	"GET /v2/books/{bookId}/images/{imageId}":             {Tag: "Images", Response: models.ChapterImages{}},
+	"DELETE /v2/books/{bookId}/images/{imageId}":          {Tag: "Images", Summary: "Move an image to the trash", Status: 204},
+	"GET /v2/books/{bookId}/images/{imageId}/file":        {Tag: "Images", Params: sizeParams, Content: "image/*"},
+	"PUT /v2/books/{bookId}/images/{imageId}/description": {Tag: "Images", Summary: "Replace the alt text, long description and decorative flag", Body: models.ImageDescription{}, Response: models.ChapterImages{}},
+
+	"GET /v2/books/{bookId}/versions":             {Tag: "Versions", Query: &db.VersionSchema, Response: models.Version{}},
+	"POST /v2/books/{bookId}/versions":            {Tag: "Versions", Body: v2.VersionInput{}, Status: 201, Response: models.Version{}},
+	"GET /v2/books/{bookId}/versions/{versionId}": {Tag: "Versions", Response: models.Version{}},
+
+	"PUT /v2/books/{bookId}/layout":            {Tag: "Pages", Summary: "Make the book reflowable or fixed-layout; fixed layout needs a viewport", Body: v2.LayoutInput{}, Response: models.Book{}},
+	"GET /v2/books/{bookId}/pages":             {Tag: "Pages", Summary: "The pages of a fixed-layout book, in page order", Query: &db.PageSchema, Response: models.Page{}},
+	"POST /v2/books/{bookId}/pages":            {Tag: "Pages", Summary: "Add a page after the last one", Body: v2.PageInput{}, Status: 201, Response: models.Page{}},
+	"PUT /v2/books/{bookId}/pages/order":       {Tag: "Pages", Summary: "Renumber the pages in the order listed, which must name every page once", Body: v2.PageOrderInput{}, Response: pageList{}},
+	"GET /v2/books/{bookId}/pages/{pageId}":    {Tag: "Pages", Response: models.Page{}},
+	"PUT /v2/books/{bookId}/pages/{pageId}":    {Tag: "Pages", Summary: "Replace the image, spread and text", Body: v2.PageInput{}, Response: models.Page{}},
+	"DELETE /v2/books/{bookId}/pages/{pageId}": {Tag: "Pages", Summary: "Delete a page for good; the pages after it move up", Status: 204},
+
+	"GET /v2/books/{bookId}/editions":                {Tag: "Editions", Summary: "The formats the book is published in", Query: &db.EditionSchema, Response: models.Edition{}},
+	"POST /v2/books/{bookId}/editions":               {Tag: "Editions", Summary: "Add an edition; large-print editions default to a text scale of 1.3, and an ISBN, ASIN or UUID another edition of any book has is a conflict", Body: v2.EditionInput{}, Status: 201, Response: models.Edition{}},
+	"GET /v2/books/{bookId}/editions/{editionId}":    {Tag: "Editions", Response: models.Edition{}},
+	"PUT /v2/books/{bookId}/editions/{editionId}":    {Tag: "Editions", Summary: "Replace the edition's format, identifiers, prices, settings and overrides", Body: v2.EditionInput{}, Response: models.Edition{}},
+	"DELETE /v2/books/{bookId}/editions/{editionId}": {Tag: "Editions", Summary: "Delete an edition for good", Status: 204},
+
+	"GET /v2/books/{bookId}/series":      {Tag: "Series", Summary: "The series the book is in", Response: models.Series{}},
+	"GET /v2/books/{bookId}/back-matter": {Tag: "Series", Summary: "The pages exports add after the last chapter: the next book in the series and the author's other books", Response: chapterList{}},
+	"GET /v2/series":                     {Tag: "Series", Summary: "The caller's series", Query: &db.SeriesSchema, Response: models.Series{}},
+	"POST /v2/series":                    {Tag: "Series", Summary: "Add a series of the caller's books, in reading order; a book can be in one series", Body: v2.SeriesInput{}, Status: 201, Response: models.Series{}},
+	"GET /v2/series/{seriesId}":          {Tag: "Series", Response: models.Series{}},
+	"PUT /v2/series/{seriesId}":          {Tag: "Series", Summary: "Replace the name, description and books", Body: v2.SeriesInput{}, Response: models.Series{}},
+	"DELETE /v2/series/{seriesId}":       {Tag: "Series", Summary: "Delete a series and its notes for good; its books are kept", Status: 204},
+	"GET /v2/series/{seriesId}/books":    {Tag: "Series", Summary: "The series' books in reading order", Response: bookList{}},
+
+	"POST /v2/omnibuses":                       {Tag: "Omnibus", Summary: "Add a book owned by the caller made of other books, in reading order; each part can use one version of its book", Body: v2.OmnibusInput{}, Status: 201, Response: models.Book{}},
+	"GET /v2/books/{bookId}/omnibus":           {Tag: "Omnibus", Summary: "The omnibus' contents: its parts with their chapters, as exports have them", Response: v2.OmnibusContents{}},
+	"PUT /v2/books/{bookId}/omnibus":           {Tag: "Omnibus", Summary: "Replace the parts of an omnibus that isn't frozen", Body: v2.OmnibusPartsInput{}, Response: models.Book{}},
+	"POST /v2/books/{bookId}/omnibus/freeze":   {Tag: "Omnibus", Summary: "Copy the parts into the omnibus so changes to their books stop reaching its exports; freezing again copies them afresh", Response: models.Book{}},
+	"DELETE /v2/books/{bookId}/omnibus/freeze": {Tag: "Omnibus", Summary: "Drop the copies so the parts follow their books again", Response: models.Book{}},
+
+	"GET /v2/series/{seriesId}/notes":             {Tag: "Notes", Summary: "The story bible shared by the series' books", Query: &db.NoteSchema, Response: models.Notes{}},
+	"POST /v2/series/{seriesId}/notes":            {Tag: "Notes", Body: v2.NoteInput{}, Status: 201, Response: models.Notes{}},
+	"GET /v2/series/{seriesId}/notes/{noteId}":    {Tag: "Notes", Response: models.Notes{}},
+	"PUT /v2/series/{seriesId}/notes/{noteId}":    {Tag: "Notes", Summary: "Replace the title and text", Body: v2.NoteInput{}, Response: models.Notes{}},
+	"DELETE /v2/series/{seriesId}/notes/{noteId}": {Tag: "Notes", Summary: "Move a note to the trash", Status: 204},
+
+	"GET /v2/trash":                          {Tag: "Trash", Summary: "The caller's deleted items", Response: trashList{}},
+	"POST /v2/trash/{kind}/{itemId}/restore": {Tag: "Trash", Params: []openapi.Parameter{kindParam}, Status: 204},
+	"DELETE /v2/trash/{kind}/{itemId}":       {Tag: "Trash", Summary: "Delete an item for good", Params: []openapi.Parameter{kindParam}, Response: deletion.Summary{}},
+}
@@ -2,23 +2,53 @@ package routes
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
 	books "github.com/programmingbunny/epub-backend/controllers/books"
 	chapters "github.com/programmingbunny/epub-backend/controllers/chapters"
+	"github.com/programmingbunny/epub-backend/controllers/health"
+	"github.com/programmingbunny/epub-backend/controllers/images"
 	"github.com/programmingbunny/epub-backend/controllers/notes"
-	"go.mongodb.org/mongo-driver/mongo"
+	trash "github.com/programmingbunny/epub-backend/controllers/trash"
 	"github.com/programmingbunny/epub-backend/controllers/users"
-	
+	v2 "github.com/programmingbunny/epub-backend/controllers/v2"
+	"github.com/programmingbunny/epub-backend/controllers/version"
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/library"
+	"github.com/programmingbunny/epub-backend/middleware"
+	"github.com/programmingbunny/epub-backend/storage"
+	bin "github.com/programmingbunny/epub-backend/trash"
 )
 
-func Routes(router *mux.Router, client *mongo.Client) {
+func Routes(router *mux.Router, store *db.Store, blobs *storage.BlobStore, bin *bin.Service, auth *middleware.Auth, limits configs.LimitsConfig) {
+	router.Use(auth.Authenticate)
+
+	lib := library.New(store, blobs, bin)
+	users := users.New(lib, auth, limits)
+	books := books.New(lib, limits)
+	chapters := chapters.New(lib, limits)
+	images := images.New(lib, limits)
+	notes := notes.New(lib, limits)
+	versions := version.New(lib, limits)
+	trash := trash.New(bin, limits)
+
+	router.HandleFunc("/login", users.Login()).Methods("POST")
 	router.HandleFunc("/createUser", users.CreateUser()).Methods("POST")
 	router.HandleFunc("/getUser/{userId}", users.GetUser()).Methods("GET")
 	router.HandleFunc("/deleteUser/{userId}", users.DeleteUser()).Methods("DELETE")
 	router.HandleFunc("/updateUser/{userId}", users.UpdateUser()).Methods("PUT")
 
-
 	router.HandleFunc("/createBook", books.CreateBook()).Methods("POST")
+	router.HandleFunc("/getBooks", books.GetBooks()).Methods("GET")
 	router.HandleFunc("/book/{bookId}", books.GetABook()).Methods("GET")
+	router.HandleFunc("/book/{bookId}/cover", images.ServeBookCover()).Methods("GET", "HEAD")
+	router.HandleFunc("/book/{bookId}/importDocx", books.ImportDocx()).Methods("POST")
+	router.HandleFunc("/book/{bookId}/export.docx", books.ExportDocx()).Methods("GET")
+	router.HandleFunc("/book/{bookId}/interior.pdf", books.ExportPDF()).Methods("GET")
+	router.HandleFunc("/book/{bookId}/site.zip", books.ExportSite()).Methods("GET")
+	router.HandleFunc("/book/{bookId}/coverWrap", books.CoverWrap()).Methods("POST")
+	router.HandleFunc("/book/{bookId}/export.epub", books.ExportEPUB()).Methods("GET")
+	router.HandleFunc("/book/{bookId}/onix.xml", books.ExportONIX()).Methods("GET")
+	router.HandleFunc("/onix.xml", books.ExportCatalog()).Methods("GET")
 	router.HandleFunc("/deleteBook/{bookId}", books.DeleteBook()).Methods("Delete")
 
 	router.HandleFunc("/createChapter", chapters.CreateChapter()).Methods("POST")
@@ -29,11 +59,120 @@ func Routes(router *mux.Router, client *mongo.Client) {
 
 	router.HandleFunc("/getChapterImage/{bookId}/{chapterId}", books.GetChapterHeader()).Methods("GET")
 	router.HandleFunc("/createChapterImage", books.CreateChapterHeader()).Methods("POST")
+	router.HandleFunc("/deleteChapterImage/{imageId}", books.DeleteChapterHeader()).Methods("DELETE")
+	router.HandleFunc("/images/{imageId}", images.ServeImage()).Methods("GET", "HEAD")
+	router.HandleFunc("/getImages/{bookId}", images.GetImages()).Methods("GET")
+
+	router.HandleFunc("/createVersion", versions.CreateVersion()).Methods("POST")
+	router.HandleFunc("/getVersion/{versionId}", versions.GetVersion()).Methods("GET")
+	router.HandleFunc("/getVersions/{bookId}", versions.GetVersions()).Methods("GET")
 
 	router.HandleFunc("/getNotes", notes.GetAllNotes()).Methods("GET")
 	router.HandleFunc("/getNotes/{noteId}", notes.GetNotes()).Methods("GET")
 	router.HandleFunc("/createNotes", notes.CreateNotes()).Methods("POST")
 	router.HandleFunc("/updateNotes/{noteId}", notes.UpdateNote()).Methods("PUT")
 	router.HandleFunc("/deleteNotes/{noteId}", notes.DeleteNote()).Methods("DELETE")
-	
+
+	router.HandleFunc("/trash", trash.ListTrash()).Methods("GET")
+	router.HandleFunc("/trash/{kind}/{itemId}/restore", trash.RestoreItem()).Methods("POST")
+	router.HandleFunc("/trash/{kind}/{itemId}", trash.DeleteItem()).Methods("DELETE")
+
+	V2(router.PathPrefix("/v2").Subrouter(), v2.New(lib, bin, auth, images, books, limits))
+}
+
+// V2 registers the resource-oriented API. Everything about a book lives
+// under /v2/books/{bookId}.
+func V2(router *mux.Router, api *v2.API) {
+	router.NotFoundHandler = api.NotFound()
+	router.MethodNotAllowedHandler = api.MethodNotAllowed()
+
+	router.HandleFunc("/sessions", api.CreateSession()).Methods("POST")
+	router.HandleFunc("/users", api.CreateUser()).Methods("POST")
+	router.HandleFunc("/users/{userId}", api.GetUser()).Methods("GET")
+	router.HandleFunc("/users/{userId}", api.UpdateUser()).Methods("PUT")
+	router.HandleFunc("/users/{userId}", api.DeleteUser()).Methods("DELETE")
+
+	router.HandleFunc("/books", api.ListBooks()).Methods("GET")
+	router.HandleFunc("/books", api.CreateBook()).Methods("POST")
+	router.HandleFunc("/books/{bookId}", api.GetBook()).Methods("GET")
+	router.HandleFunc("/books/{bookId}", api.UpdateBook()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}", api.DeleteBook()).Methods("DELETE")
+	router.HandleFunc("/books/{bookId}/cover", api.GetBookCover()).Methods("GET", "HEAD")
+	router.HandleFunc("/books/{bookId}/accessibility", api.Accessibility()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/export.docx", api.ExportDocx()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/export.epub", api.ExportEPUB()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/interior.pdf", api.ExportPDF()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/cover-wrap", api.CoverWrap()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/site.zip", api.ExportSite()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/onix.xml", api.ExportONIX()).Methods("GET")
+	router.HandleFunc("/onix.xml", api.ExportCatalog()).Methods("GET")
+
+	router.HandleFunc("/books/{bookId}/chapters", api.ListChapters()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/chapters", api.CreateChapter()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/chapters/{chapterId}", api.GetChapter()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/chapters/{chapterId}", api.UpdateChapter()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/chapters/{chapterId}", api.DeleteChapter()).Methods("DELETE")
+
+	router.HandleFunc("/books/{bookId}/notes", api.ListNotes()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/notes", api.CreateNote()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/notes/{noteId}", api.GetNote()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/notes/{noteId}", api.UpdateNote()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/notes/{noteId}", api.DeleteNote()).Methods("DELETE")
+
+	router.HandleFunc("/books/{bookId}/images", api.ListImages()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/images", api.CreateImage()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/images/{imageId}", api.GetImage()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/images/{imageId}", api.DeleteImage()).Methods("DELETE")
+	router.HandleFunc("/books/{bookId}/images/{imageId}/file", api.GetImageFile()).Methods("GET", "HEAD")
+	router.HandleFunc("/books/{bookId}/images/{imageId}/description", api.DescribeImage()).Methods("PUT")
+
+	router.HandleFunc("/books/{bookId}/versions", api.ListVersions()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/versions", api.CreateVersion()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/versions/{versionId}", api.GetVersion()).Methods("GET")
+
+	router.HandleFunc("/books/{bookId}/layout", api.SetLayout()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/pages", api.ListPages()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/pages", api.CreatePage()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/pages/order", api.ReorderPages()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/pages/{pageId}", api.GetPage()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/pages/{pageId}", api.UpdatePage()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/pages/{pageId}", api.DeletePage()).Methods("DELETE")
+
+	router.HandleFunc("/books/{bookId}/editions", api.ListEditions()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/editions", api.CreateEdition()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/editions/{editionId}", api.GetEdition()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/editions/{editionId}", api.UpdateEdition()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/editions/{editionId}", api.DeleteEdition()).Methods("DELETE")
+
+	router.HandleFunc("/books/{bookId}/series", api.GetBookSeries()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/back-matter", api.GetBackMatter()).Methods("GET")
+
+	router.HandleFunc("/omnibuses", api.CreateOmnibus()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/omnibus", api.GetOmnibus()).Methods("GET")
+	router.HandleFunc("/books/{bookId}/omnibus", api.UpdateOmnibus()).Methods("PUT")
+	router.HandleFunc("/books/{bookId}/omnibus/freeze", api.FreezeOmnibus()).Methods("POST")
+	router.HandleFunc("/books/{bookId}/omnibus/freeze", api.ThawOmnibus()).Methods("DELETE")
+
+	router.HandleFunc("/series", api.ListSeries()).Methods("GET")
+	router.HandleFunc("/series", api.CreateSeries()).Methods("POST")
+	router.HandleFunc("/series/{seriesId}", api.GetSeries()).Methods("GET")
+	router.HandleFunc("/series/{seriesId}", api.UpdateSeries()).Methods("PUT")
+	router.HandleFunc("/series/{seriesId}", api.DeleteSeries()).Methods("DELETE")
+	router.HandleFunc("/series/{seriesId}/books", api.ListSeriesBooks()).Methods("GET")
+
+	router.HandleFunc("/series/{seriesId}/notes", api.ListSeriesNotes()).Methods("GET")
+	router.HandleFunc("/series/{seriesId}/notes", api.CreateSeriesNote()).Methods("POST")
+	router.HandleFunc("/series/{seriesId}/notes/{noteId}", api.GetSeriesNote()).Methods("GET")
+	router.HandleFunc("/series/{seriesId}/notes/{noteId}", api.UpdateSeriesNote()).Methods("PUT")
+	router.HandleFunc("/series/{seriesId}/notes/{noteId}", api.DeleteSeriesNote()).Methods("DELETE")
+
+	router.HandleFunc("/trash", api.ListTrash()).Methods("GET")
+	router.HandleFunc("/trash/{kind}/{itemId}/restore", api.RestoreTrashItem()).Methods("POST")
+	router.HandleFunc("/trash/{kind}/{itemId}", api.DeleteTrashItem()).Methods("DELETE")
+}
+
+// Health registers the liveness and readiness probes
+func Health(router *mux.Router, health *health.Controller) {
+	router.HandleFunc("/healthz", health.Healthz()).Methods("GET", "HEAD")
+	router.HandleFunc("/readyz", health.Readyz()).Methods("GET", "HEAD")
 }
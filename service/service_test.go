@@ -0,0 +1,561 @@
+package routes_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/programmingbunny/epub-backend/models"
+	"github.com/programmingbunny/epub-backend/service/servicetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newServer serves the routes from an in-memory store
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(servicetest.Handler(t))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// call sends body as JSON and decodes the response into out, if given,
+// returning the status code
+func call(t *testing.T, server *httptest.Server, method, path string, body, out interface{}) int {
+	t.Helper()
+	return callAs(t, server, "", method, path, body, out)
+}
+
+// callAs is call signed in with token
+func callAs(t *testing.T, server *httptest.Server, token, method, path string, body, out interface{}) int {
+	t.Helper()
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, server.URL+path, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding the response: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+// page is a v1 list response
+type page struct {
+	Data struct {
+		Data struct {
+			Items []json.RawMessage `json:"items"`
+		} `json:"data"`
+	} `json:"data"`
+}
+
+func createBook(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	var id string
+	book := models.Book{Title: "The Cats", Subtitle: "A tale", Author: "Ann Lee"}
+	if status := call(t, server, "POST", "/createBook", book, &id); status != http.StatusOK {
+		t.Fatalf("createBook: status %d", status)
+	}
+	return id
+}
+
+func createChapter(t *testing.T, server *httptest.Server, bookID string, number int) string {
+	t.Helper()
+	bookObjectID, _ := primitive.ObjectIDFromHex(bookID)
+	chapter := models.Chapter{Title: "Chapter", ChapterNum: number, Text: "<p>Hello</p>", BookID: bookObjectID}
+	var created struct {
+		Data struct {
+			Data struct {
+				InsertedID string `json:"InsertedID"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	if status := call(t, server, "POST", "/createChapter", chapter, &created); status != http.StatusCreated {
+		t.Fatalf("createChapter: status %d", status)
+	}
+	return created.Data.Data.InsertedID
+}
+
+func TestBookRoundTrip(t *testing.T) {
+	server := newServer(t)
+	id := createBook(t, server)
+
+	var book models.Book
+	if status := call(t, server, "GET", "/book/"+id, nil, &book); status != http.StatusOK {
+		t.Fatalf("getBook: status %d", status)
+	}
+	if book.Title != "The Cats" || book.Visibility != models.VisibilityPublic {
+		t.Errorf("getBook: got %+v", book)
+	}
+
+	var list page
+	if status := call(t, server, "GET", "/getBooks", nil, &list); status != http.StatusOK {
+		t.Fatalf("getBooks: status %d", status)
+	}
+	if len(list.Data.Data.Items) != 1 {
+		t.Errorf("getBooks: got %d books, want one", len(list.Data.Data.Items))
+	}
+}
+
+func TestSelectsFields(t *testing.T) {
+	server := newServer(t)
+	createBook(t, server)
+
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if status := call(t, server, "GET", "/v2/books?fields=title,id", nil, &list); status != http.StatusOK {
+		t.Fatalf("selecting fields: status %d", status)
+	}
+	if len(list.Items) != 1 || len(list.Items[0]) != 2 || list.Items[0]["title"] != "The Cats" || list.Items[0]["_id"] == nil {
+		t.Errorf("got %v, want only the title and _id", list.Items)
+	}
+	for _, fields := range []string{"bogus", "created", "title,"} {
+		if status := call(t, server, "GET", "/v2/books?fields="+fields, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("fields=%s: status %d, want 400", fields, status)
+		}
+	}
+}
+
+func TestMissingBookIsNotFound(t *testing.T) {
+	server := newServer(t)
+	if status := call(t, server, "GET", "/book/"+primitive.NewObjectID().Hex(), nil, nil); status != http.StatusNotFound {
+		t.Errorf("got status %d, want 404", status)
+	}
+}
+
+func TestChapters(t *testing.T) {
+	server := newServer(t)
+	bookID := createBook(t, server)
+	first := createChapter(t, server, bookID, 1)
+	createChapter(t, server, bookID, 2)
+
+	var list page
+	if status := call(t, server, "GET", "/getChapters/"+bookID, nil, &list); status != http.StatusOK {
+		t.Fatalf("getChapters: status %d", status)
+	}
+	if len(list.Data.Data.Items) != 2 {
+		t.Fatalf("getChapters: got %d chapters, want two", len(list.Data.Data.Items))
+	}
+
+	// chapter 2 is taken
+	renumbered := models.Chapter{Title: "Renumbered", ChapterNum: 2}
+	if status := call(t, server, "PUT", "/updateChapter/"+bookID+"/"+first, renumbered, nil); status != http.StatusConflict {
+		t.Errorf("updateChapter to a taken number: status %d, want 409", status)
+	}
+	renamed := models.Chapter{Title: "Renamed", ChapterNum: 1}
+	if status := call(t, server, "PUT", "/updateChapter/"+bookID+"/"+first, renamed, nil); status != http.StatusOK {
+		t.Errorf("updateChapter: status %d", status)
+	}
+	var chapter models.Chapter
+	call(t, server, "GET", "/getChapter/"+first, nil, &chapter)
+	if chapter.Title != "Renamed" {
+		t.Errorf("getChapter: title %q, want Renamed", chapter.Title)
+	}
+
+	if status := call(t, server, "DELETE", "/deleteChapter/"+first, nil, nil); status != http.StatusOK {
+		t.Fatalf("deleteChapter: status %d", status)
+	}
+	if status := call(t, server, "GET", "/getChapter/"+first, nil, nil); status != http.StatusNotFound {
+		t.Errorf("getChapter after delete: status %d, want 404", status)
+	}
+	if status := call(t, server, "DELETE", "/deleteChapter/"+first, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleteChapter twice: status %d, want 404", status)
+	}
+}
+
+func TestInvalidBookBody(t *testing.T) {
+	server := newServer(t)
+	if status := call(t, server, "POST", "/createBook", map[string]string{"title": "No author"}, nil); status != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400", status)
+	}
+}
+
+// signUp creates a user and returns a token for them
+func signUp(t *testing.T, server *httptest.Server, email string) string {
+	t.Helper()
+	user := map[string]string{"email": email, "firstName": "Ann", "lastName": "Lee", "password": "secret"}
+	if status := call(t, server, "POST", "/v2/users", user, nil); status != http.StatusCreated {
+		t.Fatalf("creating %s: status %d", email, status)
+	}
+	var session struct {
+		Token string `json:"token"`
+	}
+	credentials := map[string]string{"email": email, "password": "secret"}
+	if status := call(t, server, "POST", "/v2/sessions", credentials, &session); status != http.StatusCreated {
+		t.Fatalf("signing in %s: status %d", email, status)
+	}
+	return session.Token
+}
+
+func TestOnlyOwnersChangeBooks(t *testing.T) {
+	server := newServer(t)
+	owner := signUp(t, server, "owner@example.com")
+	other := signUp(t, server, "other@example.com")
+
+	var book struct {
+		ID string `json:"_id"`
+	}
+	input := map[string]string{"title": "The Cats", "subtitle": "A tale", "author": "Ann Lee"}
+	if status := callAs(t, server, owner, "POST", "/v2/books", input, &book); status != http.StatusCreated {
+		t.Fatalf("creating the book: status %d", status)
+	}
+	chapter := map[string]interface{}{"title": "One", "chapterNum": 1, "text": "<p>Hello</p>"}
+
+	for _, token := range []string{other, ""} {
+		if status := callAs(t, server, token, "GET", "/v2/books/"+book.ID, nil, nil); status != http.StatusOK {
+			t.Errorf("reading a public book: status %d", status)
+		}
+		if status := callAs(t, server, token, "PUT", "/v2/books/"+book.ID, input, nil); status != http.StatusForbidden {
+			t.Errorf("updating someone else's book: status %d, want 403", status)
+		}
+		if status := callAs(t, server, token, "POST", "/v2/books/"+book.ID+"/chapters", chapter, nil); status != http.StatusForbidden {
+			t.Errorf("adding a chapter to someone else's book: status %d, want 403", status)
+		}
+		if status := callAs(t, server, token, "DELETE", "/v2/books/"+book.ID, nil, nil); status != http.StatusForbidden {
+			t.Errorf("trashing someone else's book: status %d, want 403", status)
+		}
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+book.ID+"/chapters", chapter, nil); status != http.StatusCreated {
+		t.Errorf("adding a chapter to one's own book: status %d", status)
+	}
+}
+
+func TestOwnerlessBooksAreLeftToV1(t *testing.T) {
+	server := newServer(t)
+	token := signUp(t, server, "owner@example.com")
+
+	input := map[string]string{"title": "The Cats", "subtitle": "A tale", "author": "Ann Lee"}
+	if status := callAs(t, server, "", "POST", "/v2/books", input, nil); status != http.StatusUnauthorized {
+		t.Errorf("creating a book anonymously: status %d, want 401", status)
+	}
+
+	// books made on v1 have no owner
+	id := createBook(t, server)
+	for _, token := range []string{token, ""} {
+		if status := callAs(t, server, token, "GET", "/v2/books/"+id, nil, nil); status != http.StatusOK {
+			t.Errorf("reading an ownerless book: status %d", status)
+		}
+		if status := callAs(t, server, token, "PUT", "/v2/books/"+id, input, nil); status != http.StatusForbidden {
+			t.Errorf("updating an ownerless book on v2: status %d, want 403", status)
+		}
+	}
+	createChapter(t, server, id, 1)
+}
+
+func TestExportsReflowableEPUB(t *testing.T) {
+	server := newServer(t)
+	owner := signUp(t, server, "owner@example.com")
+
+	var book struct {
+		ID string `json:"_id"`
+	}
+	input := map[string]string{"title": "The Cats", "subtitle": "A tale", "author": "Ann Lee", "language": "en"}
+	if status := callAs(t, server, owner, "POST", "/v2/books", input, &book); status != http.StatusCreated {
+		t.Fatalf("creating the book: status %d", status)
+	}
+	chapter := map[string]interface{}{"title": "One", "chapterNum": 1, "text": `<p onclick="x()">Hello<br>there<script>alert(1)</script></p><img src="https://example.com/a.png">`}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+book.ID+"/chapters", chapter, nil); status != http.StatusCreated {
+		t.Fatalf("creating the chapter: status %d", status)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/v2/books/"+book.ID+"/export.epub", nil)
+	req.Header.Set("Authorization", "Bearer "+owner)
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	content, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("exporting: status %d: %s", res.StatusCode, content)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, _ := io.ReadAll(r)
+		r.Close()
+		files[file.Name] = string(read)
+		if strings.HasSuffix(file.Name, ".xhtml") || strings.HasSuffix(file.Name, ".opf") {
+			decoder := xml.NewDecoder(bytes.NewReader(read))
+			for {
+				if _, err := decoder.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("%s is not well-formed: %v", file.Name, err)
+				}
+			}
+		}
+	}
+	if archive.File[0].Name != "mimetype" {
+		t.Errorf("the first file is %s, want mimetype", archive.File[0].Name)
+	}
+	if opf := files["OEBPS/package.opf"]; !strings.Contains(opf, `<meta property="schema:accessMode">textual</meta>`) {
+		t.Errorf("the package document has no accessibility metadata:\n%s", opf)
+	}
+	text := files["OEBPS/text-2.xhtml"]
+	if !strings.Contains(text, "<p>Hello<br/>there</p>") || strings.Contains(text, "script") || strings.Contains(text, "example.com") {
+		t.Errorf("the chapter was not cleaned up:\n%s", text)
+	}
+
+	if status := call(t, server, "GET", "/v2/books/"+primitive.NewObjectID().Hex()+"/export.epub", nil, nil); status != http.StatusNotFound {
+		t.Errorf("exporting a missing book: status %d, want 404", status)
+	}
+}
+
+func TestISBNsBelongToOneEdition(t *testing.T) {
+	server := newServer(t)
+	owner := signUp(t, server, "owner@example.com")
+
+	newBook := func(title string) string {
+		var book struct {
+			ID string `json:"_id"`
+		}
+		input := map[string]string{"title": title, "subtitle": "A tale", "author": "Ann Lee"}
+		if status := callAs(t, server, owner, "POST", "/v2/books", input, &book); status != http.StatusCreated {
+			t.Fatalf("creating %s: status %d", title, status)
+		}
+		return book.ID
+	}
+	edition := func(name, isbn string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "format": "ebook", "identifiers": []map[string]string{{"type": "isbn13", "value": isbn}}}
+	}
+	first, second := newBook("The Cats"), newBook("The Dogs")
+
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+first+"/editions", edition("Ebook", "978-0-306-40615-7"), nil); status != http.StatusCreated {
+		t.Fatalf("creating the edition: status %d", status)
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+first+"/editions", edition("Again", "9780306406157"), nil); status != http.StatusConflict {
+		t.Errorf("reusing an ISBN in the same book: status %d, want 409", status)
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+second+"/editions", edition("Ebook", "9780306406157"), nil); status != http.StatusConflict {
+		t.Errorf("reusing an ISBN in another book: status %d, want 409", status)
+	}
+	var created struct {
+		ID string `json:"_id"`
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+second+"/editions", edition("Ebook", "9781861972712"), &created); status != http.StatusCreated {
+		t.Fatalf("creating an edition with its own ISBN: status %d", status)
+	}
+	if status := callAs(t, server, owner, "PUT", "/v2/books/"+second+"/editions/"+created.ID, edition("Ebook", "9780306406157"), nil); status != http.StatusConflict {
+		t.Errorf("changing to a taken ISBN: status %d, want 409", status)
+	}
+}
+
+func TestPrivateBooksStayOutOfTheSeries(t *testing.T) {
+	server := newServer(t)
+	owner := signUp(t, server, "owner@example.com")
+	other := signUp(t, server, "other@example.com")
+
+	newBook := func(title, visibility string) string {
+		var book struct {
+			ID string `json:"_id"`
+		}
+		input := map[string]string{"title": title, "subtitle": "A tale", "author": "Ann Lee", "visibility": visibility}
+		if status := callAs(t, server, owner, "POST", "/v2/books", input, &book); status != http.StatusCreated {
+			t.Fatalf("creating %s: status %d", title, status)
+		}
+		return book.ID
+	}
+	first, second, third := newBook("The Cats", "public"), newBook("The Secret", "private"), newBook("The Dogs", "public")
+	newBook("The Diary", "private")
+
+	var series, note struct {
+		ID string `json:"_id"`
+	}
+	input := map[string]interface{}{"name": "Pets", "books": []string{first, second, third}}
+	if status := callAs(t, server, owner, "POST", "/v2/series", input, &series); status != http.StatusCreated {
+		t.Fatalf("creating the series: status %d", status)
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/series/"+series.ID+"/notes", map[string]string{"title": "Cast"}, &note); status != http.StatusCreated {
+		t.Fatalf("creating the series note: status %d", status)
+	}
+
+	var matter struct {
+		Items []models.Chapter `json:"items"`
+	}
+	if status := callAs(t, server, other, "GET", "/v2/books/"+first+"/back-matter", nil, &matter); status != http.StatusOK {
+		t.Fatalf("reading the back matter: status %d", status)
+	}
+	if len(matter.Items) != 2 || !strings.Contains(matter.Items[0].Text, "The Dogs") {
+		t.Fatalf("back matter: got %+v, want the next public book and the author's others", matter.Items)
+	}
+	for _, page := range matter.Items {
+		if strings.Contains(page.Text, "The Secret") || strings.Contains(page.Text, "The Diary") {
+			t.Errorf("back matter %q lists a private book: %s", page.Title, page.Text)
+		}
+	}
+
+	for token, want := range map[string]int{owner: 1, other: 0, "": 0} {
+		var notes struct {
+			Items []models.Notes `json:"items"`
+		}
+		if status := callAs(t, server, token, "GET", "/v2/books/"+first+"/notes", nil, &notes); status != http.StatusOK {
+			t.Fatalf("listing the notes: status %d", status)
+		}
+		if len(notes.Items) != want {
+			t.Errorf("listing the notes: got %d, want %d", len(notes.Items), want)
+		}
+	}
+	if status := callAs(t, server, other, "GET", "/v2/books/"+first+"/notes/"+note.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("reading someone else's series note: status %d, want 404", status)
+	}
+}
+
+func TestFrozenPartsStayOutOfTheOmnibusChapters(t *testing.T) {
+	server := newServer(t)
+	owner := signUp(t, server, "owner@example.com")
+
+	var book, version, omnibus, other struct {
+		ID string `json:"_id"`
+	}
+	input := map[string]string{"title": "The Cats", "subtitle": "A tale", "author": "Ann Lee"}
+	if status := callAs(t, server, owner, "POST", "/v2/books", input, &book); status != http.StatusCreated {
+		t.Fatalf("creating the book: status %d", status)
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+book.ID+"/versions", map[string]string{"type": "draft"}, &version); status != http.StatusCreated {
+		t.Fatalf("creating the version: status %d", status)
+	}
+	first := map[string]interface{}{"title": "One", "chapterNum": 1}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+book.ID+"/chapters", first, nil); status != http.StatusCreated {
+		t.Fatalf("creating the chapter: status %d", status)
+	}
+	// the draft's first chapter shares its number with the book's
+	draft := map[string]interface{}{"title": "Draft one", "chapterNum": 1, "versionID": version.ID}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+book.ID+"/chapters", draft, &other); status != http.StatusCreated {
+		t.Fatalf("creating the draft chapter: status %d", status)
+	}
+	if status := callAs(t, server, owner, "PUT", "/v2/books/"+book.ID+"/chapters/"+other.ID, draft, nil); status != http.StatusOK {
+		t.Fatalf("renumbering the draft chapter: status %d", status)
+	}
+
+	parts := map[string]interface{}{"title": "The Collection", "subtitle": "Both tales", "author": "Ann Lee", "parts": []map[string]string{{"bookID": book.ID}}}
+	if status := callAs(t, server, owner, "POST", "/v2/omnibuses", parts, &omnibus); status != http.StatusCreated {
+		t.Fatalf("creating the omnibus: status %d", status)
+	}
+	foreword := map[string]interface{}{"title": "Foreword", "chapterNum": 1}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+omnibus.ID+"/chapters", foreword, nil); status != http.StatusCreated {
+		t.Fatalf("creating the foreword: status %d", status)
+	}
+	for i := 0; i < 2; i++ {
+		if status := callAs(t, server, owner, "POST", "/v2/books/"+omnibus.ID+"/omnibus/freeze", nil, nil); status != http.StatusOK {
+			t.Fatalf("freezing: status %d", status)
+		}
+	}
+
+	var contents struct {
+		Parts []struct {
+			Chapters []models.Chapter `json:"chapters"`
+		} `json:"parts"`
+	}
+	if status := callAs(t, server, owner, "GET", "/v2/books/"+omnibus.ID+"/omnibus", nil, &contents); status != http.StatusOK {
+		t.Fatalf("reading the contents: status %d", status)
+	}
+	if len(contents.Parts) != 1 || len(contents.Parts[0].Chapters) != 2 {
+		t.Fatalf("got contents %+v, want one part with both chapters", contents)
+	}
+	copied := contents.Parts[0].Chapters
+	if copied[0].ChapterNum != 1 || copied[1].ChapterNum != 2 {
+		t.Errorf("the copies are numbered %d and %d, want 1 and 2", copied[0].ChapterNum, copied[1].ChapterNum)
+	}
+
+	var list struct {
+		Items []models.Chapter `json:"items"`
+	}
+	if status := callAs(t, server, owner, "GET", "/v2/books/"+omnibus.ID+"/chapters", nil, &list); status != http.StatusOK {
+		t.Fatalf("listing the chapters: status %d", status)
+	}
+	if len(list.Items) != 1 || list.Items[0].Title != "Foreword" {
+		t.Errorf("got chapters %+v, want only the foreword", list.Items)
+	}
+	var v1 page
+	call(t, server, "GET", "/getChapters/"+omnibus.ID, nil, &v1)
+	if len(v1.Data.Data.Items) != 1 {
+		t.Errorf("getChapters: got %d chapters, want only the foreword", len(v1.Data.Data.Items))
+	}
+
+	path := "/v2/books/" + omnibus.ID + "/chapters/" + copied[0].ID.Hex()
+	if status := callAs(t, server, owner, "GET", path, nil, nil); status != http.StatusNotFound {
+		t.Errorf("reading a copy: status %d, want 404", status)
+	}
+	if status := callAs(t, server, owner, "PUT", path, foreword, nil); status != http.StatusNotFound {
+		t.Errorf("changing a copy: status %d, want 404", status)
+	}
+	if status := callAs(t, server, owner, "DELETE", path, nil, nil); status != http.StatusNotFound {
+		t.Errorf("trashing a copy: status %d, want 404", status)
+	}
+}
+
+func TestFrozenPartsOutliveTheirBooks(t *testing.T) {
+	server := newServer(t)
+	owner := signUp(t, server, "owner@example.com")
+
+	var book, omnibus struct {
+		ID string `json:"_id"`
+	}
+	input := map[string]string{"title": "The Cats", "subtitle": "A tale", "author": "Ann Lee"}
+	if status := callAs(t, server, owner, "POST", "/v2/books", input, &book); status != http.StatusCreated {
+		t.Fatalf("creating the book: status %d", status)
+	}
+	chapter := map[string]interface{}{"title": "One", "chapterNum": 1, "text": "<p>Hello</p>"}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+book.ID+"/chapters", chapter, nil); status != http.StatusCreated {
+		t.Fatalf("creating the chapter: status %d", status)
+	}
+	parts := map[string]interface{}{"title": "The Collection", "subtitle": "One tale", "author": "Ann Lee", "parts": []map[string]string{{"bookID": book.ID}}}
+	if status := callAs(t, server, owner, "POST", "/v2/omnibuses", parts, &omnibus); status != http.StatusCreated {
+		t.Fatalf("creating the omnibus: status %d", status)
+	}
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+omnibus.ID+"/omnibus/freeze", nil, nil); status != http.StatusOK {
+		t.Fatalf("freezing: status %d", status)
+	}
+	if status := callAs(t, server, owner, "DELETE", "/v2/books/"+book.ID, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("trashing the book: status %d", status)
+	}
+	// the part's book is gone, so its copies are kept as they are
+	if status := callAs(t, server, owner, "POST", "/v2/books/"+omnibus.ID+"/omnibus/freeze", nil, nil); status != http.StatusOK {
+		t.Fatalf("freezing again: status %d", status)
+	}
+
+	var contents struct {
+		Parts []struct {
+			Title    string           `json:"title"`
+			Chapters []models.Chapter `json:"chapters"`
+		} `json:"parts"`
+	}
+	if status := callAs(t, server, owner, "GET", "/v2/books/"+omnibus.ID+"/omnibus", nil, &contents); status != http.StatusOK {
+		t.Fatalf("reading the contents: status %d", status)
+	}
+	if len(contents.Parts) != 1 || contents.Parts[0].Title != "The Cats" || len(contents.Parts[0].Chapters) != 1 {
+		t.Errorf("got contents %+v, want the part as it was frozen", contents)
+	}
+}
@@ -0,0 +1,34 @@
+// Package servicetest serves the API from an in-memory store for tests of
+// the routes and of their clients.
+package servicetest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/programmingbunny/epub-backend/configs"
+	"github.com/programmingbunny/epub-backend/db/memory"
+	"github.com/programmingbunny/epub-backend/deletion"
+	"github.com/programmingbunny/epub-backend/middleware"
+	routes "github.com/programmingbunny/epub-backend/service"
+	"github.com/programmingbunny/epub-backend/storage"
+	"github.com/programmingbunny/epub-backend/trash"
+)
+
+// Handler serves the routes from an empty in-memory store, with files kept
+// in a directory removed when the test ends
+func Handler(t testing.TB) http.Handler {
+	t.Helper()
+	auth, err := middleware.NewAuth("test-secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := memory.NewStore()
+	blobs := storage.NewBlobStore(t.TempDir())
+	bin := trash.New(store, deletion.New(store, blobs, 0), time.Hour)
+	router := mux.NewRouter()
+	routes.Routes(router, store, blobs, bin, auth, configs.Default().Limits)
+	return middleware.Recover(router)
+}
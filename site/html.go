@@ -0,0 +1,212 @@
+package site
+
+import (
+	"html/template"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// excerptLength is roughly how many characters of a chapter describe it
+// in link previews and the feed
+const excerptLength = 200
+
+// chapterHTML renders a chapter's stored HTML for a page, with its images
+// copied into the site. Images on other websites are linked as they are.
+// Only the elements and attributes of book text are kept, so nothing in
+// a chapter can run on the site.
+func (b *builder) chapterHTML(src string) template.HTML {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(src))
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	b.clean(body)
+	var out strings.Builder
+	for n := body.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&out, n); err != nil {
+			return template.HTML(template.HTMLEscapeString(src))
+		}
+	}
+	return template.HTML(out.String())
+}
+
+// allowed are the elements kept in chapters, with the attributes each may
+// have besides the global ones
+var allowed = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Section: nil, atom.Aside: nil, atom.Header: nil, atom.Footer: nil,
+	atom.Em: nil, atom.Strong: nil, atom.B: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Sub: nil, atom.Sup: nil, atom.Small: nil, atom.Mark: nil, atom.Abbr: nil,
+	atom.Code: nil, atom.Pre: nil, atom.Kbd: nil, atom.Samp: nil, atom.Var: nil, atom.Wbr: nil,
+	atom.Ruby: nil, atom.Rt: nil, atom.Rp: nil, atom.Cite: nil, atom.Dfn: nil,
+	atom.Blockquote: {"cite"}, atom.Q: {"cite"}, atom.Time: {"datetime"},
+	atom.Del: {"cite", "datetime"}, atom.Ins: {"cite", "datetime"},
+	atom.Ul: nil, atom.Ol: {"start", "reversed", "type"}, atom.Li: {"value"},
+	atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.A: {"href", "rel"}, atom.Img: {"src", "alt", "width", "height"},
+	atom.Figure: nil, atom.Figcaption: nil,
+	atom.Table: nil, atom.Caption: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil, atom.Tr: nil,
+	atom.Th: {"colspan", "rowspan", "scope"}, atom.Td: {"colspan", "rowspan"},
+	atom.Colgroup: {"span"}, atom.Col: {"span"},
+}
+
+// globalAttributes are kept on every allowed element
+var globalAttributes = []string{"class", "id", "title", "lang", "dir"}
+
+// dropped are elements left out with everything in them. Other elements
+// that aren't allowed are left out but their content is kept.
+var dropped = map[atom.Atom]bool{
+	atom.Script: true, atom.Noscript: true, atom.Style: true, atom.Template: true, atom.Title: true,
+	atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true, atom.Embed: true,
+	atom.Applet: true, atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Svg: true, atom.Math: true, atom.Audio: true, atom.Video: true,
+}
+
+// linkSchemes are the URL schemes links and images may use; the empty
+// scheme is a link within the site
+var linkSchemes = map[string]bool{"": true, "http": true, "https": true, "mailto": true}
+
+// clean keeps the allowed elements and attributes under n and points
+// images at their copies in the site
+func (b *builder) clean(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.TextNode:
+		case c.Type != html.ElementNode, c.Namespace != "", dropped[c.DataAtom]:
+			n.RemoveChild(c)
+		case !allowedElement(c):
+			// its children take its place and are cleaned in turn
+			if c.FirstChild != nil {
+				next = c.FirstChild
+			}
+			for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+				c.RemoveChild(gc)
+				n.InsertBefore(gc, c)
+			}
+			n.RemoveChild(c)
+		default:
+			c.Attr = allowedAttributes(c)
+			if c.DataAtom == atom.Img && !b.image(c) {
+				n.RemoveChild(c)
+				break
+			}
+			b.clean(c)
+		}
+		c = next
+	}
+}
+
+func allowedElement(n *html.Node) bool {
+	_, ok := allowed[n.DataAtom]
+	return ok
+}
+
+// allowedAttributes are n's attributes the element may have, leaving out
+// links to scripts and other schemes
+func allowedAttributes(n *html.Node) []html.Attribute {
+	var kept []html.Attribute
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !contains(globalAttributes, key) && !contains(allowed[n.DataAtom], key) {
+			continue
+		}
+		if key == "href" || key == "src" || key == "cite" {
+			u, err := url.Parse(strings.TrimSpace(a.Val))
+			if err != nil || !linkSchemes[strings.ToLower(u.Scheme)] {
+				continue
+			}
+		}
+		kept = append(kept, html.Attribute{Key: key, Val: a.Val})
+	}
+	return kept
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *builder) image(n *html.Node) bool {
+	for i, a := range n.Attr {
+		if a.Key != "src" {
+			continue
+		}
+		if u, err := url.Parse(a.Val); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return true
+		}
+		if b.site.Image == nil {
+			return false
+		}
+		name := b.asset(b.site.Image(a.Val))
+		n.Attr[i].Val = name
+		return name != ""
+	}
+	return false
+}
+
+// excerpt is the start of a chapter's text, cut at a word
+func excerpt(src string) string {
+	text := src
+	if doc, err := html.Parse(strings.NewReader(src)); err == nil {
+		var sb strings.Builder
+		textOf(doc, &sb)
+		text = sb.String()
+	}
+	words := strings.Fields(text)
+	var out strings.Builder
+	for _, word := range words {
+		if out.Len()+len(word) > excerptLength {
+			return out.String() + "…"
+		}
+		if out.Len() > 0 {
+			out.WriteByte(' ')
+		}
+		out.WriteString(word)
+	}
+	return out.String()
+}
+
+// textOf writes the text under n, with blocks kept apart by spaces and
+// footnotes left out
+func textOf(n *html.Node, sb *strings.Builder) {
+	switch {
+	case n.Type == html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case n.Type == html.ElementNode && (n.DataAtom == atom.Sup || n.DataAtom == atom.Script || n.DataAtom == atom.Style):
+		return
+	case n.Type == html.ElementNode && n.DataAtom == atom.Div && hasClass(n, "footnotes"):
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		textOf(c, sb)
+	}
+	if n.Type == html.ElementNode && n.DataAtom != atom.Em && n.DataAtom != atom.Strong && n.DataAtom != atom.A && n.DataAtom != atom.Span {
+		sb.WriteByte(' ')
+	}
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, a := range n.Attr {
+		if a.Key == "class" {
+			for _, c := range strings.Fields(a.Val) {
+				if c == class {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
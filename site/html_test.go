@@ -0,0 +1,25 @@
+package site
+
+import "testing"
+
+func TestChapterHTMLKeepsOnlyBookText(t *testing.T) {
+	b := &builder{site: &Site{}, assets: map[string]string{}}
+	for _, c := range []struct{ src, want string }{
+		{`<p class="x">Hello <em>there</em></p>`, `<p class="x">Hello <em>there</em></p>`},
+		{`<p>Hi<script>alert(1)</script></p>`, `<p>Hi</p>`},
+		{`<p onclick="alert(1)" style="color:red">Hi</p>`, `<p>Hi</p>`},
+		{`<a href="javascript:alert(1)">Hi</a>`, `<a>Hi</a>`},
+		{`<a href=" JavaScript:alert(1)">Hi</a>`, `<a>Hi</a>`},
+		{`<a href="https://example.com/" target="_blank">Hi</a>`, `<a href="https://example.com/">Hi</a>`},
+		{`<a href="#fn1">1</a>`, `<a href="#fn1">1</a>`},
+		{`<font color="red"><b>Hi</b></font>`, `<b>Hi</b>`},
+		{`<iframe src="https://example.com/"></iframe><p>Hi</p>`, `<p>Hi</p>`},
+		{`<svg><script>alert(1)</script></svg>`, ``},
+		{`<img src="https://example.com/a.png" onerror="alert(1)">`, `<img src="https://example.com/a.png"/>`},
+		{`<img src="cover.png">`, ``},
+	} {
+		if got := string(b.chapterHTML(c.src)); got != c.want {
+			t.Errorf("%s\ngot  %s\nwant %s", c.src, got, c.want)
+		}
+	}
+}
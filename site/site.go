@@ -0,0 +1,319 @@
+// Package site renders a book as a static website: an index page with the
+// cover and contents, a page per chapter and per part of an omnibus, a
+// stylesheet, a sitemap and an Atom feed of the chapters, packed in a zip
+// ready to upload to any web host.
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/models"
+)
+
+// ContentType is the media type of the exported sites
+const ContentType = "application/zip"
+
+//go:embed templates style.css
+var files embed.FS
+
+var templates = template.Must(template.ParseFS(files, "templates/*"))
+
+// Site is a book and what it takes to publish it
+type Site struct {
+	// BaseURL is the absolute URL the site will be published at. Links
+	// between pages are relative, but Open Graph, the sitemap and the feed
+	// need full URLs.
+	BaseURL  string
+	Book     models.Book
+	Chapters []models.Chapter
+	// Parts are the books an omnibus is made of, after its own chapters.
+	// Each has a title page and its chapters nested under it in the
+	// contents.
+	Parts []Part
+	// BackMatter are unnumbered pages after the last chapter. They are in
+	// the contents and sitemap but not in the feed.
+	BackMatter []models.Chapter
+	// Cover is the book's cover image, if any
+	Cover []byte
+	// Headers are the images shown above chapters, by chapter number
+	Headers map[int][]byte
+	// HeaderAlt is the alt text of the header images, by chapter number;
+	// headers without it are decorative
+	HeaderAlt map[int]string
+	// Image returns the content of an image shown in chapter text by its
+	// src, or nil to leave the image out
+	Image func(src string) []byte
+}
+
+// Part is a book of an omnibus
+type Part struct {
+	Title, Subtitle, Author string
+	Chapters                []models.Chapter
+	// Headers and HeaderAlt are the images shown above the chapters and
+	// their alt text, by the chapter's place in the part
+	Headers   [][]byte
+	HeaderAlt []string
+}
+
+// file is a file of the site by its path in the zip
+type file struct {
+	name    string
+	content []byte
+}
+
+// builder collects the files of a site
+type builder struct {
+	site  *Site
+	files []file
+	// assets are the names images were saved under, by content hash
+	assets map[string]string
+}
+
+// Write renders the site and writes it as a zip. Images that can't be read
+// or aren't JPEG, PNG, GIF or WebP are left out.
+func Write(w io.Writer, s *Site) error {
+	site := *s
+	if !strings.HasSuffix(site.BaseURL, "/") {
+		site.BaseURL += "/"
+	}
+	b := &builder{site: &site, assets: map[string]string{}}
+	if err := b.render(); err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for _, f := range b.files {
+		out, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(f.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// page is what the page templates are given
+type page struct {
+	Book  models.Book
+	Title string
+	// Type is the Open Graph type of the page
+	Type        string
+	URL         string
+	Description string
+	// Image is the absolute URL of the picture shared with the page
+	Image string
+	// Cover is the path of the cover image on the index page
+	Cover string
+	// Chapters are the pages in reading order and Contents the same pages
+	// with those of parts nested under them
+	Chapters   []*entry
+	Contents   []*entry
+	Chapter    *entry
+	Prev, Next *entry
+	Updated    string
+}
+
+// entry is a chapter or the title page of a part as it is linked, shown
+// and syndicated
+type entry struct {
+	Number    int
+	Title     string
+	Part      bool
+	Subtitle  string
+	Author    string
+	Children  []*entry
+	Path      string
+	URL       string
+	Summary   string
+	Header    string
+	HeaderAlt string
+	Content   template.HTML
+	Updated   string
+}
+
+func (b *builder) render() error {
+	s := b.site
+	index := &page{
+		Book:        s.Book,
+		Title:       s.Book.Title,
+		Type:        "book",
+		URL:         s.BaseURL,
+		Description: s.Book.Subtitle,
+		Cover:       b.asset(s.Cover),
+	}
+	if index.Cover != "" {
+		index.Image = s.BaseURL + index.Cover
+	}
+
+	updated := s.Book.ID.Timestamp()
+	// chapter returns the entry of a chapter at path, noting it for the
+	// feed
+	var feed []*entry
+	chapter := func(chapter models.Chapter, path string, header []byte, alt string) *entry {
+		created := chapter.ID.Timestamp()
+		if created.After(updated) {
+			updated = created
+		}
+		e := &entry{
+			Number:    chapter.ChapterNum,
+			Title:     chapter.Title,
+			Path:      path,
+			URL:       s.BaseURL + path,
+			Summary:   excerpt(chapter.Text),
+			Header:    b.asset(header),
+			HeaderAlt: alt,
+			Content:   b.chapterHTML(chapter.Text),
+			Updated:   created.UTC().Format(time.RFC3339),
+		}
+		feed = append(feed, e)
+		return e
+	}
+	for _, c := range s.Chapters {
+		e := chapter(c, fmt.Sprintf("chapter-%d.html", c.ChapterNum), s.Headers[c.ChapterNum], s.HeaderAlt[c.ChapterNum])
+		index.Chapters = append(index.Chapters, e)
+		index.Contents = append(index.Contents, e)
+	}
+	var parts []*entry
+	for i, part := range s.Parts {
+		path := fmt.Sprintf("part-%d.html", i+1)
+		p := &entry{Title: part.Title, Part: true, Subtitle: part.Subtitle, Author: part.Author, Path: path, URL: s.BaseURL + path, Summary: part.Subtitle}
+		parts = append(parts, p)
+		index.Chapters = append(index.Chapters, p)
+		index.Contents = append(index.Contents, p)
+		for j, c := range part.Chapters {
+			var header []byte
+			var alt string
+			if j < len(part.Headers) {
+				header = part.Headers[j]
+			}
+			if j < len(part.HeaderAlt) {
+				alt = part.HeaderAlt[j]
+			}
+			e := chapter(c, fmt.Sprintf("part-%d-%d.html", i+1, j+1), header, alt)
+			p.Children = append(p.Children, e)
+			index.Chapters = append(index.Chapters, e)
+		}
+	}
+	index.Updated = updated.UTC().Format(time.RFC3339)
+	for _, p := range parts {
+		p.Updated = index.Updated
+	}
+	for i, back := range s.BackMatter {
+		path := fmt.Sprintf("back-%d.html", i+1)
+		e := &entry{
+			Title:   back.Title,
+			Path:    path,
+			URL:     s.BaseURL + path,
+			Summary: excerpt(back.Text),
+			Content: b.chapterHTML(back.Text),
+			Updated: index.Updated,
+		}
+		index.Chapters = append(index.Chapters, e)
+		index.Contents = append(index.Contents, e)
+	}
+
+	if err := b.execute("index.html", "index.html", index); err != nil {
+		return err
+	}
+	for i, chapter := range index.Chapters {
+		p := &page{
+			Book:        s.Book,
+			Title:       chapter.Title + " · " + s.Book.Title,
+			Type:        "article",
+			URL:         chapter.URL,
+			Description: chapter.Summary,
+			Image:       index.Image,
+			Chapter:     chapter,
+		}
+		if chapter.Header != "" {
+			p.Image = s.BaseURL + chapter.Header
+		}
+		if i > 0 {
+			p.Prev = index.Chapters[i-1]
+		}
+		if i < len(index.Chapters)-1 {
+			p.Next = index.Chapters[i+1]
+		}
+		tmpl := "chapter.html"
+		if chapter.Part {
+			tmpl = "part.html"
+		}
+		if err := b.execute(chapter.Path, tmpl, p); err != nil {
+			return err
+		}
+	}
+
+	// the feed lists the newest chapters first
+	syndicated := *index
+	syndicated.Chapters = nil
+	for i := len(feed) - 1; i >= 0; i-- {
+		syndicated.Chapters = append(syndicated.Chapters, feed[i])
+	}
+	if err := b.execute("feed.xml", "feed.xml", &syndicated); err != nil {
+		return err
+	}
+	if err := b.execute("sitemap.xml", "sitemap.xml", index); err != nil {
+		return err
+	}
+
+	style, err := files.ReadFile("style.css")
+	if err != nil {
+		return err
+	}
+	b.files = append(b.files, file{"style.css", style})
+	return nil
+}
+
+// execute renders a template into a file of the site. XML files get their
+// declaration here, as html/template would escape it.
+func (b *builder) execute(name, tmpl string, p *page) error {
+	var out bytes.Buffer
+	if strings.HasSuffix(name, ".xml") {
+		out.WriteString(xml.Header)
+	}
+	if err := templates.ExecuteTemplate(&out, tmpl, p); err != nil {
+		return err
+	}
+	b.files = append(b.files, file{name, out.Bytes()})
+	return nil
+}
+
+// imageTypes are the image formats browsers show, by the extension they
+// are saved with
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// asset adds an image to the site and returns its path. The same image is
+// only saved once. Nothing is saved for content that isn't an image.
+func (b *builder) asset(content []byte) string {
+	ext, ok := imageTypes[http.DetectContentType(content)]
+	if len(content) == 0 || !ok {
+		return ""
+	}
+	sum := sha1.Sum(content)
+	hash := hex.EncodeToString(sum[:])
+	if name, ok := b.assets[hash]; ok {
+		return name
+	}
+	name := "images/" + hash[:16] + ext
+	b.assets[hash] = name
+	b.files = append(b.files, file{name, content})
+	return name
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Blob is a file stored under the SHA-256 of its content
+type Blob struct {
+	Hash        string
+	Size        int64
+	ContentType string
+	// Location is the forward-slashed path recorded on documents
+	Location string
+}
+
+// BlobStore keeps uploads content-addressed so identical files are stored once
+type BlobStore struct {
+	Root string
+}
+
+func NewBlobStore(root string) *BlobStore {
+	return &BlobStore{Root: root}
+}
+
+// Put stores the content of r and returns its blob. Storing content that is
+// already present leaves the existing file untouched.
+func (s *BlobStore) Put(r io.Reader) (Blob, error) {
+	if err := os.MkdirAll(s.Root, 0o755); err != nil {
+		return Blob{}, err
+	}
+	tmp, err := os.CreateTemp(s.Root, ".upload-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	sniff := &sniffWriter{}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, sniff), r)
+	if err != nil {
+		return Blob{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Blob{}, err
+	}
+
+	blob := Blob{
+		Hash:        hex.EncodeToString(hash.Sum(nil)),
+		Size:        size,
+		ContentType: http.DetectContentType(sniff.buf),
+	}
+	blob.Location = s.Location(blob.Hash)
+
+	dest := s.Path(blob.Hash)
+	if _, err := os.Stat(dest); err == nil {
+		// refresh the timestamp so a concurrent garbage collection treats it as new
+		now := time.Now()
+		os.Chtimes(dest, now, now)
+		return blob, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return Blob{}, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return Blob{}, err
+	}
+	return blob, nil
+}
+
+// Location returns the document location for a hash
+func (s *BlobStore) Location(hash string) string {
+	return path.Join(filepath.ToSlash(s.Root), hash[:2], hash)
+}
+
+// HashOf returns the hash of a location inside this store
+func (s *BlobStore) HashOf(location string) (string, bool) {
+	prefix := filepath.ToSlash(filepath.Clean(s.Root)) + "/"
+	location = path.Clean(location)
+	if !strings.HasPrefix(location, prefix) {
+		return "", false
+	}
+	hash := path.Base(location)
+	if len(hash) != sha256.Size*2 || location != s.Location(hash) {
+		return "", false
+	}
+	return hash, true
+}
+
+// Remove deletes a blob from disk
+func (s *BlobStore) Remove(hash string) error {
+	err := os.Remove(s.Path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// StoredFile is a file found while walking a storage directory
+type StoredFile struct {
+	Location string    `json:"location"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+}
+
+// Walk lists every blob in the store
+func (s *BlobStore) Walk(fn func(StoredFile) error) error {
+	return WalkDir(s.Root, func(f StoredFile) error {
+		if _, ok := s.HashOf(f.Location); !ok {
+			return nil
+		}
+		return fn(f)
+	})
+}
+
+// WalkDir lists the regular files under dir, skipping a missing dir
+func WalkDir(dir string, fn func(StoredFile) error) error {
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(StoredFile{Location: filepath.ToSlash(p), Size: info.Size(), ModTime: info.ModTime()})
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Path returns the file holding a blob
+func (s *BlobStore) Path(hash string) string {
+	return filepath.FromSlash(s.Location(hash))
+}
+
+// sniffWriter keeps the first 512 bytes for content type detection
+type sniffWriter struct {
+	buf []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if room := 512 - len(w.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		w.buf = append(w.buf, p[:room]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNoLocation is returned when a document has no uploaded file recorded
+var ErrNoLocation = errors.New("no file stored for this resource")
+
+// File describes an uploaded file on disk
+type File struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	SHA256  string
+}
+
+type digestKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// digests caches content hashes so repeated requests don't re-read the file
+var digests sync.Map
+
+// Open opens an uploaded file by the location recorded in the database.
+// The caller is responsible for closing the returned file.
+func Open(location string) (*os.File, File, error) {
+	if location == "" {
+		return nil, File{}, ErrNoLocation
+	}
+
+	path := filepath.Clean(filepath.FromSlash(location))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, File{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, File{}, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, File{}, os.ErrNotExist
+	}
+
+	file := File{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+	key := digestKey{path: path, size: file.Size, modTime: file.ModTime}
+	if sum, ok := digests.Load(key); ok {
+		file.SHA256 = sum.(string)
+		return f, file, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		f.Close()
+		return nil, File{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, File{}, err
+	}
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	digests.Store(key, file.SHA256)
+
+	return f, file, nil
+}
@@ -0,0 +1,440 @@
+// Package trash soft-deletes books, chapters, notes and images so authors
+// can restore them, and purges them once the retention period has passed.
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/programmingbunny/epub-backend/db"
+	"github.com/programmingbunny/epub-backend/deletion"
+	"github.com/programmingbunny/epub-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	KindBook    = "book"
+	KindChapter = "chapter"
+	KindNote    = "note"
+	KindImage   = "image"
+)
+
+var (
+	// ErrUnknownKind is returned for a kind other than the Kind constants
+	ErrUnknownKind = errors.New("unknown trash item kind")
+	// ErrForbidden is returned when someone other than the user who trashed
+	// an item, or the owner of its book, tries to restore or purge it
+	ErrForbidden = errors.New("not allowed to change this trash item")
+	// ErrBookTrashed is returned when restoring an item whose book is
+	// itself in the trash
+	ErrBookTrashed = errors.New("the item's book is in the trash, restore the book first")
+)
+
+// Item is one entry of a trash listing
+type Item struct {
+	Kind       string              `json:"kind"`
+	ID         primitive.ObjectID  `json:"id"`
+	BookID     primitive.ObjectID  `json:"bookID"`
+	Title      string              `json:"title,omitempty"`
+	ChapterNum int                 `json:"chapterNum,omitempty"`
+	DeletedAt  time.Time           `json:"deletedAt"`
+	DeletedBy  *primitive.ObjectID `json:"deletedBy,omitempty"`
+	// PurgeAt is when the item is deleted for good
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// PurgeReport summarizes a purge run
+type PurgeReport struct {
+	Books    int      `json:"books"`
+	Chapters int      `json:"chapters"`
+	Notes    int      `json:"notes"`
+	Images   int      `json:"images"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Service moves documents in and out of the trash
+type Service struct {
+	store     *db.Store
+	deleter   *deletion.Service
+	retention time.Duration
+}
+
+func New(store *db.Store, deleter *deletion.Service, retention time.Duration) *Service {
+	return &Service{store: store, deleter: deleter, retention: retention}
+}
+
+func mark(actor primitive.ObjectID) models.Trash {
+	now := time.Now().UTC()
+	trash := models.Trash{DeletedAt: &now}
+	if !actor.IsZero() {
+		trash.DeletedBy = &actor
+	}
+	return trash
+}
+
+// TrashBook trashes a book together with its chapters, notes and images.
+// Restoring the book brings back exactly those, not items trashed earlier.
+func (s *Service) TrashBook(ctx context.Context, id, actor primitive.ObjectID) error {
+	return s.atomically(ctx, func(ctx context.Context) error {
+		m := mark(actor)
+		if err := s.store.Books.Trash(ctx, id, m); err != nil {
+			return err
+		}
+		m.WithBook = true
+		if _, err := s.store.Chapters.TrashByBook(ctx, id, m); err != nil {
+			return err
+		}
+		if _, err := s.store.Notes.TrashByBook(ctx, id, m); err != nil {
+			return err
+		}
+		_, err := s.store.Images.TrashByBook(ctx, id, m)
+		return err
+	})
+}
+
+func (s *Service) TrashChapter(ctx context.Context, id, actor primitive.ObjectID) error {
+	return s.store.Chapters.Trash(ctx, id, mark(actor))
+}
+
+func (s *Service) TrashNote(ctx context.Context, id, actor primitive.ObjectID) error {
+	return s.store.Notes.Trash(ctx, id, mark(actor))
+}
+
+func (s *Service) TrashImage(ctx context.Context, id, actor primitive.ObjectID) error {
+	return s.store.Images.Trash(ctx, id, mark(actor))
+}
+
+// List returns what the user trashed, newest first. Items trashed along
+// with their book are listed through the book.
+func (s *Service) List(ctx context.Context, actor primitive.ObjectID) ([]Item, error) {
+	filter := db.TrashFilter{DeletedBy: actor}
+	items := []Item{}
+
+	books, err := s.store.Books.ListTrashed(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range books {
+		items = append(items, s.item(KindBook, book.ID, book.ID, book.Title, 0, book.Trash))
+	}
+	chapters, err := s.store.Chapters.ListTrashed(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, chapter := range chapters {
+		if !chapter.WithBook {
+			items = append(items, s.item(KindChapter, chapter.ID, chapter.BookID, chapter.Title, chapter.DeletedChapterNum, chapter.Trash))
+		}
+	}
+	notes, err := s.store.Notes.ListTrashed(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		if !note.WithBook {
+			items = append(items, s.item(KindNote, note.ID, note.BookID, note.Title, 0, note.Trash))
+		}
+	}
+	images, err := s.store.Images.ListTrashed(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		if !image.WithBook {
+			items = append(items, s.item(KindImage, image.ID, image.BookID, "", image.ChapterNum, image.Trash))
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+func (s *Service) item(kind string, id, bookID primitive.ObjectID, title string, chapterNum int, mark models.Trash) Item {
+	return Item{
+		Kind:       kind,
+		ID:         id,
+		BookID:     bookID,
+		Title:      title,
+		ChapterNum: chapterNum,
+		DeletedAt:  *mark.DeletedAt,
+		DeletedBy:  mark.DeletedBy,
+		PurgeAt:    mark.DeletedAt.Add(s.retention),
+	}
+}
+
+// Restore takes an item out of the trash. A chapter goes back to its old
+// number; when another chapter has taken it since, that chapter and the
+// ones after it move up by one.
+func (s *Service) Restore(ctx context.Context, kind string, id, actor primitive.ObjectID) error {
+	return s.atomically(ctx, func(ctx context.Context) error {
+		switch kind {
+		case KindBook:
+			book, err := s.store.Books.GetTrashed(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := s.allowed(ctx, book.Trash, book.ID, actor); err != nil {
+				return err
+			}
+			return s.restoreBook(ctx, *book)
+		case KindChapter:
+			chapter, err := s.store.Chapters.GetTrashed(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := s.restorable(ctx, chapter.Trash, chapter.BookID, actor); err != nil {
+				return err
+			}
+			return s.restoreChapter(ctx, *chapter)
+		case KindNote:
+			note, err := s.store.Notes.GetTrashed(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := s.restorable(ctx, note.Trash, note.BookID, actor); err != nil {
+				return err
+			}
+			return s.store.Notes.Restore(ctx, id)
+		case KindImage:
+			image, err := s.store.Images.GetTrashed(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := s.restorable(ctx, image.Trash, image.BookID, actor); err != nil {
+				return err
+			}
+			return s.store.Images.Restore(ctx, id)
+		}
+		return ErrUnknownKind
+	})
+}
+
+// restorable checks a book's child may come back on its own
+func (s *Service) restorable(ctx context.Context, mark models.Trash, bookID, actor primitive.ObjectID) error {
+	if mark.WithBook {
+		return ErrBookTrashed
+	}
+	if _, err := s.store.Books.GetTrashed(ctx, bookID); err == nil {
+		return ErrBookTrashed
+	}
+	return s.allowed(ctx, mark, bookID, actor)
+}
+
+// allowed lets the user who trashed an item, or the owner of its book,
+// change it
+func (s *Service) allowed(ctx context.Context, mark models.Trash, bookID, actor primitive.ObjectID) error {
+	if mark.DeletedByUser(actor) {
+		return nil
+	}
+	book, err := s.store.Books.Get(ctx, bookID)
+	if errors.Is(err, db.ErrNotFound) {
+		book, err = s.store.Books.GetTrashed(ctx, bookID)
+	}
+	if err == nil && !book.OwnerID.IsZero() && book.OwnerID == actor {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func (s *Service) restoreBook(ctx context.Context, book models.Book) error {
+	if err := s.store.Books.Restore(ctx, book.ID); err != nil {
+		return err
+	}
+	filter := db.TrashFilter{BookID: book.ID}
+	chapters, err := s.store.Chapters.ListTrashed(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, chapter := range chapters {
+		if chapter.WithBook {
+			if err := s.restoreChapter(ctx, chapter); err != nil {
+				return err
+			}
+		}
+	}
+	notes, err := s.store.Notes.ListTrashed(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if note.WithBook {
+			if err := s.store.Notes.Restore(ctx, note.ID); err != nil {
+				return err
+			}
+		}
+	}
+	images, err := s.store.Images.ListTrashed(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if image.WithBook {
+			if err := s.store.Images.Restore(ctx, image.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreChapter makes room in the chapter's old slot before restoring it
+func (s *Service) restoreChapter(ctx context.Context, chapter models.Chapter) error {
+	slot := chapter.DeletedChapterNum
+	if slot != 0 {
+		siblings, err := s.store.Chapters.ListByBook(ctx, chapter.BookID)
+		if err != nil {
+			return err
+		}
+		taken := false
+		var after []models.Chapter
+		for _, sibling := range siblings {
+			if sibling.VersionID != chapter.VersionID || sibling.ChapterNum < slot {
+				continue
+			}
+			if sibling.ChapterNum == slot {
+				taken = true
+			}
+			after = append(after, sibling)
+		}
+		if taken {
+			// move from the top down so no two chapters share a number
+			sort.Slice(after, func(i, j int) bool { return after[i].ChapterNum > after[j].ChapterNum })
+			for _, sibling := range after {
+				sibling.ChapterNum++
+				if err := s.store.Chapters.Update(ctx, sibling.ID, sibling); err != nil {
+					return fmt.Errorf("renumbering chapter %s: %w", sibling.ID.Hex(), err)
+				}
+			}
+		}
+	}
+	return s.store.Chapters.Restore(ctx, chapter.ID)
+}
+
+// Delete removes a trashed item for good ahead of the retention period
+func (s *Service) Delete(ctx context.Context, kind string, id, actor primitive.ObjectID) (*deletion.Summary, error) {
+	var trash models.Trash
+	var bookID primitive.ObjectID
+	switch kind {
+	case KindBook:
+		book, err := s.store.Books.GetTrashed(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		trash, bookID = book.Trash, book.ID
+	case KindChapter:
+		chapter, err := s.store.Chapters.GetTrashed(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		trash, bookID = chapter.Trash, chapter.BookID
+	case KindNote:
+		note, err := s.store.Notes.GetTrashed(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		trash, bookID = note.Trash, note.BookID
+	case KindImage:
+		image, err := s.store.Images.GetTrashed(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		trash, bookID = image.Trash, image.BookID
+	default:
+		return nil, ErrUnknownKind
+	}
+	if err := s.allowed(ctx, trash, bookID, actor); err != nil {
+		return nil, err
+	}
+	return s.purge(ctx, kind, id, bookID)
+}
+
+func (s *Service) purge(ctx context.Context, kind string, id, bookID primitive.ObjectID) (*deletion.Summary, error) {
+	switch kind {
+	case KindBook:
+		return s.deleter.DeleteBook(ctx, id)
+	case KindImage:
+		return s.deleter.DeleteImage(ctx, id)
+	case KindChapter:
+		if err := s.store.Chapters.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+		return &deletion.Summary{BookID: bookID, Chapters: 1, Files: []string{}}, nil
+	case KindNote:
+		if err := s.store.Notes.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+		return &deletion.Summary{BookID: bookID, Notes: 1, Files: []string{}}, nil
+	}
+	return nil, ErrUnknownKind
+}
+
+// Purge deletes everything that has been in the trash longer than the
+// retention period. Books go first, taking their trashed children along.
+func (s *Service) Purge(ctx context.Context) (*PurgeReport, error) {
+	report := &PurgeReport{}
+	expired := db.TrashFilter{Before: time.Now().Add(-s.retention)}
+
+	books, err := s.store.Books.ListTrashed(ctx, expired)
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range books {
+		if s.purgeOne(ctx, report, KindBook, book.ID, book.ID) {
+			report.Books++
+		}
+	}
+	chapters, err := s.store.Chapters.ListTrashed(ctx, expired)
+	if err != nil {
+		return nil, err
+	}
+	for _, chapter := range chapters {
+		if s.purgeOne(ctx, report, KindChapter, chapter.ID, chapter.BookID) {
+			report.Chapters++
+		}
+	}
+	notes, err := s.store.Notes.ListTrashed(ctx, expired)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		if s.purgeOne(ctx, report, KindNote, note.ID, note.BookID) {
+			report.Notes++
+		}
+	}
+	images, err := s.store.Images.ListTrashed(ctx, expired)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		if s.purgeOne(ctx, report, KindImage, image.ID, image.BookID) {
+			report.Images++
+		}
+	}
+	return report, nil
+}
+
+// purgeOne records failures in the report so one bad item doesn't stop
+// the run; items already removed with their book are skipped
+func (s *Service) purgeOne(ctx context.Context, report *PurgeReport, kind string, id, bookID primitive.ObjectID) bool {
+	_, err := s.purge(ctx, kind, id, bookID)
+	if errors.Is(err, db.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %v", kind, id.Hex(), err))
+		return false
+	}
+	return true
+}
+
+// atomically runs fn in a transaction when the store supports them
+func (s *Service) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := s.store.Transactions.WithTransaction(ctx, fn)
+	if errors.Is(err, db.ErrTransactionsUnsupported) {
+		return fn(ctx)
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package typeset
+
+// EAN-13 digit patterns, one module per character. Right-hand digits are
+// the complement of the L patterns.
+var (
+	eanL = [10]string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	eanG = [10]string{"0100111", "0110011", "0011011", "0100001", "0011101", "0111001", "0000101", "0010001", "0001001", "0010111"}
+	eanR = [10]string{"1110010", "1100110", "1101100", "1000010", "1011100", "1001110", "1010000", "1000100", "1001000", "1110100"}
+	// eanParity is how the first digit is encoded: in the L or G patterns
+	// chosen for the next six
+	eanParity = [10]string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+)
+
+// eanModules is the width of an EAN-13 symbol without its quiet zones
+const eanModules = 95
+
+// ean13 encodes 13 digits as the 95 modules of an EAN-13 barcode, true
+// for a dark bar
+func ean13(digits string) []bool {
+	var pattern []byte
+	pattern = append(pattern, "101"...)
+	parity := eanParity[digits[0]-'0']
+	for i := 1; i <= 6; i++ {
+		if parity[i-1] == 'L' {
+			pattern = append(pattern, eanL[digits[i]-'0']...)
+		} else {
+			pattern = append(pattern, eanG[digits[i]-'0']...)
+		}
+	}
+	pattern = append(pattern, "01010"...)
+	for i := 7; i <= 12; i++ {
+		pattern = append(pattern, eanR[digits[i]-'0']...)
+	}
+	pattern = append(pattern, "101"...)
+
+	modules := make([]bool, len(pattern))
+	for i, m := range pattern {
+		modules[i] = m == '1'
+	}
+	return modules
+}
+
+// guardModule reports whether a module belongs to one of the guard
+// patterns, which run longer than the digit bars
+func guardModule(i int) bool {
+	return i < 3 || (i >= 45 && i < 50) || i >= 92
+}
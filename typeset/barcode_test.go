@@ -0,0 +1,25 @@
+package typeset
+
+import "testing"
+
+func TestEAN13(t *testing.T) {
+	for _, c := range []struct{ digits, left, right string }{
+		// 9 puts the left half in the L G G L G L patterns
+		{"9780306406157", "011101100010010100111011110101001110101111", "101110011100101010000110011010011101000100"},
+		// 4 puts it in L G L L G G
+		{"4006381333931", "000110101001110101111011110100010010110011", "100001010000101000010111010010000101100110"},
+	} {
+		want := "101" + c.left + "01010" + c.right + "101"
+		modules := ean13(c.digits)
+		got := make([]byte, len(modules))
+		for i, dark := range modules {
+			got[i] = '0'
+			if dark {
+				got[i] = '1'
+			}
+		}
+		if len(got) != eanModules || string(got) != want {
+			t.Errorf("ean13(%s)\ngot  %s\nwant %s", c.digits, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package typeset
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/programmingbunny/epub-backend/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// box is a rectangle in inches from the top left corner
+type box struct {
+	x, y, w, h float64
+}
+
+func (b box) inset(dx, dy float64) box {
+	return box{b.x + dx, b.y + dy, b.w - 2*dx, b.h - 2*dy}
+}
+
+// canvas draws covers either as PDF or as an image. Positions are in
+// inches, font sizes in points, and text is placed by its baseline.
+type canvas interface {
+	fill(b box, c color.NRGBA)
+	stroke(b box, c color.NRGBA, dashed bool)
+	line(x1, y1, x2, y2 float64, c color.NRGBA)
+	// picture draws an image already scaled to the box at coverDPI
+	picture(img image.Image, b box)
+	text(x, y float64, s string, st style, size float64, c color.NRGBA)
+	// textDown runs text down the page from x, y with the tops of the
+	// letters to the right, the way it reads on a spine
+	textDown(x, y float64, s string, st style, size float64, c color.NRGBA)
+	width(s string, st style, size float64) float64
+}
+
+// guideWidth is the width of guide lines in inches
+const guideWidth = 0.5 / inch
+
+type pdfCanvas struct {
+	pdf      *fpdf.Fpdf
+	pictures int
+}
+
+func newPDFCanvas(width, height float64) *pdfCanvas {
+	pdf := fpdf.NewCustom(&fpdf.InitType{UnitStr: "in", Size: fpdf.SizeType{Wd: width, Ht: height}})
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetCreator("OnWord", true)
+	addFonts(pdf)
+	pdf.AddPage()
+	return &pdfCanvas{pdf: pdf}
+}
+
+func (p *pdfCanvas) fill(b box, c color.NRGBA) {
+	p.pdf.SetFillColor(int(c.R), int(c.G), int(c.B))
+	p.pdf.Rect(b.x, b.y, b.w, b.h, "F")
+}
+
+func (p *pdfCanvas) stroke(b box, c color.NRGBA, dashed bool) {
+	p.pdf.SetDrawColor(int(c.R), int(c.G), int(c.B))
+	p.pdf.SetLineWidth(guideWidth)
+	if dashed {
+		p.pdf.SetDashPattern([]float64{0.08, 0.05}, 0)
+	}
+	p.pdf.Rect(b.x, b.y, b.w, b.h, "D")
+	p.pdf.SetDashPattern(nil, 0)
+}
+
+func (p *pdfCanvas) line(x1, y1, x2, y2 float64, c color.NRGBA) {
+	p.pdf.SetDrawColor(int(c.R), int(c.G), int(c.B))
+	p.pdf.SetLineWidth(guideWidth)
+	p.pdf.Line(x1, y1, x2, y2)
+}
+
+func (p *pdfCanvas) picture(img image.Image, b box) {
+	var buf bytes.Buffer
+	if _, err := imaging.Encode(&buf, img, "jpeg"); err != nil {
+		return
+	}
+	p.pictures++
+	name := fmt.Sprintf("picture%d", p.pictures)
+	p.pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "JPG"}, &buf)
+	p.pdf.ImageOptions(name, b.x, b.y, b.w, b.h, false, fpdf.ImageOptions{}, 0, "")
+}
+
+func (p *pdfCanvas) text(x, y float64, s string, st style, size float64, c color.NRGBA) {
+	setFont(p.pdf, st, size)
+	p.pdf.SetTextColor(int(c.R), int(c.G), int(c.B))
+	p.pdf.Text(x, y, s)
+}
+
+func (p *pdfCanvas) textDown(x, y float64, s string, st style, size float64, c color.NRGBA) {
+	p.pdf.TransformBegin()
+	p.pdf.TransformRotate(-90, x, y)
+	p.text(x, y, s, st, size, c)
+	p.pdf.TransformEnd()
+}
+
+func (p *pdfCanvas) width(s string, st style, size float64) float64 {
+	setFont(p.pdf, st, size)
+	return p.pdf.GetStringWidth(s)
+}
+
+type pngCanvas struct {
+	img   *image.NRGBA
+	faces map[faceKey]font.Face
+}
+
+type faceKey struct {
+	st   style
+	size float64
+}
+
+func newPNGCanvas(width, height float64) *pngCanvas {
+	return &pngCanvas{
+		img:   image.NewNRGBA(image.Rect(0, 0, pixels(width), pixels(height))),
+		faces: map[faceKey]font.Face{},
+	}
+}
+
+// pixels converts inches to pixels at coverDPI
+func pixels(inches float64) int {
+	return int(math.Round(inches * coverDPI))
+}
+
+func (p *pngCanvas) rect(b box) image.Rectangle {
+	return image.Rect(pixels(b.x), pixels(b.y), pixels(b.x+b.w), pixels(b.y+b.h))
+}
+
+func (p *pngCanvas) fill(b box, c color.NRGBA) {
+	draw.Draw(p.img, p.rect(b), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func (p *pngCanvas) stroke(b box, c color.NRGBA, dashed bool) {
+	r := p.rect(b)
+	thick := guidePixels()
+	dash := func(x0, y0, x1, y1 int) {
+		for i := 0; ; i++ {
+			x, y := x0+i*sign(x1-x0), y0+i*sign(y1-y0)
+			// dashes are 0.08 inches long with 0.05 inch gaps
+			if !dashed || i%pixels(0.13) < pixels(0.08) {
+				draw.Draw(p.img, image.Rect(x, y, x+thick, y+thick), image.NewUniform(c), image.Point{}, draw.Over)
+			}
+			if x == x1 && y == y1 {
+				return
+			}
+		}
+	}
+	dash(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y)
+	dash(r.Min.X, r.Max.Y, r.Max.X, r.Max.Y)
+	dash(r.Min.X, r.Min.Y, r.Min.X, r.Max.Y)
+	dash(r.Max.X, r.Min.Y, r.Max.X, r.Max.Y)
+}
+
+// line draws horizontal and vertical lines, the only ones a cover needs
+func (p *pngCanvas) line(x1, y1, x2, y2 float64, c color.NRGBA) {
+	thick := guidePixels()
+	r := image.Rect(pixels(x1), pixels(y1), pixels(x2)+thick, pixels(y2)+thick)
+	draw.Draw(p.img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+func (p *pngCanvas) picture(img image.Image, b box) {
+	draw.Draw(p.img, p.rect(b), img, img.Bounds().Min, draw.Over)
+}
+
+func (p *pngCanvas) text(x, y float64, s string, st style, size float64, c color.NRGBA) {
+	d := font.Drawer{Dst: p.img, Src: image.NewUniform(c), Face: p.face(st, size), Dot: fixed.P(pixels(x), pixels(y))}
+	d.DrawString(s)
+}
+
+func (p *pngCanvas) textDown(x, y float64, s string, st style, size float64, c color.NRGBA) {
+	face := p.face(st, size)
+	metrics := face.Metrics()
+	ascent, height := metrics.Ascent.Ceil(), (metrics.Ascent + metrics.Descent).Ceil()
+	length := font.MeasureString(face, s).Ceil()
+	if length == 0 || height == 0 {
+		return
+	}
+	flat := image.NewNRGBA(image.Rect(0, 0, length, height))
+	d := font.Drawer{Dst: flat, Src: image.NewUniform(c), Face: face, Dot: fixed.P(0, ascent)}
+	d.DrawString(s)
+
+	// turn a quarter clockwise, so the baseline ends up at x
+	turned := image.NewNRGBA(image.Rect(0, 0, height, length))
+	for fy := 0; fy < height; fy++ {
+		for fx := 0; fx < length; fx++ {
+			turned.SetNRGBA(height-1-fy, fx, flat.NRGBAAt(fx, fy))
+		}
+	}
+	left := pixels(x) - (height - 1 - ascent)
+	draw.Draw(p.img, image.Rect(left, pixels(y), left+height, pixels(y)+length), turned, image.Point{}, draw.Over)
+}
+
+func (p *pngCanvas) width(s string, st style, size float64) float64 {
+	return float64(font.MeasureString(p.face(st, size), s)) / 64 / coverDPI
+}
+
+func (p *pngCanvas) face(st style, size float64) font.Face {
+	key := faceKey{st, size}
+	if face, ok := p.faces[key]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(parsedFont(st), &opentype.FaceOptions{Size: size, DPI: coverDPI, Hinting: font.HintingNone})
+	if err != nil {
+		// the embedded fonts always load
+		panic(err)
+	}
+	p.faces[key] = face
+	return face
+}
+
+var (
+	parseFonts sync.Once
+	parsed     map[style]*opentype.Font
+)
+
+// parsedFont returns the Go font for text in the style
+func parsedFont(st style) *opentype.Font {
+	parseFonts.Do(func() {
+		parsed = map[style]*opentype.Font{}
+		for _, mono := range []bool{false, true} {
+			for _, bold := range []bool{false, true} {
+				for _, italic := range []bool{false, true} {
+					st := style{mono: mono, bold: bold, italic: italic}
+					f, err := opentype.Parse(ttf(st))
+					if err != nil {
+						panic(err)
+					}
+					parsed[st] = f
+				}
+			}
+		}
+	})
+	return parsed[style{mono: st.mono, bold: st.bold, italic: st.italic}]
+}
+
+// guidePixels is the width of guide lines in pixels
+func guidePixels() int {
+	if thick := pixels(guideWidth); thick > 1 {
+		return thick
+	}
+	return 1
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
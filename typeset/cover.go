@@ -0,0 +1,428 @@
+package typeset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/programmingbunny/epub-backend/imaging"
+	"github.com/programmingbunny/epub-backend/isbn"
+)
+
+// Papers are the paper stocks a paperback can be printed on, by name, with
+// the thickness of a page in inches
+var Papers = map[string]float64{
+	"white": 0.002252,
+	"cream": 0.0025,
+	"color": 0.002347,
+}
+
+// DefaultPaper is the paper used when none is chosen
+const DefaultPaper = "white"
+
+const (
+	// Bleed is printed past the trim on every side of a cover and cut off
+	Bleed = 0.125
+	// safeMargin keeps text this far inside the trim, and spineSafe this
+	// far inside the folds of the spine
+	safeMargin = 0.25
+	spineSafe  = 0.0625
+	// spineTextPages is the fewest pages a spine is thick enough for text
+	spineTextPages = 100
+	// coverDPI is the resolution of PNG covers and of the art in PDF ones
+	coverDPI = 300
+	// maxPages is the thickest paperback printers bind
+	maxPages = 1000
+)
+
+// The barcode area on the back cover, in inches. Printers leave the
+// area free or place their own barcode in it when there is no ISBN.
+const (
+	barcodeWidth  = 2.0
+	barcodeHeight = 1.2
+	// eanModule is the width of a bar at the nominal EAN-13 size
+	eanModule = 0.013
+)
+
+var (
+	white     = color.NRGBA{255, 255, 255, 255}
+	black     = color.NRGBA{0, 0, 0, 255}
+	gray      = color.NRGBA{120, 120, 120, 255}
+	bleedTint = color.NRGBA{250, 215, 215, 255}
+	trimLine  = color.NRGBA{0, 160, 220, 255}
+	foldLine  = color.NRGBA{220, 0, 140, 255}
+	safeLine  = color.NRGBA{0, 160, 60, 255}
+	areaLine  = color.NRGBA{240, 140, 0, 255}
+)
+
+// Cover is a paperback's full cover: the back, the spine and the front
+// side by side, with bleed all round
+type Cover struct {
+	Trim                    Trim
+	Pages                   int
+	Paper                   string
+	Title, Subtitle, Author string
+	// Front is the image printed on the front cover, which goes without
+	// text when there is one
+	Front []byte
+	// Blurb is the text on the back, paragraphs separated by blank lines
+	Blurb string
+	// ISBN is the 13 digits printed as a barcode on the back
+	ISBN string
+	// Template leaves out the art and text and labels the parts instead
+	Template bool
+	// Guides draws the trim, the spine folds, the safe zones and the
+	// barcode area
+	Guides bool
+}
+
+// Spine is the width of the spine in inches. An odd page count gains a
+// blank page, as every sheet is printed on both sides.
+func (c *Cover) Spine() float64 {
+	return float64(c.Pages+c.Pages%2) * Papers[c.Paper]
+}
+
+// Size is the width and height of the cover with its bleed in inches
+func (c *Cover) Size() (float64, float64) {
+	return 2*c.Trim.Width + c.Spine() + 2*Bleed, c.Trim.Height + 2*Bleed
+}
+
+// Validate checks the cover can be printed
+func (c *Cover) Validate() error {
+	if _, ok := Papers[c.Paper]; !ok {
+		return fmt.Errorf("unknown paper %q", c.Paper)
+	}
+	if c.Trim.Width <= 0 || c.Trim.Height <= 0 {
+		return errors.New("trim size must be positive")
+	}
+	if c.Pages < 1 || c.Pages > maxPages {
+		return fmt.Errorf("page count must be between 1 and %d", maxPages)
+	}
+	if c.ISBN != "" {
+		if digits, err := isbn.Parse(c.ISBN); err != nil || digits != c.ISBN {
+			return errors.New("ISBN must be 13 digits")
+		}
+	}
+	return nil
+}
+
+// WriteCoverPDF draws the cover as a single page PDF of its full size
+func WriteCoverPDF(w io.Writer, c *Cover) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	width, height := c.Size()
+	cv := newPDFCanvas(width, height)
+	cv.pdf.SetTitle(c.Title, true)
+	cv.pdf.SetAuthor(c.Author, true)
+	c.draw(cv)
+	return cv.pdf.Output(w)
+}
+
+// WriteCoverPNG draws the cover as a PNG image at 300 dpi
+func WriteCoverPNG(w io.Writer, c *Cover) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	cv := newPNGCanvas(c.Size())
+	c.draw(cv)
+	return png.Encode(w, cv.img)
+}
+
+// panels are the back, spine and front of a cover within their trim
+func (c *Cover) panels() (back, spine, front box) {
+	back = box{Bleed, Bleed, c.Trim.Width, c.Trim.Height}
+	spine = box{back.x + back.w, Bleed, c.Spine(), c.Trim.Height}
+	front = box{spine.x + spine.w, Bleed, c.Trim.Width, c.Trim.Height}
+	return back, spine, front
+}
+
+func (c *Cover) draw(cv canvas) {
+	width, height := c.Size()
+	back, spine, front := c.panels()
+
+	background, ink := white, black
+	var art image.Image
+	if !c.Template && len(c.Front) > 0 {
+		if img, _, err := imaging.Decode(bytes.NewReader(c.Front)); err == nil && !img.Bounds().Empty() {
+			art = img
+			// the back and spine take the colour of the front
+			background = average(img)
+			ink = contrast(background)
+		}
+	}
+
+	if c.Template {
+		cv.fill(box{0, 0, width, height}, bleedTint)
+		cv.fill(box{Bleed, Bleed, width - 2*Bleed, height - 2*Bleed}, white)
+	} else {
+		cv.fill(box{0, 0, width, height}, background)
+	}
+
+	switch {
+	case art != nil:
+		// the art runs into the bleed on the three outer edges
+		bleeding := box{front.x, 0, front.w + Bleed, height}
+		cv.picture(cropTo(art, bleeding, background), bleeding)
+	case !c.Template:
+		c.frontText(cv, front, ink)
+	}
+	if !c.Template {
+		c.spineText(cv, spine, ink)
+		c.blurb(cv, back, ink)
+	}
+	if c.ISBN != "" {
+		c.barcode(cv, back)
+	}
+	if c.Template || c.Guides {
+		c.guides(cv, back, spine, front)
+	}
+	if c.Template {
+		c.labels(cv, back, spine, front)
+	}
+}
+
+// frontText sets the title, subtitle and author on a cover without art
+func (c *Cover) frontText(cv canvas, front box, ink color.NRGBA) {
+	safe := front.inset(safeMargin, safeMargin)
+	y := front.y + front.h/4
+	for _, line := range wrap(cv, c.Title, style{bold: true}, 28, safe.w) {
+		cv.text(safe.x+(safe.w-cv.width(line, style{bold: true}, 28))/2, y, line, style{bold: true}, 28, ink)
+		y += 34.0 / inch
+	}
+	y += 10.0 / inch
+	for _, line := range wrap(cv, c.Subtitle, style{italic: true}, 14, safe.w) {
+		cv.text(safe.x+(safe.w-cv.width(line, style{italic: true}, 14))/2, y, line, style{italic: true}, 14, ink)
+		y += 18.0 / inch
+	}
+	author := c.Author
+	cv.text(safe.x+(safe.w-cv.width(author, style{}, 18))/2, front.y+front.h*0.85, author, style{}, 18, ink)
+}
+
+// spineText runs the title and author down spines thick enough to carry
+// text
+func (c *Cover) spineText(cv canvas, spine box, ink color.NRGBA) {
+	if c.Pages < spineTextPages {
+		return
+	}
+	size := spine.inset(spineSafe, 0).w * inch * 0.7
+	if size > 14 {
+		size = 14
+	}
+	text := strings.TrimSpace(strings.ToUpper(c.Title) + "    " + c.Author)
+	room := spine.h - 2*safeMargin
+	for size > 6 && cv.width(text, style{bold: true}, size) > room {
+		size--
+	}
+	length := cv.width(text, style{bold: true}, size)
+	if length > room {
+		return
+	}
+	// centre the letters, whose tops point at the front, across the spine
+	x := spine.x + spine.w/2 - size*0.35/inch
+	cv.textDown(x, spine.y+(spine.h-length)/2, text, style{bold: true}, size, ink)
+}
+
+// blurb sets the back cover text above the barcode area, smaller if it
+// doesn't fit
+func (c *Cover) blurb(cv canvas, back box, ink color.NRGBA) {
+	if strings.TrimSpace(c.Blurb) == "" {
+		return
+	}
+	safe := back.inset(safeMargin, safeMargin)
+	room := safe.h - barcodeHeight - safeMargin
+	var paragraphs [][]string
+	size := 12.0
+	for ; ; size-- {
+		paragraphs = nil
+		height := 0.0
+		for _, p := range strings.Split(strings.ReplaceAll(c.Blurb, "\r\n", "\n"), "\n\n") {
+			if p = strings.Join(strings.Fields(p), " "); p != "" {
+				lines := wrap(cv, p, style{}, size, safe.w)
+				paragraphs = append(paragraphs, lines)
+				height += float64(len(lines)+1) * size * 1.4 / inch
+			}
+		}
+		if height <= room || size <= 8 {
+			break
+		}
+	}
+	leading := size * 1.4 / inch
+	y := safe.y + size/inch
+	for _, lines := range paragraphs {
+		for _, line := range lines {
+			if y > safe.y+room {
+				return
+			}
+			cv.text(safe.x, y, line, style{}, size, ink)
+			y += leading
+		}
+		y += leading
+	}
+}
+
+// barcode draws the ISBN as an EAN-13 on white at the foot of the back
+// cover
+func (c *Cover) barcode(cv canvas, back box) {
+	area := box{back.x + back.w - safeMargin - barcodeWidth, back.y + back.h - safeMargin - barcodeHeight, barcodeWidth, barcodeHeight}
+	cv.fill(area, white)
+
+	// quiet zones of 11 and 7 modules either side of the bars
+	symbol := float64(11+eanModules+7) * eanModule
+	left := area.x + (area.w-symbol)/2
+	bars := left + 11*eanModule
+	top := area.y + 0.25
+	height := 0.7
+
+	label := "ISBN " + c.ISBN
+	cv.text(area.x+(area.w-cv.width(label, style{mono: true}, 8))/2, area.y+0.17, label, style{mono: true}, 8, black)
+	modules := ean13(c.ISBN)
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		run := i
+		for run < len(modules) && modules[run] && guardModule(run) == guardModule(i) {
+			run++
+		}
+		h := height
+		if guardModule(i) {
+			h += 0.08
+		}
+		cv.fill(box{bars + float64(i)*eanModule, top, float64(run-i) * eanModule, h}, black)
+		i = run
+	}
+
+	digits := top + height + 0.12
+	cv.text(left+eanModule, digits, c.ISBN[:1], style{mono: true}, 9, black)
+	for i, group := range []string{c.ISBN[1:7], c.ISBN[7:]} {
+		start := bars + float64(3+i*47)*eanModule
+		w := 42 * eanModule
+		cv.text(start+(w-cv.width(group, style{mono: true}, 9))/2, digits, group, style{mono: true}, 9, black)
+	}
+}
+
+// guides outline the trim, the folds of the spine, the safe zones and
+// the barcode area
+func (c *Cover) guides(cv canvas, back, spine, front box) {
+	width, height := c.Size()
+	cv.stroke(box{Bleed, Bleed, width - 2*Bleed, height - 2*Bleed}, trimLine, false)
+	cv.line(spine.x, 0, spine.x, height, foldLine)
+	cv.line(spine.x+spine.w, 0, spine.x+spine.w, height, foldLine)
+	cv.stroke(back.inset(safeMargin, safeMargin), safeLine, true)
+	cv.stroke(front.inset(safeMargin, safeMargin), safeLine, true)
+	if spine.w > 2*spineSafe {
+		cv.stroke(spine.inset(spineSafe, safeMargin), safeLine, true)
+	}
+	cv.stroke(box{back.x + back.w - safeMargin - barcodeWidth, back.y + back.h - safeMargin - barcodeHeight, barcodeWidth, barcodeHeight}, areaLine, true)
+}
+
+// labels name the parts of a template and give its measurements
+func (c *Cover) labels(cv canvas, back, spine, front box) {
+	label := func(b box, name string) {
+		cv.text(b.x+(b.w-cv.width(name, style{bold: true}, 16))/2, b.y+b.h/2, name, style{bold: true}, 16, gray)
+		trim := inches(c.Trim.Width) + " × " + inches(c.Trim.Height) + " in"
+		cv.text(b.x+(b.w-cv.width(trim, style{}, 10))/2, b.y+b.h/2+0.25, trim, style{}, 10, gray)
+	}
+	label(back, "BACK COVER")
+	label(front, "FRONT COVER")
+
+	name := "SPINE " + inches(spine.w) + " in"
+	if size := spine.w * inch * 0.6; size >= 4 {
+		if size > 12 {
+			size = 12
+		}
+		length := cv.width(name, style{bold: true}, size)
+		cv.textDown(spine.x+spine.w/2-size*0.35/inch, spine.y+(spine.h-length)/2, name, style{bold: true}, size, gray)
+	}
+
+	width, height := c.Size()
+	caption := fmt.Sprintf("Full cover %s × %s in with %s in bleed · spine %s in for %d pages of %s paper",
+		inches(width), inches(height), inches(Bleed), inches(spine.w), c.Pages, c.Paper)
+	safe := back.inset(safeMargin, safeMargin)
+	for i, line := range wrap(cv, caption, style{}, 8, safe.w-barcodeWidth-safeMargin) {
+		cv.text(safe.x, safe.y+safe.h-0.5+float64(i)*11/inch, line, style{}, 8, gray)
+	}
+	cv.text(safe.x, safe.y+0.15, "Keep text inside the dashed lines", style{italic: true}, 8, gray)
+}
+
+// wrap breaks text into lines no wider than width
+func wrap(cv canvas, text string, st style, size, width float64) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && cv.width(line+" "+word, st, size) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// cropTo scales an image to cover the box at coverDPI, cutting off what
+// sticks out evenly on both sides, and flattens it onto the background
+func cropTo(img image.Image, b box, background color.NRGBA) image.Image {
+	bounds := img.Bounds()
+	w, h := pixels(b.x+b.w)-pixels(b.x), pixels(b.y+b.h)-pixels(b.y)
+	crop := bounds
+	if float64(bounds.Dx())*float64(h) > float64(bounds.Dy())*float64(w) {
+		keep := bounds.Dy() * w / h
+		crop.Min.X += (bounds.Dx() - keep) / 2
+		crop.Max.X = crop.Min.X + keep
+	} else {
+		keep := bounds.Dx() * h / w
+		crop.Min.Y += (bounds.Dy() - keep) / 2
+		crop.Max.Y = crop.Min.Y + keep
+	}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		img = sub.SubImage(crop)
+	}
+
+	flat := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), imaging.Resize(img, w, h), image.Point{}, draw.Over)
+	return flat
+}
+
+// average is the mean colour of an image, sampled on a grid
+func average(img image.Image) color.NRGBA {
+	bounds := img.Bounds()
+	var r, g, b, n uint64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 1 + bounds.Dy()/64 {
+		for x := bounds.Min.X; x < bounds.Max.X; x += 1 + bounds.Dx()/64 {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			r, g, b, n = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), n+1
+		}
+	}
+	return color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), 255}
+}
+
+// contrast picks black or white text for a background
+func contrast(c color.NRGBA) color.NRGBA {
+	if 299*int(c.R)+587*int(c.G)+114*int(c.B) > 128000 {
+		return black
+	}
+	return white
+}
+
+// inches formats a measurement with up to three decimals
+func inches(v float64) string {
+	return strings.TrimRight(strings.TrimRight(strconv.FormatFloat(v, 'f', 3, 64), "0"), ".")
+}
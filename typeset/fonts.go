@@ -0,0 +1,85 @@
+package typeset
+
+import (
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Font families registered with every document
+const (
+	bodyFont = "go"
+	monoFont = "gomono"
+)
+
+// addFonts embeds the Go fonts. Only the glyphs a book uses end up in the
+// PDF.
+func addFonts(pdf *fpdf.Fpdf) {
+	for _, st := range []style{{}, {italic: true}, {bold: true}, {bold: true, italic: true}} {
+		pdf.AddUTF8FontFromBytes(bodyFont, variant(st), ttf(st))
+		st.mono = true
+		pdf.AddUTF8FontFromBytes(monoFont, variant(st), ttf(st))
+	}
+}
+
+// ttf returns the Go font for text in the style
+func ttf(st style) []byte {
+	switch {
+	case st.mono && st.bold && st.italic:
+		return gomonobolditalic.TTF
+	case st.mono && st.bold:
+		return gomonobold.TTF
+	case st.mono && st.italic:
+		return gomonoitalic.TTF
+	case st.mono:
+		return gomono.TTF
+	case st.bold && st.italic:
+		return gobolditalic.TTF
+	case st.bold:
+		return gobold.TTF
+	case st.italic:
+		return goitalic.TTF
+	}
+	return goregular.TTF
+}
+
+// style is the formatting of a run of text
+type style struct {
+	italic, bold, mono, sup bool
+}
+
+// setFont selects the font for text in the style at a body size
+func setFont(pdf *fpdf.Fpdf, st style, size float64) {
+	family := bodyFont
+	if st.mono {
+		family = monoFont
+	}
+	if st.sup {
+		size *= supScale
+	}
+	pdf.SetFont(family, variant(st), size)
+}
+
+// variant is the fpdf style string of a font
+func variant(st style) string {
+	v := ""
+	if st.bold {
+		v += "B"
+	}
+	if st.italic {
+		v += "I"
+	}
+	return v
+}
+
+// supScale shrinks superscripts, which are raised by supRise of the size
+const (
+	supScale = 0.65
+	supRise  = 0.35
+)
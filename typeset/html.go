@@ -0,0 +1,442 @@
+package typeset
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// lineBreak is a forced line break inside a paragraph
+const lineBreak = '\u2028'
+
+// blockContext is how the paragraphs of a block are set
+type blockContext struct {
+	left  float64
+	quote bool
+	st    style
+}
+
+// builder turns chapter HTML into the blocks that are laid out
+type builder struct {
+	t      *typesetter
+	blocks []block
+	// indent gives the next paragraph a first-line indent, which the first
+	// paragraph after a heading or a break goes without
+	indent bool
+	// defs are the footnotes of the chapter by label, numbered in the
+	// order they are referred to and printed as notes at its end
+	defs    map[string]*html.Node
+	numbers map[string]int
+	notes   []*html.Node
+	inNote  bool
+}
+
+func (b *builder) chapter(src string) []block {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		b.paragraph(blockContext{}, []span{{text: src}}, nil)
+		return b.blocks
+	}
+	b.defs, b.numbers = map[string]*html.Node{}, map[string]int{}
+	for _, n := range nodes {
+		b.findNotes(n)
+	}
+	b.walk(nodes, blockContext{})
+	b.endNotes()
+	return b.blocks
+}
+
+func (b *builder) findNotes(n *html.Node) {
+	if n.DataAtom == atom.Li && strings.HasPrefix(attr(n, "id"), "fn:") {
+		b.defs[strings.TrimPrefix(attr(n, "id"), "fn:")] = n
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.findNotes(c)
+	}
+}
+
+func (b *builder) walk(nodes []*html.Node, ctx blockContext) {
+	var pending []*html.Node
+	flush := func() {
+		if len(pending) > 0 {
+			spans, images := b.inline(pending, ctx.st)
+			b.paragraph(ctx, spans, images)
+			pending = nil
+		}
+	}
+	for _, n := range nodes {
+		if n.Type != html.ElementNode || !blockElements[n.DataAtom] {
+			pending = append(pending, n)
+			continue
+		}
+		flush()
+		switch n.DataAtom {
+		case atom.P, atom.Dt, atom.Dd, atom.Figcaption:
+			spans, images := b.inline(children(n), ctx.st)
+			b.paragraph(ctx, spans, images)
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			spans, _ := b.inline(children(n), style{bold: true})
+			if spans = collapse(spans); len(spans) > 0 {
+				b.space(bodyLeading)
+				b.add(block{spans: spans, size: headingSize, leading: headingLeading, align: left, left: ctx.left, keep: true})
+				b.indent = false
+			}
+		case atom.Hr:
+			b.space(bodyLeading / 2)
+			b.add(block{spans: []span{{text: "* * *"}}, size: bodySize, leading: bodyLeading, align: center})
+			b.space(bodyLeading / 2)
+			b.indent = false
+		case atom.Blockquote:
+			b.space(bodyLeading / 2)
+			b.walk(children(n), blockContext{left: ctx.left + quoteIndent, quote: true, st: ctx.st})
+			b.space(bodyLeading / 2)
+			b.indent = false
+		case atom.Ul, atom.Ol:
+			b.space(bodyLeading / 2)
+			b.list(n, ctx.left)
+			b.space(bodyLeading / 2)
+			b.indent = false
+		case atom.Pre:
+			b.pre(n, ctx)
+		case atom.Table:
+			b.table(n, ctx)
+		case atom.Script, atom.Style, atom.Template:
+		default:
+			if hasClass(n, "footnotes") || attr(n, "role") == "doc-endnotes" {
+				continue
+			}
+			b.walk(children(n), ctx)
+		}
+	}
+	flush()
+}
+
+// paragraph adds the text as a justified paragraph followed by the images
+// it holds. Paragraphs with nothing in them are left out.
+func (b *builder) paragraph(ctx blockContext, spans []span, images []string) {
+	if spans = collapse(spans); len(spans) > 0 {
+		p := block{spans: spans, size: bodySize, leading: bodyLeading, align: justify, left: ctx.left}
+		if b.indent && !ctx.quote {
+			p.indent = firstIndent
+		}
+		b.add(p)
+		b.indent = true
+	}
+	for _, src := range images {
+		b.image(src, ctx.left)
+	}
+}
+
+// image adds a picture shown in the text, as wide as the text at most
+func (b *builder) image(src string, left float64) {
+	if b.t.book.Image == nil {
+		return
+	}
+	pic := b.t.picture("src:"+src, b.t.book.Image(src), b.t.textWidth-left, b.t.textHeight/2)
+	if pic == nil {
+		return
+	}
+	b.space(bodyLeading / 2)
+	b.add(block{picture: pic, left: left, align: center})
+	b.space(bodyLeading / 2)
+	b.indent = false
+}
+
+// list adds each item as a paragraph with a hanging bullet or number,
+// indented further for every level of nesting
+func (b *builder) list(n *html.Node, left float64) {
+	number := 1
+	if start := attr(n, "start"); start != "" {
+		fmt.Sscan(start, &number)
+	}
+	left += quoteIndent
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "•"
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d.", number)
+			number++
+		}
+		add := func(nodes []*html.Node) {
+			spans, images := b.inline(nodes, style{})
+			if spans = collapse(spans); len(spans) > 0 || marker != "" {
+				b.add(block{spans: spans, size: bodySize, leading: bodyLeading, align: justify, left: left, marker: marker})
+				marker = ""
+			}
+			for _, src := range images {
+				b.image(src, left)
+			}
+		}
+		var pending []*html.Node
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Ul, atom.Ol:
+				if len(pending) > 0 || marker != "" {
+					add(pending)
+					pending = nil
+				}
+				b.list(c, left)
+			case atom.P:
+				if len(pending) > 0 {
+					add(pending)
+					pending = nil
+				}
+				add(children(c))
+			default:
+				pending = append(pending, c)
+			}
+		}
+		if len(pending) > 0 || marker != "" {
+			add(pending)
+		}
+	}
+}
+
+// pre keeps the lines and spacing of preformatted text
+func (b *builder) pre(n *html.Node, ctx blockContext) {
+	text := strings.TrimSuffix(textOf(n), "\n")
+	text = strings.NewReplacer(" ", "\u00a0", "\t", "\u00a0\u00a0\u00a0\u00a0", "\n", string(lineBreak)).Replace(text)
+	b.space(bodyLeading / 2)
+	b.add(block{spans: []span{{text: text, st: style{mono: true}}}, size: monoSize, leading: monoLeading, align: left, left: ctx.left})
+	b.space(bodyLeading / 2)
+	b.indent = false
+}
+
+// table sets every row as a line of its cells, as a book has no room for
+// ruled tables
+func (b *builder) table(n *html.Node, ctx blockContext) {
+	var rows []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.DataAtom {
+			case atom.Tr:
+				rows = append(rows, c)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	b.space(bodyLeading / 2)
+	for _, tr := range rows {
+		var spans []span
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom != atom.Td && c.DataAtom != atom.Th {
+				continue
+			}
+			if len(spans) > 0 {
+				spans = append(spans, span{text: " | "})
+			}
+			cell, _ := b.inline(children(c), style{bold: c.DataAtom == atom.Th})
+			spans = append(spans, collapse(cell)...)
+		}
+		if spans = collapse(spans); len(spans) > 0 {
+			b.add(block{spans: spans, size: bodySize, leading: bodyLeading, align: left, left: ctx.left})
+		}
+	}
+	b.space(bodyLeading / 2)
+	b.indent = false
+}
+
+// endNotes adds the footnotes referred to in the chapter after its text
+func (b *builder) endNotes() {
+	if len(b.notes) == 0 {
+		return
+	}
+	b.space(bodyLeading)
+	b.add(block{spans: []span{{text: "Notes", st: style{bold: true}}}, size: noteSize, leading: noteLeading, align: left, keep: true})
+	b.inNote = true
+	for i, def := range b.notes {
+		spans, _ := b.inline(children(def), style{})
+		b.add(block{spans: collapse(spans), size: noteSize, leading: noteLeading, align: justify, left: quoteIndent, marker: fmt.Sprintf("%d.", i+1)})
+	}
+	b.inNote = false
+}
+
+func (b *builder) add(p block) {
+	b.blocks = append(b.blocks, p)
+}
+
+// space adds vertical space, which is dropped at the top of a page
+func (b *builder) space(height float64) {
+	b.blocks = append(b.blocks, block{space: height})
+}
+
+// inline collects the styled text of nodes and the src of the images
+// among them
+func (b *builder) inline(nodes []*html.Node, st style) ([]span, []string) {
+	var spans []span
+	var images []string
+	var walk func(n *html.Node, st style)
+	walk = func(n *html.Node, st style) {
+		switch n.Type {
+		case html.TextNode:
+			spans = append(spans, span{text: n.Data, st: st})
+			return
+		case html.ElementNode:
+		default:
+			return
+		}
+		switch n.DataAtom {
+		case atom.Em, atom.I, atom.Cite, atom.U:
+			st.italic = !st.italic
+		case atom.Strong, atom.B:
+			st.bold = true
+		case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+			st.mono = true
+		case atom.Sup:
+			if a := firstElement(n); a != nil && a.DataAtom == atom.A && isNoteRef(a) {
+				walk(a, st)
+				return
+			}
+			st.sup = true
+		case atom.A:
+			if attr(n, "role") == "doc-backlink" || hasClass(n, "footnote-backref") {
+				return
+			}
+			if isNoteRef(n) {
+				if number := b.noteRef(strings.TrimPrefix(attr(n, "href"), "#fn:")); number > 0 {
+					spans = append(spans, span{text: fmt.Sprint(number), st: style{sup: true}})
+				}
+				return
+			}
+		case atom.Br:
+			spans = append(spans, span{text: string(lineBreak), st: st})
+			return
+		case atom.Img:
+			if src := attr(n, "src"); src != "" {
+				images = append(images, src)
+			}
+			return
+		case atom.Script, atom.Style, atom.Template:
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, st)
+		}
+		if blockElements[n.DataAtom] {
+			spans = append(spans, span{text: " ", st: st})
+		}
+	}
+	for _, n := range nodes {
+		walk(n, st)
+	}
+	return spans, images
+}
+
+// noteRef returns the number of the footnote with the label. Footnotes
+// inside footnotes are left out.
+func (b *builder) noteRef(label string) int {
+	def := b.defs[label]
+	if def == nil || b.inNote {
+		return 0
+	}
+	if number, ok := b.numbers[label]; ok {
+		return number
+	}
+	b.notes = append(b.notes, def)
+	b.numbers[label] = len(b.notes)
+	return len(b.notes)
+}
+
+// collapse turns runs of HTML white space into single spaces and trims
+// the ends of the paragraph
+func collapse(spans []span) []span {
+	var out []span
+	space := true
+	for _, s := range spans {
+		var text strings.Builder
+		for _, r := range s.text {
+			switch r {
+			case ' ', '\t', '\n', '\r', '\f':
+				if !space {
+					text.WriteByte(' ')
+				}
+				space = true
+			case lineBreak:
+				text.WriteRune(r)
+				space = true
+			default:
+				text.WriteRune(r)
+				space = false
+			}
+		}
+		if text.Len() > 0 {
+			out = append(out, span{text: text.String(), st: s.st})
+		}
+	}
+	for len(out) > 0 {
+		last := &out[len(out)-1]
+		last.text = strings.TrimRight(last.text, " "+string(lineBreak))
+		if last.text != "" {
+			break
+		}
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// blockElements start a paragraph of their own
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Dd: true,
+	atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+	atom.Section: true, atom.Table: true, atom.Ul: true, atom.Script: true, atom.Style: true, atom.Template: true,
+}
+
+func children(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
+
+func firstElement(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			return c
+		}
+	}
+	return nil
+}
+
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text.WriteString(textOf(c))
+	}
+	return text.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func isNoteRef(a *html.Node) bool {
+	return (attr(a, "role") == "doc-noteref" || hasClass(a, "footnote-ref")) && strings.HasPrefix(attr(a, "href"), "#fn:")
+}
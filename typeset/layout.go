@@ -0,0 +1,638 @@
+package typeset
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/programmingbunny/epub-backend/imaging"
+)
+
+// inch is the number of points in an inch, the unit of the PDF
+const inch = 72.0
+
+// Sizes and leading in points
+const (
+	bodySize       = 11.0
+	bodyLeading    = 15.0
+	headingSize    = 12.0
+	headingLeading = 16.0
+	monoSize       = 9.0
+	monoLeading    = 12.0
+	noteSize       = 9.0
+	noteLeading    = 12.0
+	headSize       = 9.0
+	titleSize      = 18.0
+	titleLeading   = 24.0
+	firstIndent    = 18.0
+	quoteIndent    = 18.0
+	// minLines of a paragraph stay together at the top or bottom of a page,
+	// so there are no widows or orphans
+	minLines = 2
+	// printDPI is the resolution pictures are embedded at
+	printDPI = 300
+)
+
+// alignment of the lines of a block
+type alignment int
+
+const (
+	justify alignment = iota
+	left
+	center
+)
+
+// span is text in one style
+type span struct {
+	text string
+	st   style
+}
+
+// block is a paragraph, a picture or vertical space
+type block struct {
+	spans         []span
+	size, leading float64
+	align         alignment
+	// left indents every line and indent the first one further
+	left, indent float64
+	// marker hangs in the left indent of the first line
+	marker string
+	// keep holds the block on the page of the one that follows it
+	keep    bool
+	space   float64
+	picture *picture
+}
+
+// picture is an image registered with the document and the size it is
+// printed at
+type picture struct {
+	name          string
+	width, height float64
+}
+
+// fragment is part of a word in one style
+type fragment struct {
+	text  string
+	st    style
+	width float64
+}
+
+// word is text between spaces. space is the width of the space after it.
+type word struct {
+	fragments []fragment
+	width     float64
+	space     float64
+	brk       bool
+}
+
+// line is a line of a paragraph. width is its natural width with single
+// spaces between the words.
+type line struct {
+	words  []word
+	width  float64
+	loose  bool
+	indent float64
+}
+
+// item is what a page is filled with: a line, a picture or space.
+// Lines know their place in the paragraph so page breaks can keep them
+// together.
+type item struct {
+	height       float64
+	block        *block
+	line         *line
+	index, count int
+}
+
+func (it item) spacer() bool {
+	return it.line == nil && it.block.picture == nil
+}
+
+// typesetter places a book's blocks on pages
+type typesetter struct {
+	pdf    *fpdf.Fpdf
+	book   *Book
+	layout Layout
+	// textWidth and textHeight are the size of the text block in points
+	textWidth, textHeight float64
+	y                     float64
+	// empty is set until something is placed on the page
+	empty bool
+	// runningHead is the title of the chapter being set and bookHead the
+	// title of the book or part it is in
+	runningHead, bookHead string
+	pictures              map[string]*picture
+	// starts are the pages the chapters and parts start on, in the order
+	// the contents list them
+	starts []int
+}
+
+func newTypesetter(pdf *fpdf.Fpdf, book *Book, layout Layout) *typesetter {
+	return &typesetter{
+		pdf:        pdf,
+		book:       book,
+		layout:     layout,
+		textWidth:  (layout.Trim.Width - layout.Inside - layout.Gutter - layout.Outside) * inch,
+		textHeight: (layout.Trim.Height - layout.Top - layout.Bottom) * inch,
+		bookHead:   book.Title,
+		pictures:   map[string]*picture{},
+	}
+}
+
+// left is the left edge of the text block, which sits at the inside
+// margin on right-hand pages
+func (t *typesetter) left() float64 {
+	if t.pdf.PageNo()%2 == 1 {
+		return (t.layout.Inside + t.layout.Gutter) * inch
+	}
+	return t.layout.Outside * inch
+}
+
+func (t *typesetter) top() float64 {
+	return t.layout.Top * inch
+}
+
+func (t *typesetter) bottom() float64 {
+	return t.top() + t.textHeight
+}
+
+// titlePage sets the title, subtitle and author, with the back of the
+// page left blank
+func (t *typesetter) titlePage() {
+	t.pdf.AddPage()
+	t.y = t.top() + t.textHeight/4
+	t.place(t.items([]block{
+		{spans: []span{{text: t.book.Title, st: style{bold: true}}}, size: 24, leading: 30, align: center},
+		{space: bodyLeading},
+		{spans: []span{{text: t.book.Subtitle, st: style{italic: true}}}, size: 14, leading: 18, align: center},
+	}))
+	t.y = t.top() + t.textHeight*2/3
+	t.place(t.items([]block{{spans: []span{{text: t.book.Author}}, size: 14, leading: 18, align: center}}))
+}
+
+// contents lists the chapters, the parts with their chapters nested under
+// them and the back matter, from a new right-hand page. Each entry shows
+// the page it starts on, from starts; entries past its end go without.
+func (t *typesetter) contents(starts []int) {
+	type entry struct {
+		title        string
+		part, nested bool
+	}
+	var entries []entry
+	for _, c := range t.book.Chapters {
+		entries = append(entries, entry{title: c.Title})
+	}
+	for _, p := range t.book.Parts {
+		entries = append(entries, entry{title: p.Title, part: true})
+		for _, c := range p.Chapters {
+			entries = append(entries, entry{title: c.Title, nested: true})
+		}
+	}
+	for _, c := range t.book.BackMatter {
+		entries = append(entries, entry{title: c.Title})
+	}
+
+	if t.pdf.PageNo()%2 == 1 {
+		t.pdf.AddPage()
+	}
+	t.runningHead = "Contents"
+	t.newPage(false)
+	t.y += t.textHeight / 8
+	t.place(t.items([]block{
+		{spans: []span{{text: "Contents", st: style{bold: true}}}, size: titleSize, leading: titleLeading, align: center},
+		{space: 2 * bodyLeading},
+	}))
+
+	size, leading, indent := bodySize, bodyLeading, quoteIndent
+	if scale := t.layout.Scale; scale > 0 {
+		size, leading, indent = size*scale, leading*scale, indent*scale
+	}
+	for i, e := range entries {
+		if t.y+leading > t.bottom()+0.01 {
+			t.newPage(true)
+		}
+		x := t.left()
+		if e.nested {
+			x += indent
+		}
+		baseline := t.y + (leading+size)/2 - size*0.15
+		setFont(t.pdf, style{}, size)
+		folio := ""
+		if i < len(starts) {
+			folio = fmt.Sprint(starts[i])
+		}
+		folioWidth := t.pdf.GetStringWidth(folio)
+		t.pdf.Text(t.left()+t.textWidth-folioWidth, baseline, folio)
+		setFont(t.pdf, style{bold: e.part}, size)
+		t.pdf.Text(x, baseline, t.clip(e.title, t.left()+t.textWidth-folioWidth-size-x))
+		t.y += leading
+	}
+}
+
+// part sets the title page of a part on a new right-hand page, with the
+// back of the page left blank. The part's title heads its left-hand pages.
+func (t *typesetter) part(p Part) {
+	if t.pdf.PageNo()%2 == 1 {
+		t.pdf.AddPage()
+	}
+	t.pdf.AddPage()
+	t.starts = append(t.starts, t.pdf.PageNo())
+	t.bookHead = p.Title
+	t.y = t.top() + t.textHeight/4
+	t.place(t.items([]block{
+		{spans: []span{{text: p.Title, st: style{bold: true}}}, size: 24, leading: 30, align: center},
+		{space: bodyLeading},
+		{spans: []span{{text: p.Subtitle, st: style{italic: true}}}, size: 14, leading: 18, align: center},
+	}))
+	t.y = t.top() + t.textHeight*2/3
+	t.place(t.items([]block{{spans: []span{{text: p.Author}}, size: 14, leading: 18, align: center}}))
+}
+
+// chapter sets a chapter from a new right-hand page. The opening page
+// drops the chapter's header image and title down the page and goes
+// without a running head.
+func (t *typesetter) chapter(c Chapter) {
+	if t.pdf.PageNo()%2 == 1 {
+		t.pdf.AddPage()
+	}
+	t.runningHead = c.Title
+	t.newPage(false)
+	t.starts = append(t.starts, t.pdf.PageNo())
+
+	var blocks []block
+	drop := t.textHeight / 4
+	// parts can number their chapters alike, so headers go by where they
+	// are set
+	if pic := t.picture(fmt.Sprintf("header:%d", t.pdf.PageNo()), c.Header, t.textWidth, math.Min(2*inch, t.textHeight/4)); pic != nil {
+		drop = t.textHeight / 8
+		blocks = append(blocks, block{picture: pic, align: center, keep: true}, block{space: bodyLeading, keep: true})
+	}
+	t.y += drop
+	label := fmt.Sprintf("Chapter %d", c.Number)
+	if c.Number > 0 && !strings.EqualFold(strings.TrimSpace(c.Title), label) {
+		blocks = append(blocks, block{spans: []span{{text: strings.ToUpper(label)}}, size: headSize, leading: titleLeading, align: center, keep: true})
+	}
+	blocks = append(blocks,
+		block{spans: []span{{text: c.Title, st: style{bold: true}}}, size: titleSize, leading: titleLeading, align: center, keep: true},
+		block{space: 2 * bodyLeading, keep: true},
+	)
+	b := &builder{t: t}
+	t.flow(t.items(append(blocks, b.chapter(c.HTML)...)))
+}
+
+// newPage starts a page with its folio and, unless it opens a chapter,
+// a running head: the book's or part's title on left-hand pages and the
+// chapter's on right-hand ones
+func (t *typesetter) newPage(head bool) {
+	t.pdf.AddPage()
+	t.y, t.empty = t.top(), true
+	setFont(t.pdf, style{}, headSize)
+	folio := fmt.Sprint(t.pdf.PageNo())
+	t.pdf.Text(t.left()+(t.textWidth-t.pdf.GetStringWidth(folio))/2, t.bottom()+t.layout.Bottom*inch/2, folio)
+	if !head {
+		return
+	}
+	text := t.bookHead
+	if t.pdf.PageNo()%2 == 1 {
+		text = t.runningHead
+	}
+	setFont(t.pdf, style{italic: true}, headSize)
+	text = t.clip(text, t.textWidth)
+	t.pdf.Text(t.left()+(t.textWidth-t.pdf.GetStringWidth(text))/2, t.top()*0.6, text)
+}
+
+// clip shortens text in the current font to fit the width
+func (t *typesetter) clip(text string, width float64) string {
+	if t.pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for text != "" && t.pdf.GetStringWidth(text+"…") > width {
+		_, size := utf8.DecodeLastRuneInString(text)
+		text = text[:len(text)-size]
+	}
+	return strings.TrimSpace(text) + "…"
+}
+
+// flow places items on as many pages as they need. A page ends early
+// rather than leave a single line of a paragraph on either side of the
+// break or a heading at its foot.
+func (t *typesetter) flow(items []item) {
+	for i := 0; i < len(items); {
+		if t.empty && items[i].spacer() {
+			i++
+			continue
+		}
+		n := t.fit(items[i:])
+		if i+n == len(items) {
+			t.place(items[i:])
+			return
+		}
+		brk := pageBreak(items, i, i+n)
+		if brk == i {
+			if !t.empty {
+				t.newPage(true)
+				continue
+			}
+			// nothing fits on a page of its own, so the rules give way
+			brk = i + n
+			if n == 0 {
+				brk++
+			}
+		}
+		t.place(items[i:brk])
+		t.newPage(true)
+		i = brk
+	}
+}
+
+// fit counts the items that fit on the rest of the page
+func (t *typesetter) fit(items []item) int {
+	y := t.y
+	for i, it := range items {
+		if y+it.height > t.bottom()+0.01 {
+			return i
+		}
+		y += it.height
+	}
+	return len(items)
+}
+
+// pageBreak moves a break before items[brk] back so at least minLines of
+// a paragraph end up on each page and kept items stay with the next
+func pageBreak(items []item, start, brk int) int {
+	if it := items[brk]; it.line != nil && it.index > 0 {
+		if after := it.count - it.index; after < minLines {
+			brk -= minLines - after
+		}
+		if before := items[brk].index; before < minLines {
+			brk -= before
+		}
+	}
+	for brk > start && (items[brk-1].block.keep || items[brk-1].spacer()) {
+		brk--
+	}
+	if brk < start {
+		return start
+	}
+	return brk
+}
+
+// place draws items from the top of the page down
+func (t *typesetter) place(items []item) {
+	for _, it := range items {
+		switch {
+		case it.line != nil:
+			t.drawLine(it)
+			t.empty = false
+		case it.block.picture != nil:
+			pic := it.block.picture
+			x := t.left() + it.block.left + (t.textWidth-it.block.left-pic.width)/2
+			t.pdf.ImageOptions(pic.name, x, t.y, pic.width, pic.height, false, fpdf.ImageOptions{}, 0, "")
+			t.empty = false
+		}
+		t.y += it.height
+	}
+}
+
+// items breaks blocks into lines, scaled to the layout's text size
+func (t *typesetter) items(blocks []block) []item {
+	var items []item
+	for i := range blocks {
+		b := &blocks[i]
+		if scale := t.layout.Scale; scale > 0 {
+			b.size, b.leading, b.space, b.indent = b.size*scale, b.leading*scale, b.space*scale, b.indent*scale
+		}
+		switch {
+		case b.picture != nil:
+			items = append(items, item{height: b.picture.height, block: b})
+		case len(b.spans) == 0 && b.marker == "":
+			items = append(items, item{height: b.space, block: b})
+		default:
+			lines := t.breakLines(b)
+			for j := range lines {
+				items = append(items, item{height: b.leading, block: b, line: &lines[j], index: j, count: len(lines)})
+			}
+		}
+	}
+	return items
+}
+
+// breakLines fills lines with as many words as fit
+func (t *typesetter) breakLines(b *block) []line {
+	width := t.textWidth - b.left
+	var lines []line
+	current := line{indent: b.indent}
+	flush := func(loose bool) {
+		current.loose = loose
+		lines = append(lines, current)
+		current = line{}
+	}
+	for _, w := range t.words(b) {
+		room := width - current.indent
+		if len(current.words) > 0 && current.width+current.words[len(current.words)-1].space+w.width > room {
+			flush(false)
+			room = width
+		}
+		for w.width > room && len(w.fragments) > 0 {
+			head, rest := t.splitWord(w, room, b.size)
+			if len(head.fragments) == 0 {
+				break
+			}
+			current.words, current.width = append(current.words, head), head.width
+			flush(true)
+			room, w = width, rest
+		}
+		if len(current.words) > 0 {
+			current.width += current.words[len(current.words)-1].space
+		}
+		current.words = append(current.words, w)
+		current.width += w.width
+		if w.brk {
+			flush(true)
+		}
+	}
+	if len(current.words) > 0 || len(lines) == 0 {
+		flush(true)
+	}
+	lines[len(lines)-1].loose = true
+	return lines
+}
+
+// words splits the text of a block at spaces and measures it
+func (t *typesetter) words(b *block) []word {
+	var words []word
+	var current word
+	var text strings.Builder
+	var st style
+	endFragment := func() {
+		if text.Len() > 0 {
+			current.fragments = append(current.fragments, fragment{text: text.String(), st: st})
+			text.Reset()
+		}
+	}
+	for _, s := range b.spans {
+		if s.st != st {
+			endFragment()
+			st = s.st
+		}
+		for _, r := range s.text {
+			switch r {
+			case ' ':
+				endFragment()
+				if len(current.fragments) > 0 {
+					words, current = append(words, current), word{}
+				}
+				if last := len(words) - 1; last >= 0 && words[last].space == 0 && !words[last].brk {
+					setFont(t.pdf, s.st, b.size)
+					words[last].space = t.pdf.GetStringWidth(" ")
+				}
+			case lineBreak:
+				endFragment()
+				switch last := len(words) - 1; {
+				case len(current.fragments) > 0:
+					current.brk = true
+					words, current = append(words, current), word{}
+				case last >= 0 && !words[last].brk:
+					words[last].brk = true
+				default:
+					// a break after a break leaves an empty line
+					words = append(words, word{brk: true})
+				}
+			default:
+				text.WriteRune(r)
+			}
+		}
+	}
+	endFragment()
+	if len(current.fragments) > 0 {
+		words = append(words, current)
+	}
+
+	for i := range words {
+		for j := range words[i].fragments {
+			f := &words[i].fragments[j]
+			setFont(t.pdf, f.st, b.size)
+			f.width = t.pdf.GetStringWidth(f.text)
+			words[i].width += f.width
+		}
+	}
+	return words
+}
+
+// splitWord breaks a word too long for a line after as many characters
+// as fit in the width
+func (t *typesetter) splitWord(w word, width, size float64) (word, word) {
+	var head word
+	for i, f := range w.fragments {
+		setFont(t.pdf, f.st, size)
+		if head.width+f.width <= width {
+			head.fragments = append(head.fragments, f)
+			head.width += f.width
+			continue
+		}
+		cut := 0
+		for j := range f.text {
+			if j > 0 && head.width+t.pdf.GetStringWidth(f.text[:j]) > width {
+				break
+			}
+			cut = j
+		}
+		if cut == 0 && len(head.fragments) == 0 {
+			_, cut = utf8.DecodeRuneInString(f.text)
+		}
+		rest := word{space: w.space, brk: w.brk}
+		if cut > 0 {
+			part := fragment{text: f.text[:cut], st: f.st, width: t.pdf.GetStringWidth(f.text[:cut])}
+			head.fragments = append(head.fragments, part)
+			head.width += part.width
+		}
+		if cut < len(f.text) {
+			part := fragment{text: f.text[cut:], st: f.st, width: t.pdf.GetStringWidth(f.text[cut:])}
+			rest.fragments = append(rest.fragments, part)
+			rest.width += part.width
+		}
+		for _, f := range w.fragments[i+1:] {
+			rest.fragments = append(rest.fragments, f)
+			rest.width += f.width
+		}
+		return head, rest
+	}
+	return head, word{}
+}
+
+// drawLine sets a line at the current position. Justified lines stretch
+// their spaces to the full width, except the last of a paragraph.
+func (t *typesetter) drawLine(it item) {
+	b, l := it.block, it.line
+	x := t.left() + b.left + l.indent
+	room := t.textWidth - b.left - l.indent
+	baseline := t.y + (b.leading+b.size)/2 - b.size*0.15
+	if it.index == 0 && b.marker != "" {
+		setFont(t.pdf, style{}, b.size)
+		t.pdf.Text(x-t.pdf.GetStringWidth(b.marker)-b.size/2, baseline, b.marker)
+	}
+	extra := 0.0
+	switch {
+	case b.align == center:
+		x += (room - l.width) / 2
+	case b.align == justify && !l.loose && len(l.words) > 1:
+		extra = (room - l.width) / float64(len(l.words)-1)
+	}
+	for _, w := range l.words {
+		for _, f := range w.fragments {
+			setFont(t.pdf, f.st, b.size)
+			y := baseline
+			if f.st.sup {
+				y -= b.size * supRise
+			}
+			t.pdf.Text(x, y, f.text)
+			x += f.width
+		}
+		x += w.space + extra
+	}
+}
+
+// picture registers an image to print no larger than maxWidth x maxHeight
+// points. Images are printed at 150 dpi or less and embedded at printDPI
+// at most. It returns nil when there is no image or it can't be decoded.
+func (t *typesetter) picture(key string, data []byte, maxWidth, maxHeight float64) *picture {
+	if len(data) == 0 {
+		return nil
+	}
+	if pic, ok := t.pictures[key]; ok {
+		return pic
+	}
+	img, format, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return nil
+	}
+	width, height := float64(bounds.Dx())*inch/150, float64(bounds.Dy())*inch/150
+	scale := math.Min(1, math.Min(maxWidth/width, maxHeight/height))
+	width, height = width*scale, height*scale
+
+	pixelsW, pixelsH := imaging.FitSize(bounds, int(math.Min(width/inch*printDPI, imaging.MaxDimension)), int(math.Min(height/inch*printDPI, imaging.MaxDimension)))
+	var buf bytes.Buffer
+	kind := "JPG"
+	if format == "jpeg" && pixelsW == bounds.Dx() && pixelsH == bounds.Dy() {
+		buf.Write(data)
+	} else {
+		if format != "jpeg" {
+			format, kind = "png", "PNG"
+		}
+		// resizing also leaves 8-bit colour, which is all PDF images take
+		if _, err := imaging.Encode(&buf, imaging.Resize(img, pixelsW, pixelsH), format); err != nil {
+			return nil
+		}
+	}
+	pic := &picture{name: key, width: width, height: height}
+	t.pdf.RegisterImageOptionsReader(pic.name, fpdf.ImageOptions{ImageType: kind}, &buf)
+	t.pictures[key] = pic
+	return pic
+}
@@ -0,0 +1,170 @@
+// Package typeset lays out books as print-ready paperback interiors. It is
+// pure Go: the PDF is written with embedded Go fonts and needs no external
+// programs.
+package typeset
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// ContentType is the media type of the interiors
+const ContentType = "application/pdf"
+
+// Trim is the size of a printed page in inches
+type Trim struct {
+	Width, Height float64
+}
+
+// TrimSizes are the paperback sizes a book can be laid out for, by name
+var TrimSizes = map[string]Trim{
+	"5x8":     {5, 8},
+	"5.5x8.5": {5.5, 8.5},
+	"6x9":     {6, 9},
+}
+
+// DefaultTrim is the trim size used when none is chosen
+const DefaultTrim = "6x9"
+
+// Layout is the page geometry of an interior in inches
+type Layout struct {
+	Trim        Trim
+	Top, Bottom float64
+	// Inside is the margin at the spine, Outside the one at the fore edge
+	Inside, Outside float64
+	// Gutter widens the inside margin for the part of the page lost in
+	// the binding
+	Gutter float64
+	// Scale enlarges the text and the space around it, as large-print
+	// editions do; 0 sets it at its usual size
+	Scale float64
+}
+
+// minMargin leaves room for the running heads, folios and trimming
+const minMargin = 0.25
+
+// DefaultLayout returns the usual margins for a trim size
+func DefaultLayout(trim Trim) Layout {
+	return Layout{Trim: trim, Top: 0.75, Bottom: 0.75, Inside: 0.5, Outside: 0.5, Gutter: 0.25}
+}
+
+// Validate checks that the margins are wide enough to print and leave room
+// for text
+func (l Layout) Validate() error {
+	for _, margin := range []float64{l.Top, l.Bottom, l.Inside, l.Outside} {
+		if margin < minMargin {
+			return fmt.Errorf("margins must be at least %g inches", minMargin)
+		}
+	}
+	if l.Gutter < 0 {
+		return errors.New("gutter must not be negative")
+	}
+	if l.Scale != 0 && (l.Scale < 1 || l.Scale > 2) {
+		return errors.New("text scale must be between 1 and 2")
+	}
+	if l.Trim.Width-l.Inside-l.Outside-l.Gutter < 2 || l.Trim.Height-l.Top-l.Bottom < 3 {
+		return errors.New("margins leave too little room for text")
+	}
+	return nil
+}
+
+// Book is what goes into an interior. Parts, when there are any, follow
+// the chapters and are listed with them on a contents page. Back matter
+// comes last.
+type Book struct {
+	Title, Subtitle, Author string
+	Chapters                []Chapter
+	Parts                   []Part
+	BackMatter              []Chapter
+	// Image returns the content of an image shown in chapter text by its
+	// src, or nil to leave the image out
+	Image func(src string) []byte
+}
+
+// Chapter is a chapter of a book in the order it is printed
+type Chapter struct {
+	Number int
+	Title  string
+	HTML   string
+	// Header is the image printed above the chapter title, if any
+	Header []byte
+}
+
+// Part is a book of an omnibus, opening with its own title page
+type Part struct {
+	Title, Subtitle, Author string
+	Chapters                []Chapter
+}
+
+// Write lays out the book and writes the PDF. The title page comes first
+// and every part and chapter starts on a right-hand page. Images that
+// can't be decoded are left out.
+func Write(w io.Writer, book *Book, layout Layout) error {
+	pdf, err := typeset(book, layout)
+	if err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// Pages counts the pages of the book's interior
+func Pages(book *Book, layout Layout) (int, error) {
+	pdf, err := typeset(book, layout)
+	if err != nil {
+		return 0, err
+	}
+	return pdf.PageNo(), pdf.Error()
+}
+
+// typeset lays out the book. Books with parts are laid out twice, the
+// first time to find the pages the contents point to.
+func typeset(book *Book, layout Layout) (*fpdf.Fpdf, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	if len(book.Parts) == 0 {
+		return typesetPages(book, layout, nil).pdf, nil
+	}
+	first := typesetPages(book, layout, nil)
+	if err := first.pdf.Error(); err != nil {
+		return nil, err
+	}
+	return typesetPages(book, layout, first.starts).pdf, nil
+}
+
+// typesetPages lays out the book once, with the contents pointing at the
+// given pages
+func typesetPages(book *Book, layout Layout, starts []int) *typesetter {
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "pt",
+		Size:    fpdf.SizeType{Wd: layout.Trim.Width * inch, Ht: layout.Trim.Height * inch},
+	})
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetTitle(book.Title, true)
+	pdf.SetAuthor(book.Author, true)
+	pdf.SetCreator("OnWord", true)
+	addFonts(pdf)
+
+	t := newTypesetter(pdf, book, layout)
+	t.titlePage()
+	if len(book.Parts) > 0 {
+		t.contents(starts)
+	}
+	for _, chapter := range book.Chapters {
+		t.chapter(chapter)
+	}
+	for _, part := range book.Parts {
+		t.part(part)
+		for _, chapter := range part.Chapters {
+			t.chapter(chapter)
+		}
+	}
+	t.bookHead = book.Title
+	for _, chapter := range book.BackMatter {
+		t.chapter(chapter)
+	}
+	return t
+}